@@ -5,8 +5,14 @@ import (
 	"ditto/config"
 	"ditto/internal/app"
 	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
 	"ditto/internal/http"
 	"ditto/internal/influxdb"
+	"ditto/internal/job"
+	"ditto/internal/oidc"
+	"ditto/internal/rbac"
+	"ditto/internal/repository"
+	"ditto/internal/service"
 	"ditto/pkg/database"
 	"ditto/pkg/logger"
 	"log"
@@ -23,6 +29,12 @@ func main() {
 		database.Module,
 		app.Module,
 		http.Module,
+		repository.Module,
+		job.Module,
+		eventbus.Module,
+		oidc.Module,
+		rbac.Module,
+		service.Module,
 		logger.Module,
 		fx.Provide(
 			// Initialize InfluxDB client
@@ -30,8 +42,12 @@ func main() {
 				return influxdb.NewClient(cfg.InfluxDB.URL, cfg.InfluxDB.Token, cfg.InfluxDB.Org, cfg.InfluxDB.Bucket)
 			},
 			// Initialize Ditto client
-			func(cfg *config.Config) *ditto.Client {
-				return ditto.NewClient(cfg.Ditto.WSURL, cfg.Ditto.Username, cfg.Ditto.Password)
+			func(cfg *config.Config) (*ditto.Client, error) {
+				client := ditto.NewClient(cfg.Ditto.WSURL, cfg.Ditto.Username, cfg.Ditto.Password)
+				if err := client.ForwardIdentity(cfg.Ditto.IdentityMode, cfg.Ditto.PreAuthIssuer); err != nil {
+					return nil, err
+				}
+				return client, nil
 			},
 			// Initialize Ditto service
 			ditto.NewService,
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/viper"
@@ -11,12 +12,25 @@ import (
 )
 
 type Config struct {
-	DB       DBConfig
-	JWT      JWTConfig
-	Server   ServerCfg
-	InfluxDB InfluxDBConfig
-	Proxy    ProxyConfig
-	Ditto    DittoConfig
+	DB           DBConfig
+	JWT          JWTConfig
+	Auth         AuthConfig
+	OIDC         OIDCConfig
+	RBAC         RBACConfig
+	Server       ServerCfg
+	InfluxDB     InfluxDBConfig
+	Proxy        ProxyConfig
+	Ditto        DittoConfig
+	Job          JobConfig
+	Command      CommandConfig
+	Scheduler    SchedulerConfig
+	Reconcile    ReconcileConfig
+	Connectivity ConnectivityConfig
+	Rules        RulesConfig
+	Webhook      WebhookConfig
+	Email        EmailConfig
+	Stream       StreamConfig
+	EventBus     EventBusConfig
 }
 
 type DittoConfig struct {
@@ -24,6 +38,9 @@ type DittoConfig struct {
 	Username string `envconfig:"DITTO_USERNAME"`
 	Password string `envconfig:"DITTO_PASSWORD"`
 	WSURL    string `envconfig:"DITTO_WS_URL"`
+	// IdentityMode selects how requests made for a user authenticate to Ditto: service, pre-authenticated or jwt
+	IdentityMode  string `envconfig:"DITTO_IDENTITY_MODE" default:"service"`
+	PreAuthIssuer string `envconfig:"DITTO_PRE_AUTH_ISSUER" default:"nginx"`
 }
 
 type DBConfig struct {
@@ -45,12 +62,51 @@ type JWTConfig struct {
 	RefreshExpirationTime string `envconfig:"JWT_REFRESH_EXPIRATION_TIME"`
 }
 
+type AuthConfig struct {
+	// AdminUsername and AdminPassword create the first user at startup, when no user has that name
+	AdminUsername string `envconfig:"AUTH_ADMIN_USERNAME"`
+	AdminPassword string `envconfig:"AUTH_ADMIN_PASSWORD"`
+	AdminEmail    string `envconfig:"AUTH_ADMIN_EMAIL"`
+	AdminRoles    string `envconfig:"AUTH_ADMIN_ROLES" default:"admin"`
+	BcryptCost    int    `envconfig:"AUTH_BCRYPT_COST" default:"12"`
+}
+
+type OIDCConfig struct {
+	// Issuer enables tokens of an OpenID provider, its keys are found through the discovery document
+	Issuer string `envconfig:"OIDC_ISSUER"`
+	// JWKSURL skips discovery and loads the keys from this URL
+	JWKSURL string `envconfig:"OIDC_JWKS_URL"`
+	// Audiences are the accepted aud values, a token must name one of them. Required with Issuer.
+	Audiences  string        `envconfig:"OIDC_AUDIENCE"`
+	Algorithms string        `envconfig:"OIDC_ALGORITHMS" default:"RS256,ES256"`
+	Leeway     time.Duration `envconfig:"OIDC_LEEWAY" default:"30s"`
+	// JWKSRefresh is how long keys are cached, JWKSMinRefresh the least time between fetches for an unknown kid
+	JWKSRefresh    time.Duration `envconfig:"OIDC_JWKS_REFRESH" default:"1h"`
+	JWKSMinRefresh time.Duration `envconfig:"OIDC_JWKS_MIN_REFRESH" default:"1m"`
+	HTTPTimeout    time.Duration `envconfig:"OIDC_HTTP_TIMEOUT" default:"10s"`
+	// Claims mapped to the user, nested claims are written as dotted paths such as realm_access.roles
+	UserIDClaim   string `envconfig:"OIDC_CLAIM_USER_ID" default:"sub"`
+	UsernameClaim string `envconfig:"OIDC_CLAIM_USERNAME" default:"preferred_username"`
+	EmailClaim    string `envconfig:"OIDC_CLAIM_EMAIL" default:"email"`
+	RolesClaim    string `envconfig:"OIDC_CLAIM_ROLES" default:"roles"`
+}
+
+type RBACConfig struct {
+	// Roles defines the roles as comma separated role=permission permission entries, * grants every permission
+	Roles string `envconfig:"RBAC_ROLES" default:"viewer=devices:read,operator=devices:read devices:write commands:send,admin=*"`
+	// BasicAuthRoles are the roles of the PROXY_AUTH_USERNAME basic auth user
+	BasicAuthRoles string `envconfig:"RBAC_BASIC_AUTH_ROLES" default:"admin"`
+}
+
 type ServerCfg struct {
 	ServerURL  string `envconfig:"SERVER_URL" default:"localhost"`
 	Port       string `envconfig:"PORT" default:"3001"`
 	Env        string `envconfig:"ENVIRONMENT" default:"development"`
 	GINMode    string `envconfig:"GIN_MODE" default:"debug"`
 	Production bool   `envconfig:"PRODUCTION" default:"false"`
+	// WSAllowedOrigins are the comma separated origins browsers may open WebSockets from,
+	// besides the origin of the server itself. * allows every origin.
+	WSAllowedOrigins string `envconfig:"WS_ALLOWED_ORIGINS"`
 }
 
 type InfluxDBConfig struct {
@@ -65,6 +121,116 @@ type ProxyConfig struct {
 	AuthPassword string `envconfig:"PROXY_AUTH_PASSWORD"`
 	TargetURL    string `envconfig:"PROXY_TARGET_URL"`
 	WSURL        string `envconfig:"PROXY_WS_URL"`
+	// Routes map /api paths to the Ditto API, see handler.ParseProxyRules
+	Routes string `envconfig:"PROXY_ROUTES" default:"POST /things/:thingId/commands/:subject -> /things/:thingId/inbox/messages/:subject, POST /things/:thingId/features/:featureId/commands/:subject -> /things/:thingId/features/:featureId/inbox/messages/:subject, /things/*path -> /things/*path, /policies/*path -> /policies/*path"`
+	// Timeout bounds the wait for the response headers of Ditto, bodies are streamed without limit
+	Timeout         time.Duration `envconfig:"PROXY_TIMEOUT" default:"30s"`
+	DialTimeout     time.Duration `envconfig:"PROXY_DIAL_TIMEOUT" default:"5s"`
+	IdleConnTimeout time.Duration `envconfig:"PROXY_IDLE_CONN_TIMEOUT" default:"90s"`
+	MaxIdleConns    int           `envconfig:"PROXY_MAX_IDLE_CONNS" default:"64"`
+	// RequestHeaders and ResponseHeaders are the headers passed through the proxy, all others are dropped
+	RequestHeaders  string `envconfig:"PROXY_REQUEST_HEADERS" default:"Accept,Accept-Language,Content-Type,If-Match,If-None-Match,correlation-id,response-required,timeout,condition,live-channel-condition,requested-acks,put-metadata,get-metadata,delete-metadata"`
+	ResponseHeaders string `envconfig:"PROXY_RESPONSE_HEADERS" default:"Content-Type,Content-Length,ETag,Location,Cache-Control,Last-Modified,correlation-id"`
+	// WSStreams are the START-SEND-* subscriptions WebSocket proxy clients may request
+	WSStreams string `envconfig:"PROXY_WS_STREAMS" default:"EVENTS,LIVE-EVENTS"`
+	// WSNamespaces restrict WebSocket proxy clients to these namespaces, all when empty
+	WSNamespaces string `envconfig:"PROXY_WS_NAMESPACES"`
+}
+
+type JobConfig struct {
+	Workers      int           `envconfig:"JOB_WORKERS" default:"4"`
+	PollInterval time.Duration `envconfig:"JOB_POLL_INTERVAL" default:"2s"`
+	MaxAttempts  int           `envconfig:"JOB_MAX_ATTEMPTS" default:"3"`
+	RetryBackoff time.Duration `envconfig:"JOB_RETRY_BACKOFF" default:"10s"`
+	LeaseTimeout time.Duration `envconfig:"JOB_LEASE_TIMEOUT" default:"30s"`
+}
+
+type CommandConfig struct {
+	DefaultTimeout time.Duration `envconfig:"COMMAND_DEFAULT_TIMEOUT" default:"10s"`
+	MaxTimeout     time.Duration `envconfig:"COMMAND_MAX_TIMEOUT" default:"60s"`
+}
+
+type SchedulerConfig struct {
+	PollInterval time.Duration `envconfig:"SCHEDULER_POLL_INTERVAL" default:"15s"`
+	Concurrency  int           `envconfig:"SCHEDULER_CONCURRENCY" default:"8"`
+	RetryBackoff time.Duration `envconfig:"SCHEDULER_RETRY_BACKOFF" default:"10s"`
+}
+
+type ReconcileConfig struct {
+	Enabled     bool          `envconfig:"RECONCILE_ENABLED" default:"true"`
+	GracePeriod time.Duration `envconfig:"RECONCILE_GRACE_PERIOD" default:"2m"`
+	Interval    time.Duration `envconfig:"RECONCILE_INTERVAL" default:"30s"`
+	MaxResends  int           `envconfig:"RECONCILE_MAX_RESENDS" default:"3"`
+	Subject     string        `envconfig:"RECONCILE_COMMAND_SUBJECT" default:"applyDesired"`
+}
+
+type ConnectivityConfig struct {
+	Enabled        bool          `envconfig:"CONNECTIVITY_ENABLED" default:"true"`
+	OfflineTimeout time.Duration `envconfig:"CONNECTIVITY_OFFLINE_TIMEOUT" default:"5m"`
+	// DefinitionTimeouts overrides the offline timeout per thing definition, as
+	// comma separated definition=duration pairs
+	DefinitionTimeouts  string        `envconfig:"CONNECTIVITY_DEFINITION_TIMEOUTS"`
+	UseConnectionStatus bool          `envconfig:"CONNECTIVITY_USE_CONNECTION_STATUS" default:"false"`
+	FlushInterval       time.Duration `envconfig:"CONNECTIVITY_FLUSH_INTERVAL" default:"5s"`
+	SweepInterval       time.Duration `envconfig:"CONNECTIVITY_SWEEP_INTERVAL" default:"30s"`
+}
+
+type RulesConfig struct {
+	Enabled bool `envconfig:"RULES_ENABLED" default:"true"`
+	// QueueSize bounds the measurements waiting for evaluation, newer ones are dropped when full
+	QueueSize      int           `envconfig:"RULES_QUEUE_SIZE" default:"10000"`
+	ReloadInterval time.Duration `envconfig:"RULES_RELOAD_INTERVAL" default:"30s"`
+	CheckInterval  time.Duration `envconfig:"RULES_CHECK_INTERVAL" default:"10s"`
+}
+
+type WebhookConfig struct {
+	Enabled     bool          `envconfig:"WEBHOOK_ENABLED" default:"true"`
+	Timeout     time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+	MaxAttempts int           `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
+	// RetryBackoff is the delay before the first retry, doubled on every further one up to MaxBackoff
+	RetryBackoff time.Duration `envconfig:"WEBHOOK_RETRY_BACKOFF" default:"10s"`
+	MaxBackoff   time.Duration `envconfig:"WEBHOOK_MAX_BACKOFF" default:"1h"`
+	PollInterval time.Duration `envconfig:"WEBHOOK_POLL_INTERVAL" default:"2s"`
+	Concurrency  int           `envconfig:"WEBHOOK_CONCURRENCY" default:"4"`
+	QueueSize    int           `envconfig:"WEBHOOK_QUEUE_SIZE" default:"1000"`
+}
+
+type EmailConfig struct {
+	Enabled  bool   `envconfig:"EMAIL_ENABLED" default:"false"`
+	Host     string `envconfig:"SMTP_HOST"`
+	Port     int    `envconfig:"SMTP_PORT" default:"587"`
+	Username string `envconfig:"SMTP_USERNAME"`
+	Password string `envconfig:"SMTP_PASSWORD"`
+	From     string `envconfig:"SMTP_FROM"`
+	// TLSMode is starttls, tls (implicit TLS, usually port 465) or none
+	TLSMode            string `envconfig:"SMTP_TLS_MODE" default:"starttls"`
+	InsecureSkipVerify bool   `envconfig:"SMTP_INSECURE_SKIP_VERIFY" default:"false"`
+	// Throttle is the shortest time between two emails about the same rule or event of a thing
+	Throttle     time.Duration `envconfig:"EMAIL_THROTTLE" default:"15m"`
+	MaxPerMinute int           `envconfig:"EMAIL_MAX_PER_MINUTE" default:"60"`
+	QueueSize    int           `envconfig:"EMAIL_QUEUE_SIZE" default:"1000"`
+	// OfflineRecipients are notified when a device goes offline, as a comma separated list
+	OfflineRecipients string `envconfig:"EMAIL_OFFLINE_RECIPIENTS"`
+	// Templates override the built-in Go templates
+	AlertSubject   string `envconfig:"EMAIL_ALERT_SUBJECT"`
+	AlertBody      string `envconfig:"EMAIL_ALERT_BODY"`
+	OfflineSubject string `envconfig:"EMAIL_OFFLINE_SUBJECT"`
+	OfflineBody    string `envconfig:"EMAIL_OFFLINE_BODY"`
+}
+
+type StreamConfig struct {
+	// ClientBuffer is the number of events queued per client, a client falling further behind is disconnected
+	ClientBuffer int           `envconfig:"STREAM_CLIENT_BUFFER" default:"256"`
+	MaxClients   int           `envconfig:"STREAM_MAX_CLIENTS" default:"1000"`
+	WriteTimeout time.Duration `envconfig:"STREAM_WRITE_TIMEOUT" default:"10s"`
+	PingInterval time.Duration `envconfig:"STREAM_PING_INTERVAL" default:"30s"`
+	// ReplaySize is the number of recent events kept to resume SSE streams from their Last-Event-ID
+	ReplaySize int `envconfig:"STREAM_REPLAY_SIZE" default:"1000"`
+}
+
+type EventBusConfig struct {
+	// QueueSize is the number of events queued per subscriber, events are dropped when it is full
+	QueueSize int `envconfig:"EVENT_BUS_QUEUE_SIZE" default:"1024"`
 }
 
 func NewConfig() (*Config, error) {
@@ -82,6 +248,15 @@ func NewConfig() (*Config, error) {
 	if err := envconfig.Process("", &cfg.JWT); err != nil {
 		log.Fatalf("Failed to process JWT config: %v", err)
 	}
+	if err := envconfig.Process("", &cfg.Auth); err != nil {
+		log.Fatalf("Failed to process Auth config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.OIDC); err != nil {
+		log.Fatalf("Failed to process OIDC config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.RBAC); err != nil {
+		log.Fatalf("Failed to process RBAC config: %v", err)
+	}
 	if err := envconfig.Process("", &cfg.Server); err != nil {
 		log.Fatalf("Failed to process Server config: %v", err)
 	}
@@ -91,6 +266,36 @@ func NewConfig() (*Config, error) {
 	if err := envconfig.Process("", &cfg.Proxy); err != nil {
 		log.Fatalf("Failed to process Proxy config: %v", err)
 	}
+	if err := envconfig.Process("", &cfg.Job); err != nil {
+		log.Fatalf("Failed to process Job config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Command); err != nil {
+		log.Fatalf("Failed to process Command config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Scheduler); err != nil {
+		log.Fatalf("Failed to process Scheduler config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Reconcile); err != nil {
+		log.Fatalf("Failed to process Reconcile config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Connectivity); err != nil {
+		log.Fatalf("Failed to process Connectivity config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Rules); err != nil {
+		log.Fatalf("Failed to process Rules config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Webhook); err != nil {
+		log.Fatalf("Failed to process Webhook config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Email); err != nil {
+		log.Fatalf("Failed to process Email config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.Stream); err != nil {
+		log.Fatalf("Failed to process Stream config: %v", err)
+	}
+	if err := envconfig.Process("", &cfg.EventBus); err != nil {
+		log.Fatalf("Failed to process EventBus config: %v", err)
+	}
 
 	return &cfg, nil
 }
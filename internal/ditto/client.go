@@ -2,37 +2,81 @@ package ditto
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const defaultHTTPTimeout = 30 * time.Second
+
 // Client represents a Ditto WebSocket client
 type Client struct {
-	conn     *websocket.Conn
-	host     string
-	username string
-	password string
+	conn        *websocket.Conn
+	host        string
+	username    string
+	password    string
+	credentials *Credentials
+	httpClient  *http.Client
 }
 
 // NewClient creates a new Ditto WebSocket client
 func NewClient(host, username, password string) *Client {
+	// Accept WebSocket URLs and convert them to their HTTP counterpart
+	switch {
+	case strings.HasPrefix(host, "ws://"):
+		host = "http://" + strings.TrimPrefix(host, "ws://")
+	case strings.HasPrefix(host, "wss://"):
+		host = "https://" + strings.TrimPrefix(host, "wss://")
+	}
+
 	// Ensure host is a valid URL
 	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
 		host = "http://" + host
 	}
 
+	// Strip API and WebSocket paths so host always points at the Ditto root
+	host = strings.TrimRight(host, "/")
+	host = strings.TrimSuffix(host, "/ws/2")
+	host = strings.TrimSuffix(host, "/api/2")
+
+	credentials, _ := NewCredentials(username, password, IdentityService, "")
 	return &Client{
-		host:     host,
-		username: username,
-		password: password,
+		host:        host,
+		username:    username,
+		password:    password,
+		credentials: credentials,
+		httpClient:  &http.Client{Timeout: defaultHTTPTimeout},
+	}
+}
+
+// ForwardIdentity makes the requests whose context carries a user identity on
+// behalf of that user, see NewCredentials
+func (c *Client) ForwardIdentity(mode, issuer string) error {
+	credentials, err := NewCredentials(c.username, c.password, mode, issuer)
+	if err != nil {
+		return err
 	}
+	c.credentials = credentials
+	return nil
+}
+
+// Authorize sets the Ditto credentials of a request according to its context
+func (c *Client) Authorize(req *http.Request) error {
+	return c.credentials.Apply(req)
+}
+
+// apiURL returns the absolute URL of a Ditto HTTP API v2 path
+func (c *Client) apiURL(path string) string {
+	return c.host + "/api/2" + path
 }
 
 // Connect establishes a WebSocket connection to Ditto
@@ -44,7 +88,11 @@ func (c *Client) Connect() error {
 	}
 
 	// Convert HTTP URL to WebSocket URL
-	u.Scheme = "ws"
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
 	if strings.HasSuffix(u.Path, "/") {
 		u.Path = u.Path + "ws/2"
 	} else {
@@ -117,7 +165,7 @@ func (c *Client) SendMessage(topic string, value json.RawMessage) error {
 }
 
 // Listen starts listening for events
-func (c *Client) Listen(handler func(topic string, value json.RawMessage)) error {
+func (c *Client) Listen(handler EventHandler) error {
 	if c.conn == nil {
 		return fmt.Errorf("not connected to Ditto")
 	}
@@ -146,20 +194,17 @@ func (c *Client) Listen(handler func(topic string, value json.RawMessage)) error
 			continue
 		}
 
-		var msg struct {
-			Topic string          `json:"topic"`
-			Value json.RawMessage `json:"value"`
-		}
-		if err := json.Unmarshal(msgBytes, &msg); err != nil {
+		var event Event
+		if err := json.Unmarshal(msgBytes, &event); err != nil {
 			log.Printf("Failed to parse message as JSON: %v", err)
 			log.Printf("Message content: %s", string(msgBytes))
 			continue
 		}
 
-		if !strings.HasSuffix(msg.Topic, "/things/twin/events/merged") {
-			log.Printf("Processing non-merged event with topic: %s", msg.Topic)
+		if !strings.HasSuffix(event.Topic, "/things/twin/events/merged") {
+			log.Printf("Processing non-merged event with topic: %s", event.Topic)
 		}
-		handler(msg.Topic, msg.Value)
+		handler(event)
 	}
 }
 
@@ -175,20 +220,16 @@ func (c *Client) Close() error {
 }
 
 // GetThing retrieves a thing by its ID
-func (c *Client) GetThing(thingID string) (json.RawMessage, error) {
-	url := fmt.Sprintf("%s/api/2/things/%s", c.host, thingID)
-
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) GetThing(ctx context.Context, thingID string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiURL("/things/"+thingID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
+	if err := c.Authorize(req); err != nil {
+		return nil, err
+	}
 
-	// Add Basic Auth
-	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
-	req.Header.Add("Authorization", "Basic "+auth)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
@@ -206,24 +247,25 @@ func (c *Client) GetThing(thingID string) (json.RawMessage, error) {
 	return result, nil
 }
 
-// CreateThing creates a new thing
-func (c *Client) CreateThing(thingID string, thing json.RawMessage) error {
-	url := fmt.Sprintf("%s/api/2/things/%s", c.host, thingID)
+// FetchThing retrieves selected fields of a thing, all fields if none are given
+func (c *Client) FetchThing(ctx context.Context, thingID string, fields []string) (json.RawMessage, error) {
+	target := c.apiURL("/things/" + thingID)
+	if len(fields) > 0 {
+		target += "?fields=" + url.QueryEscape(strings.Join(fields, ","))
+	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(thing))
+	body, err := c.doGet(ctx, target)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
+	return json.RawMessage(body), nil
+}
 
-	// Add Basic Auth
-	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
-	req.Header.Add("Authorization", "Basic "+auth)
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// CreateThing creates a new thing
+func (c *Client) CreateThing(ctx context.Context, thingID string, thing json.RawMessage) error {
+	resp, err := c.put(ctx, c.apiURL("/things/"+thingID), thing)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return err
 	}
 	defer resp.Body.Close()
 
@@ -235,23 +277,10 @@ func (c *Client) CreateThing(thingID string, thing json.RawMessage) error {
 }
 
 // UpdateThing updates an existing thing
-func (c *Client) UpdateThing(thingID string, thing json.RawMessage) error {
-	url := fmt.Sprintf("%s/api/2/things/%s", c.host, thingID)
-
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(thing))
+func (c *Client) UpdateThing(ctx context.Context, thingID string, thing json.RawMessage) error {
+	resp, err := c.put(ctx, c.apiURL("/things/"+thingID), thing)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-
-	// Add Basic Auth
-	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
-	req.Header.Add("Authorization", "Basic "+auth)
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return err
 	}
 	defer resp.Body.Close()
 
@@ -263,20 +292,16 @@ func (c *Client) UpdateThing(thingID string, thing json.RawMessage) error {
 }
 
 // DeleteThing deletes a thing
-func (c *Client) DeleteThing(thingID string) error {
-	url := fmt.Sprintf("%s/api/2/things/%s", c.host, thingID)
-
-	req, err := http.NewRequest("DELETE", url, nil)
+func (c *Client) DeleteThing(ctx context.Context, thingID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.apiURL("/things/"+thingID), nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
+	if err := c.Authorize(req); err != nil {
+		return err
+	}
 
-	// Add Basic Auth
-	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
-	req.Header.Add("Authorization", "Basic "+auth)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
 	}
@@ -290,9 +315,7 @@ func (c *Client) DeleteThing(thingID string) error {
 }
 
 // CreatePolicy creates a new policy
-func (c *Client) CreatePolicy(policyID string) error {
-	url := fmt.Sprintf("%s/api/2/policies/%s", c.host, policyID)
-
+func (c *Client) CreatePolicy(ctx context.Context, policyID string) error {
 	policy := map[string]interface{}{
 		"entries": map[string]interface{}{
 			"owner": map[string]interface{}{
@@ -324,26 +347,72 @@ func (c *Client) CreatePolicy(policyID string) error {
 		return fmt.Errorf("failed to marshal policy: %v", err)
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(policyBytes))
+	resp, err := c.put(ctx, c.apiURL("/policies/"+policyID), policyBytes)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
 	}
+	defer resp.Body.Close()
 
-	// Add Basic Auth
-	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", c.username, c.password)))
-	req.Header.Add("Authorization", "Basic "+auth)
-	req.Header.Add("Content-Type", "application/json")
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to create policy: status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// put sends an authenticated PUT request with a JSON body
+func (c *Client) put(ctx context.Context, target string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if err := c.Authorize(req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	return resp, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// MergeThing applies a JSON merge patch to a thing. A non-empty etag is sent as
+// If-Match, ErrPreconditionFailed is returned when the thing changed in the meantime.
+func (c *Client) MergeThing(ctx context.Context, thingID string, patch json.RawMessage, etag string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.apiURL("/things/"+thingID), bytes.NewReader(patch))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if err := c.Authorize(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to create policy: status code %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to merge thing: status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
+}
 
-	return nil
+// RevisionETag returns the ETag Ditto uses for an entity revision
+func RevisionETag(revision int64) string {
+	return fmt.Sprintf(`"rev:%d"`, revision)
 }
@@ -0,0 +1,27 @@
+package ditto
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// entityIDPattern matches Ditto thing and policy IDs in the namespace:name notation
+var entityIDPattern = regexp.MustCompile(`^(?:[a-zA-Z]\w*(?:\.[a-zA-Z]\w*)*)?:[^/\s\-][^/\s]*$`)
+
+// ValidateEntityID checks that an ID is a valid namespaced Ditto entity ID
+func ValidateEntityID(id string) error {
+	if len(id) > 256 || !entityIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid entity ID %q: expected namespace:name", id)
+	}
+	return nil
+}
+
+// Namespace returns the namespace part of an entity ID
+func Namespace(id string) string {
+	for i := 0; i < len(id); i++ {
+		if id[i] == ':' {
+			return id[:i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,10 @@
+package ditto
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a thing does not exist or is not visible
+	ErrNotFound = errors.New("thing not found")
+	// ErrPreconditionFailed is returned when an If-Match condition does not hold
+	ErrPreconditionFailed = errors.New("precondition failed: thing was modified")
+)
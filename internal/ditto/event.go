@@ -0,0 +1,69 @@
+package ditto
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"ditto/internal/eventbus"
+)
+
+const (
+	ActionCreated  = "created"
+	ActionModified = "modified"
+	ActionMerged   = "merged"
+	ActionDeleted  = "deleted"
+)
+
+// Event is a twin event received over the Ditto WebSocket, in the Ditto Protocol envelope
+type Event struct {
+	Topic     string                 `json:"topic"`
+	Headers   map[string]interface{} `json:"headers,omitempty"`
+	Path      string                 `json:"path"`
+	Value     json.RawMessage        `json:"value,omitempty"`
+	Revision  int64                  `json:"revision,omitempty"`
+	Timestamp time.Time              `json:"timestamp,omitempty"`
+}
+
+// EventHandler receives decoded twin events
+type EventHandler func(event Event)
+
+// EventTopic carries every event received from Ditto
+var EventTopic = eventbus.NewTopic[Event]("ditto.events")
+
+// ThingID returns the ID of the thing the event belongs to, derived from its topic
+func (e Event) ThingID() string {
+	parts := strings.SplitN(e.Topic, "/", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + ":" + parts[1]
+}
+
+// Action returns the last topic segment, e.g. modified or merged
+func (e Event) Action() string {
+	if i := strings.LastIndex(e.Topic, "/"); i >= 0 {
+		return e.Topic[i+1:]
+	}
+	return e.Topic
+}
+
+// IsTwinEvent reports whether the event is a thing twin event
+func (e Event) IsTwinEvent() bool {
+	return strings.Contains(e.Topic, "/things/twin/events/")
+}
+
+// Feature returns the feature changed by the event, empty if the event is not
+// scoped to a single feature
+func (e Event) Feature() string {
+	parts := strings.Split(strings.Trim(e.Path, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "features" {
+		return parts[1]
+	}
+	return ""
+}
+
+// TouchesFeatures reports whether the event may change feature properties
+func (e Event) TouchesFeatures() bool {
+	return e.Path == "" || e.Path == "/" || e.Path == "/features" || strings.HasPrefix(e.Path, "/features/")
+}
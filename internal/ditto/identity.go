@@ -0,0 +1,116 @@
+package ditto
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Identity modes, selecting how requests made on behalf of a user authenticate to Ditto
+const (
+	// IdentityService sends every request with the service account
+	IdentityService = "service"
+	// IdentityPreAuthenticated sends the user as <issuer>:<subject> in PreAuthenticatedHeader
+	IdentityPreAuthenticated = "pre-authenticated"
+	// IdentityJWT passes the bearer token the user authenticated with
+	IdentityJWT = "jwt"
+)
+
+// PreAuthenticatedHeader carries the subject of a caller authenticated by a trusted proxy
+const PreAuthenticatedHeader = "x-ditto-pre-authenticated"
+
+// ErrNoBearerToken is returned when a user's token must be forwarded but the user has none
+var ErrNoBearerToken = errors.New("no bearer token to forward to Ditto")
+
+// Identity is the user on whose behalf a request is sent to Ditto
+type Identity struct {
+	Subject string
+	// Token is the bearer JWT the user authenticated with, if any
+	Token string
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context whose Ditto requests are made on behalf of a user
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// WithoutIdentity returns a context whose Ditto requests are made with the service account
+func WithoutIdentity(ctx context.Context) context.Context {
+	return context.WithValue(ctx, identityKey{}, nil)
+}
+
+// IdentityFromContext returns the user of a context, ok is false for the service itself
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+// Credentials authenticate requests to Ditto. Requests whose context carries an
+// identity are made on behalf of that user according to the mode, all others,
+// such as the background work of the service, use the service account.
+type Credentials struct {
+	username string
+	password string
+	mode     string
+	issuer   string
+}
+
+// NewCredentials creates the credentials of a service account forwarding user identities in the given mode
+func NewCredentials(username, password, mode, issuer string) (*Credentials, error) {
+	switch mode {
+	case "":
+		mode = IdentityService
+	case IdentityService, IdentityJWT:
+	case IdentityPreAuthenticated:
+		if issuer == "" {
+			return nil, fmt.Errorf("an issuer is required to forward pre-authenticated identities")
+		}
+	default:
+		return nil, fmt.Errorf("identity mode must be one of %s, %s, %s", IdentityService, IdentityPreAuthenticated, IdentityJWT)
+	}
+
+	return &Credentials{
+		username: username,
+		password: password,
+		mode:     mode,
+		issuer:   issuer,
+	}, nil
+}
+
+// ForwardsIdentity tells whether requests made for a user are sent on their behalf
+func (c *Credentials) ForwardsIdentity() bool {
+	return c.mode != IdentityService
+}
+
+// Header returns the authentication headers of a request made with the context
+func (c *Credentials) Header(ctx context.Context) (http.Header, error) {
+	header := http.Header{}
+	identity, ok := IdentityFromContext(ctx)
+	switch {
+	case !ok || c.mode == IdentityService:
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.username+":"+c.password)))
+	case c.mode == IdentityPreAuthenticated:
+		header.Set(PreAuthenticatedHeader, c.issuer+":"+identity.Subject)
+	case identity.Token == "":
+		return nil, ErrNoBearerToken
+	default:
+		header.Set("Authorization", "Bearer "+identity.Token)
+	}
+	return header, nil
+}
+
+// Apply sets the authentication headers of a request according to its context
+func (c *Credentials) Apply(req *http.Request) error {
+	header, err := c.Header(req.Context())
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return nil
+}
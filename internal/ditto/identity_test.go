@@ -0,0 +1,77 @@
+package ditto
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCredentialsHeader(t *testing.T) {
+	const basic = "Basic ZGl0dG86ZGl0dG8="
+	user := WithIdentity(context.Background(), Identity{Subject: "alice", Token: "token"})
+	tokenless := WithIdentity(context.Background(), Identity{Subject: "alice"})
+	// The service context of a request made for a user
+	service := WithoutIdentity(user)
+
+	tests := []struct {
+		name    string
+		mode    string
+		ctx     context.Context
+		want    http.Header
+		wantErr error
+	}{
+		{name: "service account", mode: IdentityService, ctx: user, want: http.Header{"Authorization": {basic}}},
+		{name: "default mode", mode: "", ctx: user, want: http.Header{"Authorization": {basic}}},
+		{name: "pre-authenticated user", mode: IdentityPreAuthenticated, ctx: user, want: http.Header{PreAuthenticatedHeader: {"oidc:alice"}}},
+		{name: "pre-authenticated without user", mode: IdentityPreAuthenticated, ctx: context.Background(), want: http.Header{"Authorization": {basic}}},
+		{name: "pre-authenticated service", mode: IdentityPreAuthenticated, ctx: service, want: http.Header{"Authorization": {basic}}},
+		{name: "jwt user", mode: IdentityJWT, ctx: user, want: http.Header{"Authorization": {"Bearer token"}}},
+		{name: "jwt without token", mode: IdentityJWT, ctx: tokenless, wantErr: ErrNoBearerToken},
+		{name: "jwt without user", mode: IdentityJWT, ctx: context.Background(), want: http.Header{"Authorization": {basic}}},
+		{name: "jwt service", mode: IdentityJWT, ctx: service, want: http.Header{"Authorization": {basic}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentials, err := NewCredentials("ditto", "ditto", tt.mode, "oidc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := credentials.Header(tt.ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(header) != len(tt.want) {
+				t.Errorf("expected headers %v, got %v", tt.want, header)
+			}
+			for name := range tt.want {
+				if got, want := header.Get(name), tt.want[name][0]; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCredentialsRefusesInvalidModes(t *testing.T) {
+	if _, err := NewCredentials("ditto", "ditto", "basic", "oidc"); err == nil {
+		t.Error("expected an unknown mode to be refused")
+	}
+	if _, err := NewCredentials("ditto", "ditto", IdentityPreAuthenticated, ""); err == nil {
+		t.Error("expected pre-authenticated identities to require an issuer")
+	}
+	credentials, err := NewCredentials("ditto", "ditto", IdentityService, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.ForwardsIdentity() {
+		t.Error("expected the service mode not to forward identities")
+	}
+}
@@ -0,0 +1,92 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ThingIterator walks over every thing matching a search query, following
+// cursors transparently so only one page is held in memory at a time
+type ThingIterator struct {
+	client *Client
+	ctx    context.Context
+	query  SearchQuery
+	page   []json.RawMessage
+	pos    int
+	item   json.RawMessage
+	last   bool
+	err    error
+}
+
+// Iterate returns an iterator over all things matching the query, the query
+// cursor is used as the starting point and pages default to MaxSearchSize
+func (c *Client) Iterate(ctx context.Context, query SearchQuery) *ThingIterator {
+	if query.Size <= 0 {
+		query.Size = MaxSearchSize
+	}
+	return &ThingIterator{
+		client: c,
+		ctx:    ctx,
+		query:  query,
+	}
+}
+
+// Next advances to the next thing, it returns false when the result is
+// exhausted or an error occurred
+func (it *ThingIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.pos >= len(it.page) {
+		if it.last {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+
+		result, err := it.client.Search(it.ctx, it.query)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = result.Items
+		it.pos = 0
+		it.query.Cursor = result.Cursor
+		it.last = result.Cursor == ""
+	}
+
+	it.item = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Thing returns the current thing
+func (it *ThingIterator) Thing() json.RawMessage {
+	return it.item
+}
+
+// Cursor returns the cursor of the page after the one currently being iterated,
+// it can be used to resume an interrupted iteration
+func (it *ThingIterator) Cursor() string {
+	return it.query.Cursor
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ThingIterator) Err() error {
+	return it.err
+}
+
+// ForEach calls fn for every thing matching the query, stopping at the first error
+func (c *Client) ForEach(ctx context.Context, query SearchQuery, fn func(thing json.RawMessage) error) error {
+	it := c.Iterate(ctx, query)
+	for it.Next() {
+		if err := fn(it.Thing()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
@@ -0,0 +1,154 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ditto/internal/ditto/rql"
+)
+
+// searchPages serves pages of things keyed by the cursor they are requested with
+type searchPages struct {
+	mu      sync.Mutex
+	options []string
+	pages   map[string]string
+}
+
+func newSearchServer(t *testing.T, pages map[string]string) (*Client, *searchPages) {
+	t.Helper()
+	s := &searchPages{pages: pages}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/2/search/things" {
+			http.NotFound(w, r)
+			return
+		}
+		option := r.URL.Query().Get("option")
+		s.mu.Lock()
+		s.options = append(s.options, option)
+		s.mu.Unlock()
+
+		cursor := ""
+		for _, o := range strings.Split(option, ",") {
+			if strings.HasPrefix(o, "cursor(") {
+				cursor = strings.TrimSuffix(strings.TrimPrefix(o, "cursor("), ")")
+			}
+		}
+		page, ok := s.pages[cursor]
+		if !ok {
+			http.Error(w, `{"status":500}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, "ditto", "ditto"), s
+}
+
+func (s *searchPages) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.options...)
+}
+
+// thingIDs collects the ids of the things of an iteration
+func thingIDs(t *testing.T, it *ThingIterator) []string {
+	t.Helper()
+	var ids []string
+	for it.Next() {
+		var thing struct {
+			ThingID string `json:"thingId"`
+		}
+		if err := json.Unmarshal(it.Thing(), &thing); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, thing.ThingID)
+	}
+	return ids
+}
+
+func TestIteratorFollowsCursors(t *testing.T) {
+	client, server := newSearchServer(t, map[string]string{
+		"":   `{"items":[{"thingId":"org:a"},{"thingId":"org:b"}],"cursor":"c1"}`,
+		"c1": `{"items":[],"cursor":"c2"}`,
+		"c2": `{"items":[{"thingId":"org:c"}]}`,
+	})
+
+	it := client.Iterate(context.Background(), SearchQuery{Sort: []rql.SortKey{rql.Desc("thingId")}})
+	ids := thingIDs(t, it)
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "org:a,org:b,org:c" {
+		t.Errorf("expected every page to be walked, got %v", ids)
+	}
+	if it.Cursor() != "" {
+		t.Errorf("expected no cursor after the last page, got %q", it.Cursor())
+	}
+
+	// Pages default to the largest size, the sort is only sent for the first page
+	want := []string{"size(200),sort(-thingId)", "size(200),cursor(c1)", "size(200),cursor(c2)"}
+	if got := server.requests(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected requests %v, got %v", want, got)
+	}
+}
+
+func TestIteratorResumesFromCursor(t *testing.T) {
+	client, server := newSearchServer(t, map[string]string{
+		"c1": `{"items":[{"thingId":"org:c"}],"cursor":"c2"}`,
+	})
+
+	// The query cursor is the starting point, a failing page stops the iteration
+	it := client.Iterate(context.Background(), SearchQuery{Size: 10, Cursor: "c1"})
+	if ids := thingIDs(t, it); strings.Join(ids, ",") != "org:c" {
+		t.Errorf("expected the things from the cursor on, got %v", ids)
+	}
+	if it.Err() == nil {
+		t.Fatal("expected the failing page to stop the iteration")
+	}
+	// The cursor of the failed page resumes the iteration
+	if it.Cursor() != "c2" {
+		t.Errorf("expected the cursor of the failed page, got %q", it.Cursor())
+	}
+	if it.Next() {
+		t.Error("expected a failed iteration to stay stopped")
+	}
+	if got := server.requests(); len(got) != 2 || got[0] != "size(10),cursor(c1)" {
+		t.Errorf("unexpected requests %v", got)
+	}
+}
+
+func TestForEachStops(t *testing.T) {
+	client, server := newSearchServer(t, map[string]string{
+		"":   `{"items":[{"thingId":"org:a"},{"thingId":"org:b"}],"cursor":"c1"}`,
+		"c1": `{"items":[{"thingId":"org:c"}]}`,
+	})
+
+	// An error of the callback stops before the next page is fetched
+	stop := errors.New("stop")
+	var seen int
+	err := client.ForEach(context.Background(), SearchQuery{}, func(json.RawMessage) error {
+		seen++
+		if seen == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || seen != 2 || len(server.requests()) != 1 {
+		t.Errorf("expected the callback error after 2 things and a single page, got %v after %d things and %d pages", err, seen, len(server.requests()))
+	}
+
+	// So does a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.ForEach(ctx, SearchQuery{}, func(json.RawMessage) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package ditto
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ditto/internal/eventbus"
+)
+
+// Measurement is a numeric feature value decoded from a twin event
+type Measurement struct {
+	ThingID   string
+	Feature   string
+	Value     float64
+	Timestamp time.Time
+}
+
+// MeasurementTopic carries the measurements decoded from twin events
+var MeasurementTopic = eventbus.NewTopic[Measurement]("ditto.measurements")
+
+// DecodeMeasurements extracts the properties.value of every feature changed by an event.
+// The event value is interpreted according to its path, from a whole thing down to a
+// single property. The optional properties.timestamp overrides the event time.
+func DecodeMeasurements(e Event) []Measurement {
+	if !e.IsTwinEvent() || e.Action() == ActionDeleted || len(e.Value) == 0 {
+		return nil
+	}
+
+	features, ok := featuresAtPath(e.Path, e.Value)
+	if !ok {
+		return nil
+	}
+
+	thingID := e.ThingID()
+	timestamp := e.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var measurements []Measurement
+	for feature, properties := range features {
+		val, ok := properties["value"]
+		if !ok {
+			continue
+		}
+
+		// Convert value to float64
+		var floatVal float64
+		switch v := val.(type) {
+		case float64:
+			floatVal = v
+		case string:
+			// Try to parse string as float
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				log.Printf("Failed to parse value as float: %v", err)
+				continue
+			}
+			floatVal = f
+		default:
+			log.Printf("Unsupported value type: %T", val)
+			continue
+		}
+
+		// Get timestamp from properties or use the event time
+		measuredAt := timestamp
+		if ts, ok := properties["timestamp"].(string); ok {
+			if t, err := time.Parse(time.RFC3339, ts); err == nil {
+				measuredAt = t
+			}
+		}
+
+		measurements = append(measurements, Measurement{
+			ThingID:   thingID,
+			Feature:   feature,
+			Value:     floatVal,
+			Timestamp: measuredAt,
+		})
+	}
+	return measurements
+}
+
+// featuresAtPath rebuilds the changed feature properties from an event value at the given path
+func featuresAtPath(path string, value json.RawMessage) (map[string]map[string]interface{}, bool) {
+	type feature struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+
+	switch {
+	case len(parts) == 0:
+		var thing struct {
+			Features map[string]feature `json:"features"`
+		}
+		if err := json.Unmarshal(value, &thing); err != nil {
+			return nil, false
+		}
+		result := make(map[string]map[string]interface{}, len(thing.Features))
+		for name, f := range thing.Features {
+			result[name] = f.Properties
+		}
+		return result, true
+	case parts[0] != "features":
+		return nil, false
+	case len(parts) == 1:
+		var features map[string]feature
+		if err := json.Unmarshal(value, &features); err != nil {
+			return nil, false
+		}
+		result := make(map[string]map[string]interface{}, len(features))
+		for name, f := range features {
+			result[name] = f.Properties
+		}
+		return result, true
+	case len(parts) == 2:
+		var f feature
+		if err := json.Unmarshal(value, &f); err != nil {
+			return nil, false
+		}
+		return map[string]map[string]interface{}{parts[1]: f.Properties}, true
+	case len(parts) == 3 && parts[2] == "properties":
+		var properties map[string]interface{}
+		if err := json.Unmarshal(value, &properties); err != nil {
+			return nil, false
+		}
+		return map[string]map[string]interface{}{parts[1]: properties}, true
+	case len(parts) == 4 && parts[2] == "properties" && parts[3] == "value":
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, false
+		}
+		return map[string]map[string]interface{}{parts[1]: {"value": v}}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,118 @@
+package ditto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// responseTimeoutGrace is added to the message timeout so Ditto can answer with 408 itself
+const responseTimeoutGrace = 5 * time.Second
+
+// MessageRequest is a message sent to the inbox of a thing feature
+type MessageRequest struct {
+	ThingID string
+	Feature string
+	Subject string
+	Payload json.RawMessage
+	// Timeout is how long Ditto waits for the device to reply, zero sends without waiting
+	Timeout time.Duration
+	// ResponseRequired tells the device whether a reply is expected
+	ResponseRequired bool
+	// CorrelationID correlates the message with the device reply
+	CorrelationID string
+}
+
+// MessageResponse is the reply of a device relayed by Ditto
+type MessageResponse struct {
+	// Status is the status code set by the device, 408 when it did not answer in time
+	// and 202 when no reply was awaited
+	Status        int
+	ContentType   string
+	Payload       json.RawMessage
+	CorrelationID string
+}
+
+// Timeout reports whether the device did not reply in time
+func (r *MessageResponse) Timeout() bool {
+	return r.Status == http.StatusRequestTimeout
+}
+
+// timeoutSeconds converts a message timeout to the whole seconds Ditto accepts,
+// rounding up so a sub-second timeout still waits for the reply
+func timeoutSeconds(timeout time.Duration) int {
+	if timeout <= 0 {
+		return 0
+	}
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// SendFeatureMessage sends a message to a feature inbox and waits for the device reply
+// up to the request timeout
+func (c *Client) SendFeatureMessage(ctx context.Context, msg MessageRequest) (*MessageResponse, error) {
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(timeoutSeconds(msg.Timeout)))
+
+	target := fmt.Sprintf("%s?%s", c.apiURL(fmt.Sprintf("/things/%s/features/%s/inbox/messages/%s",
+		url.PathEscape(msg.ThingID),
+		url.PathEscape(msg.Feature),
+		url.PathEscape(msg.Subject),
+	)), params.Encode())
+
+	payload := msg.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, msg.Timeout+responseTimeoutGrace)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if err := c.Authorize(req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("response-required", strconv.FormatBool(msg.ResponseRequired))
+	if msg.CorrelationID != "" {
+		req.Header.Set("correlation-id", msg.CorrelationID)
+	}
+
+	// The shared client timeout would cut off long running commands, the context bounds the request instead
+	client := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message response: %v", err)
+	}
+
+	result := &MessageResponse{
+		Status:        resp.StatusCode,
+		ContentType:   resp.Header.Get("Content-Type"),
+		CorrelationID: resp.Header.Get("correlation-id"),
+	}
+	if len(body) > 0 {
+		if json.Valid(body) {
+			result.Payload = body
+		} else {
+			// Keep non JSON replies readable by wrapping them in a JSON string
+			quoted, _ := json.Marshal(string(body))
+			result.Payload = quoted
+		}
+	}
+
+	return result, nil
+}
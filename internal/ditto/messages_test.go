@@ -0,0 +1,48 @@
+package ditto
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendFeatureMessageTimeout(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{timeout: 0, want: "0"},
+		{timeout: 300 * time.Millisecond, want: "1"},
+		{timeout: time.Second, want: "1"},
+		{timeout: 1500 * time.Millisecond, want: "2"},
+		{timeout: 30 * time.Second, want: "30"},
+	}
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("timeout")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "ditto", "ditto")
+
+	for _, tt := range tests {
+		t.Run(tt.timeout.String(), func(t *testing.T) {
+			_, err := client.SendFeatureMessage(context.Background(), MessageRequest{
+				ThingID: "org:dev",
+				Feature: "system",
+				Subject: "reboot",
+				Timeout: tt.timeout,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A sub-second timeout must not turn into a message sent without waiting
+			if got != tt.want {
+				t.Errorf("expected timeout=%s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -8,12 +8,16 @@ import (
 )
 
 var Module = fx.Options(
-	fx.Provide(func(cfg *config.Config) *Client {
-		return NewClient(
+	fx.Provide(func(cfg *config.Config) (*Client, error) {
+		client := NewClient(
 			cfg.Ditto.URL,
 			cfg.Ditto.Username,
 			cfg.Ditto.Password,
 		)
+		if err := client.ForwardIdentity(cfg.Ditto.IdentityMode, cfg.Ditto.PreAuthIssuer); err != nil {
+			return nil, err
+		}
+		return client, nil
 	}),
 	fx.Provide(NewService),
 	influxdb.Module,
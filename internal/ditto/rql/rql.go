@@ -0,0 +1,252 @@
+package rql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pathPattern matches the JSON pointer style paths Ditto accepts in RQL
+// expressions, e.g. thingId, attributes/location or features/temp/properties/value
+var pathPattern = regexp.MustCompile(`^[A-Za-z0-9_\-:.@]+(/[A-Za-z0-9_\-:.@]+)*$`)
+
+// Expr represents an RQL expression understood by the Ditto search API
+type Expr interface {
+	// String renders the expression in RQL syntax
+	String() string
+	// Validate reports whether the expression can be rendered safely
+	Validate() error
+}
+
+// ValidatePath checks that a property path is safe to embed in an RQL expression
+func ValidatePath(path string) error {
+	if !pathPattern.MatchString(path) {
+		return fmt.Errorf("invalid RQL path: %q", path)
+	}
+	return nil
+}
+
+// Quote renders a string as an RQL string literal, escaping quotes and backslashes
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Value renders a Go value as an RQL literal
+func Value(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return Quote(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int32:
+		return strconv.FormatInt(int64(val), 10), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(val), 10), nil
+	case uint64:
+		return strconv.FormatUint(val, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported RQL value type: %T", v)
+	}
+}
+
+// comparison is a single-property operator such as eq(attributes/location,"room1")
+type comparison struct {
+	op     string
+	path   string
+	values []interface{}
+}
+
+func (c comparison) String() string {
+	parts := make([]string, 0, len(c.values)+1)
+	parts = append(parts, c.path)
+	for _, v := range c.values {
+		s, err := Value(v)
+		if err != nil {
+			s = "null"
+		}
+		parts = append(parts, s)
+	}
+	return c.op + "(" + strings.Join(parts, ",") + ")"
+}
+
+func (c comparison) Validate() error {
+	if err := ValidatePath(c.path); err != nil {
+		return err
+	}
+	if c.op == "in" && len(c.values) == 0 {
+		return fmt.Errorf("in(%s) requires at least one value", c.path)
+	}
+	for _, v := range c.values {
+		if _, err := Value(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Eq matches things whose property equals the value
+func Eq(path string, value interface{}) Expr {
+	return comparison{op: "eq", path: path, values: []interface{}{value}}
+}
+
+// Ne matches things whose property does not equal the value
+func Ne(path string, value interface{}) Expr {
+	return comparison{op: "ne", path: path, values: []interface{}{value}}
+}
+
+// Gt matches things whose property is greater than the value
+func Gt(path string, value interface{}) Expr {
+	return comparison{op: "gt", path: path, values: []interface{}{value}}
+}
+
+// Ge matches things whose property is greater than or equal to the value
+func Ge(path string, value interface{}) Expr {
+	return comparison{op: "ge", path: path, values: []interface{}{value}}
+}
+
+// Lt matches things whose property is less than the value
+func Lt(path string, value interface{}) Expr {
+	return comparison{op: "lt", path: path, values: []interface{}{value}}
+}
+
+// Le matches things whose property is less than or equal to the value
+func Le(path string, value interface{}) Expr {
+	return comparison{op: "le", path: path, values: []interface{}{value}}
+}
+
+// In matches things whose property equals one of the values
+func In(path string, values ...interface{}) Expr {
+	return comparison{op: "in", path: path, values: values}
+}
+
+// Like matches string properties against a pattern where * matches any
+// number of characters and ? matches a single character
+func Like(path, pattern string) Expr {
+	return comparison{op: "like", path: path, values: []interface{}{pattern}}
+}
+
+// ILike is the case-insensitive variant of Like
+func ILike(path, pattern string) Expr {
+	return comparison{op: "ilike", path: path, values: []interface{}{pattern}}
+}
+
+// Exists matches things that have the given property
+func Exists(path string) Expr {
+	return comparison{op: "exists", path: path}
+}
+
+// logical combines several expressions with and/or
+type logical struct {
+	op    string
+	exprs []Expr
+}
+
+func (l logical) String() string {
+	if len(l.exprs) == 0 {
+		return ""
+	}
+	if len(l.exprs) == 1 {
+		return l.exprs[0].String()
+	}
+	parts := make([]string, len(l.exprs))
+	for i, e := range l.exprs {
+		parts[i] = e.String()
+	}
+	return l.op + "(" + strings.Join(parts, ",") + ")"
+}
+
+func (l logical) Validate() error {
+	if len(l.exprs) == 0 {
+		return fmt.Errorf("%s() requires at least one expression", l.op)
+	}
+	for _, e := range l.exprs {
+		if e == nil {
+			return fmt.Errorf("%s() contains a nil expression", l.op)
+		}
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// And matches things satisfying all expressions, nil expressions are skipped
+func And(exprs ...Expr) Expr {
+	return logical{op: "and", exprs: compact(exprs)}
+}
+
+// Or matches things satisfying at least one expression, nil expressions are skipped
+func Or(exprs ...Expr) Expr {
+	return logical{op: "or", exprs: compact(exprs)}
+}
+
+// negation inverts an expression
+type negation struct {
+	expr Expr
+}
+
+func (n negation) String() string {
+	return "not(" + n.expr.String() + ")"
+}
+
+func (n negation) Validate() error {
+	if n.expr == nil {
+		return fmt.Errorf("not() requires an expression")
+	}
+	return n.expr.Validate()
+}
+
+// Not matches things not satisfying the expression
+func Not(expr Expr) Expr {
+	return negation{expr: expr}
+}
+
+// Build validates and renders an expression, a nil expression renders as an empty string
+func Build(expr Expr) (string, error) {
+	if expr == nil {
+		return "", nil
+	}
+	if l, ok := expr.(logical); ok && len(l.exprs) == 0 {
+		return "", nil
+	}
+	if err := expr.Validate(); err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+func compact(exprs []Expr) []Expr {
+	result := make([]Expr, 0, len(exprs))
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		if l, ok := e.(logical); ok && len(l.exprs) == 0 {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package rql
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: `""`},
+		{in: "room1", want: `"room1"`},
+		{in: `say "hi"`, want: `"say \"hi\""`},
+		{in: `C:\temp`, want: `"C:\\temp"`},
+		{in: `\"`, want: `"\\\""`},
+		{in: `"),or(exists(thingId`, want: `"\"),or(exists(thingId"`},
+		{in: "Zürich 🌡", want: `"Zürich 🌡"`},
+	}
+
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	valid := []string{
+		"thingId",
+		"attributes/location",
+		"features/temp/properties/value",
+		"attributes/org.eclipse:model-v1",
+		"attributes/owner@site_2",
+	}
+	for _, path := range valid {
+		if err := ValidatePath(path); err != nil {
+			t.Errorf("ValidatePath(%q): unexpected error %v", path, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"/attributes",
+		"attributes/",
+		"attributes//location",
+		"attributes/loc ation",
+		`attributes/"location"`,
+		`attributes\location`,
+		"attributes/location,1)",
+		"attributes/location)",
+		"attributes/*",
+		"attributes/location\n",
+	}
+	for _, path := range invalid {
+		if err := ValidatePath(path); err == nil {
+			t.Errorf("ValidatePath(%q): expected an error", path)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    Expr
+		want    string
+		wantErr bool
+	}{
+		{name: "nil", expr: nil, want: ""},
+		{name: "empty and", expr: And(), want: ""},
+		{name: "eq string", expr: Eq("attributes/location", `room "1"`), want: `eq(attributes/location,"room \"1\"")`},
+		{name: "numbers and null", expr: In("attributes/level", 1, 2.5, nil), want: `in(attributes/level,1,2.5,null)`},
+		{name: "single and", expr: And(Eq("thingId", "a:b")), want: `eq(thingId,"a:b")`},
+		{name: "nested", expr: Or(And(Gt("a", 1), Le("b", int64(2))), Not(Exists("c"))), want: `or(and(gt(a,1),le(b,2)),not(exists(c)))`},
+		{name: "nil expressions are skipped", expr: And(nil, Eq("a", true), And()), want: `eq(a,true)`},
+		{name: "invalid path", expr: Eq(`a,"x")`, "v"), wantErr: true},
+		{name: "nested invalid path", expr: And(Eq("a", 1), Like("b c", "*")), wantErr: true},
+		{name: "empty in", expr: In("a"), wantErr: true},
+		{name: "unsupported value", expr: Eq("a", []string{"x"}), wantErr: true},
+		{name: "not nil", expr: Not(nil), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Build(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SortKey
+		wantErr bool
+	}{
+		{in: "thingId", want: Asc("thingId")},
+		{in: "+attributes/location", want: Asc("attributes/location")},
+		{in: " -_modified ", want: Desc("_modified")},
+		{in: "-", wantErr: true},
+		{in: "-a,b", wantErr: true},
+		{in: "a)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSortKey(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSortKey(%q): expected an error", tt.in)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSortKey(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	option, err := SortOption([]SortKey{Asc("a"), Desc("b/c")})
+	if err != nil || option != "sort(+a,-b/c)" {
+		t.Errorf("unexpected sort option %q, %v", option, err)
+	}
+}
@@ -0,0 +1,61 @@
+package rql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortKey is a single sort criterion of a search query
+type SortKey struct {
+	Path       string
+	Descending bool
+}
+
+// Asc sorts ascending by the given property
+func Asc(path string) SortKey {
+	return SortKey{Path: path}
+}
+
+// Desc sorts descending by the given property
+func Desc(path string) SortKey {
+	return SortKey{Path: path, Descending: true}
+}
+
+// ParseSortKey parses a key in the +path / -path notation, a missing sign means ascending
+func ParseSortKey(s string) (SortKey, error) {
+	s = strings.TrimSpace(s)
+	key := SortKey{Path: s}
+	switch {
+	case strings.HasPrefix(s, "-"):
+		key = SortKey{Path: s[1:], Descending: true}
+	case strings.HasPrefix(s, "+"):
+		key = SortKey{Path: s[1:]}
+	}
+	if err := ValidatePath(key.Path); err != nil {
+		return SortKey{}, fmt.Errorf("invalid sort key %q: %w", s, err)
+	}
+	return key, nil
+}
+
+// String renders the key in the +path / -path notation
+func (k SortKey) String() string {
+	if k.Descending {
+		return "-" + k.Path
+	}
+	return "+" + k.Path
+}
+
+// SortOption renders the keys as a Ditto sort(...) option
+func SortOption(keys []SortKey) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		if err := ValidatePath(k.Path); err != nil {
+			return "", err
+		}
+		parts[i] = k.String()
+	}
+	return "sort(" + strings.Join(parts, ",") + ")", nil
+}
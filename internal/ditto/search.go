@@ -0,0 +1,190 @@
+package ditto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ditto/internal/ditto/rql"
+)
+
+const (
+	// DefaultSearchSize is the page size used when a query does not specify one
+	DefaultSearchSize = 25
+	// MaxSearchSize is the largest page size accepted by the Ditto search API
+	MaxSearchSize = 200
+)
+
+// cursorPattern matches the opaque cursor tokens returned by the Ditto search API
+var cursorPattern = regexp.MustCompile(`^[A-Za-z0-9_\-=]+$`)
+
+// SearchQuery describes a request to the Ditto search API
+type SearchQuery struct {
+	// Filter restricts the result, nil matches all things
+	Filter rql.Expr
+	// Namespaces limits the search to the given namespaces
+	Namespaces []string
+	// Fields selects the parts of each thing to return, e.g. thingId or attributes/location
+	Fields []string
+	// Sort orders the result, Ditto sorts by thingId when empty
+	Sort []rql.SortKey
+	// Size is the page size, capped at MaxSearchSize
+	Size int
+	// Cursor continues a previous search with the same filter and namespaces. The
+	// cursor carries the sort of the first page, Sort is not sent along with it.
+	Cursor string
+}
+
+// SearchResult is a single page of search results
+type SearchResult struct {
+	Items []json.RawMessage `json:"items"`
+	// Cursor points at the next page, it is empty on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// values encodes the query as Ditto search query parameters
+func (q SearchQuery) values() (url.Values, error) {
+	params := url.Values{}
+
+	filter, err := rql.Build(q.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search filter: %v", err)
+	}
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+
+	if len(q.Namespaces) > 0 {
+		params.Set("namespaces", strings.Join(q.Namespaces, ","))
+	}
+
+	if len(q.Fields) > 0 {
+		for _, field := range q.Fields {
+			if err := rql.ValidatePath(field); err != nil {
+				return nil, fmt.Errorf("invalid search field: %v", err)
+			}
+		}
+		params.Set("fields", strings.Join(q.Fields, ","))
+	}
+
+	size := q.Size
+	if size <= 0 {
+		size = DefaultSearchSize
+	}
+	if size > MaxSearchSize {
+		size = MaxSearchSize
+	}
+	options := []string{fmt.Sprintf("size(%d)", size)}
+
+	sort, err := rql.SortOption(q.Sort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search sort: %v", err)
+	}
+
+	// Ditto refuses a sort next to a cursor, which already encodes it
+	switch {
+	case q.Cursor != "":
+		if !cursorPattern.MatchString(q.Cursor) {
+			return nil, fmt.Errorf("invalid search cursor")
+		}
+		options = append(options, fmt.Sprintf("cursor(%s)", q.Cursor))
+	case sort != "":
+		options = append(options, sort)
+	}
+	params.Set("option", strings.Join(options, ","))
+
+	return params, nil
+}
+
+// Search runs a search query and returns a single page of things
+func (c *Client) Search(ctx context.Context, query SearchQuery) (*SearchResult, error) {
+	params, err := query.values()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.doGet(ctx, c.apiURL("/search/things")+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search things: %v", err)
+	}
+
+	var result SearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %v", err)
+	}
+	if result.Items == nil {
+		result.Items = []json.RawMessage{}
+	}
+
+	return &result, nil
+}
+
+// Count returns the number of things matching the filter
+func (c *Client) Count(ctx context.Context, filter rql.Expr, namespaces []string) (int, error) {
+	params := url.Values{}
+
+	rendered, err := rql.Build(filter)
+	if err != nil {
+		return 0, fmt.Errorf("invalid search filter: %v", err)
+	}
+	if rendered != "" {
+		params.Set("filter", rendered)
+	}
+	if len(namespaces) > 0 {
+		params.Set("namespaces", strings.Join(namespaces, ","))
+	}
+
+	target := c.apiURL("/search/things/count")
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	body, err := c.doGet(ctx, target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count things: %v", err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(body)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode count response: %v", err)
+	}
+
+	return count, nil
+}
+
+// doGet sends an authenticated GET request and returns the body of a 200 response
+func (c *Client) doGet(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if err := c.Authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
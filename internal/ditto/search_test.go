@@ -0,0 +1,86 @@
+package ditto
+
+import (
+	"testing"
+
+	"ditto/internal/ditto/rql"
+)
+
+func TestSearchQueryValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   SearchQuery
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			query: SearchQuery{},
+			want:  map[string]string{"option": "size(25)"},
+		},
+		{
+			name: "first page",
+			query: SearchQuery{
+				Filter:     rql.Eq("attributes/location", "hall 3"),
+				Namespaces: []string{"org.a", "org.b"},
+				Fields:     []string{"thingId", "attributes/location"},
+				Sort:       []rql.SortKey{rql.Desc("attributes/location"), rql.Asc("thingId")},
+				Size:       500,
+			},
+			want: map[string]string{
+				"filter":     `eq(attributes/location,"hall 3")`,
+				"namespaces": "org.a,org.b",
+				"fields":     "thingId,attributes/location",
+				"option":     "size(200),sort(-attributes/location,+thingId)",
+			},
+		},
+		{
+			// The cursor carries the sort of the first page, Ditto refuses both
+			name: "next page",
+			query: SearchQuery{
+				Sort:   []rql.SortKey{rql.Desc("attributes/location")},
+				Size:   50,
+				Cursor: "LOREMIPSUM_-=",
+			},
+			want: map[string]string{"option": "size(50),cursor(LOREMIPSUM_-=)"},
+		},
+		{
+			name:    "invalid cursor",
+			query:   SearchQuery{Cursor: "abc),sort(+thingId"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid sort next to a cursor",
+			query:   SearchQuery{Sort: []rql.SortKey{rql.Asc("thingId,x")}, Cursor: "abc"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid field",
+			query:   SearchQuery{Fields: []string{"attributes/(x)"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tt.query.values()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", params.Encode())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(params) != len(tt.want) {
+				t.Errorf("expected parameters %v, got %v", tt.want, params)
+			}
+			for name, want := range tt.want {
+				if got := params.Get(name); got != want {
+					t.Errorf("%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
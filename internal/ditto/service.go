@@ -5,9 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strconv"
-	"time"
 
+	"ditto/internal/eventbus"
 	"ditto/internal/influxdb"
 )
 
@@ -15,24 +14,31 @@ import (
 type Service interface {
 	Start(ctx context.Context) error
 	Stop() error
-	GetThing(thingID string) (json.RawMessage, error)
-	CreateThing(thingID string, thing json.RawMessage) error
-	UpdateThing(thingID string, thing json.RawMessage) error
-	DeleteThing(thingID string) error
+	GetThing(ctx context.Context, thingID string) (json.RawMessage, error)
+	CreateThing(ctx context.Context, thingID string, thing json.RawMessage) error
+	UpdateThing(ctx context.Context, thingID string, thing json.RawMessage) error
+	DeleteThing(ctx context.Context, thingID string) error
 }
 
-// service implements the Ditto service
+// service implements the Ditto service. Received events and the measurements
+// decoded from them are published on the event bus.
 type service struct {
 	client   *Client
 	influxDB *influxdb.Client
+	bus      *eventbus.Bus
 }
 
 // NewService creates a new Ditto service
-func NewService(client *Client, influxDB *influxdb.Client) Service {
-	return &service{
+func NewService(client *Client, influxDB *influxdb.Client, bus *eventbus.Bus) Service {
+	s := &service{
 		client:   client,
 		influxDB: influxDB,
+		bus:      bus,
 	}
+
+	// Decoded feature values are stored in InfluxDB
+	eventbus.Subscribe(bus, MeasurementTopic, "influxdb", s.storeMeasurement)
+	return s
 }
 
 // Start starts the Ditto service
@@ -50,81 +56,14 @@ func (s *service) Start(ctx context.Context) error {
 
 	// Start listening for events from Ditto
 	go func() {
-		if err := s.client.Listen(func(topic string, value json.RawMessage) {
+		if err := s.client.Listen(func(e Event) {
 			log.Printf("Received event from Ditto:")
-			log.Printf("Topic: %s", topic)
-			log.Printf("Content: %s", string(value))
-
-			// Parse the event value
-			var event struct {
-				ThingID  string `json:"thingId"`
-				Features map[string]struct {
-					Properties map[string]interface{} `json:"properties"`
-				} `json:"features"`
-			}
-
-			// Try to parse the value as a direct event
-			if err := json.Unmarshal(value, &event); err != nil {
-				// If direct parsing fails, try to parse as a wrapped event
-				var wrappedEvent struct {
-					Value struct {
-						ThingID  string `json:"thingId"`
-						Features map[string]struct {
-							Properties map[string]interface{} `json:"properties"`
-						} `json:"features"`
-					} `json:"value"`
-				}
-				if err := json.Unmarshal(value, &wrappedEvent); err != nil {
-					log.Printf("Failed to parse event payload: %v", err)
-					return
-				}
-				event = wrappedEvent.Value
-			}
+			log.Printf("Topic: %s", e.Topic)
+			log.Printf("Content: %s", string(e.Value))
 
-			// Process features and store in InfluxDB
-			for feature, data := range event.Features {
-				if val, ok := data.Properties["value"]; ok {
-					// Convert value to float64
-					var floatVal float64
-					switch v := val.(type) {
-					case float64:
-						floatVal = v
-					case int:
-						floatVal = float64(v)
-					case int64:
-						floatVal = float64(v)
-					case string:
-						// Try to parse string as float
-						if f, err := strconv.ParseFloat(v, 64); err == nil {
-							floatVal = f
-						} else {
-							log.Printf("Failed to parse value as float: %v", err)
-							continue
-						}
-					default:
-						log.Printf("Unsupported value type: %T", val)
-						continue
-					}
-
-					// Get timestamp from properties or use current time
-					timestamp := time.Now()
-					if ts, ok := data.Properties["timestamp"].(string); ok {
-						if t, err := time.Parse(time.RFC3339, ts); err == nil {
-							timestamp = t
-						}
-					}
-
-					// Store in InfluxDB
-					err := s.influxDB.WriteEvent(
-						event.ThingID,
-						feature,
-						floatVal,
-						timestamp,
-					)
-					if err != nil {
-						log.Printf("Failed to store event in InfluxDB: %v", err)
-					}
-				}
+			eventbus.Publish(s.bus, EventTopic, e)
+			for _, m := range DecodeMeasurements(e) {
+				eventbus.Publish(s.bus, MeasurementTopic, m)
 			}
 		}); err != nil {
 			log.Printf("Error listening to Ditto events: %v", err)
@@ -134,6 +73,13 @@ func (s *service) Start(ctx context.Context) error {
 	return nil
 }
 
+// storeMeasurement writes a decoded feature value to InfluxDB
+func (s *service) storeMeasurement(m Measurement) {
+	if err := s.influxDB.WriteEvent(m.ThingID, m.Feature, m.Value, m.Timestamp); err != nil {
+		log.Printf("Failed to store event in InfluxDB: %v", err)
+	}
+}
+
 // Stop stops the Ditto service
 func (s *service) Stop() error {
 	if err := s.client.Close(); err != nil {
@@ -143,21 +89,21 @@ func (s *service) Stop() error {
 }
 
 // GetThing retrieves a thing by its ID
-func (s *service) GetThing(thingID string) (json.RawMessage, error) {
-	return s.client.GetThing(thingID)
+func (s *service) GetThing(ctx context.Context, thingID string) (json.RawMessage, error) {
+	return s.client.GetThing(ctx, thingID)
 }
 
 // CreateThing creates a new thing
-func (s *service) CreateThing(thingID string, thing json.RawMessage) error {
-	return s.client.CreateThing(thingID, thing)
+func (s *service) CreateThing(ctx context.Context, thingID string, thing json.RawMessage) error {
+	return s.client.CreateThing(ctx, thingID, thing)
 }
 
 // UpdateThing updates an existing thing
-func (s *service) UpdateThing(thingID string, thing json.RawMessage) error {
-	return s.client.UpdateThing(thingID, thing)
+func (s *service) UpdateThing(ctx context.Context, thingID string, thing json.RawMessage) error {
+	return s.client.UpdateThing(ctx, thingID, thing)
 }
 
 // DeleteThing deletes a thing
-func (s *service) DeleteThing(thingID string) error {
-	return s.client.DeleteThing(thingID)
+func (s *service) DeleteThing(ctx context.Context, thingID string) error {
+	return s.client.DeleteThing(ctx, thingID)
 }
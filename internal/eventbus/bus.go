@@ -0,0 +1,137 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"ditto/config"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Topic names a stream of events whose payloads are of type T
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic declares a topic, topics are declared next to the type of their payload
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// subscription is a subscriber with its own bounded queue and goroutine, so a
+// slow subscriber only loses its own events
+type subscription struct {
+	topic   string
+	name    string
+	queue   chan any
+	handler func(any)
+}
+
+// Bus is an in-process publish/subscribe bus between the Ditto listener, which
+// publishes what it receives, and the services consuming it. Publishing never
+// blocks: every subscriber gets its own queue and events are dropped for a
+// subscriber whose queue is full.
+type Bus struct {
+	logger    logger.Logger
+	queueSize int
+
+	mu     sync.RWMutex
+	topics map[string][]*subscription
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBus creates the event bus, its subscribers stop with the application
+func NewBus(lc fx.Lifecycle, logger logger.Logger, cfg *config.Config) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		logger:    logger,
+		queueSize: cfg.EventBus.QueueSize,
+		topics:    make(map[string][]*subscription),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	if b.queueSize <= 0 {
+		b.queueSize = 1024
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			b.cancel()
+			b.wg.Wait()
+			return nil
+		},
+	})
+
+	return b
+}
+
+// Subscribe registers a handler for the events of a topic. Handlers of one
+// subscriber are called in publishing order on a goroutine of their own.
+func Subscribe[T any](b *Bus, topic Topic[T], name string, handler func(T)) {
+	sub := &subscription{
+		topic:   topic.name,
+		name:    name,
+		queue:   make(chan any, b.queueSize),
+		handler: func(payload any) { handler(payload.(T)) },
+	}
+
+	b.mu.Lock()
+	b.topics[topic.name] = append(b.topics[topic.name], sub)
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go b.consume(sub)
+}
+
+// Publish passes an event to the subscribers of a topic without blocking
+func Publish[T any](b *Bus, topic Topic[T], payload T) {
+	b.mu.RLock()
+	subs := b.topics[topic.name]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.queue <- payload:
+		default:
+			b.logger.Warn("Event bus queue is full, dropping event",
+				zap.String("topic", sub.topic), zap.String("subscriber", sub.name))
+		}
+	}
+}
+
+// consume feeds the queued events of a subscription to its handler until the bus stops
+func (b *Bus) consume(sub *subscription) {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case payload := <-sub.queue:
+			b.dispatch(sub, payload)
+		}
+	}
+}
+
+// dispatch calls the handler, a panicking handler loses the event but keeps its subscription
+func (b *Bus) dispatch(sub *subscription, payload any) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Error("Event bus subscriber panicked",
+				zap.String("topic", sub.topic), zap.String("subscriber", sub.name), zap.String("panic", fmt.Sprint(r)))
+		}
+	}()
+	sub.handler(payload)
+}
@@ -0,0 +1,117 @@
+package eventbus
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+var testTopic = NewTopic[int]("test.numbers")
+
+func newTestBus(t *testing.T, queueSize int) (*Bus, *fxtest.Lifecycle) {
+	t.Helper()
+	cfg := &config.Config{EventBus: config.EventBusConfig{QueueSize: queueSize}}
+	lc := fxtest.NewLifecycle(t)
+	return NewBus(lc, logger.NewLogger(cfg), cfg), lc
+}
+
+// receive reads n events of a subscriber
+func receive(t *testing.T, events <-chan int, n int) []int {
+	t.Helper()
+	var got []int
+	for len(got) < n {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, got %v", n, got)
+		}
+	}
+	return got
+}
+
+func TestBusDeliversInOrder(t *testing.T) {
+	bus, lc := newTestBus(t, 100)
+	first, second := make(chan int, 100), make(chan int, 100)
+	Subscribe(bus, testTopic, "first", func(n int) { first <- n })
+	Subscribe(bus, testTopic, "second", func(n int) { second <- n })
+	// Subscribers of other topics see nothing of it
+	Subscribe(bus, NewTopic[int]("test.other"), "other", func(n int) { t.Errorf("unexpected event %d", n) })
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	var want []int
+	for n := range 100 {
+		Publish(bus, testTopic, n)
+		want = append(want, n)
+	}
+	for name, events := range map[string]chan int{"first": first, "second": second} {
+		if got := receive(t, events, 100); !slices.Equal(got, want) {
+			t.Errorf("%s: expected the events in publishing order, got %v", name, got)
+		}
+	}
+}
+
+func TestBusDropsEventsOfFullQueues(t *testing.T) {
+	bus, lc := newTestBus(t, 1)
+	release := make(chan struct{})
+	slow, fast := make(chan int, 10), make(chan int, 10)
+	Subscribe(bus, testTopic, "slow", func(n int) {
+		slow <- n
+		<-release
+	})
+	Subscribe(bus, testTopic, "fast", func(n int) { fast <- n })
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	// The slow subscriber blocks on the first event, queues the second and loses the third
+	for n := 1; n <= 3; n++ {
+		Publish(bus, testTopic, n)
+		receive(t, fast, 1)
+		if n == 1 {
+			receive(t, slow, 1)
+		}
+	}
+	close(release)
+
+	if got := receive(t, slow, 1); got[0] != 2 {
+		t.Fatalf("expected the queued event 2, got %d", got[0])
+	}
+	// Publishing did not block, and nothing else is delivered
+	Publish(bus, testTopic, 4)
+	if got := receive(t, slow, 1); got[0] != 4 {
+		t.Errorf("expected event 3 to be dropped, got %d", got[0])
+	}
+}
+
+func TestBusRecoversFromPanics(t *testing.T) {
+	bus, lc := newTestBus(t, 10)
+	events := make(chan int, 10)
+	Subscribe(bus, testTopic, "panicking", func(n int) {
+		if n == 1 {
+			panic("boom")
+		}
+		events <- n
+	})
+	lc.RequireStart()
+
+	Publish(bus, testTopic, 1)
+	Publish(bus, testTopic, 2)
+	if got := receive(t, events, 1); got[0] != 2 {
+		t.Fatalf("expected the subscriber to keep receiving after a panic, got %d", got[0])
+	}
+
+	// Stopping waits for the subscribers, which no longer receive events
+	lc.RequireStop()
+	Publish(bus, testTopic, 3)
+	select {
+	case n := <-events:
+		t.Errorf("expected no event after the bus stopped, got %d", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,9 @@
+package eventbus
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewBus),
+)
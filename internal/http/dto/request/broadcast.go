@@ -0,0 +1,56 @@
+package request
+
+import (
+	"encoding/json"
+	"time"
+
+	"ditto/internal/model"
+)
+
+// BroadcastRequest is the body of POST /api/commands/broadcast
+type BroadcastRequest struct {
+	Filter  model.SearchFilter `json:"filter"`
+	Feature string             `json:"feature" binding:"required"`
+	Command string             `json:"command" binding:"required"`
+	Params  json.RawMessage    `json:"params"`
+	// Timeout is the number of seconds to wait for each device reply
+	Timeout int `json:"timeout" binding:"omitempty,min=0"`
+	// ResponseRequired defaults to true
+	ResponseRequired *bool `json:"response_required"`
+	// Rate is the number of commands sent per second, zero is unlimited
+	Rate        float64        `json:"rate" binding:"omitempty,min=0"`
+	Concurrency int            `json:"concurrency" binding:"omitempty,min=1,max=32"`
+	Canary      *CanaryRequest `json:"canary"`
+}
+
+// CanaryRequest sends to size devices first and continues only if enough of them succeeded
+type CanaryRequest struct {
+	Size            int     `json:"size" binding:"required,min=1"`
+	MinSuccessRatio float64 `json:"min_success_ratio" binding:"required,gt=0,lte=1"`
+}
+
+// Broadcast converts the request to a broadcast
+func (r BroadcastRequest) Broadcast() model.Broadcast {
+	responseRequired := true
+	if r.ResponseRequired != nil {
+		responseRequired = *r.ResponseRequired
+	}
+
+	broadcast := model.Broadcast{
+		Filter:           r.Filter,
+		Feature:          r.Feature,
+		Subject:          r.Command,
+		Params:           r.Params,
+		Timeout:          time.Duration(r.Timeout) * time.Second,
+		ResponseRequired: responseRequired,
+		Rate:             r.Rate,
+		Concurrency:      r.Concurrency,
+	}
+	if r.Canary != nil {
+		broadcast.Canary = &model.BroadcastCanary{
+			Size:            r.Canary.Size,
+			MinSuccessRatio: r.Canary.MinSuccessRatio,
+		}
+	}
+	return broadcast
+}
@@ -0,0 +1,30 @@
+package request
+
+import (
+	"encoding/json"
+
+	"ditto/internal/model"
+)
+
+// BulkUpdateRequest is the body of POST /api/devices/bulk-update
+type BulkUpdateRequest struct {
+	Filter model.SearchFilter `json:"filter"`
+	// Patch is a JSON merge patch applied to every matching thing
+	Patch json.RawMessage `json:"patch" binding:"required"`
+	// All must be set to patch every thing when the filter has no predicates
+	All bool `json:"all"`
+	// ExpectedCount is the count returned by the preview, the update aborts if it changed
+	ExpectedCount *int `json:"expected_count"`
+	Concurrency   int  `json:"concurrency" binding:"omitempty,min=1,max=32"`
+}
+
+// BulkUpdate converts the request to a bulk update
+func (r BulkUpdateRequest) BulkUpdate() model.BulkUpdate {
+	return model.BulkUpdate{
+		Filter:        r.Filter,
+		Patch:         r.Patch,
+		All:           r.All,
+		ExpectedCount: r.ExpectedCount,
+		Concurrency:   r.Concurrency,
+	}
+}
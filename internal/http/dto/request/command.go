@@ -0,0 +1,38 @@
+package request
+
+import (
+	"encoding/json"
+	"time"
+
+	"ditto/internal/model"
+)
+
+// CommandRequest is the body of POST /api/devices/:thingId/features/:feature/command
+type CommandRequest struct {
+	// Command is the message subject delivered to the feature inbox
+	Command string          `json:"command" binding:"required"`
+	Params  json.RawMessage `json:"params"`
+	// Timeout is the number of seconds to wait for the device reply, zero uses the default
+	Timeout int `json:"timeout" binding:"omitempty,min=0"`
+	// Async returns a command ID immediately instead of waiting for the reply
+	Async bool `json:"async"`
+	// ResponseRequired defaults to true, false sends the command fire-and-forget
+	ResponseRequired *bool `json:"response_required"`
+}
+
+// ToCommand converts the request to a command for a thing feature
+func (r CommandRequest) ToCommand(thingID, feature string) model.Command {
+	responseRequired := true
+	if r.ResponseRequired != nil {
+		responseRequired = *r.ResponseRequired
+	}
+
+	return model.Command{
+		ThingID:          thingID,
+		Feature:          feature,
+		Subject:          r.Command,
+		Params:           r.Params,
+		Timeout:          time.Duration(r.Timeout) * time.Second,
+		ResponseRequired: responseRequired,
+	}
+}
@@ -0,0 +1,50 @@
+package request
+
+import (
+	"fmt"
+
+	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
+	"ditto/internal/model"
+)
+
+// DeviceSearchRequest is the body of POST /api/devices/search
+type DeviceSearchRequest struct {
+	model.SearchFilter
+	Sort   []string `json:"sort"`
+	Fields []string `json:"fields"`
+	Size   int      `json:"size" binding:"omitempty,min=1,max=200"`
+	Cursor string   `json:"cursor"`
+}
+
+// Query converts the request to a Ditto search query
+func (r DeviceSearchRequest) Query() (ditto.SearchQuery, error) {
+	query := ditto.SearchQuery{
+		Namespaces: r.Namespaces,
+		Fields:     r.Fields,
+		Size:       r.Size,
+		Cursor:     r.Cursor,
+	}
+
+	filter, err := r.SearchFilter.Expr()
+	if err != nil {
+		return query, err
+	}
+	query.Filter = filter
+
+	for _, key := range r.Sort {
+		sortKey, err := rql.ParseSortKey(key)
+		if err != nil {
+			return query, err
+		}
+		query.Sort = append(query.Sort, sortKey)
+	}
+
+	for _, field := range r.Fields {
+		if err := rql.ValidatePath(field); err != nil {
+			return query, fmt.Errorf("invalid field: %v", err)
+		}
+	}
+
+	return query, nil
+}
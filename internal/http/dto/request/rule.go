@@ -0,0 +1,67 @@
+package request
+
+import (
+	"time"
+
+	"ditto/internal/model"
+)
+
+// RuleRequest is the body of POST and PUT /api/rules
+type RuleRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	// ThingID and Namespace optionally restrict the rule, neither applies it to every thing
+	ThingID   string   `json:"thing_id"`
+	Namespace string   `json:"namespace"`
+	Feature   string   `json:"feature" binding:"required"`
+	Kind      string   `json:"kind" binding:"required,oneof=THRESHOLD RANGE RATE_OF_CHANGE ABSENCE"`
+	Operator  string   `json:"operator" binding:"omitempty,oneof=gt ge lt le"`
+	Threshold float64  `json:"threshold"`
+	Min       *float64 `json:"min"`
+	Max       *float64 `json:"max"`
+	// RatePerMinute is the largest change of the value per minute of a RATE_OF_CHANGE rule
+	RatePerMinute float64 `json:"rate_per_minute"`
+	// Absence is the number of seconds a feature may stay silent under an ABSENCE rule
+	Absence    int     `json:"absence" binding:"omitempty,min=0"`
+	Hysteresis float64 `json:"hysteresis"`
+	// Debounce is the number of seconds a condition must hold before the alert fires
+	Debounce int    `json:"debounce" binding:"omitempty,min=0"`
+	Severity string `json:"severity" binding:"omitempty,oneof=INFO WARNING CRITICAL"`
+	// Recipients are emailed when an alert fires or resolves
+	Recipients []string `json:"recipients"`
+	// EmailSubject and EmailBody are Go templates overriding the default alert email
+	EmailSubject string `json:"email_subject"`
+	EmailBody    string `json:"email_body"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// RuleSpec converts the request to a rule spec
+func (r RuleRequest) RuleSpec() model.RuleSpec {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+
+	return model.RuleSpec{
+		Name:          r.Name,
+		Description:   r.Description,
+		Enabled:       enabled,
+		ThingID:       r.ThingID,
+		Namespace:     r.Namespace,
+		Feature:       r.Feature,
+		Kind:          r.Kind,
+		Operator:      r.Operator,
+		Threshold:     r.Threshold,
+		Min:           r.Min,
+		Max:           r.Max,
+		RatePerMinute: r.RatePerMinute,
+		Absence:       time.Duration(r.Absence) * time.Second,
+		Hysteresis:    r.Hysteresis,
+		Debounce:      time.Duration(r.Debounce) * time.Second,
+		Severity:      r.Severity,
+		Recipients:    r.Recipients,
+		EmailSubject:  r.EmailSubject,
+		EmailBody:     r.EmailBody,
+	}
+}
@@ -0,0 +1,52 @@
+package request
+
+import (
+	"encoding/json"
+	"time"
+
+	"ditto/internal/model"
+)
+
+// ScheduleRequest is the body of POST and PUT /api/schedules
+type ScheduleRequest struct {
+	Name string `json:"name" binding:"required"`
+	Kind string `json:"kind" binding:"required,oneof=ONCE CRON INTERVAL"`
+	// RunAt is the time of a ONCE schedule, or the first run of an INTERVAL schedule
+	RunAt *time.Time `json:"run_at"`
+	Cron  string     `json:"cron"`
+	// Interval is the number of seconds between runs of an INTERVAL schedule
+	Interval    int                 `json:"interval" binding:"omitempty,min=0"`
+	ThingID     string              `json:"thing_id"`
+	Filter      *model.SearchFilter `json:"filter"`
+	Feature     string              `json:"feature" binding:"required"`
+	Command     string              `json:"command" binding:"required"`
+	Params      json.RawMessage     `json:"params"`
+	Timeout     int                 `json:"timeout" binding:"omitempty,min=0"`
+	MaxAttempts int                 `json:"max_attempts" binding:"omitempty,min=0"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// ScheduleSpec converts the request to a schedule spec
+func (r ScheduleRequest) ScheduleSpec() model.ScheduleSpec {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+
+	return model.ScheduleSpec{
+		Name:        r.Name,
+		Kind:        r.Kind,
+		RunAt:       r.RunAt,
+		Cron:        r.Cron,
+		Interval:    time.Duration(r.Interval) * time.Second,
+		ThingID:     r.ThingID,
+		Filter:      r.Filter,
+		Feature:     r.Feature,
+		Subject:     r.Command,
+		Params:      r.Params,
+		Timeout:     time.Duration(r.Timeout) * time.Second,
+		MaxAttempts: r.MaxAttempts,
+		Enabled:     enabled,
+	}
+}
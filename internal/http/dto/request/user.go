@@ -0,0 +1,15 @@
+package request
+
+// UserRequest is the body of POST /api/users
+type UserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+	// Roles are names defined in RBAC_ROLES or permission names
+	Roles []string `json:"roles"`
+}
+
+// UserRolesRequest is the body of PUT /api/users/:userId/roles
+type UserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
@@ -0,0 +1,36 @@
+package request
+
+import "ditto/internal/model"
+
+// WebhookRequest is the body of POST and PUT /api/webhooks
+type WebhookRequest struct {
+	Name string `json:"name" binding:"required"`
+	URL  string `json:"url" binding:"required,url"`
+	// Secret signs the deliveries. It is generated on create when empty and kept on update when empty.
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types" binding:"required,min=1"`
+	ThingID    string   `json:"thing_id"`
+	Namespace  string   `json:"namespace"`
+	Feature    string   `json:"feature"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// WebhookSpec converts the request to a webhook spec
+func (r WebhookRequest) WebhookSpec() model.WebhookSpec {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+
+	return model.WebhookSpec{
+		Name:       r.Name,
+		URL:        r.URL,
+		Secret:     r.Secret,
+		Enabled:    enabled,
+		EventTypes: r.EventTypes,
+		ThingID:    r.ThingID,
+		Namespace:  r.Namespace,
+		Feature:    r.Feature,
+	}
+}
@@ -0,0 +1,24 @@
+package response
+
+import "ditto/internal/model"
+
+// TokenResponse is the token pair returned by a login or refresh
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn and RefreshExpiresIn are the lifetimes of the tokens in seconds
+	ExpiresIn        int64 `json:"expires_in"`
+	RefreshExpiresIn int64 `json:"refresh_expires_in"`
+}
+
+// NewTokenResponse creates a response from a token pair
+func NewTokenResponse(pair *model.TokenPair) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:      pair.AccessToken,
+		RefreshToken:     pair.RefreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(pair.ExpiresIn.Seconds()),
+		RefreshExpiresIn: int64(pair.RefreshExpiresIn.Seconds()),
+	}
+}
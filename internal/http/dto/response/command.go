@@ -0,0 +1,66 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// CommandResponse is the API representation of a command of the history
+type CommandResponse struct {
+	ID               string      `json:"id"`
+	ThingID          string      `json:"thing_id"`
+	Feature          string      `json:"feature"`
+	Subject          string      `json:"subject"`
+	Params           entity.JSON `json:"params,omitempty"`
+	CorrelationID    string      `json:"correlation_id"`
+	Async            bool        `json:"async"`
+	ResponseRequired bool        `json:"response_required"`
+	TimeoutMs        int64       `json:"timeout_ms"`
+	Status           string      `json:"status"`
+	ResponseStatus   int         `json:"response_status,omitempty"`
+	Response         entity.JSON `json:"response,omitempty"`
+	Error            string      `json:"error,omitempty"`
+	SentBy           string      `json:"sent_by,omitempty"`
+	SentAt           time.Time   `json:"sent_at"`
+	CompletedAt      *time.Time  `json:"completed_at,omitempty"`
+	LatencyMs        int64       `json:"latency_ms"`
+}
+
+// CommandListResponse is a page of the command history
+type CommandListResponse struct {
+	Items []*CommandResponse `json:"items"`
+	Total int64              `json:"total"`
+}
+
+// NewCommandResponse converts a command entity to its API representation
+func NewCommandResponse(command *entity.Command) *CommandResponse {
+	return &CommandResponse{
+		ID:               command.ID,
+		ThingID:          command.ThingID,
+		Feature:          command.Feature,
+		Subject:          command.Subject,
+		Params:           command.Params,
+		CorrelationID:    command.CorrelationID,
+		Async:            command.Async,
+		ResponseRequired: command.ResponseRequired,
+		TimeoutMs:        command.TimeoutMs,
+		Status:           command.Status,
+		ResponseStatus:   command.ResponseStatus,
+		Response:         command.Response,
+		Error:            command.Error,
+		SentBy:           command.CreatedBy,
+		SentAt:           command.SentAt,
+		CompletedAt:      command.CompletedAt,
+		LatencyMs:        command.LatencyMs,
+	}
+}
+
+// NewCommandListResponse converts a page of command entities
+func NewCommandListResponse(commands []*entity.Command, total int64) *CommandListResponse {
+	items := make([]*CommandResponse, len(commands))
+	for i, command := range commands {
+		items[i] = NewCommandResponse(command)
+	}
+	return &CommandListResponse{Items: items, Total: total}
+}
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+)
+
+// ConnectivityResponse is the API representation of the connectivity of a device
+type ConnectivityResponse struct {
+	ThingID    string     `json:"thing_id"`
+	Definition string     `json:"definition,omitempty"`
+	Online     bool       `json:"online"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ChangedAt  time.Time  `json:"changed_at"`
+	ReadySince *time.Time `json:"ready_since,omitempty"`
+	ReadyUntil *time.Time `json:"ready_until,omitempty"`
+	// OfflineTimeout is the number of seconds without events after which the device is offline
+	OfflineTimeout int64 `json:"offline_timeout"`
+}
+
+// ConnectivityListResponse is a page of device connectivity
+type ConnectivityListResponse struct {
+	Items []*ConnectivityResponse `json:"items"`
+	Total int64                   `json:"total"`
+}
+
+// ConnectivitySummaryResponse counts online and offline devices of the fleet
+type ConnectivitySummaryResponse struct {
+	Total        int64                     `json:"total"`
+	Online       int64                     `json:"online"`
+	Offline      int64                     `json:"offline"`
+	ByDefinition []model.ConnectivityCount `json:"by_definition"`
+}
+
+// NewConnectivityResponse converts a connectivity entity to its API representation
+func NewConnectivityResponse(connectivity *entity.Connectivity, timeout time.Duration) *ConnectivityResponse {
+	return &ConnectivityResponse{
+		ThingID:        connectivity.ThingID,
+		Definition:     connectivity.Definition,
+		Online:         connectivity.Online,
+		LastSeenAt:     connectivity.LastSeenAt,
+		ChangedAt:      connectivity.ChangedAt,
+		ReadySince:     connectivity.ReadySince,
+		ReadyUntil:     connectivity.ReadyUntil,
+		OfflineTimeout: int64(timeout / time.Second),
+	}
+}
+
+// NewConnectivitySummaryResponse sums the per-definition counts
+func NewConnectivitySummaryResponse(counts []model.ConnectivityCount) *ConnectivitySummaryResponse {
+	summary := &ConnectivitySummaryResponse{ByDefinition: counts}
+	for _, count := range counts {
+		summary.Online += count.Online
+		summary.Offline += count.Offline
+	}
+	summary.Total = summary.Online + summary.Offline
+	return summary
+}
@@ -0,0 +1,11 @@
+package response
+
+import "encoding/json"
+
+// DeviceSearchResponse is the data of a device search result
+type DeviceSearchResponse struct {
+	Items []json.RawMessage `json:"items"`
+	Total int               `json:"total"`
+	// Cursor continues the search with the same request, it is empty on the last page
+	Cursor string `json:"cursor,omitempty"`
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+)
+
+// DriftResponse is the API representation of the drift of a feature
+type DriftResponse struct {
+	ThingID       string      `json:"thing_id"`
+	Feature       string      `json:"feature"`
+	Status        string      `json:"status"`
+	Desired       entity.JSON `json:"desired,omitempty"`
+	Reported      entity.JSON `json:"reported,omitempty"`
+	DetectedAt    time.Time   `json:"detected_at"`
+	CheckedAt     time.Time   `json:"checked_at"`
+	ConvergedAt   *time.Time  `json:"converged_at,omitempty"`
+	Resends       int         `json:"resends"`
+	LastResentAt  *time.Time  `json:"last_resent_at,omitempty"`
+	LastCommandID string      `json:"last_command_id,omitempty"`
+}
+
+// DeviceDriftResponse is the drift state of all features of a device
+type DeviceDriftResponse struct {
+	ThingID  string           `json:"thing_id"`
+	InSync   bool             `json:"in_sync"`
+	Features []*DriftResponse `json:"features"`
+}
+
+// DriftListResponse is a page of drifts across the fleet
+type DriftListResponse struct {
+	Items []*DriftResponse `json:"items"`
+	Total int64            `json:"total"`
+}
+
+// NewDriftResponse converts a drift entity to its API representation
+func NewDriftResponse(drift *entity.Drift) *DriftResponse {
+	return &DriftResponse{
+		ThingID:       drift.ThingID,
+		Feature:       drift.Feature,
+		Status:        drift.Status,
+		Desired:       drift.Desired,
+		Reported:      drift.Reported,
+		DetectedAt:    drift.DetectedAt,
+		CheckedAt:     drift.CheckedAt,
+		ConvergedAt:   drift.ConvergedAt,
+		Resends:       drift.Resends,
+		LastResentAt:  drift.LastResentAt,
+		LastCommandID: drift.LastCommandID,
+	}
+}
+
+// NewDeviceDriftResponse summarizes the drifts of a device, it is in sync when no feature drifts
+func NewDeviceDriftResponse(thingID string, drifts []*entity.Drift) *DeviceDriftResponse {
+	resp := &DeviceDriftResponse{
+		ThingID:  thingID,
+		InSync:   true,
+		Features: make([]*DriftResponse, len(drifts)),
+	}
+	for i, drift := range drifts {
+		resp.Features[i] = NewDriftResponse(drift)
+		if drift.Status != enum.DriftConverged {
+			resp.InSync = false
+		}
+	}
+	return resp
+}
+
+// NewDriftListResponse converts a page of drift entities
+func NewDriftListResponse(drifts []*entity.Drift, total int64) *DriftListResponse {
+	items := make([]*DriftResponse, len(drifts))
+	for i, drift := range drifts {
+		items[i] = NewDriftResponse(drift)
+	}
+	return &DriftListResponse{Items: items, Total: total}
+}
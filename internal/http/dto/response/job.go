@@ -0,0 +1,53 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// JobResponse is the API representation of a background job
+type JobResponse struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	Status      string      `json:"status"`
+	Progress    int         `json:"progress"`
+	Total       int         `json:"total"`
+	Attempts    int         `json:"attempts"`
+	MaxAttempts int         `json:"max_attempts"`
+	Error       string      `json:"error,omitempty"`
+	Payload     entity.JSON `json:"payload,omitempty"`
+	Result      entity.JSON `json:"result,omitempty"`
+	CreatedBy   string      `json:"created_by,omitempty"`
+	CreatedAt   *time.Time  `json:"created_at,omitempty"`
+	RunAfter    *time.Time  `json:"run_after,omitempty"`
+	StartedAt   *time.Time  `json:"started_at,omitempty"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+}
+
+// JobListResponse is a page of jobs
+type JobListResponse struct {
+	Items []*JobResponse `json:"items"`
+	Total int64          `json:"total"`
+}
+
+// NewJobResponse converts a job entity to its API representation
+func NewJobResponse(job *entity.Job) *JobResponse {
+	return &JobResponse{
+		ID:          job.ID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Progress:    job.Progress,
+		Total:       job.Total,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		Error:       job.Error,
+		Payload:     job.Payload,
+		Result:      job.Result,
+		CreatedBy:   job.CreatedBy,
+		CreatedAt:   job.CreatedAt,
+		RunAfter:    job.RunAfter,
+		StartedAt:   job.StartedAt,
+		FinishedAt:  job.FinishedAt,
+	}
+}
@@ -0,0 +1,129 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// RuleResponse is the API representation of an alert rule
+type RuleResponse struct {
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description,omitempty"`
+	Enabled       bool        `json:"enabled"`
+	ThingID       string      `json:"thing_id,omitempty"`
+	Namespace     string      `json:"namespace,omitempty"`
+	Feature       string      `json:"feature"`
+	Kind          string      `json:"kind"`
+	Operator      string      `json:"operator,omitempty"`
+	Threshold     float64     `json:"threshold"`
+	Min           *float64    `json:"min,omitempty"`
+	Max           *float64    `json:"max,omitempty"`
+	RatePerMinute float64     `json:"rate_per_minute,omitempty"`
+	Absence       int64       `json:"absence,omitempty"`
+	Hysteresis    float64     `json:"hysteresis"`
+	Debounce      int64       `json:"debounce"`
+	Severity      string      `json:"severity"`
+	Recipients    entity.JSON `json:"recipients,omitempty"`
+	EmailSubject  string      `json:"email_subject,omitempty"`
+	EmailBody     string      `json:"email_body,omitempty"`
+	CreatedBy     string      `json:"created_by,omitempty"`
+	CreatedAt     *time.Time  `json:"created_at,omitempty"`
+	UpdatedBy     string      `json:"updated_by,omitempty"`
+	UpdatedAt     *time.Time  `json:"updated_at,omitempty"`
+}
+
+// RuleListResponse is a page of alert rules
+type RuleListResponse struct {
+	Items []*RuleResponse `json:"items"`
+	Total int64           `json:"total"`
+}
+
+// AlertResponse is the API representation of an alert
+type AlertResponse struct {
+	ID             string     `json:"id"`
+	RuleID         string     `json:"rule_id"`
+	ThingID        string     `json:"thing_id"`
+	Feature        string     `json:"feature"`
+	Severity       string     `json:"severity"`
+	Status         string     `json:"status"`
+	Value          *float64   `json:"value,omitempty"`
+	Message        string     `json:"message"`
+	FiredAt        time.Time  `json:"fired_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+}
+
+// AlertListResponse is a page of alerts
+type AlertListResponse struct {
+	Items []*AlertResponse `json:"items"`
+	Total int64            `json:"total"`
+}
+
+// NewRuleResponse converts a rule entity to its API representation
+func NewRuleResponse(rule *entity.Rule) *RuleResponse {
+	return &RuleResponse{
+		ID:            rule.ID,
+		Name:          rule.Name,
+		Description:   rule.Description,
+		Enabled:       rule.Enabled,
+		ThingID:       rule.ThingID,
+		Namespace:     rule.Namespace,
+		Feature:       rule.Feature,
+		Kind:          rule.Kind,
+		Operator:      rule.Operator,
+		Threshold:     rule.Threshold,
+		Min:           rule.Min,
+		Max:           rule.Max,
+		RatePerMinute: rule.RatePerMinute,
+		Absence:       rule.AbsenceSeconds,
+		Hysteresis:    rule.Hysteresis,
+		Debounce:      rule.DebounceSeconds,
+		Severity:      rule.Severity,
+		Recipients:    rule.Recipients,
+		EmailSubject:  rule.EmailSubject,
+		EmailBody:     rule.EmailBody,
+		CreatedBy:     rule.CreatedBy,
+		CreatedAt:     rule.CreatedAt,
+		UpdatedBy:     rule.UpdatedBy,
+		UpdatedAt:     rule.UpdatedAt,
+	}
+}
+
+// NewRuleListResponse converts a page of rule entities
+func NewRuleListResponse(rules []*entity.Rule, total int64) *RuleListResponse {
+	items := make([]*RuleResponse, len(rules))
+	for i, rule := range rules {
+		items[i] = NewRuleResponse(rule)
+	}
+	return &RuleListResponse{Items: items, Total: total}
+}
+
+// NewAlertResponse converts an alert entity to its API representation
+func NewAlertResponse(alert *entity.Alert) *AlertResponse {
+	return &AlertResponse{
+		ID:             alert.ID,
+		RuleID:         alert.RuleID,
+		ThingID:        alert.ThingID,
+		Feature:        alert.Feature,
+		Severity:       alert.Severity,
+		Status:         alert.Status,
+		Value:          alert.Value,
+		Message:        alert.Message,
+		FiredAt:        alert.FiredAt,
+		ResolvedAt:     alert.ResolvedAt,
+		AcknowledgedAt: alert.AcknowledgedAt,
+		AcknowledgedBy: alert.AcknowledgedBy,
+	}
+}
+
+// NewAlertListResponse converts a page of alert entities
+func NewAlertListResponse(alerts []*entity.Alert, total int64) *AlertListResponse {
+	items := make([]*AlertResponse, len(alerts))
+	for i, alert := range alerts {
+		items[i] = NewAlertResponse(alert)
+	}
+	return &AlertListResponse{Items: items, Total: total}
+}
@@ -0,0 +1,119 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// ScheduleResponse is the API representation of a schedule
+type ScheduleResponse struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Kind        string      `json:"kind"`
+	RunAt       *time.Time  `json:"run_at,omitempty"`
+	Cron        string      `json:"cron,omitempty"`
+	Interval    int64       `json:"interval,omitempty"`
+	ThingID     string      `json:"thing_id,omitempty"`
+	Filter      entity.JSON `json:"filter,omitempty"`
+	Feature     string      `json:"feature"`
+	Command     string      `json:"command"`
+	Params      entity.JSON `json:"params,omitempty"`
+	TimeoutMs   int64       `json:"timeout_ms"`
+	MaxAttempts int         `json:"max_attempts"`
+	Enabled     bool        `json:"enabled"`
+	NextRunAt   *time.Time  `json:"next_run_at,omitempty"`
+	LastRunAt   *time.Time  `json:"last_run_at,omitempty"`
+	CreatedBy   string      `json:"created_by,omitempty"`
+	CreatedAt   *time.Time  `json:"created_at,omitempty"`
+	UpdatedBy   string      `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time  `json:"updated_at,omitempty"`
+}
+
+// ScheduleListResponse is a page of schedules
+type ScheduleListResponse struct {
+	Items []*ScheduleResponse `json:"items"`
+	Total int64               `json:"total"`
+}
+
+// ScheduleExecutionResponse is the API representation of a schedule run
+type ScheduleExecutionResponse struct {
+	ID         string      `json:"id"`
+	ScheduleID string      `json:"schedule_id"`
+	JobID      string      `json:"job_id"`
+	Status     string      `json:"status"`
+	Matched    int         `json:"matched"`
+	Succeeded  int         `json:"succeeded"`
+	Failed     int         `json:"failed"`
+	Results    entity.JSON `json:"results,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+}
+
+// ScheduleExecutionListResponse is a page of schedule runs
+type ScheduleExecutionListResponse struct {
+	Items []*ScheduleExecutionResponse `json:"items"`
+	Total int64                        `json:"total"`
+}
+
+// NewScheduleResponse converts a schedule entity to its API representation
+func NewScheduleResponse(schedule *entity.Schedule) *ScheduleResponse {
+	return &ScheduleResponse{
+		ID:          schedule.ID,
+		Name:        schedule.Name,
+		Kind:        schedule.Kind,
+		RunAt:       schedule.RunAt,
+		Cron:        schedule.Cron,
+		Interval:    schedule.IntervalSeconds,
+		ThingID:     schedule.ThingID,
+		Filter:      schedule.Filter,
+		Feature:     schedule.Feature,
+		Command:     schedule.Subject,
+		Params:      schedule.Params,
+		TimeoutMs:   schedule.TimeoutMs,
+		MaxAttempts: schedule.MaxAttempts,
+		Enabled:     schedule.Enabled,
+		NextRunAt:   schedule.NextRunAt,
+		LastRunAt:   schedule.LastRunAt,
+		CreatedBy:   schedule.CreatedBy,
+		CreatedAt:   schedule.CreatedAt,
+		UpdatedBy:   schedule.UpdatedBy,
+		UpdatedAt:   schedule.UpdatedAt,
+	}
+}
+
+// NewScheduleListResponse converts a page of schedule entities
+func NewScheduleListResponse(schedules []*entity.Schedule, total int64) *ScheduleListResponse {
+	items := make([]*ScheduleResponse, len(schedules))
+	for i, schedule := range schedules {
+		items[i] = NewScheduleResponse(schedule)
+	}
+	return &ScheduleListResponse{Items: items, Total: total}
+}
+
+// NewScheduleExecutionResponse converts a schedule execution entity to its API representation
+func NewScheduleExecutionResponse(execution *entity.ScheduleExecution) *ScheduleExecutionResponse {
+	return &ScheduleExecutionResponse{
+		ID:         execution.ID,
+		ScheduleID: execution.ScheduleID,
+		JobID:      execution.JobID,
+		Status:     execution.Status,
+		Matched:    execution.Matched,
+		Succeeded:  execution.Succeeded,
+		Failed:     execution.Failed,
+		Results:    execution.Results,
+		Error:      execution.Error,
+		StartedAt:  execution.StartedAt,
+		FinishedAt: execution.FinishedAt,
+	}
+}
+
+// NewScheduleExecutionListResponse converts a page of schedule execution entities
+func NewScheduleExecutionListResponse(executions []*entity.ScheduleExecution, total int64) *ScheduleExecutionListResponse {
+	items := make([]*ScheduleExecutionResponse, len(executions))
+	for i, execution := range executions {
+		items[i] = NewScheduleExecutionResponse(execution)
+	}
+	return &ScheduleExecutionListResponse{Items: items, Total: total}
+}
@@ -0,0 +1,55 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// UserResponse is the API representation of a user, without its password hash
+type UserResponse struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	Roles       []string   `json:"roles"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedBy   string     `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// UserListResponse is a page of users
+type UserListResponse struct {
+	Items []*UserResponse `json:"items"`
+	Total int64           `json:"total"`
+}
+
+// NewUserResponse converts a user entity to its API representation
+func NewUserResponse(user *entity.User) *UserResponse {
+	roles := []string{}
+	_ = user.Roles.Decode(&roles)
+
+	return &UserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		Enabled:     user.Enabled,
+		Roles:       roles,
+		LastLoginAt: user.LastLoginAt,
+		CreatedBy:   user.CreatedBy,
+		CreatedAt:   user.CreatedAt,
+		UpdatedBy:   user.UpdatedBy,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// NewUserListResponse converts a page of users
+func NewUserListResponse(users []*entity.User, total int64) *UserListResponse {
+	items := make([]*UserResponse, len(users))
+	for i, user := range users {
+		items[i] = NewUserResponse(user)
+	}
+	return &UserListResponse{Items: items, Total: total}
+}
@@ -0,0 +1,111 @@
+package response
+
+import (
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// WebhookResponse is the API representation of a webhook
+type WebhookResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Secret is only returned when the webhook is created
+	Secret     string      `json:"secret,omitempty"`
+	Enabled    bool        `json:"enabled"`
+	EventTypes entity.JSON `json:"event_types"`
+	ThingID    string      `json:"thing_id,omitempty"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Feature    string      `json:"feature,omitempty"`
+	CreatedBy  string      `json:"created_by,omitempty"`
+	CreatedAt  *time.Time  `json:"created_at,omitempty"`
+	UpdatedBy  string      `json:"updated_by,omitempty"`
+	UpdatedAt  *time.Time  `json:"updated_at,omitempty"`
+}
+
+// WebhookListResponse is a page of webhooks
+type WebhookListResponse struct {
+	Items []*WebhookResponse `json:"items"`
+	Total int64              `json:"total"`
+}
+
+// WebhookDeliveryResponse is the API representation of a webhook delivery
+type WebhookDeliveryResponse struct {
+	ID             string      `json:"id"`
+	WebhookID      string      `json:"webhook_id"`
+	EventID        string      `json:"event_id"`
+	EventType      string      `json:"event_type"`
+	ThingID        string      `json:"thing_id,omitempty"`
+	Payload        entity.JSON `json:"payload"`
+	Status         string      `json:"status"`
+	Attempts       int         `json:"attempts"`
+	NextAttemptAt  *time.Time  `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time  `json:"last_attempt_at,omitempty"`
+	ResponseStatus int         `json:"response_status,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	DeliveredAt    *time.Time  `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// WebhookDeliveryListResponse is a page of webhook deliveries
+type WebhookDeliveryListResponse struct {
+	Items []*WebhookDeliveryResponse `json:"items"`
+	Total int64                      `json:"total"`
+}
+
+// NewWebhookResponse converts a webhook entity to its API representation, without its secret
+func NewWebhookResponse(webhook *entity.Webhook) *WebhookResponse {
+	return &WebhookResponse{
+		ID:         webhook.ID,
+		Name:       webhook.Name,
+		URL:        webhook.URL,
+		Enabled:    webhook.Enabled,
+		EventTypes: webhook.EventTypes,
+		ThingID:    webhook.ThingID,
+		Namespace:  webhook.Namespace,
+		Feature:    webhook.Feature,
+		CreatedBy:  webhook.CreatedBy,
+		CreatedAt:  webhook.CreatedAt,
+		UpdatedBy:  webhook.UpdatedBy,
+		UpdatedAt:  webhook.UpdatedAt,
+	}
+}
+
+// NewWebhookListResponse converts a page of webhook entities
+func NewWebhookListResponse(webhooks []*entity.Webhook, total int64) *WebhookListResponse {
+	items := make([]*WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		items[i] = NewWebhookResponse(webhook)
+	}
+	return &WebhookListResponse{Items: items, Total: total}
+}
+
+// NewWebhookDeliveryResponse converts a webhook delivery entity to its API representation
+func NewWebhookDeliveryResponse(delivery *entity.WebhookDelivery) *WebhookDeliveryResponse {
+	return &WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		ThingID:        delivery.ThingID,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+// NewWebhookDeliveryListResponse converts a page of webhook delivery entities
+func NewWebhookDeliveryListResponse(deliveries []*entity.WebhookDelivery, total int64) *WebhookDeliveryListResponse {
+	items := make([]*WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		items[i] = NewWebhookDeliveryResponse(delivery)
+	}
+	return &WebhookDeliveryListResponse{Items: items, Total: total}
+}
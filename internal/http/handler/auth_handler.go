@@ -0,0 +1,110 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	authService *service.AuthService
+}
+
+// NewAuthHandler creates a new AuthHandler
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+// Login handles POST /api/auth/login
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req request.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid login: %v", err)),
+		))
+		return
+	}
+
+	pair, err := h.authService.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		if stderrors.Is(err, service.ErrInvalidCredentials) {
+			log.Printf("Failed login for user: %s", req.Username)
+		}
+		respondAuthError(c, err)
+		return
+	}
+
+	log.Printf("User %s logged in", req.Username)
+	wrapper.JSONOk(c, response.NewTokenResponse(pair))
+}
+
+// Refresh handles POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req request.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid refresh request: %v", err)),
+		))
+		return
+	}
+
+	pair, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		respondAuthError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewTokenResponse(pair))
+}
+
+// Logout handles POST /api/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req request.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid logout request: %v", err)),
+		))
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		respondAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondAuthError maps auth service errors to HTTP responses
+func respondAuthError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrInvalidCredentials), stderrors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, wrapper.NewErrorResponse(
+			errors.NewUnauthorizedError(err.Error()),
+		))
+	case stderrors.Is(err, service.ErrAuthNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, wrapper.NewResponse(
+			http.StatusServiceUnavailable,
+			constant.IllegalStateError,
+			nil,
+			err.Error(),
+		))
+	default:
+		log.Printf("Auth request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError("Auth request failed"),
+		))
+	}
+}
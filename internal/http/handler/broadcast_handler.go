@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BroadcastHandler struct {
+	broadcastService *service.BroadcastService
+}
+
+// NewBroadcastHandler creates a new BroadcastHandler
+func NewBroadcastHandler(broadcastService *service.BroadcastService) *BroadcastHandler {
+	return &BroadcastHandler{
+		broadcastService: broadcastService,
+	}
+}
+
+// Broadcast handles POST /api/commands/broadcast
+func (h *BroadcastHandler) Broadcast(c *gin.Context) {
+	var req request.BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid broadcast request: %v", err)),
+		))
+		return
+	}
+
+	broadcast := req.Broadcast()
+	if err := h.broadcastService.Validate(broadcast); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	job, err := h.broadcastService.Start(c.Request.Context(), broadcast, c.GetString("username"))
+	if err != nil {
+		log.Printf("Failed to start broadcast job: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to start broadcast: %v", err)),
+		))
+		return
+	}
+
+	log.Printf("Started broadcast job %s of %s/%s", job.ID, broadcast.Feature, broadcast.Subject)
+
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewJobResponse(job),
+		"Broadcast started",
+	))
+}
+
+// GetBroadcast handles GET /api/commands/broadcast/:jobId
+func (h *BroadcastHandler) GetBroadcast(c *gin.Context) {
+	job, err := h.broadcastService.Get(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewJobResponse(job))
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/model"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BulkUpdateHandler struct {
+	bulkUpdateService *service.BulkUpdateService
+}
+
+// NewBulkUpdateHandler creates a new BulkUpdateHandler
+func NewBulkUpdateHandler(bulkUpdateService *service.BulkUpdateService) *BulkUpdateHandler {
+	return &BulkUpdateHandler{
+		bulkUpdateService: bulkUpdateService,
+	}
+}
+
+// Preview handles POST /api/devices/bulk-update/preview
+func (h *BulkUpdateHandler) Preview(c *gin.Context) {
+	var filter model.SearchFilter
+	if err := c.ShouldBindJSON(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid filter: %v", err)),
+		))
+		return
+	}
+
+	if _, err := filter.Expr(); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	preview, err := h.bulkUpdateService.Preview(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("Failed to preview bulk update: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to preview bulk update: %v", err)),
+		))
+		return
+	}
+
+	wrapper.JSONOk(c, preview)
+}
+
+// Apply handles POST /api/devices/bulk-update
+func (h *BulkUpdateHandler) Apply(c *gin.Context) {
+	var req request.BulkUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid bulk update request: %v", err)),
+		))
+		return
+	}
+
+	update := req.BulkUpdate()
+	if err := h.bulkUpdateService.Validate(update); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	job, err := h.bulkUpdateService.Start(c.Request.Context(), update, c.GetString("username"))
+	if err != nil {
+		log.Printf("Failed to start bulk update job: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to start bulk update: %v", err)),
+		))
+		return
+	}
+
+	log.Printf("Started bulk update job %s", job.ID)
+
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewJobResponse(job),
+		"Bulk update started",
+	))
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ditto/internal/ditto"
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommandHandler struct {
+	commandService *service.CommandService
+}
+
+// NewCommandHandler creates a new CommandHandler
+func NewCommandHandler(commandService *service.CommandService) *CommandHandler {
+	return &CommandHandler{
+		commandService: commandService,
+	}
+}
+
+// SendCommand handles POST /api/devices/:thingId/features/:feature/command
+func (h *CommandHandler) SendCommand(c *gin.Context) {
+	thingID := c.Param("thingId")
+	feature := c.Param("feature")
+
+	if err := ditto.ValidateEntityID(thingID); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	var req request.CommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid command request: %v", err)),
+		))
+		return
+	}
+
+	cmd := req.ToCommand(thingID, feature)
+	user := c.GetString("username")
+	log.Printf("Sending command %s to %s/%s for %s", cmd.Subject, thingID, feature, user)
+
+	if req.Async {
+		command, err := h.commandService.SendAsync(c.Request.Context(), cmd, user)
+		if err != nil {
+			respondCommandError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, wrapper.NewResponse(
+			http.StatusAccepted,
+			constant.Processing,
+			response.NewCommandResponse(command),
+			"Command accepted",
+		))
+		return
+	}
+
+	command, err := h.commandService.Send(c.Request.Context(), cmd, user)
+	if err != nil {
+		respondCommandError(c, err)
+		return
+	}
+	respondCommandOutcome(c, command)
+}
+
+// GetCommand handles GET /api/commands/:commandId
+func (h *CommandHandler) GetCommand(c *gin.Context) {
+	command, err := h.commandService.Get(c.Request.Context(), c.Param("commandId"))
+	if err != nil {
+		respondCommandError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewCommandResponse(command))
+}
+
+// ListCommands handles GET /api/commands, filtered by thing_id, feature, user, status, from and to
+func (h *CommandHandler) ListCommands(c *gin.Context) {
+	filter, err := commandFilterFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+	filter.ThingID = c.Query("thing_id")
+
+	h.listCommands(c, filter)
+}
+
+// ListDeviceCommands handles GET /api/devices/:thingId/commands
+func (h *CommandHandler) ListDeviceCommands(c *gin.Context) {
+	filter, err := commandFilterFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+	filter.ThingID = c.Param("thingId")
+
+	h.listCommands(c, filter)
+}
+
+func (h *CommandHandler) listCommands(c *gin.Context, filter repository.CommandFilter) {
+	commands, total, err := h.commandService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondCommandError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewCommandListResponse(commands, total))
+}
+
+// commandFilterFromRequest reads the history filters and paging shared by the listing endpoints
+func commandFilterFromRequest(c *gin.Context) (repository.CommandFilter, error) {
+	limit, offset := pageFromRequest(c)
+	filter := repository.CommandFilter{
+		Feature: c.Query("feature"),
+		User:    c.Query("user"),
+		Status:  c.Query("status"),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s, expected RFC3339 time: %s", param, value)
+		}
+		*target = &t
+	}
+
+	return filter, nil
+}
+
+// respondCommandOutcome maps the outcome of a synchronous command to an HTTP status
+func respondCommandOutcome(c *gin.Context, command *entity.Command) {
+	switch command.Status {
+	case enum.CommandSucceeded, enum.CommandSent:
+		wrapper.JSONOk(c, response.NewCommandResponse(command))
+	case enum.CommandTimeout:
+		c.JSON(http.StatusGatewayTimeout, wrapper.NewResponse(
+			http.StatusGatewayTimeout,
+			constant.InternalServerError,
+			response.NewCommandResponse(command),
+			command.Error,
+		))
+	default:
+		c.JSON(http.StatusBadGateway, wrapper.NewResponse(
+			http.StatusBadGateway,
+			constant.InternalServerError,
+			response.NewCommandResponse(command),
+			command.Error,
+		))
+	}
+}
+
+// respondCommandError maps command history errors to API error responses
+func respondCommandError(c *gin.Context, err error) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("Command not found"),
+		))
+		return
+	}
+
+	log.Printf("Command request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("Command request failed: %v", err)),
+	))
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ditto/internal/http/dto/response"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConnectivityHandler struct {
+	connectivityService *service.ConnectivityService
+}
+
+// NewConnectivityHandler creates a new ConnectivityHandler
+func NewConnectivityHandler(connectivityService *service.ConnectivityService) *ConnectivityHandler {
+	return &ConnectivityHandler{
+		connectivityService: connectivityService,
+	}
+}
+
+// GetDeviceConnectivity handles GET /api/devices/:thingId/connectivity
+func (h *ConnectivityHandler) GetDeviceConnectivity(c *gin.Context) {
+	connectivity, err := h.connectivityService.Get(c.Request.Context(), c.Param("thingId"))
+	if err != nil {
+		respondConnectivityError(c, err)
+		return
+	}
+
+	timeout := h.connectivityService.Timeout(connectivity.Definition)
+	wrapper.JSONOk(c, response.NewConnectivityResponse(connectivity, timeout))
+}
+
+// ListConnectivity handles GET /api/devices/connectivity
+func (h *ConnectivityHandler) ListConnectivity(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	filter := repository.ConnectivityFilter{
+		Definition: c.Query("definition"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	if value := c.Query("online"); value != "" {
+		online, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+				errors.NewBadRequestError(fmt.Sprintf("Invalid online: %s", value)),
+			))
+			return
+		}
+		filter.Online = &online
+	}
+
+	rows, total, err := h.connectivityService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondConnectivityError(c, err)
+		return
+	}
+
+	items := make([]*response.ConnectivityResponse, len(rows))
+	for i, row := range rows {
+		items[i] = response.NewConnectivityResponse(row, h.connectivityService.Timeout(row.Definition))
+	}
+	wrapper.JSONOk(c, response.ConnectivityListResponse{
+		Items: items,
+		Total: total,
+	})
+}
+
+// GetConnectivitySummary handles GET /api/devices/connectivity/summary
+func (h *ConnectivityHandler) GetConnectivitySummary(c *gin.Context) {
+	counts, err := h.connectivityService.Counts(c.Request.Context())
+	if err != nil {
+		respondConnectivityError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewConnectivitySummaryResponse(counts))
+}
+
+// respondConnectivityError maps connectivity errors to API error responses
+func respondConnectivityError(c *gin.Context, err error) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("Device has not been seen yet"),
+		))
+		return
+	}
+
+	log.Printf("Connectivity request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("Connectivity request failed: %v", err)),
+	))
+}
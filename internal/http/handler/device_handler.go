@@ -6,16 +6,23 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 
 	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
 
 	"github.com/gin-gonic/gin"
 )
 
 type DeviceHandler struct {
-	config *config.Config
+	config      *config.Config
+	dittoClient *ditto.Client
 }
 
 type Thing struct {
@@ -26,8 +33,9 @@ type Thing struct {
 }
 
 type ThingsResponse struct {
-	Items []Thing `json:"items"`
-	Total int     `json:"total"`
+	Items  []json.RawMessage `json:"items"`
+	Total  int               `json:"total"`
+	Cursor string            `json:"cursor,omitempty"`
 }
 
 type ThingState struct {
@@ -35,109 +43,220 @@ type ThingState struct {
 	Features map[string]interface{} `json:"features"`
 }
 
-type CommandRequest struct {
-	Command string                 `json:"command"`
-	Params  map[string]interface{} `json:"params"`
-}
-
 // NewDeviceHandler creates a new DeviceHandler
-func NewDeviceHandler(config *config.Config) *DeviceHandler {
+func NewDeviceHandler(config *config.Config, dittoClient *ditto.Client) *DeviceHandler {
 	return &DeviceHandler{
-		config: config,
+		config:      config,
+		dittoClient: dittoClient,
 	}
 }
 
 // ListThings handles GET /api/devices
 func (h *DeviceHandler) ListThings(c *gin.Context) {
-	// Get filter parameters
-	namespace := c.Query("namespace")
-	company := c.Query("company")
-	location := c.Query("location")
+	query, err := listQueryFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	// Search things in Ditto
+	result, err := h.dittoClient.Search(c.Request.Context(), query)
+	if err != nil {
+		log.Printf("Failed to search things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to search things: %v", err)),
+		))
+		return
+	}
 
-	// Build filter query
-	filter := fmt.Sprintf("namespace=\"%s\"", namespace)
-	if company != "" {
-		filter += fmt.Sprintf(" AND attributes/company=\"%s\"", company)
+	// Count all matching things, not only the current page
+	total, err := h.dittoClient.Count(c.Request.Context(), query.Filter, query.Namespaces)
+	if err != nil {
+		log.Printf("Failed to count things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to count things: %v", err)),
+		))
+		return
 	}
-	if location != "" {
-		filter += fmt.Sprintf(" AND attributes/location=\"%s\"", location)
+
+	response := ThingsResponse{
+		Items:  result.Items,
+		Total:  total,
+		Cursor: result.Cursor,
 	}
 
-	// Build Ditto API URL
-	url := fmt.Sprintf("%s/things?filter=%s", h.config.Ditto.URL, url.QueryEscape(filter))
+	log.Printf("Found %d things matching filter %s", response.Total, query.Filter)
+
+	c.JSON(http.StatusOK, response)
+}
 
-	// Create request
-	req, err := http.NewRequest("GET", url, nil)
+// CountThings handles GET /api/devices/count
+func (h *DeviceHandler) CountThings(c *gin.Context) {
+	query, err := listQueryFromRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
 		return
 	}
 
-	// Add Ditto authentication
-	req.SetBasicAuth(h.config.Ditto.Username, h.config.Ditto.Password)
-
-	// Create HTTP client and send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	total, err := h.dittoClient.Count(c.Request.Context(), query.Filter, query.Namespaces)
 	if err != nil {
-		log.Printf("Failed to send request to Ditto: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send request: %v", err)})
+		log.Printf("Failed to count things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to count things: %v", err)),
+		))
 		return
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	c.JSON(http.StatusOK, gin.H{"total": total})
+}
+
+// StreamThings handles GET /api/devices/stream and writes every matching
+// thing as newline delimited JSON, fetching pages from Ditto as it goes
+func (h *DeviceHandler) StreamThings(c *gin.Context) {
+	query, err := listQueryFromRequest(c)
 	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to read response: %v", err)})
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
 		return
 	}
 
-	// Parse response as array of things
-	var things []Thing
-	if err := json.Unmarshal(body, &things); err != nil {
-		log.Printf("Failed to parse things response: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse things response: %v", err)})
+	it := h.dittoClient.Iterate(c.Request.Context(), query)
+
+	// Fetch the first page before committing to a status code
+	hasFirst := it.Next()
+	if err := it.Err(); err != nil {
+		log.Printf("Failed to search things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to search things: %v", err)),
+		))
 		return
 	}
 
-	// Add additional filtering
-	filteredThings := make([]Thing, 0)
-	for _, thing := range things {
-		// Check if thing matches all filter criteria
-		matches := true
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
 
-		// Filter by company if specified
-		if company != "" {
-			if thingCompany, ok := thing.Attributes["company"].(string); !ok || thingCompany != company {
-				matches = false
-			}
+	count := 0
+	for ok := hasFirst; ok; ok = it.Next() {
+		line := make([]byte, 0, len(it.Thing())+1)
+		line = append(append(line, it.Thing()...), '\n')
+		if _, err := c.Writer.Write(line); err != nil {
+			log.Printf("Client disconnected while streaming things: %v", err)
+			return
 		}
-
-		// Filter by location if specified
-		if location != "" {
-			if thingLocation, ok := thing.Attributes["location"].(string); !ok || thingLocation != location {
-				matches = false
-			}
+		count++
+		if count%ditto.MaxSearchSize == 0 {
+			c.Writer.Flush()
 		}
+	}
 
-		// Add thing to filtered list if it matches all criteria
-		if matches {
-			filteredThings = append(filteredThings, thing)
+	// Headers are already sent, so report a failure as the last line
+	if err := it.Err(); err != nil {
+		log.Printf("Failed to stream things after %d items: %v", count, err)
+		line, _ := json.Marshal(gin.H{"error": err.Error(), "cursor": it.Cursor()})
+		_, _ = c.Writer.Write(append(line, '\n'))
+	}
+	c.Writer.Flush()
+
+	log.Printf("Streamed %d things matching filter %s", count, query.Filter)
+}
+
+// SearchThings handles POST /api/devices/search
+func (h *DeviceHandler) SearchThings(c *gin.Context) {
+	var req request.DeviceSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid search request: %v", err)),
+		))
+		return
+	}
+
+	query, err := req.Query()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	result, err := h.dittoClient.Search(c.Request.Context(), query)
+	if err != nil {
+		log.Printf("Failed to search things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to search things: %v", err)),
+		))
+		return
+	}
+
+	total, err := h.dittoClient.Count(c.Request.Context(), query.Filter, query.Namespaces)
+	if err != nil {
+		log.Printf("Failed to count things in Ditto: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to count things: %v", err)),
+		))
+		return
+	}
+
+	wrapper.JSONOk(c, response.DeviceSearchResponse{
+		Items:  result.Items,
+		Total:  total,
+		Cursor: result.Cursor,
+	})
+}
+
+// listQueryFromRequest builds a search query from the list query parameters
+func listQueryFromRequest(c *gin.Context) (ditto.SearchQuery, error) {
+	query := ditto.SearchQuery{
+		Namespaces: splitList(c.Query("namespace")),
+		Fields:     splitList(c.Query("fields")),
+		Cursor:     c.Query("cursor"),
+	}
+
+	// Build filter from the supported attributes
+	var filters []rql.Expr
+	if company := c.Query("company"); company != "" {
+		filters = append(filters, rql.Eq("attributes/company", company))
+	}
+	if location := c.Query("location"); location != "" {
+		filters = append(filters, rql.Eq("attributes/location", location))
+	}
+	query.Filter = rql.And(filters...)
+
+	for _, key := range splitList(c.Query("sort")) {
+		sortKey, err := rql.ParseSortKey(key)
+		if err != nil {
+			return query, err
 		}
+		query.Sort = append(query.Sort, sortKey)
 	}
 
-	// Create response with filtered items
-	response := ThingsResponse{
-		Items: filteredThings,
-		Total: len(filteredThings),
+	if size := c.Query("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf("invalid size: %s", size)
+		}
+		query.Size = n
 	}
 
-	log.Printf("Found %d things matching filters (location: %s, company: %s)",
-		response.Total, location, company)
+	return query, nil
+}
 
-	c.JSON(http.StatusOK, response)
+// splitList splits a comma separated query parameter, dropping empty entries
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 }
 
 // CreateThing handles creating a new thing
@@ -176,7 +295,7 @@ func (h *DeviceHandler) CreateThing(c *gin.Context) {
 	log.Printf("Thing data: %s", string(payload))
 
 	// Create a new request
-	req, err := http.NewRequest(http.MethodPut, targetURL, strings.NewReader(string(payload)))
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPut, targetURL, strings.NewReader(string(payload)))
 	if err != nil {
 		log.Printf("Failed to create request: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create request: %v", err)})
@@ -186,8 +305,11 @@ func (h *DeviceHandler) CreateThing(c *gin.Context) {
 	// Set content type
 	req.Header.Set("Content-Type", "application/json")
 
-	// Add Ditto authentication
-	req.SetBasicAuth(h.config.Ditto.Username, h.config.Ditto.Password)
+	// Add Ditto authentication, on behalf of the caller when identities are forwarded
+	if err := h.dittoClient.Authorize(req); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create HTTP client and send request
 	client := &http.Client{}
@@ -237,15 +359,18 @@ func (h *DeviceHandler) GetThingState(c *gin.Context) {
 	log.Printf("Getting thing state from: %s", targetURL)
 
 	// Create a new request
-	req, err := http.NewRequest(http.MethodGet, targetURL, nil)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, targetURL, nil)
 	if err != nil {
 		log.Printf("Failed to create request: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create request: %v", err)})
 		return
 	}
 
-	// Add Ditto authentication
-	req.SetBasicAuth(h.config.Ditto.Username, h.config.Ditto.Password)
+	// Add Ditto authentication, on behalf of the caller when identities are forwarded
+	if err := h.dittoClient.Authorize(req); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create HTTP client and send request
 	client := &http.Client{}
@@ -275,85 +400,3 @@ func (h *DeviceHandler) GetThingState(c *gin.Context) {
 
 	c.JSON(http.StatusOK, thingState)
 }
-
-// SendCommand handles sending a command to a thing's feature
-func (h *DeviceHandler) SendCommand(c *gin.Context) {
-	thingID := c.Param("thingId")
-	feature := c.Param("feature")
-
-	if thingID == "" || feature == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters: thingId or feature"})
-		return
-	}
-
-	// Parse command request
-	var cmdReq CommandRequest
-	if err := c.ShouldBindJSON(&cmdReq); err != nil {
-		log.Printf("Failed to parse command request: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid command request: %v", err)})
-		return
-	}
-
-	// Construct the target URL for Ditto API
-	targetURL := fmt.Sprintf("%s/things/%s/features/%s/inbox/messages/%s",
-		strings.TrimRight(h.config.Ditto.URL, "/"),
-		thingID,
-		feature,
-		cmdReq.Command)
-
-	log.Printf("Sending command to: %s", targetURL)
-	log.Printf("Command data: %+v", cmdReq)
-
-	// Convert params to JSON
-	payload, err := json.Marshal(cmdReq.Params)
-	if err != nil {
-		log.Printf("Failed to marshal command params: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to marshal command params: %v", err)})
-		return
-	}
-
-	// Create a new request
-	req, err := http.NewRequest(http.MethodPut, targetURL, strings.NewReader(string(payload)))
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create request: %v", err)})
-		return
-	}
-
-	// Set content type
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add Ditto authentication
-	req.SetBasicAuth(h.config.Ditto.Username, h.config.Ditto.Password)
-
-	// Create HTTP client and send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request to Ditto: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send request: %v", err)})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to read response: %v", err)})
-		return
-	}
-
-	log.Printf("Response from Ditto: %s", string(body))
-
-	// Set response status code
-	c.Status(resp.StatusCode)
-
-	// Try to parse as JSON for pretty printing
-	var jsonData interface{}
-	if err := json.Unmarshal(body, &jsonData); err == nil {
-		c.JSON(resp.StatusCode, jsonData)
-	} else {
-		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
-	}
-}
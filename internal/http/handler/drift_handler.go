@@ -0,0 +1,80 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ditto/internal/ditto"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DriftHandler struct {
+	reconcileService *service.ReconcileService
+}
+
+// NewDriftHandler creates a new DriftHandler
+func NewDriftHandler(reconcileService *service.ReconcileService) *DriftHandler {
+	return &DriftHandler{
+		reconcileService: reconcileService,
+	}
+}
+
+// GetDeviceDrift handles GET /api/devices/:thingId/drift
+func (h *DriftHandler) GetDeviceDrift(c *gin.Context) {
+	thingID := c.Param("thingId")
+	if err := ditto.ValidateEntityID(thingID); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	drifts, err := h.reconcileService.DeviceDrift(c.Request.Context(), thingID, refresh)
+	if err != nil {
+		respondDriftError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewDeviceDriftResponse(thingID, drifts))
+}
+
+// ListDrifts handles GET /api/drifts
+func (h *DriftHandler) ListDrifts(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	drifts, total, err := h.reconcileService.List(c.Request.Context(), repository.DriftFilter{
+		ThingID: c.Query("thing_id"),
+		Status:  c.Query("status"),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		respondDriftError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewDriftListResponse(drifts, total))
+}
+
+// respondDriftError maps drift errors to API error responses
+func respondDriftError(c *gin.Context, err error) {
+	if stderrors.Is(err, ditto.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("Thing not found"),
+		))
+		return
+	}
+	log.Printf("Drift request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("Drift request failed: %v", err)),
+	))
+}
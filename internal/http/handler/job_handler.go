@@ -0,0 +1,152 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/response"
+	"ditto/internal/job"
+	"ditto/internal/rbac"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobs *job.Manager
+}
+
+// NewJobHandler creates a new JobHandler
+func NewJobHandler(jobs *job.Manager) *JobHandler {
+	return &JobHandler{
+		jobs: jobs,
+	}
+}
+
+// ListJobs handles GET /api/jobs
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	jobs, total, err := h.jobs.List(c.Request.Context(), repository.JobFilter{
+		Type:   c.Query("type"),
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	items := make([]*response.JobResponse, len(jobs))
+	for i, j := range jobs {
+		items[i] = response.NewJobResponse(j)
+	}
+
+	wrapper.JSONOk(c, response.JobListResponse{
+		Items: items,
+		Total: total,
+	})
+}
+
+// GetJob handles GET /api/jobs/:jobId
+func (h *JobHandler) GetJob(c *gin.Context) {
+	j, err := h.jobs.Get(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewJobResponse(j))
+}
+
+// CancelJob handles POST /api/jobs/:jobId/cancel
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	if !h.authorizeJob(c) {
+		return
+	}
+
+	j, err := h.jobs.Cancel(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	log.Printf("Cancellation of job %s requested by %s", j.ID, c.GetString("username"))
+	wrapper.JSONOk(c, response.NewJobResponse(j))
+}
+
+// RetryJob handles POST /api/jobs/:jobId/retry
+func (h *JobHandler) RetryJob(c *gin.Context) {
+	if !h.authorizeJob(c) {
+		return
+	}
+
+	j, err := h.jobs.Retry(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		if stderrors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusConflict, wrapper.NewErrorResponse(
+				errors.NewConflictError("Only failed or cancelled jobs can be retried"),
+			))
+			return
+		}
+		respondJobError(c, err)
+		return
+	}
+
+	log.Printf("Retry of job %s requested by %s", j.ID, c.GetString("username"))
+	wrapper.JSONOk(c, response.NewJobResponse(j))
+}
+
+// authorizeJob loads the job of a request and checks that the user holds the
+// permission its type needs to be changed, responding with an error otherwise
+func (h *JobHandler) authorizeJob(c *gin.Context) bool {
+	j, err := h.jobs.Get(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		respondJobError(c, err)
+		return false
+	}
+
+	permission := jobPermission(j.Type)
+	if !rbac.Allows(c.GetStringSlice(constant.Permissions), permission) {
+		log.Printf("Denied %s %s to %s: %s required", c.Request.Method, c.Request.URL.Path, c.GetString("username"), permission)
+		c.JSON(http.StatusForbidden, wrapper.NewErrorResponse(
+			errors.NewForbiddenError(fmt.Sprintf("Permission %s is required", permission)),
+		))
+		return false
+	}
+	return true
+}
+
+// jobPermission returns the permission needed to cancel or retry a job of a type,
+// the one needed to submit it. Jobs of unknown types may only be changed by admins.
+func jobPermission(jobType string) string {
+	switch jobType {
+	case service.BroadcastJobType, service.ScheduleJobType:
+		return rbac.CommandsSend
+	case service.ProvisioningJobType, service.BulkUpdateJobType:
+		return rbac.DevicesWrite
+	default:
+		return rbac.All
+	}
+}
+
+// respondJobError maps job errors to API error responses
+func respondJobError(c *gin.Context, err error) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("Job not found"),
+		))
+		return
+	}
+
+	log.Printf("Job request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("Job request failed: %v", err)),
+	))
+}
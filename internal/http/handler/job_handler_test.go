@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"testing"
+
+	"ditto/internal/rbac"
+	"ditto/internal/service"
+)
+
+func TestJobPermission(t *testing.T) {
+	tests := map[string]string{
+		service.BroadcastJobType:    rbac.CommandsSend,
+		service.ScheduleJobType:     rbac.CommandsSend,
+		service.ProvisioningJobType: rbac.DevicesWrite,
+		service.BulkUpdateJobType:   rbac.DevicesWrite,
+		"unknown":                   rbac.All,
+	}
+	for jobType, want := range tests {
+		if got := jobPermission(jobType); got != want {
+			t.Errorf("jobPermission(%s) = %s, want %s", jobType, got, want)
+		}
+	}
+
+	if rbac.Allows([]string{rbac.DevicesRead, rbac.DevicesWrite}, jobPermission(service.BroadcastJobType)) {
+		t.Error("expected devices:write not to allow changing broadcast jobs")
+	}
+}
@@ -2,4 +2,20 @@ package handler
 
 import "go.uber.org/fx"
 
-var Module = fx.Options()
+var Module = fx.Options(
+	fx.Provide(
+		NewProvisioningHandler,
+		NewJobHandler,
+		NewBulkUpdateHandler,
+		NewCommandHandler,
+		NewScheduleHandler,
+		NewBroadcastHandler,
+		NewDriftHandler,
+		NewConnectivityHandler,
+		NewRuleHandler,
+		NewWebhookHandler,
+		NewStreamHandler,
+		NewAuthHandler,
+		NewUserHandler,
+	),
+)
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+// pageFromRequest reads page_size and page_index and returns the matching limit and offset
+func pageFromRequest(c *gin.Context) (limit, offset int) {
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	pageIndex, _ := strconv.Atoi(c.Query("page_index"))
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+	if pageIndex < 0 {
+		pageIndex = 0
+	}
+	return pageSize, pageIndex * pageSize
+}
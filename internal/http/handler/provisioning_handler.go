@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ditto/internal/http/dto/response"
+	"ditto/internal/model"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxProvisioningBodySize limits the size of an uploaded provisioning file
+const maxProvisioningBodySize = 10 << 20
+
+type ProvisioningHandler struct {
+	provisioningService *service.ProvisioningService
+}
+
+// NewProvisioningHandler creates a new ProvisioningHandler
+func NewProvisioningHandler(provisioningService *service.ProvisioningService) *ProvisioningHandler {
+	return &ProvisioningHandler{
+		provisioningService: provisioningService,
+	}
+}
+
+// Provision handles POST /api/devices/bulk
+func (h *ProvisioningHandler) Provision(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxProvisioningBodySize)
+
+	// Parse rows depending on the uploaded format
+	var rows []model.ProvisioningRow
+	var err error
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		rows, err = service.ParseProvisioningCSV(body)
+	} else {
+		rows, err = service.ParseProvisioningJSON(body)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid provisioning data: %v", err)),
+		))
+		return
+	}
+
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError("No devices to provision"),
+		))
+		return
+	}
+	if len(rows) > service.MaxProvisioningRows {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Too many devices: %d, maximum is %d", len(rows), service.MaxProvisioningRows)),
+		))
+		return
+	}
+
+	// Validate every row before creating anything
+	results, valid := h.provisioningService.Validate(rows)
+	if !valid {
+		c.JSON(http.StatusBadRequest, wrapper.NewResponse(
+			http.StatusBadRequest,
+			constant.BadRequestErr,
+			results,
+			"Provisioning data contains invalid rows",
+		))
+		return
+	}
+
+	concurrency, _ := strconv.Atoi(c.Query("concurrency"))
+	job, err := h.provisioningService.Start(c.Request.Context(), rows, concurrency, c.GetString("username"))
+	if err != nil {
+		log.Printf("Failed to start provisioning job: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("Failed to start provisioning: %v", err)),
+		))
+		return
+	}
+
+	log.Printf("Started provisioning job %s for %d devices", job.ID, len(rows))
+
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewJobResponse(job),
+		"Provisioning started",
+	))
+}
+
+// GetJob handles GET /api/devices/bulk/:jobId
+func (h *ProvisioningHandler) GetJob(c *gin.Context) {
+	job, err := h.provisioningService.Get(c.Request.Context(), c.Param("jobId"))
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewJobResponse(job))
+}
@@ -1,125 +1,213 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
-	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"strings"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/rbac"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
 
 	"github.com/gin-gonic/gin"
 )
 
+// proxyTargetKey carries the upstream request of a proxied request to the rewrite
+type proxyTargetKey struct{}
+
+// proxyTarget is where a proxied request goes and how it authenticates
+type proxyTarget struct {
+	url  *url.URL
+	auth http.Header
+}
+
+// ProxyHandler passes /api/things requests to the Ditto HTTP API. Bodies are
+// streamed both ways, only allow-listed headers cross the proxy and the caller's
+// credentials are replaced by the proxy credentials or the forwarded identity.
 type ProxyHandler struct {
-	dittoURL string
-	username string
-	password string
+	dittoURL    string
+	credentials *ditto.Credentials
+
+	rules           []ProxyRule
+	requestHeaders  []string
+	responseHeaders []string
+	proxy           *httputil.ReverseProxy
 }
 
-func NewProxyHandler(dittoURL, username, password string) *ProxyHandler {
+// NewProxyHandler creates the Ditto proxy, failing on invalid PROXY_ROUTES
+func NewProxyHandler(cfg config.ProxyConfig, credentials *ditto.Credentials) (*ProxyHandler, error) {
+	rules, err := ParseProxyRules(cfg.Routes)
+	if err != nil {
+		return nil, err
+	}
+
+	dittoURL := cfg.TargetURL
 	// Ensure dittoURL ends with /api/2
 	if !strings.HasSuffix(dittoURL, "/api/2") {
 		dittoURL = strings.TrimRight(dittoURL, "/") + "/api/2"
 	}
 
-	return &ProxyHandler{
-		dittoURL: dittoURL,
-		username: username,
-		password: password,
+	h := &ProxyHandler{
+		dittoURL:        dittoURL,
+		credentials:     credentials,
+		rules:           rules,
+		requestHeaders:  splitList(cfg.RequestHeaders),
+		responseHeaders: splitList(cfg.ResponseHeaders),
 	}
-}
 
-func (h *ProxyHandler) ProxyRequest(c *gin.Context) {
-	// Get the path from the request
-	path := c.Param("path")
-	if path == "" {
-		path = c.Request.URL.Path
+	// One transport for all requests, so upstream connections are kept alive and reused
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConns,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.Timeout,
 	}
 
-	// Remove /api prefix if present
-	path = strings.TrimPrefix(path, "/api")
-
-	// Map /devices to /things for Ditto API
-	path = strings.Replace(path, "/devices", "/things", 1)
-
-	// Map /policies to /api/2/policies for Ditto API
-	if strings.Contains(path, "/policies") {
-		// Extract the policy ID from the path
-		parts := strings.Split(path, "/policies/")
-		if len(parts) == 2 {
-			// Reconstruct the path in Ditto format
-			path = "/api/2/policies/" + parts[1]
-		}
+	h.proxy = &httputil.ReverseProxy{
+		Rewrite:        h.rewrite,
+		Transport:      transport,
+		ModifyResponse: h.modifyResponse,
+		ErrorHandler:   h.handleError,
 	}
+	return h, nil
+}
 
-	// Map /commands to /inbox/messages for Ditto API
-	if strings.Contains(path, "/commands") {
-		// Extract the command subject from the path
-		parts := strings.Split(path, "/commands/")
-		if len(parts) == 2 {
-			// Reconstruct the path in Ditto format
-			path = strings.Replace(parts[0], "/commands", "/inbox/messages", 1) + "/" + parts[1]
-		}
+// ProxyRequest handles the proxied /api paths, mapped to the Ditto API by the proxy rules
+func (h *ProxyHandler) ProxyRequest(c *gin.Context) {
+	path, targetPath, status := h.target(c.Request)
+	switch status {
+	case http.StatusBadRequest:
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError("Proxy paths may not contain . or .. segments"),
+		))
+		return
+	case http.StatusNotFound:
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError(fmt.Sprintf("No proxy route for %s", path)),
+		))
+		return
+	case http.StatusMethodNotAllowed:
+		c.JSON(http.StatusMethodNotAllowed, wrapper.NewResponse(
+			http.StatusMethodNotAllowed,
+			constant.BadRequestErr,
+			nil,
+			fmt.Sprintf("Method %s is not allowed for %s", c.Request.Method, path),
+		))
+		return
 	}
 
-	// Construct the target URL
-	targetURL := fmt.Sprintf("%s%s", strings.TrimRight(h.dittoURL, "/"), path)
-	log.Printf("Proxying request to: %s", targetURL)
-
-	// Create a new request
-	req, err := http.NewRequest(c.Request.Method, targetURL, c.Request.Body)
+	target, err := url.Parse(strings.TrimRight(h.dittoURL, "/") + targetPath)
 	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create request: %v", err)})
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError("Invalid proxy path"),
+		))
 		return
 	}
+	target.RawQuery = c.Request.URL.RawQuery
 
-	// Copy headers from the original request
-	for name, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(name, value)
-		}
+	auth, err := h.credentials.Header(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, wrapper.NewErrorResponse(
+			errors.NewUnauthorizedError("A bearer token is required to call Ditto"),
+		))
+		return
 	}
+	log.Printf("Proxying request to: %s", target)
 
-	// Add Basic Auth
-	req.SetBasicAuth(h.username, h.password)
+	ctx := context.WithValue(c.Request.Context(), proxyTargetKey{}, proxyTarget{url: target, auth: auth})
+	h.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
 
-	// Create HTTP client and send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send request: %v", err)})
-		return
+// Permission returns the permission a proxied request needs, judged by the Ditto
+// path it is forwarded to. Requests the proxy refuses only need devices:read.
+func (h *ProxyHandler) Permission(req *http.Request) string {
+	_, targetPath, status := h.target(req)
+	if status != http.StatusOK {
+		return rbac.DevicesRead
 	}
-	defer resp.Body.Close()
+	return rbac.ProxyPermission(req.Method, targetPath)
+}
 
-	log.Printf("Received response with status: %d", resp.StatusCode)
+// target returns the escaped path of a request below /api and the Ditto path the
+// proxy rules map it to. The status is 400 for paths with dot segments, see matchProxyRules.
+func (h *ProxyHandler) target(req *http.Request) (path, targetPath string, status int) {
+	path = strings.TrimPrefix(req.URL.EscapedPath(), "/api")
+	if hasDotSegment(path) {
+		return path, "", http.StatusBadRequest
+	}
+	targetPath, status = matchProxyRules(h.rules, req.Method, path)
+	return path, targetPath, status
+}
 
-	// Copy response headers
-	for name, values := range resp.Header {
-		for _, value := range values {
-			c.Header(name, value)
-		}
+// Routes handles GET /api/proxy/routes, listing the effective proxy rules in matching order
+func (h *ProxyHandler) Routes(c *gin.Context) {
+	wrapper.JSONOk(c, h.rules)
+}
+
+// rewrite builds the upstream request. Hop-by-hop headers are already removed,
+// of the remaining ones only the allow-listed headers are kept.
+func (h *ProxyHandler) rewrite(r *httputil.ProxyRequest) {
+	target := r.In.Context().Value(proxyTargetKey{}).(proxyTarget)
+	r.Out.URL = target.url
+	r.Out.Host = target.url.Host
+	r.Out.Header = allowHeaders(r.In.Header, h.requestHeaders)
+	for name, values := range target.auth {
+		r.Out.Header[name] = values
 	}
+	r.SetXForwarded()
+}
 
-	// Set response status code
-	c.Status(resp.StatusCode)
+// modifyResponse drops the response headers that are not allow-listed
+func (h *ProxyHandler) modifyResponse(resp *http.Response) error {
+	log.Printf("Received response with status: %d", resp.StatusCode)
+	resp.Header = allowHeaders(resp.Header, h.responseHeaders)
+	return nil
+}
 
-	// Copy response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to read response: %v", err)})
+// handleError reports requests that did not get a response from Ditto
+func (h *ProxyHandler) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		// The client went away, nobody reads the response
 		return
 	}
+	log.Printf("Failed to proxy request: %v", err)
 
-	// Try to parse as JSON for pretty printing
-	var jsonData interface{}
-	if err := json.Unmarshal(body, &jsonData); err == nil {
-		c.JSON(resp.StatusCode, jsonData)
-	} else {
-		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	status, message := http.StatusBadGateway, "Ditto is unavailable"
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		status, message = http.StatusGatewayTimeout, "Ditto did not respond in time"
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(wrapper.NewResponse(status, constant.CallInternalAPIError, nil, message))
+}
+
+// allowHeaders copies the headers named in the allow-list
+func allowHeaders(header http.Header, allowed []string) http.Header {
+	filtered := make(http.Header, len(allowed))
+	for _, name := range allowed {
+		if values := header.Values(name); len(values) > 0 {
+			filtered[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
 	}
+	return filtered
 }
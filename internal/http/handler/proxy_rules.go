@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ProxyRule maps the requests matching a path pattern to a Ditto API path.
+// Patterns and targets are made of literal segments, :name segments matching
+// one segment and a final *name matching the remaining segments, possibly none.
+type ProxyRule struct {
+	// Methods restricts the rule to these methods, any method when empty
+	Methods []string `json:"methods,omitempty"`
+	Pattern string   `json:"pattern"`
+	Target  string   `json:"target"`
+
+	pattern []string
+	target  []string
+}
+
+// ParseProxyRules parses comma separated rules of the form
+// [METHOD|METHOD ]/pattern -> /target, the first matching rule wins
+func ParseProxyRules(value string) ([]ProxyRule, error) {
+	var rules []ProxyRule
+	for _, text := range strings.Split(value, ",") {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		rule, err := parseProxyRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy route %q: %w", text, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseProxyRule(text string) (ProxyRule, error) {
+	source, target, ok := strings.Cut(text, "->")
+	if !ok {
+		return ProxyRule{}, fmt.Errorf("expected [METHODS ]/pattern -> /target")
+	}
+
+	var rule ProxyRule
+	source = strings.TrimSpace(source)
+	if methods, pattern, ok := strings.Cut(source, " "); ok {
+		for _, method := range strings.Split(methods, "|") {
+			rule.Methods = append(rule.Methods, strings.ToUpper(strings.TrimSpace(method)))
+		}
+		source = strings.TrimSpace(pattern)
+	}
+	rule.Pattern = source
+	rule.Target = strings.TrimSpace(target)
+
+	var err error
+	if rule.pattern, err = parseProxyPath(rule.Pattern); err != nil {
+		return ProxyRule{}, err
+	}
+	if rule.target, err = parseProxyPath(rule.Target); err != nil {
+		return ProxyRule{}, err
+	}
+
+	// Every parameter of the target must be bound by the pattern
+	for _, segment := range rule.target {
+		if isProxyParam(segment) && !slices.Contains(rule.pattern, segment) {
+			return ProxyRule{}, fmt.Errorf("target parameter %s is not in the pattern", segment)
+		}
+	}
+	return rule, nil
+}
+
+// parseProxyPath splits an absolute path into segments, *name may only be last
+func parseProxyPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %q must start with /", path)
+	}
+	segments := splitPath(path)
+	for i, segment := range segments {
+		switch {
+		case segment == ":" || segment == "*":
+			return nil, fmt.Errorf("parameter without a name in %q", path)
+		case strings.HasPrefix(segment, "*") && i != len(segments)-1:
+			return nil, fmt.Errorf("%s must be the last segment of %q", segment, path)
+		}
+	}
+	return segments, nil
+}
+
+// Match tells whether the rule applies to a request and returns its target path.
+// The path is matched in its escaped form, so escaped slashes stay in their segment.
+func (r ProxyRule) Match(method, path string) (string, bool) {
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, method) {
+		return "", false
+	}
+	params, ok := r.matchPath(path)
+	if !ok {
+		return "", false
+	}
+
+	var target []string
+	for _, segment := range r.target {
+		if !isProxyParam(segment) {
+			target = append(target, segment)
+		} else if value := params[segment]; value != "" {
+			target = append(target, value)
+		}
+	}
+	return "/" + strings.Join(target, "/"), true
+}
+
+// matchPath binds the parameters of the pattern to the segments of a path
+func (r ProxyRule) matchPath(path string) (map[string]string, bool) {
+	segments := splitPath(path)
+	params := make(map[string]string)
+	for i, segment := range r.pattern {
+		switch {
+		case strings.HasPrefix(segment, "*"):
+			params[segment] = strings.Join(segments[i:], "/")
+			return params, true
+		case i >= len(segments):
+			return nil, false
+		case strings.HasPrefix(segment, ":"):
+			params[segment] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(r.pattern)
+}
+
+// matchProxyRules returns the target of the first rule matching a request. The
+// status is 404 when no rule matches the path and 405 when only the method differs.
+func matchProxyRules(rules []ProxyRule, method, path string) (string, int) {
+	status := http.StatusNotFound
+	for _, rule := range rules {
+		if target, ok := rule.Match(method, path); ok {
+			return target, http.StatusOK
+		}
+		if _, ok := rule.matchPath(path); ok {
+			status = http.StatusMethodNotAllowed
+		}
+	}
+	return "", status
+}
+
+// hasDotSegment tells whether an escaped path has a . or .. segment, possibly
+// percent-encoded. Rules match the path as sent, an upstream resolving the dot
+// segments would serve another API than the one the matching rule allows.
+func hasDotSegment(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// isProxyParam tells whether a segment is a :name or *name parameter
+func isProxyParam(segment string) bool {
+	return strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")
+}
+
+// splitPath splits a path into its non-empty segments
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
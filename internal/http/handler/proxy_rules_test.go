@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"ditto/config"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testProxyRoutes = "POST /things/:thingId/commands/:subject -> /things/:thingId/inbox/messages/:subject, " +
+	"GET|HEAD /things/:thingId/state -> /things/:thingId/features, " +
+	"/things/*path -> /things/*path, " +
+	"/policies/*path -> /policies/*path"
+
+func TestParseProxyRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		rules   int
+		methods []string
+		wantErr bool
+	}{
+		{name: "empty", value: "", rules: 0},
+		{name: "defaults", value: testProxyRoutes, rules: 4},
+		{name: "blank entries", value: " , /a -> /b ,", rules: 1},
+		{name: "methods are upper cased", value: "get|Post /a -> /b", rules: 1, methods: []string{"GET", "POST"}},
+		{name: "missing arrow", value: "/a /b", wantErr: true},
+		{name: "relative pattern", value: "a -> /b", wantErr: true},
+		{name: "relative target", value: "/a -> b", wantErr: true},
+		{name: "unnamed parameter", value: "/a/: -> /b", wantErr: true},
+		{name: "unnamed wildcard", value: "/a/* -> /b", wantErr: true},
+		{name: "wildcard not last", value: "/a/*rest/b -> /b", wantErr: true},
+		{name: "unbound target parameter", value: "/a/:id -> /b/:other", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseProxyRules(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d rules", len(rules))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(rules) != tt.rules {
+				t.Fatalf("expected %d rules, got %d", tt.rules, len(rules))
+			}
+			if tt.methods != nil && !slices.Equal(rules[0].Methods, tt.methods) {
+				t.Errorf("expected methods %v, got %v", tt.methods, rules[0].Methods)
+			}
+		})
+	}
+}
+
+func TestProxyRuleMatch(t *testing.T) {
+	rules, err := ParseProxyRules(testProxyRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		target string
+		status int
+	}{
+		{name: "command rewrite", method: http.MethodPost, path: "/things/org:dev/commands/reboot", target: "/things/org:dev/inbox/messages/reboot", status: http.StatusOK},
+		{name: "command falls through to wildcard", method: http.MethodGet, path: "/things/org:dev/commands/reboot", target: "/things/org:dev/commands/reboot", status: http.StatusOK},
+		{name: "method list", method: http.MethodHead, path: "/things/org:dev/state", target: "/things/org:dev/features", status: http.StatusOK},
+		{name: "wildcard", method: http.MethodPut, path: "/things/org:dev/features/temp/properties", target: "/things/org:dev/features/temp/properties", status: http.StatusOK},
+		{name: "empty wildcard", method: http.MethodGet, path: "/things", target: "/things", status: http.StatusOK},
+		{name: "empty segments are ignored", method: http.MethodGet, path: "//policies//org:p/", target: "/policies/org:p", status: http.StatusOK},
+		{name: "escaped slash stays in its segment", method: http.MethodPost, path: "/things/org:dev/commands/a%2Fb", target: "/things/org:dev/inbox/messages/a%2Fb", status: http.StatusOK},
+		{name: "unknown path", method: http.MethodGet, path: "/connections/c1", status: http.StatusNotFound},
+		{name: "prefix is not a match", method: http.MethodGet, path: "/thingsx/org:dev", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, status := matchProxyRules(rules, tt.method, tt.path)
+			if status != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, status)
+			}
+			if target != tt.target {
+				t.Errorf("expected target %q, got %q", tt.target, target)
+			}
+		})
+	}
+}
+
+func TestMatchProxyRulesMethodNotAllowed(t *testing.T) {
+	rules, err := ParseProxyRules("POST /things/:thingId/commands/:subject -> /things/:thingId/inbox/messages/:subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, status := matchProxyRules(rules, http.MethodGet, "/things/org:dev/commands/reboot"); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a known path with another method, got %d", status)
+	}
+	if _, status := matchProxyRules(rules, http.MethodGet, "/things/org:dev"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown path, got %d", status)
+	}
+	if _, ok := rules[0].Match(http.MethodDelete, "/things/org:dev/commands/reboot"); ok {
+		t.Error("expected the rule to ignore other methods")
+	}
+}
+
+func TestHasDotSegment(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/things/org:dev", want: false},
+		{path: "/things/org:dev/features/a.b", want: false},
+		{path: "/things/..org:dev", want: false},
+		{path: "/things/org:dev/...", want: false},
+		{path: "/things/../policies/org:p", want: true},
+		{path: "/things/./org:dev", want: true},
+		{path: "/things/org:dev/..", want: true},
+		{path: "/things/%2e%2e/policies/org:p", want: true},
+		{path: "/things/%2E%2E/policies/org:p", want: true},
+		{path: "/things/.%2e/policies/org:p", want: true},
+		{path: "/things/%2e/org:dev", want: true},
+		{path: "/things/%252e%252e/policies/org:p", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := hasDotSegment(tt.path); got != tt.want {
+			t.Errorf("hasDotSegment(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestProxyRequestRejectsDotSegments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, err := NewProxyHandler(config.ProxyConfig{Routes: testProxyRoutes, TargetURL: "http://ditto.invalid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		"/api/things/../policies/org:p",
+		"/api/things/%2e%2e/policies/org:p",
+		"/api/things/org:dev/%2E%2E/%2e%2e/policies/org:p",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+
+		h.ProxyRequest(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestProxyPermission(t *testing.T) {
+	h, err := NewProxyHandler(config.ProxyConfig{Routes: testProxyRoutes, TargetURL: "http://ditto.invalid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodGet, path: "/api/things/org:dev", want: rbac.DevicesRead},
+		{method: http.MethodPut, path: "/api/things/org:dev/attributes/location", want: rbac.DevicesWrite},
+		{method: http.MethodPost, path: "/api/things/org:dev/commands/reboot", want: rbac.CommandsSend},
+		{method: http.MethodPost, path: "/api/things/org:dev/%63ommands/reboot", want: rbac.CommandsSend},
+		{method: http.MethodPost, path: "/api/things/org:dev/%69nbox/messages/reboot", want: rbac.CommandsSend},
+		{method: http.MethodGet, path: "/api/things/org:dev/state", want: rbac.DevicesRead},
+		{method: http.MethodDelete, path: "/api/policies/org:p", want: rbac.PoliciesAdmin},
+		// Requests the proxy refuses are judged by the refusal, not by their path
+		{method: http.MethodGet, path: "/api/things/%2e%2e/policies/org:p", want: rbac.DevicesRead},
+		{method: http.MethodGet, path: "/api/connections/c1", want: rbac.DevicesRead},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := h.Permission(req); got != tt.want {
+			t.Errorf("Permission(%s %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuleHandler struct {
+	ruleService  *service.RuleService
+	alertService *service.AlertService
+}
+
+// NewRuleHandler creates a new RuleHandler
+func NewRuleHandler(ruleService *service.RuleService, alertService *service.AlertService) *RuleHandler {
+	return &RuleHandler{
+		ruleService:  ruleService,
+		alertService: alertService,
+	}
+}
+
+// CreateRule handles POST /api/rules
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var req request.RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid rule: %v", err)),
+		))
+		return
+	}
+
+	spec := req.RuleSpec()
+	if err := h.ruleService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	rule, err := h.ruleService.Create(c.Request.Context(), spec, c.GetString("username"))
+	if err != nil {
+		respondRuleError(c, err, "Rule")
+		return
+	}
+
+	log.Printf("Created rule %s (%s)", rule.ID, rule.Name)
+	c.JSON(http.StatusCreated, wrapper.NewResponse(
+		http.StatusCreated,
+		constant.Success,
+		response.NewRuleResponse(rule),
+		constant.SuccessMess,
+	))
+}
+
+// ListRules handles GET /api/rules
+func (h *RuleHandler) ListRules(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	filter := repository.RuleFilter{
+		ThingID: c.Query("thing_id"),
+		Feature: c.Query("feature"),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	if value := c.Query("enabled"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+				errors.NewBadRequestError(fmt.Sprintf("Invalid enabled: %s", value)),
+			))
+			return
+		}
+		filter.Enabled = &enabled
+	}
+
+	rules, total, err := h.ruleService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondRuleError(c, err, "Rule")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewRuleListResponse(rules, total))
+}
+
+// GetRule handles GET /api/rules/:ruleId
+func (h *RuleHandler) GetRule(c *gin.Context) {
+	rule, err := h.ruleService.Get(c.Request.Context(), c.Param("ruleId"))
+	if err != nil {
+		respondRuleError(c, err, "Rule")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewRuleResponse(rule))
+}
+
+// UpdateRule handles PUT /api/rules/:ruleId
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	var req request.RuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid rule: %v", err)),
+		))
+		return
+	}
+
+	spec := req.RuleSpec()
+	if err := h.ruleService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	rule, err := h.ruleService.Update(c.Request.Context(), c.Param("ruleId"), spec, c.GetString("username"))
+	if err != nil {
+		respondRuleError(c, err, "Rule")
+		return
+	}
+
+	log.Printf("Updated rule %s (%s)", rule.ID, rule.Name)
+	wrapper.JSONOk(c, response.NewRuleResponse(rule))
+}
+
+// DeleteRule handles DELETE /api/rules/:ruleId
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	id := c.Param("ruleId")
+	if err := h.ruleService.Delete(c.Request.Context(), id, c.GetString("username")); err != nil {
+		respondRuleError(c, err, "Rule")
+		return
+	}
+
+	log.Printf("Deleted rule %s", id)
+	c.Status(http.StatusNoContent)
+}
+
+// ListAlerts handles GET /api/alerts
+func (h *RuleHandler) ListAlerts(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	filter := repository.AlertFilter{
+		RuleID:  c.Query("rule_id"),
+		ThingID: c.Query("thing_id"),
+		Status:  c.Query("status"),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	alerts, total, err := h.alertService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondRuleError(c, err, "Alert")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewAlertListResponse(alerts, total))
+}
+
+// GetAlert handles GET /api/alerts/:alertId
+func (h *RuleHandler) GetAlert(c *gin.Context) {
+	alert, err := h.alertService.Get(c.Request.Context(), c.Param("alertId"))
+	if err != nil {
+		respondRuleError(c, err, "Alert")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewAlertResponse(alert))
+}
+
+// AcknowledgeAlert handles POST /api/alerts/:alertId/acknowledge
+func (h *RuleHandler) AcknowledgeAlert(c *gin.Context) {
+	alert, err := h.alertService.Acknowledge(c.Request.Context(), c.Param("alertId"), c.GetString("username"))
+	if err != nil {
+		if stderrors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+				errors.NewNotFoundError("No firing alert found"),
+			))
+			return
+		}
+		respondRuleError(c, err, "Alert")
+		return
+	}
+
+	log.Printf("Alert %s acknowledged by %s", alert.ID, alert.AcknowledgedBy)
+	wrapper.JSONOk(c, response.NewAlertResponse(alert))
+}
+
+// respondRuleError maps rule and alert errors to API error responses
+func respondRuleError(c *gin.Context, err error, resource string) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError(fmt.Sprintf("%s not found", resource)),
+		))
+		return
+	}
+
+	log.Printf("%s request failed: %v", resource, err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("%s request failed: %v", resource, err)),
+	))
+}
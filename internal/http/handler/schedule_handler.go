@@ -0,0 +1,185 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+// NewScheduleHandler creates a new ScheduleHandler
+func NewScheduleHandler(scheduleService *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+// CreateSchedule handles POST /api/schedules
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req request.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid schedule: %v", err)),
+		))
+		return
+	}
+
+	spec := req.ScheduleSpec()
+	if err := h.scheduleService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	schedule, err := h.scheduleService.Create(c.Request.Context(), spec, c.GetString("username"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	log.Printf("Created schedule %s (%s)", schedule.ID, schedule.Name)
+	c.JSON(http.StatusCreated, wrapper.NewResponse(
+		http.StatusCreated,
+		constant.Success,
+		response.NewScheduleResponse(schedule),
+		constant.SuccessMess,
+	))
+}
+
+// ListSchedules handles GET /api/schedules
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	filter := repository.ScheduleFilter{
+		ThingID: c.Query("thing_id"),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	if value := c.Query("enabled"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+				errors.NewBadRequestError(fmt.Sprintf("Invalid enabled: %s", value)),
+			))
+			return
+		}
+		filter.Enabled = &enabled
+	}
+
+	schedules, total, err := h.scheduleService.List(c.Request.Context(), filter)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewScheduleListResponse(schedules, total))
+}
+
+// GetSchedule handles GET /api/schedules/:scheduleId
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, err := h.scheduleService.Get(c.Request.Context(), c.Param("scheduleId"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewScheduleResponse(schedule))
+}
+
+// UpdateSchedule handles PUT /api/schedules/:scheduleId
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	var req request.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid schedule: %v", err)),
+		))
+		return
+	}
+
+	spec := req.ScheduleSpec()
+	if err := h.scheduleService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	schedule, err := h.scheduleService.Update(c.Request.Context(), c.Param("scheduleId"), spec, c.GetString("username"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	log.Printf("Updated schedule %s (%s)", schedule.ID, schedule.Name)
+	wrapper.JSONOk(c, response.NewScheduleResponse(schedule))
+}
+
+// DeleteSchedule handles DELETE /api/schedules/:scheduleId
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id := c.Param("scheduleId")
+	if err := h.scheduleService.Delete(c.Request.Context(), id, c.GetString("username")); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	log.Printf("Deleted schedule %s", id)
+	c.Status(http.StatusNoContent)
+}
+
+// RunSchedule handles POST /api/schedules/:scheduleId/run
+func (h *ScheduleHandler) RunSchedule(c *gin.Context) {
+	job, err := h.scheduleService.Trigger(c.Request.Context(), c.Param("scheduleId"), c.GetString("username"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewJobResponse(job),
+		"Schedule started",
+	))
+}
+
+// ListExecutions handles GET /api/schedules/:scheduleId/executions
+func (h *ScheduleHandler) ListExecutions(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	executions, total, err := h.scheduleService.Executions(c.Request.Context(), c.Param("scheduleId"), limit, offset)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewScheduleExecutionListResponse(executions, total))
+}
+
+// respondScheduleError maps schedule errors to API error responses
+func respondScheduleError(c *gin.Context, err error) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("Schedule not found"),
+		))
+		return
+	}
+
+	log.Printf("Schedule request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("Schedule request failed: %v", err)),
+	))
+}
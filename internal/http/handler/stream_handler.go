@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/model"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamReadLimit bounds the size of client control messages
+	streamReadLimit = 64 * 1024
+
+	// Stream message types
+	streamMessageSubscribed = "subscribed"
+	streamMessageFilter     = "filter"
+	streamMessageEvent      = "event"
+	streamMessageError      = "error"
+)
+
+// streamMessage is a message exchanged with a WebSocket stream client
+type streamMessage struct {
+	Type    string              `json:"type"`
+	ID      string              `json:"id,omitempty"`
+	Filter  *model.StreamFilter `json:"filter,omitempty"`
+	Event   *model.DeviceEvent  `json:"event,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+type StreamHandler struct {
+	streamService *service.StreamService
+	upgrader      websocket.Upgrader
+}
+
+// NewStreamHandler creates a new StreamHandler
+func NewStreamHandler(cfg *config.Config, streamService *service.StreamService) *StreamHandler {
+	return &StreamHandler{
+		streamService: streamService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// Browsers may only connect from the allowed origins, like to the Ditto WebSocket proxy
+			CheckOrigin: wsOriginChecker(splitList(cfg.Server.WSAllowedOrigins)),
+		},
+	}
+}
+
+// WebSocket handles GET /api/ws. The initial filter is taken from the thing_id,
+// namespace and feature query parameters, and clients may replace it by sending
+// {"type":"filter","filter":{"things":[...],"namespaces":[...],"features":[...]}}.
+func (h *StreamHandler) WebSocket(c *gin.Context) {
+	sub, err := h.streamService.Subscribe(streamFilterFromRequest(c), c.GetString("username"))
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+	defer h.streamService.Unsubscribe(sub)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already responded
+		log.Printf("Failed to upgrade stream connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	cfg := h.streamService.Config()
+	filter := sub.Filter()
+	if err := writeStreamMessage(conn, cfg.WriteTimeout, streamMessage{Type: streamMessageSubscribed, ID: sub.ID, Filter: &filter}); err != nil {
+		return
+	}
+
+	// Control messages are read in the background, the connection is written from this goroutine only
+	controls := make(chan streamMessage, 8)
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(streamReadLimit)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * cfg.PingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * cfg.PingInterval))
+		})
+		for {
+			var msg streamMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if !stderrors.As(err, &syntaxErr) && !stderrors.As(err, &typeErr) {
+					return
+				}
+				msg = streamMessage{Type: streamMessageError, Message: "invalid message"}
+			}
+			select {
+			case controls <- msg:
+			case <-sub.Done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(cfg.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-sub.Done:
+			log.Printf("Disconnecting slow stream client %s", sub.ID)
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"),
+				time.Now().Add(cfg.WriteTimeout))
+			return
+		case event := <-sub.Events:
+			if err := writeStreamMessage(conn, cfg.WriteTimeout, streamMessage{Type: streamMessageEvent, Event: &event}); err != nil {
+				return
+			}
+		case msg := <-controls:
+			reply := streamMessage{Type: streamMessageError, Message: msg.Message}
+			switch {
+			case msg.Type == streamMessageFilter && msg.Filter != nil:
+				sub.SetFilter(*msg.Filter)
+				reply = streamMessage{Type: streamMessageFilter, Filter: msg.Filter}
+			case reply.Message == "":
+				reply.Message = "unsupported message type: " + msg.Type
+			}
+			if err := writeStreamMessage(conn, cfg.WriteTimeout, reply); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cfg.WriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeStreamMessage writes a JSON message, giving up after the write timeout
+func writeStreamMessage(conn *websocket.Conn, timeout time.Duration, msg streamMessage) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	return conn.WriteJSON(msg)
+}
+
+// streamFilterFromRequest reads the thing_id, namespace and feature query
+// parameters, each repeated or comma separated
+func streamFilterFromRequest(c *gin.Context) model.StreamFilter {
+	return model.StreamFilter{
+		ThingIDs:   queryList(c, "thing_id"),
+		Namespaces: queryList(c, "namespace"),
+		Features:   queryList(c, "feature"),
+	}
+}
+
+// queryList returns the values of a repeated or comma separated query parameter
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// respondStreamError maps stream errors to API error responses
+func respondStreamError(c *gin.Context, err error) {
+	if stderrors.Is(err, service.ErrStreamUnavailable) {
+		c.JSON(http.StatusForbidden, wrapper.NewErrorResponse(
+			errors.NewForbiddenError("Event streams are unavailable when identities are forwarded to Ditto, use the Ditto WebSocket proxy"),
+		))
+		return
+	}
+	if stderrors.Is(err, service.ErrTooManyClients) {
+		c.JSON(http.StatusServiceUnavailable, wrapper.NewResponse(
+			http.StatusServiceUnavailable,
+			constant.IllegalStateError,
+			nil,
+			"Too many stream clients",
+		))
+		return
+	}
+
+	log.Printf("Stream request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError("Stream request failed"),
+	))
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ditto/internal/ditto"
+	"ditto/internal/model"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseRetry is the reconnection delay suggested to EventSource clients
+const sseRetry = 3 * time.Second
+
+// DeviceEvents handles GET /api/devices/:thingId/events, the Server-Sent Events
+// stream of one device. Event ids are the Ditto revisions of the twin, a client
+// reconnecting with Last-Event-ID receives the buffered revisions it missed.
+func (h *StreamHandler) DeviceEvents(c *gin.Context) {
+	thingID := c.Param("thingId")
+	if err := ditto.ValidateEntityID(thingID); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	var cursor *model.StreamCursor
+	if lastEventID := sseLastEventID(c); lastEventID != "" {
+		revision, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+				errors.NewBadRequestError("Last-Event-ID must be a revision"),
+			))
+			return
+		}
+		cursor = &model.StreamCursor{ThingID: thingID, Revision: revision}
+	}
+
+	filter := model.StreamFilter{
+		ThingIDs: []string{thingID},
+		Features: queryList(c, "feature"),
+	}
+	h.serveEvents(c, filter, cursor, func(e model.DeviceEvent) string {
+		return strconv.FormatInt(e.Revision, 10)
+	})
+}
+
+// FleetEvents handles GET /api/devices/events, the Server-Sent Events stream of
+// every device, filtered by the thing_id, namespace and feature query parameters.
+// Event ids are <thingId>:<revision> and are accepted as Last-Event-ID.
+func (h *StreamHandler) FleetEvents(c *gin.Context) {
+	var cursor *model.StreamCursor
+	if lastEventID := sseLastEventID(c); lastEventID != "" {
+		parsed, err := model.ParseStreamCursor(lastEventID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+				errors.NewBadRequestError(err.Error()),
+			))
+			return
+		}
+		cursor = &parsed
+	}
+
+	h.serveEvents(c, streamFilterFromRequest(c), cursor, func(e model.DeviceEvent) string {
+		return e.Cursor().String()
+	})
+}
+
+// serveEvents subscribes to the stream service, which is fed by the event bus,
+// and writes the replayed and live events until the client goes away or falls behind
+func (h *StreamHandler) serveEvents(c *gin.Context, filter model.StreamFilter, cursor *model.StreamCursor, eventID func(model.DeviceEvent) string) {
+	sub, replay, err := h.streamService.SubscribeFrom(filter, c.GetString("username"), cursor)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+	defer h.streamService.Unsubscribe(sub)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Keeps reverse proxies such as nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return
+	}
+	for _, event := range replay {
+		if err := writeSSEEvent(c, eventID(event), event); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	ping := time.NewTicker(h.streamService.Config().PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-sub.Done:
+			log.Printf("Disconnecting slow stream client %s", sub.ID)
+			return
+		case event := <-sub.Events:
+			if err := writeSSEEvent(c, eventID(event), event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-ping.C:
+			// Comment lines keep idle connections open through proxies
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes a twin event as an SSE event named twin.<action>
+func writeSSEEvent(c *gin.Context, id string, event model.DeviceEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: twin.%s\ndata: %s\n\n", id, event.Action, data)
+	return err
+}
+
+// sseLastEventID returns the Last-Event-ID header, or the last_event_id query
+// parameter for clients that cannot set headers on their first connection
+func sseLastEventID(c *gin.Context) string {
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("last_event_id")
+}
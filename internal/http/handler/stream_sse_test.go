@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/service"
+	"ditto/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/fx/fxtest"
+)
+
+// sseEvent is an event read from an SSE stream
+type sseEvent struct {
+	id    string
+	name  string
+	event model.DeviceEvent
+}
+
+// sseStream reads the events of an SSE response
+type sseStream struct {
+	reader *bufio.Reader
+}
+
+func openSSEStream(t *testing.T, url, lastEventID string) *sseStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return &sseStream{reader: bufio.NewReader(resp.Body)}
+}
+
+// next returns the next event, skipping the retry and ping frames
+func (s *sseStream) next(t *testing.T) sseEvent {
+	t.Helper()
+	var e sseEvent
+	var data string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(data), &e.event); err != nil {
+				t.Fatalf("invalid event data %s: %v", data, err)
+			}
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func publishTwinEvent(bus *eventbus.Bus, thing, feature string, revision int64) {
+	namespace, name, _ := strings.Cut(thing, ":")
+	eventbus.Publish(bus, ditto.EventTopic, ditto.Event{
+		Topic:     namespace + "/" + name + "/things/twin/events/modified",
+		Path:      "/features/" + feature + "/properties/value",
+		Value:     json.RawMessage(`21.5`),
+		Revision:  revision,
+		Timestamp: time.Now(),
+	})
+}
+
+func TestSSEStreamsEventsOfTheBus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Stream: config.StreamConfig{ReplaySize: 10, PingInterval: time.Hour}}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	bus := eventbus.NewBus(lc, log, cfg)
+	h := NewStreamHandler(cfg, service.NewStreamService(bus, log, cfg))
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	router := gin.New()
+	router.GET("/api/devices/events", h.FleetEvents)
+	router.GET("/api/devices/:thingId/events", h.DeviceEvents)
+	server := httptest.NewServer(router)
+	// Cleaned up after the streams, which the server waits for when closing
+	t.Cleanup(server.Close)
+
+	// Events published on the bus reach the fleet stream
+	fleet := openSSEStream(t, server.URL+"/api/devices/events", "")
+	for revision := int64(1); revision <= 3; revision++ {
+		publishTwinEvent(bus, "org:dev", "temp", revision)
+	}
+	publishTwinEvent(bus, "org:other", "temp", 7)
+	for _, want := range []string{"org:dev:1", "org:dev:2", "org:dev:3", "org:other:7"} {
+		e := fleet.next(t)
+		if e.id != want || e.name != "twin.modified" {
+			t.Fatalf("expected event %s, got %s %s", want, e.id, e.name)
+		}
+	}
+
+	// A device stream resuming after revision 1 gets the rest from the replay buffer
+	device := openSSEStream(t, server.URL+"/api/devices/org:dev/events?feature=temp", "1")
+	for _, want := range []string{"2", "3"} {
+		if e := device.next(t); e.id != want || e.event.ThingID != "org:dev" || e.event.Feature != "temp" {
+			t.Fatalf("expected replayed revision %s, got %s %+v", want, e.id, e.event)
+		}
+	}
+
+	// Then live events follow, filtered by feature
+	publishTwinEvent(bus, "org:dev", "humidity", 4)
+	publishTwinEvent(bus, "org:dev", "temp", 5)
+	if e := device.next(t); e.id != "5" || string(e.event.Value) != "21.5" {
+		t.Fatalf("expected live revision 5, got %s %+v", e.id, e.event)
+	}
+
+	// The fleet stream resumes from its <thingId>:<revision> ids
+	resumed := openSSEStream(t, server.URL+"/api/devices/events?thing_id=org:dev", "org:dev:3")
+	for _, want := range []string{"org:dev:4", "org:dev:5"} {
+		if e := resumed.next(t); e.id != want {
+			t.Fatalf("expected replayed event %s, got %s", want, e.id)
+		}
+	}
+}
+
+func TestSSERejectsInvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	h := NewStreamHandler(cfg, service.NewStreamService(eventbus.NewBus(lc, log, cfg), log, cfg))
+
+	router := gin.New()
+	router.GET("/api/devices/events", h.FleetEvents)
+	router.GET("/api/devices/:thingId/events", h.DeviceEvents)
+
+	for path, lastEventID := range map[string]string{
+		"/api/devices/org:dev/events":   "org:dev:1",
+		"/api/devices/events":           "1",
+		"/api/devices/not-an-id/events": "",
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s with Last-Event-ID %q: expected 400, got %d", path, lastEventID, w.Code)
+		}
+	}
+}
+
+func TestSSEStreamsOnlyAllowedNamespaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		Stream: config.StreamConfig{ReplaySize: 10, PingInterval: time.Hour},
+		Proxy:  config.ProxyConfig{WSNamespaces: "org"},
+	}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	bus := eventbus.NewBus(lc, log, cfg)
+	h := NewStreamHandler(cfg, service.NewStreamService(bus, log, cfg))
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	router := gin.New()
+	router.GET("/api/devices/events", h.FleetEvents)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	// Asking for another namespace does not widen the stream
+	fleet := openSSEStream(t, server.URL+"/api/devices/events?namespace=org,acme", "")
+	publishTwinEvent(bus, "acme:dev", "temp", 1)
+	publishTwinEvent(bus, "org:dev", "temp", 2)
+	if e := fleet.next(t); e.id != "org:dev:2" {
+		t.Fatalf("expected only the events of org, got %s", e.id)
+	}
+
+	// Nor does replaying the buffered events
+	publishTwinEvent(bus, "acme:dev", "temp", 3)
+	publishTwinEvent(bus, "org:dev", "temp", 4)
+	if e := fleet.next(t); e.id != "org:dev:4" {
+		t.Fatalf("expected only the events of org, got %s", e.id)
+	}
+	resumed := openSSEStream(t, server.URL+"/api/devices/events", "org:dev:2")
+	if e := resumed.next(t); e.id != "org:dev:4" {
+		t.Fatalf("expected only the events of org, got %s", e.id)
+	}
+}
+
+func TestSSEUnavailableWhenIdentitiesAreForwarded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Ditto: config.DittoConfig{IdentityMode: ditto.IdentityJWT}}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	h := NewStreamHandler(cfg, service.NewStreamService(eventbus.NewBus(lc, log, cfg), log, cfg))
+
+	router := gin.New()
+	router.GET("/api/devices/events", h.FleetEvents)
+	router.GET("/api/ws", h.WebSocket)
+
+	for _, path := range []string{"/api/devices/events", "/api/ws"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	authService *service.AuthService
+}
+
+// NewUserHandler creates a new UserHandler
+func NewUserHandler(authService *service.AuthService) *UserHandler {
+	return &UserHandler{
+		authService: authService,
+	}
+}
+
+// ListUsers handles GET /api/users
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	users, total, err := h.authService.ListUsers(c.Request.Context(), limit, offset)
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewUserListResponse(users, total))
+}
+
+// CreateUser handles POST /api/users
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req request.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid user: %v", err)),
+		))
+		return
+	}
+
+	user, err := h.authService.CreateUser(c.Request.Context(), req.Username, req.Email, req.Password, req.Roles, c.GetString("username"))
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	log.Printf("Created user %s (%s)", user.ID, user.Username)
+	c.JSON(http.StatusCreated, wrapper.NewResponse(
+		http.StatusCreated,
+		constant.Success,
+		response.NewUserResponse(user),
+		constant.SuccessMess,
+	))
+}
+
+// SetUserRoles handles PUT /api/users/:userId/roles
+func (h *UserHandler) SetUserRoles(c *gin.Context) {
+	var req request.UserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid roles: %v", err)),
+		))
+		return
+	}
+
+	user, err := h.authService.SetRoles(c.Request.Context(), c.Param("userId"), req.Roles, c.GetString("username"))
+	if err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	log.Printf("Set roles of user %s to %v", user.Username, req.Roles)
+	wrapper.JSONOk(c, response.NewUserResponse(user))
+}
+
+// respondUserError maps user store errors to HTTP responses
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError("User not found"),
+		))
+	case stderrors.Is(err, service.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+	case stderrors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, wrapper.NewErrorResponse(
+			errors.NewConflictError(err.Error()),
+		))
+	default:
+		log.Printf("User request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+			errors.NewInternalServerError(fmt.Sprintf("User request failed: %v", err)),
+		))
+	}
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/http/dto/request"
+	"ditto/internal/http/dto/response"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/internal/service"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhook handles POST /api/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req request.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid webhook: %v", err)),
+		))
+		return
+	}
+
+	spec := req.WebhookSpec()
+	if err := h.webhookService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	webhook, err := h.webhookService.Create(c.Request.Context(), spec, c.GetString("username"))
+	if err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	log.Printf("Created webhook %s (%s)", webhook.ID, webhook.Name)
+	// The secret is only disclosed once, when the webhook is created
+	resp := response.NewWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+	c.JSON(http.StatusCreated, wrapper.NewResponse(
+		http.StatusCreated,
+		constant.Success,
+		resp,
+		constant.SuccessMess,
+	))
+}
+
+// ListWebhooks handles GET /api/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	limit, offset := pageFromRequest(c)
+	webhooks, total, err := h.webhookService.List(c.Request.Context(), limit, offset)
+	if err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewWebhookListResponse(webhooks, total))
+}
+
+// GetWebhook handles GET /api/webhooks/:webhookId
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, err := h.webhookService.Get(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewWebhookResponse(webhook))
+}
+
+// UpdateWebhook handles PUT /api/webhooks/:webhookId
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req request.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(fmt.Sprintf("Invalid webhook: %v", err)),
+		))
+		return
+	}
+
+	spec := req.WebhookSpec()
+	if err := h.webhookService.Validate(spec); err != nil {
+		c.JSON(http.StatusBadRequest, wrapper.NewErrorResponse(
+			errors.NewBadRequestError(err.Error()),
+		))
+		return
+	}
+
+	webhook, err := h.webhookService.Update(c.Request.Context(), c.Param("webhookId"), spec, c.GetString("username"))
+	if err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	log.Printf("Updated webhook %s (%s)", webhook.ID, webhook.Name)
+	wrapper.JSONOk(c, response.NewWebhookResponse(webhook))
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/:webhookId
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id := c.Param("webhookId")
+	if err := h.webhookService.Delete(c.Request.Context(), id, c.GetString("username")); err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	log.Printf("Deleted webhook %s", id)
+	c.Status(http.StatusNoContent)
+}
+
+// TestWebhook handles POST /api/webhooks/:webhookId/test
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	delivery, err := h.webhookService.Test(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		respondWebhookError(c, err, "Webhook")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewWebhookDeliveryResponse(delivery),
+		"Test event queued",
+	))
+}
+
+// ListWebhookDeliveries handles GET /api/webhooks/:webhookId/deliveries
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	h.listDeliveries(c, repository.DeliveryFilter{
+		WebhookID: c.Param("webhookId"),
+		Status:    c.Query("status"),
+	})
+}
+
+// ListDeliveries handles GET /api/webhooks/deliveries
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	h.listDeliveries(c, repository.DeliveryFilter{
+		WebhookID: c.Query("webhook_id"),
+		Status:    c.Query("status"),
+	})
+}
+
+// ListDeadLetters handles GET /api/webhooks/dead-letters
+func (h *WebhookHandler) ListDeadLetters(c *gin.Context) {
+	h.listDeliveries(c, repository.DeliveryFilter{
+		WebhookID: c.Query("webhook_id"),
+		Status:    enum.DeliveryDead,
+	})
+}
+
+// GetDelivery handles GET /api/webhooks/deliveries/:deliveryId
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.webhookService.GetDelivery(c.Request.Context(), c.Param("deliveryId"))
+	if err != nil {
+		respondWebhookError(c, err, "Delivery")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewWebhookDeliveryResponse(delivery))
+}
+
+// Redeliver handles POST /api/webhooks/deliveries/:deliveryId/redeliver
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.webhookService.Redeliver(c.Request.Context(), c.Param("deliveryId"))
+	if err != nil {
+		if stderrors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+				errors.NewNotFoundError("No dead delivery found"),
+			))
+			return
+		}
+		respondWebhookError(c, err, "Delivery")
+		return
+	}
+
+	log.Printf("Redelivering webhook delivery %s", delivery.ID)
+	c.JSON(http.StatusAccepted, wrapper.NewResponse(
+		http.StatusAccepted,
+		constant.Processing,
+		response.NewWebhookDeliveryResponse(delivery),
+		"Delivery queued",
+	))
+}
+
+// listDeliveries responds with a page of the delivery log
+func (h *WebhookHandler) listDeliveries(c *gin.Context, filter repository.DeliveryFilter) {
+	filter.Limit, filter.Offset = pageFromRequest(c)
+	deliveries, total, err := h.webhookService.Deliveries(c.Request.Context(), filter)
+	if err != nil {
+		respondWebhookError(c, err, "Delivery")
+		return
+	}
+
+	wrapper.JSONOk(c, response.NewWebhookDeliveryListResponse(deliveries, total))
+}
+
+// respondWebhookError maps webhook errors to API error responses
+func respondWebhookError(c *gin.Context, err error, resource string) {
+	if stderrors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, wrapper.NewErrorResponse(
+			errors.NewNotFoundError(fmt.Sprintf("%s not found", resource)),
+		))
+		return
+	}
+
+	log.Printf("%s request failed: %v", resource, err)
+	c.JSON(http.StatusInternalServerError, wrapper.NewErrorResponse(
+		errors.NewInternalServerError(fmt.Sprintf("%s request failed: %v", resource, err)),
+	))
+}
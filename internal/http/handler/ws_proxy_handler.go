@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ditto/internal/ditto"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsProxyReadLimit bounds the size of a Ditto protocol message sent by a client
+	wsProxyReadLimit = 1 << 20
+	// wsProxyWriteTimeout bounds a single frame write to either side
+	wsProxyWriteTimeout = 10 * time.Second
+
+	startSendPrefix = "START-SEND-"
+	stopSendPrefix  = "STOP-SEND-"
+	jwtTokenPrefix  = "JWT-TOKEN"
+)
+
+// WSPermissions is what a client of the Ditto WebSocket proxy may do
+type WSPermissions struct {
+	// Streams are the allowed START-SEND-* suffixes, such as EVENTS or LIVE-COMMANDS
+	Streams []string
+	// Namespaces restrict subscriptions and Ditto protocol messages, all namespaces when empty
+	Namespaces []string
+	// ModifyTwins allows twin commands other than retrieve
+	ModifyTwins bool
+	// SendMessages allows live commands, live events and messages
+	SendMessages bool
+	// ModifyPolicies allows policy commands other than retrieve
+	ModifyPolicies bool
+}
+
+// restrictsMessages tells whether Ditto protocol messages must be inspected
+func (p WSPermissions) restrictsMessages() bool {
+	return len(p.Namespaces) > 0 || !p.ModifyTwins || !p.SendMessages || !p.ModifyPolicies
+}
+
+// allowsNamespace tells whether a namespace may be used
+func (p WSPermissions) allowsNamespace(namespace string) bool {
+	return len(p.Namespaces) == 0 || slices.Contains(p.Namespaces, namespace)
+}
+
+// WSPermissionsFunc returns the permissions of the client of a request
+type WSPermissionsFunc func(c *gin.Context) WSPermissions
+
+// WSProxyHandler relays Ditto WebSocket connections. Clients are authenticated
+// like every /api request, the upstream connection uses the service credentials
+// or the forwarded identity of the client.
+type WSProxyHandler struct {
+	targetURL   string
+	credentials *ditto.Credentials
+	permissions WSPermissionsFunc
+	upgrader    websocket.Upgrader
+	dialer      *websocket.Dialer
+}
+
+// NewWSProxyHandler creates a proxy to the Ditto WebSocket at targetURL, /ws/2 is appended
+// when missing. Browsers may connect from the server origin and allowedOrigins.
+func NewWSProxyHandler(targetURL string, allowedOrigins []string, credentials *ditto.Credentials, permissions WSPermissionsFunc) *WSProxyHandler {
+	if targetURL != "" && !strings.HasSuffix(strings.TrimRight(targetURL, "/"), "/ws/2") {
+		targetURL = strings.TrimRight(targetURL, "/") + "/ws/2"
+	}
+
+	return &WSProxyHandler{
+		targetURL:   targetURL,
+		credentials: credentials,
+		permissions: permissions,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			CheckOrigin:     wsOriginChecker(allowedOrigins),
+		},
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Proxy handles GET /api/ws/2
+func (h *WSProxyHandler) Proxy(c *gin.Context) {
+	if h.targetURL == "" {
+		respondWSProxyUnavailable(c, "WebSocket proxy is not configured")
+		return
+	}
+	permissions := h.permissions(c)
+
+	header, err := h.credentials.Header(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, wrapper.NewErrorResponse(
+			errors.NewUnauthorizedError("A bearer token is required to call Ditto"),
+		))
+		return
+	}
+
+	// The upstream connection is opened first so a Ditto outage is reported as an HTTP error
+	upstream, resp, err := h.dialer.DialContext(c.Request.Context(), h.targetURL, header)
+	if err != nil {
+		if resp != nil {
+			log.Printf("Failed to connect to Ditto WebSocket: %v (HTTP status %s)", err, resp.Status)
+		} else {
+			log.Printf("Failed to connect to Ditto WebSocket: %v", err)
+		}
+		respondWSProxyUnavailable(c, "Ditto WebSocket is unavailable")
+		return
+	}
+	defer upstream.Close()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already responded
+		log.Printf("Failed to upgrade proxy connection: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsProxyReadLimit)
+
+	client := &wsProxyConn{conn: conn}
+	user := c.GetString("username")
+	log.Printf("Proxying Ditto WebSocket for %s", user)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		h.relayFromClient(client, upstream, permissions, user)
+	}()
+	go func() {
+		defer func() { done <- struct{}{} }()
+		relayFromUpstream(upstream, client)
+	}()
+
+	// Either side going away ends the session, closing both connections stops the other relay
+	<-done
+}
+
+// relayFromClient passes the client frames upstream, refusing the subscriptions
+// and messages the client is not allowed to use
+func (h *WSProxyHandler) relayFromClient(client *wsProxyConn, upstream *websocket.Conn, permissions WSPermissions, user string) {
+	for {
+		messageType, data, err := client.conn.ReadMessage()
+		if err != nil {
+			forwardClose(upstream, err)
+			return
+		}
+
+		// The Ditto protocol is text only, other frames would pass uninspected
+		reason := "binary frames are not allowed"
+		if messageType == websocket.TextMessage {
+			data, reason = filterProtocolMessage(data, permissions, h.credentials.ForwardsIdentity())
+		}
+		if reason != "" {
+			log.Printf("Refused Ditto WebSocket message of %s: %s", user, reason)
+			if err := client.write(websocket.TextMessage, wsProxyError(reason)); err != nil {
+				return
+			}
+			continue
+		}
+
+		_ = upstream.SetWriteDeadline(time.Now().Add(wsProxyWriteTimeout))
+		if err := upstream.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// wsOriginChecker returns the origin check of a WebSocket upgrader. Browsers send
+// the origin of the page opening the WebSocket, which must be the server itself
+// or one of the allowed origins, * allows all. Other clients send no origin.
+func wsOriginChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || slices.Contains(allowed, "*") {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return slices.ContainsFunc(allowed, func(a string) bool {
+			return strings.EqualFold(strings.TrimRight(a, "/"), origin)
+		})
+	}
+}
+
+// relayFromUpstream passes the Ditto frames to the client
+func relayFromUpstream(upstream *websocket.Conn, client *wsProxyConn) {
+	for {
+		messageType, data, err := upstream.ReadMessage()
+		if err != nil {
+			forwardClose(client.conn, err)
+			return
+		}
+		if err := client.write(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// filterProtocolMessage checks a text frame of the Ditto protocol. START-SEND-*
+// requests must name an allowed stream and are limited to the allowed namespaces,
+// JSON messages must address an allowed namespace and be a command the client
+// may send. JWT-TOKEN frames would replace the identity of the session, they are
+// refused when the proxy forwards the identity of the client. It returns the
+// frame to send upstream, or the reason it is refused.
+func filterProtocolMessage(data []byte, permissions WSPermissions, forwardsIdentity bool) ([]byte, string) {
+	text := string(data)
+	switch {
+	case strings.HasPrefix(text, startSendPrefix):
+		stream, rawQuery, _ := strings.Cut(strings.TrimPrefix(text, startSendPrefix), "?")
+		if !slices.Contains(permissions.Streams, stream) {
+			return nil, fmt.Sprintf("%s%s is not allowed", startSendPrefix, stream)
+		}
+		if len(permissions.Namespaces) == 0 {
+			return data, ""
+		}
+
+		params, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, "invalid subscription parameters"
+		}
+		// Every value is checked, Ditto would read the namespaces of a repeated parameter too
+		var requested []string
+		for _, value := range params["namespaces"] {
+			requested = append(requested, splitList(value)...)
+		}
+		for _, namespace := range requested {
+			if !permissions.allowsNamespace(namespace) {
+				return nil, fmt.Sprintf("namespace %s is not allowed", namespace)
+			}
+		}
+		if len(requested) == 0 {
+			requested = permissions.Namespaces
+		}
+		params.Set("namespaces", strings.Join(requested, ","))
+		return []byte(startSendPrefix + stream + "?" + params.Encode()), ""
+
+	case strings.HasPrefix(text, jwtTokenPrefix):
+		if forwardsIdentity {
+			return nil, fmt.Sprintf("%s is not allowed, the proxy authenticates the session", jwtTokenPrefix)
+		}
+		return data, ""
+
+	case strings.HasPrefix(text, stopSendPrefix):
+		return data, ""
+
+	default:
+		if !permissions.restrictsMessages() {
+			return data, ""
+		}
+		topic, err := protocolTopic(data)
+		if err != nil {
+			return nil, "invalid message"
+		}
+		if reason := checkProtocolTopic(topic, permissions); reason != "" {
+			return nil, reason
+		}
+		return data, ""
+	}
+}
+
+// protocolTopic returns the topic of a Ditto protocol message. Keys are read as
+// they are written: a message repeating a key, in any case, is refused, since
+// encoding/json and Ditto could pick different values for it.
+func protocolTopic(data []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return "", err
+	}
+	seen := make(map[string]bool, len(fields))
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		key := strings.ToLower(token.(string))
+		if seen[key] {
+			return "", fmt.Errorf("duplicate key %q", token)
+		}
+		seen[key] = true
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return "", err
+		}
+	}
+
+	var topic string
+	if raw, ok := fields["topic"]; ok {
+		if err := json.Unmarshal(raw, &topic); err != nil {
+			return "", err
+		}
+	}
+	return topic, nil
+}
+
+// checkProtocolTopic checks the topic of a Ditto protocol message, of the form
+// namespace/name/things/channel/criterion/action or namespace/name/policies/criterion/action.
+// Topics of another form are refused, Ditto could read them differently than the proxy.
+func checkProtocolTopic(topic string, permissions WSPermissions) string {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 5 || slices.Contains(parts[:5], "") {
+		return fmt.Sprintf("invalid topic %q", topic)
+	}
+	if !permissions.allowsNamespace(parts[0]) {
+		return fmt.Sprintf("namespace %s is not allowed", parts[0])
+	}
+
+	action := parts[len(parts)-1]
+	switch {
+	case parts[2] == "policies":
+		if parts[3] == "commands" && action != "retrieve" && !permissions.ModifyPolicies {
+			return "modifying policies is not allowed"
+		}
+	case parts[2] != "things" || len(parts) < 6 || (parts[3] != "twin" && parts[3] != "live"):
+		return fmt.Sprintf("invalid topic %q", topic)
+	case parts[3] == "live" || parts[4] == "messages":
+		if !permissions.SendMessages {
+			return "sending live commands and messages is not allowed"
+		}
+	case parts[4] == "commands":
+		if action != "retrieve" && !permissions.ModifyTwins {
+			return "modifying twins is not allowed"
+		}
+	}
+	return ""
+}
+
+// wsProxyError builds a Ditto protocol error telling the client a message was refused
+func wsProxyError(message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"topic":  "_/_/things/twin/errors",
+		"path":   "/",
+		"status": http.StatusForbidden,
+		"value": map[string]interface{}{
+			"status":  http.StatusForbidden,
+			"error":   "proxy:websocket.forbidden",
+			"message": message,
+		},
+	})
+	return data
+}
+
+// forwardClose passes the close code of one side to the other, it is safe
+// to call concurrently with the writes of the relays
+func forwardClose(conn *websocket.Conn, err error) {
+	code, text := websocket.CloseGoingAway, ""
+	if closeErr, ok := err.(*websocket.CloseError); ok && closeErr.Code != websocket.CloseNoStatusReceived {
+		code, text = closeErr.Code, closeErr.Text
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsProxyWriteTimeout))
+	_ = conn.Close()
+}
+
+// wsProxyConn serializes the writes to the client, which come from both relays
+type wsProxyConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsProxyConn) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsProxyWriteTimeout))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// respondWSProxyUnavailable reports that the proxy cannot reach Ditto
+func respondWSProxyUnavailable(c *gin.Context, message string) {
+	c.JSON(http.StatusServiceUnavailable, wrapper.NewResponse(
+		http.StatusServiceUnavailable,
+		constant.IllegalStateError,
+		nil,
+		message,
+	))
+}
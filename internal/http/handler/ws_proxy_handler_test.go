@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ditto/internal/ditto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestWSOriginChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{name: "no origin", origin: "", want: true},
+		{name: "same host", origin: "http://api.example.com", want: true},
+		{name: "same host other scheme", origin: "https://API.example.com", want: true},
+		{name: "foreign origin", origin: "https://evil.example.org", want: false},
+		{name: "allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", want: true},
+		{name: "allowed origin with slash", allowed: []string{"https://app.example.com/"}, origin: "https://app.example.com", want: true},
+		{name: "allowed host other scheme", allowed: []string{"https://app.example.com"}, origin: "http://app.example.com", want: false},
+		{name: "allowed host other port", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com:8443", want: false},
+		{name: "wildcard", allowed: []string{"*"}, origin: "https://evil.example.org", want: true},
+		{name: "invalid origin", allowed: []string{"https://app.example.com"}, origin: "://", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/ws/2", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := wsOriginChecker(tt.allowed)(req); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// wsProxyTest runs the WebSocket proxy in front of a fake Ditto WebSocket, which
+// passes the frames it receives to upstream
+type wsProxyTest struct {
+	proxy    *httptest.Server
+	upstream chan []byte
+}
+
+func newWSProxyTest(t *testing.T, permissions WSPermissions, allowedOrigins []string) *wsProxyTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	received := make(chan []byte, 16)
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- data
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	credentials := newTestCredentials(t)
+	h := NewWSProxyHandler("ws"+strings.TrimPrefix(upstream.URL, "http"), allowedOrigins, credentials, func(c *gin.Context) WSPermissions {
+		return permissions
+	})
+
+	engine := gin.New()
+	engine.GET("/api/ws/2", h.Proxy)
+	proxy := httptest.NewServer(engine)
+	t.Cleanup(proxy.Close)
+
+	return &wsProxyTest{proxy: proxy, upstream: received}
+}
+
+func (p *wsProxyTest) dial(t *testing.T, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(p.proxy.URL, "http")+"/api/ws/2", header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// expectUpstream waits for the next frame received by Ditto
+func (p *wsProxyTest) expectUpstream(t *testing.T, want string) {
+	t.Helper()
+	select {
+	case data := <-p.upstream:
+		if string(data) != want {
+			t.Fatalf("expected Ditto to receive %q, got %q", want, data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected Ditto to receive %q", want)
+	}
+}
+
+// expectRefused waits for the error answering a refused frame
+func expectRefused(t *testing.T, conn *websocket.Conn, reason string) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected a refusal: %v", err)
+	}
+	var refusal struct {
+		Status int `json:"status"`
+		Value  struct {
+			Message string `json:"message"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &refusal); err != nil {
+		t.Fatalf("invalid refusal %q: %v", data, err)
+	}
+	if refusal.Status != http.StatusForbidden || !strings.Contains(refusal.Value.Message, reason) {
+		t.Fatalf("expected a 403 refusal containing %q, got %s", reason, data)
+	}
+}
+
+func newTestCredentials(t *testing.T) *ditto.Credentials {
+	t.Helper()
+	credentials, err := ditto.NewCredentials("ditto", "ditto", ditto.IdentityService, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return credentials
+}
+
+func TestWSProxyRefusesBinaryFrames(t *testing.T) {
+	p := newWSProxyTest(t, WSPermissions{Streams: []string{"EVENTS"}, ModifyTwins: true, SendMessages: true, ModifyPolicies: true}, nil)
+	conn, _, err := p.dial(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("START-SEND-LIVE-COMMANDS")); err != nil {
+		t.Fatal(err)
+	}
+	expectRefused(t, conn, "binary frames are not allowed")
+
+	// The session goes on and text frames are still relayed
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("START-SEND-EVENTS")); err != nil {
+		t.Fatal(err)
+	}
+	p.expectUpstream(t, "START-SEND-EVENTS")
+}
+
+func TestWSProxyChecksOrigin(t *testing.T) {
+	p := newWSProxyTest(t, WSPermissions{}, []string{"https://app.example.com"})
+
+	_, resp, err := p.dial(t, http.Header{"Origin": {"https://evil.example.org"}})
+	if err == nil {
+		t.Fatal("expected a foreign origin to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", resp)
+	}
+
+	if _, _, err := p.dial(t, http.Header{"Origin": {"https://app.example.com"}}); err != nil {
+		t.Fatalf("expected an allowed origin to connect: %v", err)
+	}
+}
+
+func TestFilterProtocolMessage(t *testing.T) {
+	reader := WSPermissions{Streams: []string{"EVENTS", "LIVE-EVENTS"}}
+	operator := WSPermissions{Streams: []string{"EVENTS", "MESSAGES"}, ModifyTwins: true, SendMessages: true}
+	tenant := WSPermissions{Streams: []string{"EVENTS"}, Namespaces: []string{"org.a", "org.b"}, ModifyTwins: true, SendMessages: true, ModifyPolicies: true}
+	admin := WSPermissions{Streams: []string{"EVENTS"}, ModifyTwins: true, SendMessages: true, ModifyPolicies: true}
+
+	tests := []struct {
+		name        string
+		permissions WSPermissions
+		forwards    bool
+		message     string
+		// want is the frame sent upstream, the message itself when empty
+		want   string
+		reason string
+	}{
+		// Subscriptions
+		{name: "allowed stream", permissions: reader, message: "START-SEND-EVENTS"},
+		{name: "allowed stream with filter", permissions: reader, message: "START-SEND-LIVE-EVENTS?filter=eq(attributes/a,1)"},
+		{name: "stream not allowed", permissions: reader, message: "START-SEND-MESSAGES", reason: "START-SEND-MESSAGES is not allowed"},
+		{name: "unknown stream", permissions: reader, message: "START-SEND-EVENTSX", reason: "is not allowed"},
+		{name: "namespaces default to the allowed ones", permissions: tenant, message: "START-SEND-EVENTS", want: "START-SEND-EVENTS?namespaces=org.a%2Corg.b"},
+		{name: "allowed namespace", permissions: tenant, message: "START-SEND-EVENTS?namespaces=org.b", want: "START-SEND-EVENTS?namespaces=org.b"},
+		{name: "namespace not allowed", permissions: tenant, message: "START-SEND-EVENTS?namespaces=org.a,org.c", reason: "namespace org.c is not allowed"},
+		{name: "repeated namespaces parameter", permissions: tenant, message: "START-SEND-EVENTS?namespaces=org.a&namespaces=org.c", reason: "namespace org.c is not allowed"},
+		{name: "repeated allowed namespaces are joined", permissions: tenant, message: "START-SEND-EVENTS?namespaces=org.a&namespaces=org.b", want: "START-SEND-EVENTS?namespaces=org.a%2Corg.b"},
+		{name: "invalid subscription", permissions: tenant, message: "START-SEND-EVENTS?namespaces=%zz", reason: "invalid subscription parameters"},
+		{name: "stop", permissions: reader, message: "STOP-SEND-MESSAGES"},
+
+		// Tokens
+		{name: "token with service credentials", permissions: reader, message: "JWT-TOKEN?jwtToken=abc"},
+		{name: "token with forwarded identity", permissions: reader, forwards: true, message: "JWT-TOKEN?jwtToken=abc", reason: "JWT-TOKEN is not allowed"},
+		{name: "token of an unrestricted client with forwarded identity", permissions: admin, forwards: true, message: "JWT-TOKEN?jwtToken=abc", reason: "JWT-TOKEN is not allowed"},
+
+		// Twin commands
+		{name: "retrieve twin", permissions: reader, message: `{"topic":"org.a/dev/things/twin/commands/retrieve"}`},
+		{name: "modify twin", permissions: operator, message: `{"topic":"org.a/dev/things/twin/commands/modify"}`},
+		{name: "modify twin without permission", permissions: reader, message: `{"topic":"org.a/dev/things/twin/commands/modify"}`, reason: "modifying twins is not allowed"},
+		{name: "search", permissions: reader, message: `{"topic":"_/_/things/twin/search/subscribe"}`},
+
+		// Live commands and messages
+		{name: "live command", permissions: operator, message: `{"topic":"org.a/dev/things/live/commands/modify"}`},
+		{name: "live command without permission", permissions: reader, message: `{"topic":"org.a/dev/things/live/commands/retrieve"}`, reason: "sending live commands and messages is not allowed"},
+		{name: "message", permissions: operator, message: `{"topic":"org.a/dev/things/live/messages/reboot/now"}`},
+		{name: "message without permission", permissions: reader, message: `{"topic":"org.a/dev/things/live/messages/reboot"}`, reason: "sending live commands and messages is not allowed"},
+
+		// Policies
+		{name: "retrieve policy", permissions: reader, message: `{"topic":"org.a/p/policies/commands/retrieve"}`},
+		{name: "modify policy without permission", permissions: operator, message: `{"topic":"org.a/p/policies/commands/modify"}`, reason: "modifying policies is not allowed"},
+		{name: "modify policy", permissions: tenant, message: `{"topic":"org.a/p/policies/commands/modify"}`},
+
+		// Namespaces
+		{name: "allowed namespace message", permissions: tenant, message: `{"topic":"org.b/dev/things/twin/commands/modify"}`},
+		{name: "namespace of message not allowed", permissions: tenant, message: `{"topic":"org.c/dev/things/twin/commands/retrieve"}`, reason: "namespace org.c is not allowed"},
+
+		// Malformed messages
+		{name: "not json", permissions: reader, message: "hello", reason: "invalid message"},
+		{name: "no topic", permissions: reader, message: `{"path":"/"}`, reason: "invalid topic"},
+		{name: "short topic", permissions: reader, message: `{"topic":"org.a/dev/things"}`, reason: "invalid topic"},
+		{name: "short topic skipping the namespace check", permissions: tenant, message: `{"topic":"org.c"}`, reason: "invalid topic"},
+		{name: "short things topic", permissions: reader, message: `{"topic":"org.a/dev/things/live/messages"}`, reason: "invalid topic"},
+		{name: "empty segment", permissions: reader, message: `{"topic":"org.a//things/twin/commands/modify"}`, reason: "invalid topic"},
+		{name: "unknown channel", permissions: reader, message: `{"topic":"org.a/dev/things/other/commands/modify"}`, reason: "invalid topic"},
+		{name: "duplicate topic", permissions: reader, message: `{"topic":"org.a/dev/things/twin/commands/modify","topic":"org.a/dev/things/twin/commands/retrieve"}`, reason: "invalid message"},
+		{name: "case variant of the topic", permissions: reader, message: `{"topic":"org.a/dev/things/twin/commands/modify","TOPIC":"org.a/dev/things/twin/commands/retrieve"}`, reason: "invalid message"},
+		{name: "case variant of another key", permissions: reader, message: `{"topic":"org.a/dev/things/twin/commands/retrieve","path":"/","Path":"/attributes"}`, reason: "invalid message"},
+		{name: "only a case variant of the topic", permissions: reader, message: `{"Topic":"org.a/dev/things/twin/commands/retrieve"}`, reason: "invalid topic"},
+		{name: "topic is not a string", permissions: reader, message: `{"topic":1}`, reason: "invalid message"},
+		{name: "not an object", permissions: reader, message: `["org.a/dev/things/twin/commands/retrieve"]`, reason: "invalid message"},
+		{name: "unknown group", permissions: reader, message: `{"topic":"org.a/dev/connections/twin/commands/modify"}`, reason: "invalid topic"},
+
+		// Clients holding every permission are not inspected
+		{name: "unrestricted client", permissions: admin, message: "anything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, reason := filterProtocolMessage([]byte(tt.message), tt.permissions, tt.forwards)
+			if tt.reason != "" {
+				if !strings.Contains(reason, tt.reason) {
+					t.Fatalf("expected a refusal containing %q, got %q (frame %q)", tt.reason, reason, data)
+				}
+				return
+			}
+			if reason != "" {
+				t.Fatalf("unexpected refusal: %s", reason)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.message
+			}
+			if string(data) != want {
+				t.Errorf("expected frame %q, got %q", want, data)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package http
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"go.uber.org/fx"
 
 	"ditto/config"
+	"ditto/internal/ditto"
 	"ditto/internal/http/handler"
 	"ditto/internal/http/router"
+	"ditto/internal/rbac"
+	"ditto/pkg/constant"
 )
 
 func NewGinEngine() *gin.Engine {
@@ -14,14 +19,58 @@ func NewGinEngine() *gin.Engine {
 	return engine
 }
 
-func NewProxyHandler(cfg *config.Config) *handler.ProxyHandler {
-	return handler.NewProxyHandler(cfg.Proxy.TargetURL, cfg.Proxy.AuthUsername, cfg.Proxy.AuthPassword)
+func NewProxyHandler(cfg *config.Config) (*handler.ProxyHandler, error) {
+	credentials, err := ditto.NewCredentials(cfg.Proxy.AuthUsername, cfg.Proxy.AuthPassword, cfg.Ditto.IdentityMode, cfg.Ditto.PreAuthIssuer)
+	if err != nil {
+		return nil, err
+	}
+	return handler.NewProxyHandler(cfg.Proxy, credentials)
+}
+
+func NewWSProxyHandler(cfg *config.Config) (*handler.WSProxyHandler, error) {
+	credentials, err := ditto.NewCredentials(cfg.Ditto.Username, cfg.Ditto.Password, cfg.Ditto.IdentityMode, cfg.Ditto.PreAuthIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The streams configured for the proxy are narrowed down to those the permissions of the client allow
+	streams := splitList(cfg.Proxy.WSStreams)
+	namespaces := splitList(cfg.Proxy.WSNamespaces)
+	origins := splitList(cfg.Server.WSAllowedOrigins)
+	return handler.NewWSProxyHandler(cfg.Proxy.WSURL, origins, credentials, func(c *gin.Context) handler.WSPermissions {
+		granted := c.GetStringSlice(constant.Permissions)
+		permissions := handler.WSPermissions{
+			Namespaces:     namespaces,
+			ModifyTwins:    rbac.Allows(granted, rbac.DevicesWrite),
+			SendMessages:   rbac.Allows(granted, rbac.CommandsSend),
+			ModifyPolicies: rbac.Allows(granted, rbac.PoliciesAdmin),
+		}
+		for _, stream := range streams {
+			if rbac.Allows(granted, rbac.StreamPermission(stream)) {
+				permissions.Streams = append(permissions.Streams, stream)
+			}
+		}
+		return permissions
+	}), nil
+}
+
+// splitList splits a comma separated setting
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
 
 var Module = fx.Options(
+	handler.Module,
 	fx.Provide(
 		NewGinEngine,
 		NewProxyHandler,
+		NewWSProxyHandler,
 		router.NewRouter,
 	),
 	fx.Invoke(func(r *router.Router) {
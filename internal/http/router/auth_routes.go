@@ -0,0 +1,34 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authPublicPaths are reachable without credentials, the request body carries them
+var authPublicPaths = map[string]bool{
+	"/api/auth/login":   true,
+	"/api/auth/refresh": true,
+	"/api/auth/logout":  true,
+}
+
+// SetupAuthRoutes configures login and token routes
+func SetupAuthRoutes(router *gin.RouterGroup, authHandler *handler.AuthHandler) {
+	authGroup := router.Group("/auth")
+	{
+		// Exchange a username and password for a token pair
+		authGroup.POST("/login", authHandler.Login)
+
+		// Rotate a refresh token and revoke the login it belongs to
+		authGroup.POST("/refresh", authHandler.Refresh)
+		authGroup.POST("/logout", authHandler.Logout)
+	}
+
+	log.Printf("Registered auth routes:")
+	log.Printf("POST /api/auth/login")
+	log.Printf("POST /api/auth/refresh")
+	log.Printf("POST /api/auth/logout")
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBulkUpdateRoutes configures bulk update routes
+func SetupBulkUpdateRoutes(router *gin.RouterGroup, bulkUpdateHandler *handler.BulkUpdateHandler) {
+	bulkUpdateGroup := router.Group("/devices/bulk-update", middleware.RequirePermission(rbac.DevicesWrite))
+	{
+		// Count and sample the things a filter matches
+		bulkUpdateGroup.POST("/preview", bulkUpdateHandler.Preview)
+
+		// Apply a merge patch to all matching things as a background job
+		bulkUpdateGroup.POST("", bulkUpdateHandler.Apply)
+	}
+
+	log.Printf("Registered bulk update routes:")
+	log.Printf("POST /api/devices/bulk-update/preview")
+	log.Printf("POST /api/devices/bulk-update")
+}
@@ -0,0 +1,33 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupCommandRoutes configures command history routes
+func SetupCommandRoutes(router *gin.RouterGroup, commandHandler *handler.CommandHandler, broadcastHandler *handler.BroadcastHandler) {
+	commandGroup := router.Group("/commands", middleware.RequirePermission(rbac.DevicesRead))
+	{
+		// List the command history, filtered by device, user and status
+		commandGroup.GET("", commandHandler.ListCommands)
+
+		// Get the outcome of a command
+		commandGroup.GET("/:commandId", commandHandler.GetCommand)
+
+		// Send a command to every device matching a filter
+		commandGroup.POST("/broadcast", middleware.RequirePermission(rbac.CommandsSend), broadcastHandler.Broadcast)
+		commandGroup.GET("/broadcast/:jobId", broadcastHandler.GetBroadcast)
+	}
+
+	log.Printf("Registered command routes:")
+	log.Printf("GET /api/commands")
+	log.Printf("GET /api/commands/:commandId")
+	log.Printf("POST /api/commands/broadcast")
+	log.Printf("GET /api/commands/broadcast/:jobId")
+}
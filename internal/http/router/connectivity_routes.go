@@ -0,0 +1,30 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupConnectivityRoutes configures device connectivity routes
+func SetupConnectivityRoutes(router *gin.RouterGroup, connectivityHandler *handler.ConnectivityHandler) {
+	connectivityGroup := router.Group("", middleware.RequirePermission(rbac.DevicesRead))
+
+	// Online/offline state of the fleet, filtered by online and definition
+	connectivityGroup.GET("/devices/connectivity", connectivityHandler.ListConnectivity)
+
+	// Online and offline counts, in total and per definition
+	connectivityGroup.GET("/devices/connectivity/summary", connectivityHandler.GetConnectivitySummary)
+
+	// Online/offline state and last-seen time of a device
+	connectivityGroup.GET("/devices/:thingId/connectivity", connectivityHandler.GetDeviceConnectivity)
+
+	log.Printf("Registered connectivity routes:")
+	log.Printf("GET /api/devices/connectivity")
+	log.Printf("GET /api/devices/connectivity/summary")
+	log.Printf("GET /api/devices/:thingId/connectivity")
+}
@@ -4,38 +4,58 @@ import (
 	"log"
 
 	"ditto/config"
+	"ditto/internal/ditto"
 	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupDeviceRoutes configures all device-related routes
-func SetupDeviceRoutes(router *gin.RouterGroup, config *config.Config) {
+func SetupDeviceRoutes(router *gin.RouterGroup, config *config.Config, dittoClient *ditto.Client, commandHandler *handler.CommandHandler) {
 	// Initialize handler
-	deviceHandler := handler.NewDeviceHandler(config)
+	deviceHandler := handler.NewDeviceHandler(config, dittoClient)
 
-	// Device routes group
-	deviceGroup := router.Group("/devices")
+	// Device routes group, every route reads devices and writes need more
+	deviceGroup := router.Group("/devices", middleware.RequirePermission(rbac.DevicesRead))
 	{
 		// List things with filtering
 		deviceGroup.GET("", deviceHandler.ListThings)
 
+		// Count things matching the list filters
+		deviceGroup.GET("/count", deviceHandler.CountThings)
+
+		// Stream all matching things as NDJSON
+		deviceGroup.GET("/stream", deviceHandler.StreamThings)
+
+		// Search things with arbitrary predicates
+		deviceGroup.POST("/search", deviceHandler.SearchThings)
+
 		// Create/Update thing
-		deviceGroup.PUT("/:thingId", deviceHandler.CreateThing)
+		deviceGroup.PUT("/:thingId", middleware.RequirePermission(rbac.DevicesWrite), deviceHandler.CreateThing)
 
 		// Get thing state
 		deviceGroup.GET("/:thingId/state", deviceHandler.GetThingState)
 
 		// Send commands
-		deviceGroup.PUT("/:thingId/features/:feature/command", deviceHandler.SendCommand)
-		deviceGroup.POST("/:thingId/features/:feature/command", deviceHandler.SendCommand)
+		sendCommand := middleware.RequirePermission(rbac.CommandsSend)
+		deviceGroup.PUT("/:thingId/features/:feature/command", sendCommand, commandHandler.SendCommand)
+		deviceGroup.POST("/:thingId/features/:feature/command", sendCommand, commandHandler.SendCommand)
+
+		// Command history of a device
+		deviceGroup.GET("/:thingId/commands", commandHandler.ListDeviceCommands)
 	}
 
 	// Log registered device routes
 	log.Printf("Registered device routes:")
 	log.Printf("GET /api/devices")
+	log.Printf("GET /api/devices/count")
+	log.Printf("GET /api/devices/stream")
+	log.Printf("POST /api/devices/search")
 	log.Printf("PUT /api/devices/:thingId")
 	log.Printf("GET /api/devices/:thingId/state")
 	log.Printf("PUT /api/devices/:thingId/features/:feature/command")
 	log.Printf("POST /api/devices/:thingId/features/:feature/command")
+	log.Printf("GET /api/devices/:thingId/commands")
 }
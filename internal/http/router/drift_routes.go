@@ -0,0 +1,26 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDriftRoutes configures desired state reconciliation routes
+func SetupDriftRoutes(router *gin.RouterGroup, driftHandler *handler.DriftHandler) {
+	driftGroup := router.Group("", middleware.RequirePermission(rbac.DevicesRead))
+
+	// Drift of every feature of a device
+	driftGroup.GET("/devices/:thingId/drift", driftHandler.GetDeviceDrift)
+
+	// Drifts across the fleet, filtered by status
+	driftGroup.GET("/drifts", driftHandler.ListDrifts)
+
+	log.Printf("Registered drift routes:")
+	log.Printf("GET /api/devices/:thingId/drift")
+	log.Printf("GET /api/drifts")
+}
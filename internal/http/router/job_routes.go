@@ -0,0 +1,35 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupJobRoutes configures background job routes
+func SetupJobRoutes(router *gin.RouterGroup, jobHandler *handler.JobHandler) {
+	jobGroup := router.Group("/jobs", middleware.RequirePermission(rbac.DevicesRead))
+	{
+		// List jobs, filtered by type and status
+		jobGroup.GET("", jobHandler.ListJobs)
+
+		// Get job state, progress and result
+		jobGroup.GET("/:jobId", jobHandler.GetJob)
+
+		// Cancel a pending or running job, with the permission its type needs
+		jobGroup.POST("/:jobId/cancel", jobHandler.CancelJob)
+
+		// Retry a failed or cancelled job, with the permission its type needs
+		jobGroup.POST("/:jobId/retry", jobHandler.RetryJob)
+	}
+
+	log.Printf("Registered job routes:")
+	log.Printf("GET /api/jobs")
+	log.Printf("GET /api/jobs/:jobId")
+	log.Printf("POST /api/jobs/:jobId/cancel")
+	log.Printf("POST /api/jobs/:jobId/retry")
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupProvisioningRoutes configures bulk provisioning routes
+func SetupProvisioningRoutes(router *gin.RouterGroup, provisioningHandler *handler.ProvisioningHandler) {
+	bulkGroup := router.Group("/devices/bulk", middleware.RequirePermission(rbac.DevicesWrite))
+	{
+		// Provision devices from a CSV or JSON upload
+		bulkGroup.POST("", provisioningHandler.Provision)
+
+		// Poll a provisioning job
+		bulkGroup.GET("/:jobId", provisioningHandler.GetJob)
+	}
+
+	log.Printf("Registered provisioning routes:")
+	log.Printf("POST /api/devices/bulk")
+	log.Printf("GET /api/devices/bulk/:jobId")
+}
@@ -8,21 +8,80 @@ import (
 	"go.uber.org/fx"
 
 	"ditto/config"
+	"ditto/internal/ditto"
 	"ditto/internal/http/handler"
 	"ditto/internal/middleware"
+	"ditto/internal/oidc"
+	"ditto/internal/rbac"
 )
 
 type Router struct {
-	engine *gin.Engine
-	proxy  *handler.ProxyHandler
-	config *config.Config
+	engine      *gin.Engine
+	proxy       *handler.ProxyHandler
+	wsProxy     *handler.WSProxyHandler
+	config      *config.Config
+	dittoClient *ditto.Client
+	verifier    *oidc.Verifier
+	policy      *rbac.Policy
+
+	provisioningHandler *handler.ProvisioningHandler
+	jobHandler          *handler.JobHandler
+	bulkUpdateHandler   *handler.BulkUpdateHandler
+	commandHandler      *handler.CommandHandler
+	scheduleHandler     *handler.ScheduleHandler
+	broadcastHandler    *handler.BroadcastHandler
+	driftHandler        *handler.DriftHandler
+	connectivityHandler *handler.ConnectivityHandler
+	ruleHandler         *handler.RuleHandler
+	webhookHandler      *handler.WebhookHandler
+	streamHandler       *handler.StreamHandler
+	authHandler         *handler.AuthHandler
+	userHandler         *handler.UserHandler
 }
 
-func NewRouter(engine *gin.Engine, proxy *handler.ProxyHandler, config *config.Config) *Router {
+func NewRouter(
+	engine *gin.Engine,
+	proxy *handler.ProxyHandler,
+	wsProxy *handler.WSProxyHandler,
+	config *config.Config,
+	dittoClient *ditto.Client,
+	verifier *oidc.Verifier,
+	policy *rbac.Policy,
+	provisioningHandler *handler.ProvisioningHandler,
+	jobHandler *handler.JobHandler,
+	bulkUpdateHandler *handler.BulkUpdateHandler,
+	commandHandler *handler.CommandHandler,
+	scheduleHandler *handler.ScheduleHandler,
+	broadcastHandler *handler.BroadcastHandler,
+	driftHandler *handler.DriftHandler,
+	connectivityHandler *handler.ConnectivityHandler,
+	ruleHandler *handler.RuleHandler,
+	webhookHandler *handler.WebhookHandler,
+	streamHandler *handler.StreamHandler,
+	authHandler *handler.AuthHandler,
+	userHandler *handler.UserHandler,
+) *Router {
 	return &Router{
-		engine: engine,
-		proxy:  proxy,
-		config: config,
+		engine:              engine,
+		proxy:               proxy,
+		wsProxy:             wsProxy,
+		config:              config,
+		dittoClient:         dittoClient,
+		verifier:            verifier,
+		policy:              policy,
+		provisioningHandler: provisioningHandler,
+		jobHandler:          jobHandler,
+		bulkUpdateHandler:   bulkUpdateHandler,
+		commandHandler:      commandHandler,
+		scheduleHandler:     scheduleHandler,
+		broadcastHandler:    broadcastHandler,
+		driftHandler:        driftHandler,
+		connectivityHandler: connectivityHandler,
+		ruleHandler:         ruleHandler,
+		webhookHandler:      webhookHandler,
+		streamHandler:       streamHandler,
+		authHandler:         authHandler,
+		userHandler:         userHandler,
 	}
 }
 
@@ -31,6 +90,7 @@ func (r *Router) Setup() {
 	authConfig := &middleware.AuthConfig{
 		Username: r.config.Proxy.AuthUsername,
 		Password: r.config.Proxy.AuthPassword,
+		Roles:    rbac.SplitRoles(r.config.RBAC.BasicAuthRoles),
 	}
 
 	// Health check endpoint (no auth required)
@@ -40,26 +100,69 @@ func (r *Router) Setup() {
 		})
 	})
 
-	// Apply auth middleware to all routes under /api
+	// Apply auth middleware to all routes under /api, accepting access tokens and basic auth
+	auth := middleware.JWTOrBasicAuth(authConfig, r.config.JWT, r.verifier)
 	r.engine.Use(func(c *gin.Context) {
-		// Skip auth for health check
-		if c.Request.URL.Path == "/health" {
+		// Skip auth for health check and the token endpoints
+		if c.Request.URL.Path == "/health" || authPublicPaths[c.Request.URL.Path] {
 			c.Next()
 			return
 		}
 
 		// Apply auth middleware for all other routes
-		middleware.BasicAuth(authConfig)(c)
+		auth(c)
 	})
 
-	// API routes group
-	api := r.engine.Group("/api")
+	// API routes group, Ditto requests made while serving it are on behalf of the caller.
+	// The route groups check the permissions granted by the roles of the caller.
+	api := r.engine.Group("/api", middleware.DittoIdentity(), middleware.ResolvePermissions(r.policy))
 	{
+		// Setup login and token routes
+		SetupAuthRoutes(api, r.authHandler)
+
+		// Setup user management routes
+		SetupUserRoutes(api, r.userHandler)
+
 		// Setup device routes
-		SetupDeviceRoutes(api, r.config)
+		SetupDeviceRoutes(api, r.config, r.dittoClient, r.commandHandler)
+
+		// Setup bulk provisioning routes
+		SetupProvisioningRoutes(api, r.provisioningHandler)
+
+		// Setup bulk update routes
+		SetupBulkUpdateRoutes(api, r.bulkUpdateHandler)
+
+		// Setup command routes
+		SetupCommandRoutes(api, r.commandHandler, r.broadcastHandler)
+
+		// Setup scheduled command routes
+		SetupScheduleRoutes(api, r.scheduleHandler)
+
+		// Setup desired state reconciliation routes
+		SetupDriftRoutes(api, r.driftHandler)
+
+		// Setup device connectivity routes
+		SetupConnectivityRoutes(api, r.connectivityHandler)
+
+		// Setup alert rule routes
+		SetupRuleRoutes(api, r.ruleHandler)
+
+		// Setup webhook notification routes
+		SetupWebhookRoutes(api, r.webhookHandler)
+
+		// Setup live event streaming routes
+		SetupStreamRoutes(api, r.streamHandler)
+
+		// Setup background job routes
+		SetupJobRoutes(api, r.jobHandler)
+
+		// Proxy /api/things/* and /api/policies/* to Ditto through the proxy rules
+		api.Any("/things/*path", middleware.RequireRequestPermission(r.proxy.Permission), r.proxy.ProxyRequest)
+		api.Any("/policies/*path", middleware.RequireRequestPermission(r.proxy.Permission), r.proxy.ProxyRequest)
+		api.GET("/proxy/routes", middleware.RequirePermission(rbac.DevicesRead), r.proxy.Routes)
 
-		// Proxy /api/things/* to Ditto
-		api.Any("/things/*path", r.proxy.ProxyRequest)
+		// Proxy /api/ws/2 to the Ditto WebSocket, the permissions also select the allowed streams
+		api.GET("/ws/2", middleware.RequirePermission(rbac.DevicesRead), r.wsProxy.Proxy)
 	}
 
 	// Print all registered routes
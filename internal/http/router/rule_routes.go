@@ -0,0 +1,49 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRuleRoutes configures alert rule and alert routes
+func SetupRuleRoutes(router *gin.RouterGroup, ruleHandler *handler.RuleHandler) {
+	// Changing rules and acknowledging alerts needs rules:write
+	writeRules := middleware.RequirePermission(rbac.RulesWrite)
+
+	ruleGroup := router.Group("/rules", middleware.RequirePermission(rbac.DevicesRead))
+	{
+		// List and create rules
+		ruleGroup.GET("", ruleHandler.ListRules)
+		ruleGroup.POST("", writeRules, ruleHandler.CreateRule)
+
+		// Get, replace and delete a rule
+		ruleGroup.GET("/:ruleId", ruleHandler.GetRule)
+		ruleGroup.PUT("/:ruleId", writeRules, ruleHandler.UpdateRule)
+		ruleGroup.DELETE("/:ruleId", writeRules, ruleHandler.DeleteRule)
+	}
+
+	alertGroup := router.Group("/alerts", middleware.RequirePermission(rbac.DevicesRead))
+	{
+		// Alerts by status, rule or thing
+		alertGroup.GET("", ruleHandler.ListAlerts)
+		alertGroup.GET("/:alertId", ruleHandler.GetAlert)
+
+		// Acknowledge a firing alert
+		alertGroup.POST("/:alertId/acknowledge", writeRules, ruleHandler.AcknowledgeAlert)
+	}
+
+	log.Printf("Registered rule routes:")
+	log.Printf("GET /api/rules")
+	log.Printf("POST /api/rules")
+	log.Printf("GET /api/rules/:ruleId")
+	log.Printf("PUT /api/rules/:ruleId")
+	log.Printf("DELETE /api/rules/:ruleId")
+	log.Printf("GET /api/alerts")
+	log.Printf("GET /api/alerts/:alertId")
+	log.Printf("POST /api/alerts/:alertId/acknowledge")
+}
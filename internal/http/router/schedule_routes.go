@@ -0,0 +1,44 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupScheduleRoutes configures scheduled command routes
+func SetupScheduleRoutes(router *gin.RouterGroup, scheduleHandler *handler.ScheduleHandler) {
+	// Schedules send commands, changing or running them needs commands:send
+	sendCommands := middleware.RequirePermission(rbac.CommandsSend)
+
+	scheduleGroup := router.Group("/schedules", middleware.RequirePermission(rbac.DevicesRead))
+	{
+		// List and create schedules
+		scheduleGroup.GET("", scheduleHandler.ListSchedules)
+		scheduleGroup.POST("", sendCommands, scheduleHandler.CreateSchedule)
+
+		// Get, replace and delete a schedule
+		scheduleGroup.GET("/:scheduleId", scheduleHandler.GetSchedule)
+		scheduleGroup.PUT("/:scheduleId", sendCommands, scheduleHandler.UpdateSchedule)
+		scheduleGroup.DELETE("/:scheduleId", sendCommands, scheduleHandler.DeleteSchedule)
+
+		// Run a schedule right away
+		scheduleGroup.POST("/:scheduleId/run", sendCommands, scheduleHandler.RunSchedule)
+
+		// Execution history of a schedule
+		scheduleGroup.GET("/:scheduleId/executions", scheduleHandler.ListExecutions)
+	}
+
+	log.Printf("Registered schedule routes:")
+	log.Printf("GET /api/schedules")
+	log.Printf("POST /api/schedules")
+	log.Printf("GET /api/schedules/:scheduleId")
+	log.Printf("PUT /api/schedules/:scheduleId")
+	log.Printf("DELETE /api/schedules/:scheduleId")
+	log.Printf("POST /api/schedules/:scheduleId/run")
+	log.Printf("GET /api/schedules/:scheduleId/executions")
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupStreamRoutes configures live event streaming routes
+func SetupStreamRoutes(router *gin.RouterGroup, streamHandler *handler.StreamHandler) {
+	streamGroup := router.Group("", middleware.RequirePermission(rbac.DevicesRead))
+
+	// Live twin events over WebSocket, filtered per client
+	streamGroup.GET("/ws", streamHandler.WebSocket)
+	// Server-Sent Events of the fleet and of one device, resumable with Last-Event-ID
+	streamGroup.GET("/devices/events", streamHandler.FleetEvents)
+	streamGroup.GET("/devices/:thingId/events", streamHandler.DeviceEvents)
+
+	log.Printf("Registered stream routes:")
+	log.Printf("GET /api/ws")
+	log.Printf("GET /api/devices/events")
+	log.Printf("GET /api/devices/:thingId/events")
+}
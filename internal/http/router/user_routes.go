@@ -0,0 +1,29 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupUserRoutes configures user management routes
+func SetupUserRoutes(router *gin.RouterGroup, userHandler *handler.UserHandler) {
+	userGroup := router.Group("/users", middleware.RequirePermission(rbac.UsersAdmin))
+	{
+		// List and create users
+		userGroup.GET("", userHandler.ListUsers)
+		userGroup.POST("", userHandler.CreateUser)
+
+		// Replace the roles of a user
+		userGroup.PUT("/:userId/roles", userHandler.SetUserRoles)
+	}
+
+	log.Printf("Registered user routes:")
+	log.Printf("GET /api/users")
+	log.Printf("POST /api/users")
+	log.Printf("PUT /api/users/:userId/roles")
+}
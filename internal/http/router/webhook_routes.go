@@ -0,0 +1,49 @@
+package router
+
+import (
+	"log"
+
+	"ditto/internal/http/handler"
+	"ditto/internal/middleware"
+	"ditto/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupWebhookRoutes configures webhook and delivery log routes
+func SetupWebhookRoutes(router *gin.RouterGroup, webhookHandler *handler.WebhookHandler) {
+	webhookGroup := router.Group("/webhooks", middleware.RequirePermission(rbac.WebhooksAdmin))
+	{
+		// List and create webhooks
+		webhookGroup.GET("", webhookHandler.ListWebhooks)
+		webhookGroup.POST("", webhookHandler.CreateWebhook)
+
+		// Delivery log and dead letters across webhooks
+		webhookGroup.GET("/deliveries", webhookHandler.ListDeliveries)
+		webhookGroup.GET("/deliveries/:deliveryId", webhookHandler.GetDelivery)
+		webhookGroup.POST("/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+		webhookGroup.GET("/dead-letters", webhookHandler.ListDeadLetters)
+
+		// Get, replace and delete a webhook
+		webhookGroup.GET("/:webhookId", webhookHandler.GetWebhook)
+		webhookGroup.PUT("/:webhookId", webhookHandler.UpdateWebhook)
+		webhookGroup.DELETE("/:webhookId", webhookHandler.DeleteWebhook)
+
+		// Send a test event and read the delivery log of a webhook
+		webhookGroup.POST("/:webhookId/test", webhookHandler.TestWebhook)
+		webhookGroup.GET("/:webhookId/deliveries", webhookHandler.ListWebhookDeliveries)
+	}
+
+	log.Printf("Registered webhook routes:")
+	log.Printf("GET /api/webhooks")
+	log.Printf("POST /api/webhooks")
+	log.Printf("GET /api/webhooks/deliveries")
+	log.Printf("GET /api/webhooks/deliveries/:deliveryId")
+	log.Printf("POST /api/webhooks/deliveries/:deliveryId/redeliver")
+	log.Printf("GET /api/webhooks/dead-letters")
+	log.Printf("GET /api/webhooks/:webhookId")
+	log.Printf("PUT /api/webhooks/:webhookId")
+	log.Printf("DELETE /api/webhooks/:webhookId")
+	log.Printf("POST /api/webhooks/:webhookId/test")
+	log.Printf("GET /api/webhooks/:webhookId/deliveries")
+}
@@ -0,0 +1,493 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRetryBackoff caps the exponential delay between attempts
+	maxRetryBackoff = 10 * time.Minute
+
+	defaultPollInterval = 2 * time.Second
+	// minLeasePolls is the number of lease renewals a job may miss before it is requeued
+	minLeasePolls = 3
+)
+
+// SubmitOption customizes a job before it is stored
+type SubmitOption func(job *entity.Job)
+
+// WithMaxAttempts overrides the configured number of attempts
+func WithMaxAttempts(attempts int) SubmitOption {
+	return func(job *entity.Job) {
+		if attempts > 0 {
+			job.MaxAttempts = attempts
+		}
+	}
+}
+
+// WithCreatedBy records the user submitting the job
+func WithCreatedBy(user string) SubmitOption {
+	return func(job *entity.Job) {
+		job.CreatedBy = user
+	}
+}
+
+// WithRunAfter delays the first attempt until the given time
+func WithRunAfter(t time.Time) SubmitOption {
+	return func(job *entity.Job) {
+		job.RunAfter = &t
+	}
+}
+
+// Manager stores jobs in Postgres and runs them on a pool of workers. Running
+// jobs are leased to the manager, several instances may share the same table.
+type Manager struct {
+	repo   repository.JobRepository
+	logger logger.Logger
+	config config.JobConfig
+	// owner identifies the instance in the leases of the jobs it runs
+	owner string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	running  map[string]*runningJob
+	// tokens holds the bearer tokens of the users who submitted jobs. They are
+	// only kept in memory, a job resumed by another process runs without one.
+	tokens map[string]string
+
+	wake chan struct{}
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+type runningJob struct {
+	task   *Task
+	cancel context.CancelFunc
+}
+
+// NewManager creates a job manager whose workers follow the application lifecycle
+func NewManager(lc fx.Lifecycle, repo repository.JobRepository, logger logger.Logger, cfg *config.Config) *Manager {
+	jobConfig := cfg.Job
+	if jobConfig.PollInterval <= 0 {
+		jobConfig.PollInterval = defaultPollInterval
+	}
+	if jobConfig.LeaseTimeout < minLeasePolls*jobConfig.PollInterval {
+		jobConfig.LeaseTimeout = minLeasePolls * jobConfig.PollInterval
+	}
+
+	m := &Manager{
+		repo:     repo,
+		logger:   logger,
+		config:   jobConfig,
+		owner:    uuid.NewString(),
+		handlers: make(map[string]Handler),
+		running:  make(map[string]*runningJob),
+		tokens:   make(map[string]string),
+		wake:     make(chan struct{}, 1),
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return m.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			m.Stop()
+			return nil
+		},
+	})
+
+	return m
+}
+
+// Register sets the handler of a job type, it must be called before jobs of that type are submitted
+func (m *Manager) Register(jobType string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[jobType] = handler
+}
+
+// Submit stores a new pending job and wakes up a worker. A job submitted with a
+// context carrying a Ditto identity runs on behalf of that user.
+func (m *Manager) Submit(ctx context.Context, jobType string, payload interface{}, opts ...SubmitOption) (*entity.Job, error) {
+	m.mu.RLock()
+	_, ok := m.handlers[jobType]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown job type: %s", jobType)
+	}
+
+	data, err := entity.NewJSON(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	job := &entity.Job{
+		ID:          uuid.NewString(),
+		Type:        jobType,
+		Status:      enum.JobPending,
+		Payload:     data,
+		MaxAttempts: m.config.MaxAttempts,
+	}
+	identity, hasIdentity := ditto.IdentityFromContext(ctx)
+	if hasIdentity {
+		job.Subject = identity.Subject
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+
+	if err := m.repo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	if identity.Token != "" {
+		m.mu.Lock()
+		m.tokens[job.ID] = identity.Token
+		m.mu.Unlock()
+	}
+
+	m.notify()
+	return job, nil
+}
+
+// Get returns a job by its ID
+func (m *Manager) Get(ctx context.Context, id string) (*entity.Job, error) {
+	return m.repo.GetByID(ctx, id)
+}
+
+// List returns a page of jobs and the total number of matching jobs
+func (m *Manager) List(ctx context.Context, filter repository.JobFilter) ([]*entity.Job, int64, error) {
+	return m.repo.List(ctx, filter)
+}
+
+// Cancel stops a pending or running job, finished jobs are left untouched
+func (m *Manager) Cancel(ctx context.Context, id string) (*entity.Job, error) {
+	if err := m.repo.RequestCancel(ctx, id); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	running, ok := m.running[id]
+	m.mu.RUnlock()
+	if ok {
+		running.task.markCancelled()
+		running.cancel()
+	}
+
+	return m.repo.GetByID(ctx, id)
+}
+
+// Retry resubmits a failed or cancelled job with a fresh set of attempts. Like a
+// submitted job, it runs on behalf of the user retrying it, never of its submitter.
+func (m *Manager) Retry(ctx context.Context, id string) (*entity.Job, error) {
+	identity, _ := ditto.IdentityFromContext(ctx)
+	if err := m.repo.Retry(ctx, id, identity.Subject); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if identity.Token != "" {
+		m.tokens[id] = identity.Token
+	} else {
+		delete(m.tokens, id)
+	}
+	m.mu.Unlock()
+
+	m.notify()
+	return m.repo.GetByID(ctx, id)
+}
+
+// Start requeues the jobs of instances that stopped renewing their leases and starts the workers
+func (m *Manager) Start(ctx context.Context) error {
+	if err := m.requeue(ctx); err != nil {
+		return err
+	}
+
+	workers := m.config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	m.stop = cancel
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.work(runCtx)
+	}
+	m.wg.Add(1)
+	go m.reap(runCtx)
+
+	m.logger.Info(fmt.Sprintf("Started %d job workers", workers))
+	return nil
+}
+
+// Stop interrupts running jobs and waits for the workers to exit, interrupted
+// jobs are requeued and resumed by the next instance claiming them
+func (m *Manager) Stop() {
+	if m.stop != nil {
+		m.stop()
+	}
+	m.wg.Wait()
+}
+
+// requeue makes the jobs whose lease expired runnable again
+func (m *Manager) requeue(ctx context.Context) error {
+	requeued, err := m.repo.Requeue(ctx, time.Now().Add(-m.config.LeaseTimeout))
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		m.logger.Info(fmt.Sprintf("Requeued %d interrupted jobs", requeued))
+		m.notify()
+	}
+	return nil
+}
+
+// reap requeues the jobs of dead instances until ctx is cancelled
+func (m *Manager) reap(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.LeaseTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.requeue(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Error("Failed to requeue interrupted jobs", zap.Error(err))
+		}
+	}
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) types() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	types := make([]string, 0, len(m.handlers))
+	for t := range m.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// work claims and executes jobs until ctx is cancelled
+func (m *Manager) work(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if types := m.types(); len(types) > 0 {
+			job, err := m.repo.Claim(ctx, types, m.owner, time.Now())
+			if err != nil && ctx.Err() == nil {
+				m.logger.Error("Failed to claim job", zap.Error(err))
+			}
+			if job != nil {
+				m.execute(ctx, job)
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// execute runs a claimed job and stores its outcome
+func (m *Manager) execute(ctx context.Context, job *entity.Job) {
+	m.mu.RLock()
+	handler := m.handlers[job.Type]
+	m.mu.RUnlock()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if job.Subject != "" {
+		m.mu.RLock()
+		token := m.tokens[job.ID]
+		m.mu.RUnlock()
+		jobCtx = ditto.WithIdentity(jobCtx, ditto.Identity{Subject: job.Subject, Token: token})
+	}
+
+	task := &Task{
+		ID:      job.ID,
+		Type:    job.Type,
+		Attempt: job.Attempts,
+		payload: job.Payload,
+	}
+
+	m.mu.Lock()
+	m.running[job.ID] = &runningJob{task: task, cancel: cancel}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, job.ID)
+		m.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	go m.watch(jobCtx, task, cancel, done)
+
+	m.logger.Info(fmt.Sprintf("Running job %s (%s), attempt %d/%d", job.ID, job.Type, job.Attempts, job.MaxAttempts))
+	result, err := m.run(jobCtx, handler, task)
+	close(done)
+
+	now := time.Now()
+	job.Progress, job.Total, _ = task.counts()
+	job.RunAfter = nil
+	if result != nil {
+		if data, marshalErr := entity.NewJSON(result); marshalErr == nil {
+			job.Result = data
+		} else {
+			m.logger.Error("Failed to marshal job result", zap.String("job_id", job.ID), zap.Error(marshalErr))
+		}
+	}
+
+	switch {
+	case err == nil:
+		job.Status = enum.JobSucceeded
+		job.Error = ""
+		job.FinishedAt = &now
+	case task.cancelled():
+		job.Status = enum.JobCancelled
+		job.Error = "cancelled"
+		job.FinishedAt = &now
+	case ctx.Err() != nil:
+		// The service is shutting down, the job is resumed by the next instance claiming it
+		job.Status = enum.JobPending
+		job.Error = "interrupted by shutdown"
+	case !IsPermanent(err) && job.Attempts < job.MaxAttempts:
+		runAfter := now.Add(m.backoff(job.Attempts))
+		job.Status = enum.JobPending
+		job.Error = err.Error()
+		job.RunAfter = &runAfter
+	default:
+		job.Status = enum.JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+	}
+
+	err = m.repo.Finish(context.Background(), job)
+	if errors.Is(err, repository.ErrNotFound) {
+		m.logger.Warn("Job lease expired, the outcome is discarded", zap.String("job_id", job.ID))
+		return
+	}
+	if err != nil {
+		m.logger.Error("Failed to store job outcome", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+
+	if job.Status != enum.JobPending {
+		m.mu.Lock()
+		delete(m.tokens, job.ID)
+		m.mu.Unlock()
+	}
+
+	m.logger.Info(fmt.Sprintf("Job %s (%s) finished with status %s", job.ID, job.Type, job.Status))
+	if job.Status == enum.JobPending && job.RunAfter != nil {
+		m.logger.Warn(fmt.Sprintf("Job %s will be retried at %s: %s", job.ID, job.RunAfter.Format(time.RFC3339), job.Error))
+	}
+}
+
+// run calls the handler, turning a panic into a permanent error
+func (m *Manager) run(ctx context.Context, handler Handler, task *Task) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error("Job panicked",
+				zap.String("job_id", task.ID),
+				zap.Any("error", r),
+				zap.String("stack", string(debug.Stack())),
+			)
+			err = Permanent(fmt.Errorf("job panicked: %v", r))
+		}
+	}()
+	return handler(ctx, task)
+}
+
+// watch renews the lease of a job, flushes progress and picks up cancellations
+// requested through another instance. A job whose lease was lost is stopped.
+func (m *Manager) watch(ctx context.Context, task *Task, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := m.repo.Heartbeat(ctx, task.ID, m.owner, time.Now())
+		if errors.Is(err, repository.ErrNotFound) {
+			m.logger.Warn("Job lease expired, stopping the job", zap.String("job_id", task.ID))
+			cancel()
+			return
+		}
+		if err != nil {
+			m.logger.Warn("Failed to renew job lease", zap.String("job_id", task.ID), zap.Error(err))
+		}
+
+		if progress, total, changed := task.counts(); changed {
+			if err := m.repo.UpdateProgress(ctx, task.ID, progress, total); err != nil {
+				m.logger.Warn("Failed to update job progress", zap.String("job_id", task.ID), zap.Error(err))
+			}
+		}
+
+		requested, err := m.repo.IsCancelRequested(ctx, task.ID)
+		if err != nil {
+			m.logger.Warn("Failed to check job cancellation", zap.String("job_id", task.ID), zap.Error(err))
+			continue
+		}
+		if requested {
+			task.markCancelled()
+			cancel()
+			return
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, doubling with every attempt
+func (m *Manager) backoff(attempt int) time.Duration {
+	delay := m.config.RetryBackoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
@@ -0,0 +1,447 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// fakeJobRepository keeps jobs in memory with the semantics of the Postgres repository
+type fakeJobRepository struct {
+	mu   sync.Mutex
+	jobs map[string]*entity.Job
+	// order holds the job ids in creation order
+	order []string
+}
+
+func newFakeJobRepository() *fakeJobRepository {
+	return &fakeJobRepository{jobs: make(map[string]*entity.Job)}
+}
+
+func (r *fakeJobRepository) Create(_ context.Context, job *entity.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *job
+	r.jobs[job.ID] = &stored
+	r.order = append(r.order, job.ID)
+	return nil
+}
+
+func (r *fakeJobRepository) GetByID(_ context.Context, id string) (*entity.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (r *fakeJobRepository) List(context.Context, repository.JobFilter) ([]*entity.Job, int64, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (r *fakeJobRepository) Claim(_ context.Context, types []string, owner string, now time.Time) (*entity.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range r.order {
+		job := r.jobs[id]
+		if job.Status != enum.JobPending || (job.RunAfter != nil && job.RunAfter.After(now)) || !slices.Contains(types, job.Type) {
+			continue
+		}
+		job.Status = enum.JobRunning
+		job.Owner = owner
+		job.HeartbeatAt = &now
+		job.Attempts++
+		copied := *job
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeJobRepository) Heartbeat(_ context.Context, id, owner string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || job.Status != enum.JobRunning || job.Owner != owner {
+		return repository.ErrNotFound
+	}
+	job.HeartbeatAt = &now
+	return nil
+}
+
+func (r *fakeJobRepository) UpdateProgress(_ context.Context, id string, progress, total int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job, ok := r.jobs[id]; ok {
+		job.Progress, job.Total = progress, total
+	}
+	return nil
+}
+
+func (r *fakeJobRepository) Finish(_ context.Context, job *entity.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Status != enum.JobRunning || stored.Owner != job.Owner {
+		return repository.ErrNotFound
+	}
+	finished := *job
+	finished.Owner = ""
+	r.jobs[job.ID] = &finished
+	return nil
+}
+
+func (r *fakeJobRepository) RequestCancel(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	switch job.Status {
+	case enum.JobPending:
+		now := time.Now()
+		job.Status = enum.JobCancelled
+		job.Error = "cancelled"
+		job.FinishedAt = &now
+	case enum.JobRunning:
+		job.CancelRequested = true
+	}
+	return nil
+}
+
+func (r *fakeJobRepository) IsCancelRequested(_ context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	return ok && job.CancelRequested, nil
+}
+
+func (r *fakeJobRepository) Retry(_ context.Context, id, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok || (job.Status != enum.JobFailed && job.Status != enum.JobCancelled) {
+		return repository.ErrNotFound
+	}
+	job.Status = enum.JobPending
+	job.Subject = subject
+	job.Attempts = 0
+	job.Error = ""
+	job.CancelRequested = false
+	job.RunAfter = nil
+	job.FinishedAt = nil
+	return nil
+}
+
+func (r *fakeJobRepository) Requeue(_ context.Context, expiredBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var requeued int64
+	for _, job := range r.jobs {
+		if job.Status == enum.JobRunning && job.HeartbeatAt.Before(expiredBefore) {
+			job.Status = enum.JobPending
+			job.Owner = ""
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+// update changes a stored job, as another instance would
+func (r *fakeJobRepository) update(id string, change func(job *entity.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(r.jobs[id])
+}
+
+func newTestManager(t *testing.T, repo repository.JobRepository) (*Manager, *fxtest.Lifecycle) {
+	t.Helper()
+	cfg := &config.Config{Job: config.JobConfig{
+		Workers:      1,
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  1,
+		RetryBackoff: time.Millisecond,
+	}}
+	lc := fxtest.NewLifecycle(t)
+	return NewManager(lc, repo, logger.NewLogger(cfg), cfg), lc
+}
+
+// waitForStatus polls the repository until the job reaches status
+func waitForStatus(t *testing.T, repo repository.JobRepository, id, status string) *entity.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected job %s to be %s, got %s (%s)", id, status, job.Status, job.Error)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetryRunsOnBehalfOfTheRetryingUser(t *testing.T) {
+	repo := newFakeJobRepository()
+	m, lc := newTestManager(t, repo)
+
+	identities := make(chan ditto.Identity, 2)
+	m.Register("test", func(ctx context.Context, task *Task) (interface{}, error) {
+		identity, _ := ditto.IdentityFromContext(ctx)
+		identities <- identity
+		return nil, Permanent(errors.New("failed"))
+	})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	owner := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "oidc:alice", Token: "alice-token"})
+	job, err := m.Submit(owner, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity := <-identities; identity.Subject != "oidc:alice" || identity.Token != "alice-token" {
+		t.Fatalf("expected the job to run as its submitter, got %+v", identity)
+	}
+	waitForStatus(t, repo, job.ID, enum.JobFailed)
+
+	// Another user retrying the job does not borrow the identity of the submitter
+	other := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "oidc:bob", Token: "bob-token"})
+	retried, err := m.Retry(other, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Subject != "oidc:bob" {
+		t.Errorf("expected the retried job to be stamped with the retrying user, got %q", retried.Subject)
+	}
+	if identity := <-identities; identity.Subject != "oidc:bob" || identity.Token != "bob-token" {
+		t.Fatalf("expected the retried job to run as the retrying user, got %+v", identity)
+	}
+	waitForStatus(t, repo, job.ID, enum.JobFailed)
+
+	// Retried without an identity, the job runs as the service
+	if _, err := m.Retry(context.Background(), job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if identity := <-identities; identity != (ditto.Identity{}) {
+		t.Fatalf("expected the job to run without an identity, got %+v", identity)
+	}
+}
+
+func TestManagerRetriesFailedAttempts(t *testing.T) {
+	repo := newFakeJobRepository()
+	m, lc := newTestManager(t, repo)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	m.Register("test", func(ctx context.Context, task *Task) (interface{}, error) {
+		var behavior string
+		if err := task.Decode(&behavior); err != nil {
+			return nil, Permanent(err)
+		}
+		mu.Lock()
+		calls[behavior]++
+		call := calls[behavior]
+		mu.Unlock()
+
+		switch behavior {
+		case "flaky":
+			if call < 3 {
+				return nil, errors.New("flaky")
+			}
+			return map[string]int{"call": call}, nil
+		case "permanent":
+			return nil, Permanent(errors.New("bad payload"))
+		case "panic":
+			panic("boom")
+		default:
+			return nil, errors.New("down")
+		}
+	})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	tests := []struct {
+		behavior string
+		status   string
+		attempts int
+		err      string
+	}{
+		{behavior: "flaky", status: enum.JobSucceeded, attempts: 3},
+		{behavior: "failing", status: enum.JobFailed, attempts: 3, err: "down"},
+		{behavior: "permanent", status: enum.JobFailed, attempts: 1, err: "bad payload"},
+		{behavior: "panic", status: enum.JobFailed, attempts: 1, err: "job panicked: boom"},
+	}
+	ids := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			submitted, err := m.Submit(context.Background(), "test", tt.behavior, WithMaxAttempts(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[tt.behavior] = submitted.ID
+			job := waitForStatus(t, repo, submitted.ID, tt.status)
+			if job.Attempts != tt.attempts || job.Error != tt.err || job.FinishedAt == nil {
+				t.Errorf("expected %d attempts and error %q, got %d and %q", tt.attempts, tt.err, job.Attempts, job.Error)
+			}
+		})
+	}
+
+	// A retried job gets a fresh set of attempts, only failed and cancelled jobs can be retried
+	if _, err := m.Retry(context.Background(), ids["flaky"]); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected a succeeded job not to be retried, got %v", err)
+	}
+	retried, err := m.Retry(context.Background(), ids["failing"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Status != enum.JobPending && retried.Status != enum.JobRunning {
+		t.Errorf("expected the retried job to be runnable, got %s", retried.Status)
+	}
+	if job := waitForStatus(t, repo, ids["failing"], enum.JobFailed); job.Attempts != 3 {
+		t.Errorf("expected 3 more attempts, got %d", job.Attempts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["failing"] != 6 {
+		t.Errorf("expected 6 calls of the retried job, got %d", calls["failing"])
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	repo := newFakeJobRepository()
+	m, lc := newTestManager(t, repo)
+
+	started := make(chan string, 2)
+	m.Register("test", func(ctx context.Context, task *Task) (interface{}, error) {
+		started <- task.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	// A running job is interrupted
+	running, err := m.Submit(context.Background(), "test", nil, WithMaxAttempts(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := m.Cancel(context.Background(), running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := waitForStatus(t, repo, running.ID, enum.JobCancelled); job.Error != "cancelled" || job.Attempts != 1 {
+		t.Errorf("expected a single cancelled attempt, got %d attempts and %q", job.Attempts, job.Error)
+	}
+
+	// A cancellation requested through another instance is picked up with the lease renewal
+	remote, err := m.Submit(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := repo.RequestCancel(context.Background(), remote.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, repo, remote.ID, enum.JobCancelled)
+
+	// A pending job never runs
+	pending, err := m.Submit(context.Background(), "test", nil, WithRunAfter(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := m.Cancel(context.Background(), pending.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != enum.JobCancelled || cancelled.Attempts != 0 {
+		t.Errorf("expected the pending job to be cancelled without running, got %s after %d attempts", cancelled.Status, cancelled.Attempts)
+	}
+}
+
+func TestManagerRequeuesExpiredLeases(t *testing.T) {
+	repo := newFakeJobRepository()
+	m, lc := newTestManager(t, repo)
+	m.Register("test", func(ctx context.Context, task *Task) (interface{}, error) {
+		return nil, nil
+	})
+
+	// One job is leased by an instance that died, the other by one still renewing it
+	expired, renewed := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	for id, heartbeat := range map[string]time.Time{"dead": expired, "alive": renewed} {
+		if err := repo.Create(context.Background(), &entity.Job{
+			ID:          id,
+			Type:        "test",
+			Status:      enum.JobRunning,
+			Attempts:    1,
+			MaxAttempts: 3,
+			Owner:       id,
+			HeartbeatAt: &heartbeat,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	if job := waitForStatus(t, repo, "dead", enum.JobSucceeded); job.Attempts != 2 {
+		t.Errorf("expected the requeued job to be resumed with a second attempt, got %d", job.Attempts)
+	}
+	if job, _ := repo.GetByID(context.Background(), "alive"); job.Status != enum.JobRunning || job.Owner != "alive" {
+		t.Errorf("expected the job of the live instance to be left alone, got %s owned by %s", job.Status, job.Owner)
+	}
+}
+
+func TestManagerStopsJobsThatLostTheirLease(t *testing.T) {
+	repo := newFakeJobRepository()
+	m, lc := newTestManager(t, repo)
+
+	started, stopped := make(chan string, 1), make(chan struct{})
+	m.Register("test", func(ctx context.Context, task *Task) (interface{}, error) {
+		started <- task.ID
+		<-ctx.Done()
+		close(stopped)
+		return "partial", nil
+	})
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	submitted, err := m.Submit(context.Background(), "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// Another instance requeued the job and claimed it, as if this one had stalled
+	repo.update(submitted.ID, func(job *entity.Job) { job.Owner = "other" })
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the job to be stopped once its lease was lost")
+	}
+
+	// Its outcome is discarded, the new owner's run is left alone
+	m.Stop()
+	job, _ := repo.GetByID(context.Background(), submitted.ID)
+	if job.Status != enum.JobRunning || job.Owner != "other" || job.Result != nil {
+		t.Errorf("expected the job to stay with its new owner, got %s owned by %s with %s", job.Status, job.Owner, job.Result)
+	}
+}
@@ -0,0 +1,7 @@
+package job
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(NewManager),
+)
@@ -0,0 +1,105 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"ditto/internal/model/entity"
+)
+
+// Handler processes a job, the returned value is stored as the job result. Returning
+// an error retries the job until its attempts are exhausted, unless wrapped with Permanent.
+type Handler func(ctx context.Context, task *Task) (interface{}, error)
+
+// Task gives a handler access to its job and lets it report progress
+type Task struct {
+	ID      string
+	Type    string
+	Attempt int
+
+	payload entity.JSON
+
+	mu              sync.Mutex
+	progress        int
+	total           int
+	dirty           bool
+	cancelRequested bool
+}
+
+// Decode unmarshals the job payload into v
+func (t *Task) Decode(v interface{}) error {
+	return t.payload.Decode(v)
+}
+
+// SetTotal sets the number of items the job will process
+func (t *Task) SetTotal(total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = total
+	t.dirty = true
+}
+
+// SetProgress sets the number of items processed so far
+func (t *Task) SetProgress(progress int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress = progress
+	t.dirty = true
+}
+
+// Add increments the number of items processed so far
+func (t *Task) Add(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress += n
+	t.dirty = true
+}
+
+// counts returns the current progress and whether it changed since the last call
+func (t *Task) counts() (progress, total int, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed = t.dirty
+	t.dirty = false
+	return t.progress, t.total, changed
+}
+
+func (t *Task) markCancelled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cancelRequested = true
+}
+
+func (t *Task) cancelled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cancelRequested
+}
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error so the job fails without further retries
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether an error was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
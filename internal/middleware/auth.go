@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"ditto/pkg/constant"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,6 +15,8 @@ import (
 type AuthConfig struct {
 	Username string
 	Password string
+	// Roles are the roles of the basic auth user
+	Roles []string
 	// Add more auth-related configs here if needed
 }
 
@@ -58,6 +62,7 @@ func BasicAuth(config *AuthConfig) gin.HandlerFunc {
 
 		// Add user info to context
 		c.Set("username", pair[0])
+		c.Set(constant.Roles, config.Roles)
 		c.Next()
 	}
 }
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"ditto/internal/ditto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DittoIdentity makes the Ditto requests of an authenticated request on behalf of
+// its user, forwarding the bearer token the user authenticated with if any.
+// Whether Ditto sees the user or the service account depends on DITTO_IDENTITY_MODE.
+func DittoIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if username := c.GetString("username"); username != "" {
+			identity := ditto.Identity{Subject: username, Token: c.GetString(accessTokenKey)}
+			c.Request = c.Request.WithContext(ditto.WithIdentity(c.Request.Context(), identity))
+		}
+		c.Next()
+	}
+}
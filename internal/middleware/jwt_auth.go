@@ -1,9 +1,14 @@
 package middleware
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"ditto/config"
+	"ditto/internal/oidc"
+	"ditto/pkg/constant"
 	"ditto/pkg/errors"
 	"ditto/pkg/util"
 	"ditto/pkg/wrapper"
@@ -11,10 +16,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func JwtAuthMiddleware(config *config.Config) gin.HandlerFunc {
+// accessTokenKey holds the access token a request authenticated with
+const accessTokenKey = "access_token"
+
+// JwtAuthMiddleware requires a bearer access token, issued either by /api/auth/login
+// or by the OIDC provider when OIDC_ISSUER is set
+func JwtAuthMiddleware(config *config.Config, verifier *oidc.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := util.TokenValid(c, config.JWT)
-		if err != nil {
+		token := bearerToken(c)
+		if token == "" && queryTokenAllowed(c) {
+			token = c.Query("token")
+		}
+		if err := authenticateToken(c, token, config.JWT, verifier); err != nil {
 			c.JSON(http.StatusUnauthorized, wrapper.NewErrorResponse(
 				errors.NewUnauthorizedError("Unauthorized"),
 			))
@@ -24,3 +37,77 @@ func JwtAuthMiddleware(config *config.Config) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// JWTOrBasicAuth accepts an access token issued by /api/auth/login or by the OIDC
+// provider as a bearer token and falls back to basic auth otherwise. Browsers cannot set headers on
+// WebSocket and EventSource requests, those may pass the token as ?token= instead.
+func JWTOrBasicAuth(authConfig *AuthConfig, jwtConfig config.JWTConfig, verifier *oidc.Verifier) gin.HandlerFunc {
+	basicAuth := BasicAuth(authConfig)
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" && queryTokenAllowed(c) {
+			token = c.Query("token")
+		}
+		if token == "" {
+			basicAuth(c)
+			return
+		}
+
+		if err := authenticateToken(c, token, jwtConfig, verifier); err != nil {
+			c.JSON(http.StatusUnauthorized, wrapper.NewErrorResponse(
+				errors.NewUnauthorizedError("Invalid or expired token"),
+			))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticateToken validates an access token and sets its user in the context.
+// Tokens of the OIDC issuer are checked against its JWKS, all others must be
+// HS256 tokens signed with JWT_SECRET.
+func authenticateToken(c *gin.Context, token string, jwtConfig config.JWTConfig, verifier *oidc.Verifier) error {
+	if token == "" {
+		return fmt.Errorf("no access token")
+	}
+
+	if verifier.Issues(token) {
+		principal, err := verifier.Verify(c.Request.Context(), token)
+		if err != nil {
+			log.Printf("Rejected OIDC token: %v", err)
+			return err
+		}
+		c.Set("username", principal.Username)
+		c.Set(constant.UserID, principal.UserID)
+		c.Set(constant.Email, principal.Email)
+		c.Set(constant.Roles, principal.Roles)
+	} else {
+		claims, err := util.ParseAccessToken(token, jwtConfig)
+		if err != nil {
+			return err
+		}
+		c.Set("username", claims.Username)
+		c.Set(constant.UserID, claims.UserID)
+		c.Set(constant.Email, claims.Email)
+		c.Set(constant.Roles, claims.Roles)
+	}
+
+	c.Set(accessTokenKey, token)
+	return nil
+}
+
+// bearerToken returns the token of a Bearer Authorization header
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// queryTokenAllowed tells whether a request may carry its token in the query,
+// only WebSocket upgrades and event streams need to
+func queryTokenAllowed(c *gin.Context) bool {
+	return c.IsWebsocket() || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"ditto/internal/rbac"
+	"ditto/pkg/constant"
+	"ditto/pkg/errors"
+	"ditto/pkg/wrapper"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolvePermissions turns the roles of the authenticated user into the permissions
+// checked by RequirePermission. It runs after authentication, which sets the roles.
+func ResolvePermissions(policy *rbac.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(constant.Permissions, policy.Resolve(c.GetStringSlice(constant.Roles)))
+		c.Next()
+	}
+}
+
+// RequirePermission refuses requests of users without a permission with 403
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize(c, permission)
+	}
+}
+
+// RequireRequestPermission checks requests whose permission depends on the request
+// itself, such as the proxied requests, permission returns the one it needs
+func RequireRequestPermission(permission func(req *http.Request) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize(c, permission(c.Request))
+	}
+}
+
+// authorize continues the request if its user has a permission and aborts it otherwise
+func authorize(c *gin.Context, permission string) {
+	if rbac.Allows(c.GetStringSlice(constant.Permissions), permission) {
+		c.Next()
+		return
+	}
+
+	log.Printf("Denied %s %s to %s: %s required", c.Request.Method, c.Request.URL.Path, c.GetString("username"), permission)
+	c.JSON(http.StatusForbidden, wrapper.NewErrorResponse(
+		errors.NewForbiddenError(fmt.Sprintf("Permission %s is required", permission)),
+	))
+	c.Abort()
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// TokenPair is what a login or refresh returns: a short lived access token for
+// the API and a refresh token to obtain the next pair
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
+}
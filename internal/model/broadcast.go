@@ -0,0 +1,43 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CommandSkipped marks things a broadcast did not reach because the canary failed
+const CommandSkipped = "SKIPPED"
+
+// Broadcast sends the same command to every thing matching a filter
+type Broadcast struct {
+	Filter           SearchFilter    `json:"filter"`
+	Feature          string          `json:"feature"`
+	Subject          string          `json:"subject"`
+	Params           json.RawMessage `json:"params,omitempty"`
+	Timeout          time.Duration   `json:"timeout"`
+	ResponseRequired bool            `json:"responseRequired"`
+	// Rate is the number of commands sent per second, zero is unlimited
+	Rate        float64 `json:"rate"`
+	Concurrency int     `json:"concurrency"`
+	// Canary sends to a first batch and only continues if enough of it succeeded
+	Canary *BroadcastCanary `json:"canary,omitempty"`
+}
+
+// BroadcastCanary configures a canary-first rollout
+type BroadcastCanary struct {
+	Size            int     `json:"size"`
+	MinSuccessRatio float64 `json:"minSuccessRatio"`
+}
+
+// BroadcastReport aggregates the outcome of a broadcast
+type BroadcastReport struct {
+	Matched   int            `json:"matched"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Skipped   int            `json:"skipped"`
+	ByStatus  map[string]int `json:"byStatus"`
+	// CanaryRatio is the success ratio of the canary batch
+	CanaryRatio *float64              `json:"canaryRatio,omitempty"`
+	Aborted     bool                  `json:"aborted"`
+	Results     []CommandTargetResult `json:"results"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "encoding/json"
+
+const (
+	UpdateConflict = "CONFLICT"
+	UpdateFailed   = "FAILED"
+)
+
+// BulkUpdate describes a merge patch applied to every thing matching a filter
+type BulkUpdate struct {
+	Filter SearchFilter    `json:"filter"`
+	Patch  json.RawMessage `json:"patch"`
+	// All confirms that a filter without predicates patches every thing of its namespaces
+	All bool `json:"all,omitempty"`
+	// ExpectedCount aborts the update when the number of matching things changed since the preview
+	ExpectedCount *int `json:"expectedCount,omitempty"`
+	Concurrency   int  `json:"concurrency"`
+}
+
+// BulkUpdatePreview summarizes the things a bulk update would touch
+type BulkUpdatePreview struct {
+	Count  int      `json:"count"`
+	Sample []string `json:"sample"`
+}
+
+// BulkUpdateFailure describes a thing that could not be updated
+type BulkUpdateFailure struct {
+	ThingID string `json:"thingId"`
+	Status  string `json:"status"`
+	Error   string `json:"error"`
+}
+
+// BulkUpdateReport is the result of a bulk update job
+type BulkUpdateReport struct {
+	Matched   int                 `json:"matched"`
+	Updated   int                 `json:"updated"`
+	Conflicts int                 `json:"conflicts"`
+	Failed    int                 `json:"failed"`
+	Failures  []BulkUpdateFailure `json:"failures"`
+}
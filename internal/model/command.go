@@ -0,0 +1,27 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Command is a message sent to the inbox of a thing feature
+type Command struct {
+	ThingID string          `json:"thingId"`
+	Feature string          `json:"feature"`
+	Subject string          `json:"subject"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// Timeout is how long to wait for the device reply
+	Timeout time.Duration `json:"timeout"`
+	// ResponseRequired is false for fire-and-forget commands
+	ResponseRequired bool `json:"responseRequired"`
+}
+
+// CommandTargetResult is the outcome of a command sent to one thing of a group
+type CommandTargetResult struct {
+	ThingID   string `json:"thingId"`
+	CommandID string `json:"commandId,omitempty"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	ConnectivityReasonEvent            = "event"
+	ConnectivityReasonTimeout          = "timeout"
+	ConnectivityReasonConnectionStatus = "connection_status"
+)
+
+// ConnectivityChange is emitted when a thing goes online or offline
+type ConnectivityChange struct {
+	ThingID    string    `json:"thingId"`
+	Online     bool      `json:"online"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ChangedAt  time.Time `json:"changedAt"`
+	Reason     string    `json:"reason"`
+}
+
+// ConnectivityCount is the number of online and offline things of a definition
+type ConnectivityCount struct {
+	Definition string `json:"definition"`
+	Online     int64  `json:"online"`
+	Offline    int64  `json:"offline"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// Command is the audit record of a message sent to the inbox of a thing feature.
+// CreatedBy holds the user who sent it.
+type Command struct {
+	ID               string     `gorm:"column:id;type:uuid;primaryKey"`
+	ThingID          string     `gorm:"column:thing_id;type:varchar(255);index"`
+	Feature          string     `gorm:"column:feature;type:varchar(255)"`
+	Subject          string     `gorm:"column:subject;type:varchar(255)"`
+	Params           JSON       `gorm:"column:params;type:jsonb"`
+	CorrelationID    string     `gorm:"column:correlation_id;type:varchar(100);index"`
+	Async            bool       `gorm:"column:async;default:false"`
+	ResponseRequired bool       `gorm:"column:response_required;default:true"`
+	TimeoutMs        int64      `gorm:"column:timeout_ms"`
+	Status           string     `gorm:"column:status;type:varchar(20);index"`
+	ResponseStatus   int        `gorm:"column:response_status"`
+	Response         JSON       `gorm:"column:response;type:jsonb"`
+	Error            string     `gorm:"column:error;type:text"`
+	SentAt           time.Time  `gorm:"column:sent_at;index"`
+	CompletedAt      *time.Time `gorm:"column:completed_at"`
+	LatencyMs        int64      `gorm:"column:latency_ms"`
+	BaseEntity
+}
+
+func (Command) TableName() string {
+	return "commands"
+}
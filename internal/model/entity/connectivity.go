@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// Connectivity is the last known connection state of a thing
+type Connectivity struct {
+	ThingID    string `gorm:"column:thing_id;type:varchar(255);primaryKey"`
+	Definition string `gorm:"column:definition;type:varchar(255);index"`
+	Online     bool   `gorm:"column:online;index"`
+	// LastSeenAt is the time of the last event received for the thing
+	LastSeenAt time.Time `gorm:"column:last_seen_at;index"`
+	// ReadySince and ReadyUntil mirror the ConnectionStatus feature when it is used
+	ReadySince *time.Time `gorm:"column:ready_since"`
+	ReadyUntil *time.Time `gorm:"column:ready_until"`
+	ChangedAt  time.Time  `gorm:"column:changed_at"`
+	BaseEntity
+}
+
+func (Connectivity) TableName() string {
+	return "device_connectivity"
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// Drift tracks the difference between the desired and reported properties of a
+// thing feature. There is one row per feature, reused when the feature drifts again.
+type Drift struct {
+	ID      string `gorm:"column:id;type:uuid;primaryKey"`
+	ThingID string `gorm:"column:thing_id;type:varchar(255);uniqueIndex:idx_drift_thing_feature"`
+	Feature string `gorm:"column:feature;type:varchar(255);uniqueIndex:idx_drift_thing_feature"`
+	Status  string `gorm:"column:status;type:varchar(20);index"`
+	// Desired and Reported hold only the properties that differ
+	Desired       JSON       `gorm:"column:desired;type:jsonb"`
+	Reported      JSON       `gorm:"column:reported;type:jsonb"`
+	DetectedAt    time.Time  `gorm:"column:detected_at"`
+	CheckedAt     time.Time  `gorm:"column:checked_at"`
+	ConvergedAt   *time.Time `gorm:"column:converged_at"`
+	Resends       int        `gorm:"column:resends;default:0"`
+	LastResentAt  *time.Time `gorm:"column:last_resent_at"`
+	LastCommandID string     `gorm:"column:last_command_id;type:varchar(100)"`
+	BaseEntity
+}
+
+func (Drift) TableName() string {
+	return "device_drifts"
+}
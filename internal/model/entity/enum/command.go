@@ -0,0 +1,9 @@
+package enum
+
+const (
+	CommandPending   = "PENDING"
+	CommandSent      = "SENT"
+	CommandSucceeded = "SUCCEEDED"
+	CommandFailed    = "FAILED"
+	CommandTimeout   = "TIMEOUT"
+)
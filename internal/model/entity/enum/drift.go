@@ -0,0 +1,7 @@
+package enum
+
+const (
+	DriftDrifting  = "DRIFTING"
+	DriftConverged = "CONVERGED"
+	DriftStuck     = "STUCK"
+)
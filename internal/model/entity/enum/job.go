@@ -0,0 +1,19 @@
+package enum
+
+const (
+	JobPending   = "PENDING"
+	JobRunning   = "RUNNING"
+	JobSucceeded = "SUCCEEDED"
+	JobFailed    = "FAILED"
+	JobCancelled = "CANCELLED"
+)
+
+// JobFinished reports whether a job status is terminal
+func JobFinished(status string) bool {
+	switch status {
+	case JobSucceeded, JobFailed, JobCancelled:
+		return true
+	default:
+		return false
+	}
+}
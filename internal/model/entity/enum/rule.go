@@ -0,0 +1,20 @@
+package enum
+
+const (
+	RuleThreshold    = "THRESHOLD"
+	RuleRange        = "RANGE"
+	RuleRateOfChange = "RATE_OF_CHANGE"
+	RuleAbsence      = "ABSENCE"
+)
+
+const (
+	AlertFiring       = "FIRING"
+	AlertResolved     = "RESOLVED"
+	AlertAcknowledged = "ACKNOWLEDGED"
+)
+
+const (
+	SeverityInfo     = "INFO"
+	SeverityWarning  = "WARNING"
+	SeverityCritical = "CRITICAL"
+)
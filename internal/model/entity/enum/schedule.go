@@ -0,0 +1,14 @@
+package enum
+
+const (
+	ScheduleOnce     = "ONCE"
+	ScheduleCron     = "CRON"
+	ScheduleInterval = "INTERVAL"
+)
+
+const (
+	ExecutionRunning   = "RUNNING"
+	ExecutionSucceeded = "SUCCEEDED"
+	ExecutionPartial   = "PARTIAL"
+	ExecutionFailed    = "FAILED"
+)
@@ -0,0 +1,8 @@
+package enum
+
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryRetrying  = "RETRYING"
+	DeliveryDead      = "DEAD"
+)
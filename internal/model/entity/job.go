@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// Job is a unit of background work processed by the job manager
+type Job struct {
+	ID              string     `gorm:"column:id;type:uuid;primaryKey"`
+	Type            string     `gorm:"column:type;type:varchar(100);index"`
+	Status          string     `gorm:"column:status;type:varchar(20);index"`
+	Payload         JSON       `gorm:"column:payload;type:jsonb"`
+	Result          JSON       `gorm:"column:result;type:jsonb"`
+	Error           string     `gorm:"column:error;type:text"`
+	Progress        int        `gorm:"column:progress;default:0"`
+	Total           int        `gorm:"column:total;default:0"`
+	Attempts        int        `gorm:"column:attempts;default:0"`
+	MaxAttempts     int        `gorm:"column:max_attempts;default:1"`
+	CancelRequested bool       `gorm:"column:cancel_requested;default:false"`
+	RunAfter        *time.Time `gorm:"column:run_after;index"`
+	StartedAt       *time.Time `gorm:"column:started_at"`
+	FinishedAt      *time.Time `gorm:"column:finished_at"`
+	// Owner is the manager instance holding the lease of a running job, renewed at HeartbeatAt
+	Owner       string     `gorm:"column:owner;type:varchar(36)"`
+	HeartbeatAt *time.Time `gorm:"column:heartbeat_at"`
+	// Subject is the Ditto subject of the user who submitted or last retried the job, empty for the service itself
+	Subject string `gorm:"column:subject;type:varchar(255)"`
+	BaseEntity
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,62 @@
+package entity
+
+import "time"
+
+// Rule raises alerts when the values of a feature breach a condition. A rule
+// applies to a single thing, a namespace or, with neither set, every thing.
+type Rule struct {
+	ID          string `gorm:"column:id;type:uuid;primaryKey"`
+	Name        string `gorm:"column:name;type:varchar(255)"`
+	Description string `gorm:"column:description;type:text"`
+	Enabled     bool   `gorm:"column:enabled;default:true"`
+	ThingID     string `gorm:"column:thing_id;type:varchar(255);index"`
+	Namespace   string `gorm:"column:namespace;type:varchar(255)"`
+	Feature     string `gorm:"column:feature;type:varchar(255)"`
+	Kind        string `gorm:"column:kind;type:varchar(20)"`
+	// Operator and Threshold define a THRESHOLD condition
+	Operator  string  `gorm:"column:operator;type:varchar(5)"`
+	Threshold float64 `gorm:"column:threshold"`
+	// Min and Max bound the values allowed by a RANGE condition
+	Min *float64 `gorm:"column:min"`
+	Max *float64 `gorm:"column:max"`
+	// RatePerMinute is the largest change per minute allowed by a RATE_OF_CHANGE condition
+	RatePerMinute float64 `gorm:"column:rate_per_minute"`
+	// AbsenceSeconds is how long a feature may stay silent under an ABSENCE condition
+	AbsenceSeconds int64 `gorm:"column:absence_seconds"`
+	// Hysteresis is the margin a value must recover by before the alert resolves
+	Hysteresis float64 `gorm:"column:hysteresis"`
+	// DebounceSeconds is how long a condition must hold before the alert fires
+	DebounceSeconds int64  `gorm:"column:debounce_seconds"`
+	Severity        string `gorm:"column:severity;type:varchar(20)"`
+	// Recipients is the JSON array of email addresses notified of the alerts
+	Recipients JSON `gorm:"column:recipients;type:jsonb"`
+	// EmailSubject and EmailBody override the default alert email templates
+	EmailSubject string `gorm:"column:email_subject;type:text"`
+	EmailBody    string `gorm:"column:email_body;type:text"`
+	BaseEntity
+}
+
+func (Rule) TableName() string {
+	return "rules"
+}
+
+// Alert is a breach of a rule by a thing. It stays FIRING until the condition
+// clears, and may be ACKNOWLEDGED by a user in the meantime.
+type Alert struct {
+	ID             string     `gorm:"column:id;type:uuid;primaryKey"`
+	RuleID         string     `gorm:"column:rule_id;type:uuid;index"`
+	ThingID        string     `gorm:"column:thing_id;type:varchar(255);index"`
+	Feature        string     `gorm:"column:feature;type:varchar(255)"`
+	Severity       string     `gorm:"column:severity;type:varchar(20)"`
+	Status         string     `gorm:"column:status;type:varchar(20);index"`
+	Value          *float64   `gorm:"column:value"`
+	Message        string     `gorm:"column:message;type:text"`
+	FiredAt        time.Time  `gorm:"column:fired_at;index"`
+	ResolvedAt     *time.Time `gorm:"column:resolved_at"`
+	AcknowledgedAt *time.Time `gorm:"column:acknowledged_at"`
+	AcknowledgedBy string     `gorm:"column:acknowledged_by;type:varchar(50)"`
+}
+
+func (Alert) TableName() string {
+	return "alerts"
+}
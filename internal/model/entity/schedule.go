@@ -0,0 +1,55 @@
+package entity
+
+import "time"
+
+// Schedule runs a command against a thing or a search-selected group of things,
+// either once, on a cron expression or on a fixed interval
+type Schedule struct {
+	ID   string `gorm:"column:id;type:uuid;primaryKey"`
+	Name string `gorm:"column:name;type:varchar(255)"`
+	Kind string `gorm:"column:kind;type:varchar(20)"`
+	// RunAt is the time of a one-shot schedule
+	RunAt *time.Time `gorm:"column:run_at"`
+	// Cron is a standard five field expression, optionally prefixed with CRON_TZ=<zone>
+	Cron            string `gorm:"column:cron;type:varchar(100)"`
+	IntervalSeconds int64  `gorm:"column:interval_seconds"`
+	// Targets are either a single thing or a search filter
+	ThingID     string     `gorm:"column:thing_id;type:varchar(255);index"`
+	Filter      JSON       `gorm:"column:filter;type:jsonb"`
+	Feature     string     `gorm:"column:feature;type:varchar(255)"`
+	Subject     string     `gorm:"column:subject;type:varchar(255)"`
+	Params      JSON       `gorm:"column:params;type:jsonb"`
+	TimeoutMs   int64      `gorm:"column:timeout_ms"`
+	MaxAttempts int        `gorm:"column:max_attempts;default:1"`
+	Enabled     bool       `gorm:"column:enabled;default:true"`
+	NextRunAt   *time.Time `gorm:"column:next_run_at;index"`
+	LastRunAt   *time.Time `gorm:"column:last_run_at"`
+	// RunAs is the Ditto subject of the user who last created or updated the schedule,
+	// its runs are made on their behalf. It is empty for the service itself.
+	RunAs string `gorm:"column:run_as;type:varchar(255)"`
+	BaseEntity
+}
+
+func (Schedule) TableName() string {
+	return "schedules"
+}
+
+// ScheduleExecution is a single run of a schedule
+type ScheduleExecution struct {
+	ID         string     `gorm:"column:id;type:uuid;primaryKey"`
+	ScheduleID string     `gorm:"column:schedule_id;type:uuid;index"`
+	JobID      string     `gorm:"column:job_id;type:uuid"`
+	Status     string     `gorm:"column:status;type:varchar(20)"`
+	Matched    int        `gorm:"column:matched;default:0"`
+	Succeeded  int        `gorm:"column:succeeded;default:0"`
+	Failed     int        `gorm:"column:failed;default:0"`
+	Results    JSON       `gorm:"column:results;type:jsonb"`
+	Error      string     `gorm:"column:error;type:text"`
+	StartedAt  time.Time  `gorm:"column:started_at;index"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+	BaseEntity
+}
+
+func (ScheduleExecution) TableName() string {
+	return "schedule_executions"
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// User is an account that logs in to the API with a username and password
+type User struct {
+	ID       string `gorm:"column:id;type:uuid;primaryKey"`
+	Username string `gorm:"column:username;type:varchar(255);uniqueIndex"`
+	Email    string `gorm:"column:email;type:varchar(255)"`
+	// PasswordHash is the bcrypt hash of the password
+	PasswordHash string `gorm:"column:password_hash;type:varchar(255)"`
+	Enabled      bool   `gorm:"column:enabled;default:true"`
+	// Roles is the JSON array of the roles granting the permissions of the user
+	Roles       JSON       `gorm:"column:roles;type:jsonb"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at"`
+	BaseEntity
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+// RefreshToken is an issued refresh token, identified by the jti of the token.
+// Refreshing revokes the token and links it to its successor. The tokens issued
+// from one login share a family, which is revoked as a whole on logout or when
+// an already rotated token is used again.
+type RefreshToken struct {
+	ID         string     `gorm:"column:id;type:uuid;primaryKey"`
+	UserID     string     `gorm:"column:user_id;type:uuid;index"`
+	FamilyID   string     `gorm:"column:family_id;type:uuid;index"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+	ReplacedBy string     `gorm:"column:replaced_by;type:varchar(36)"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
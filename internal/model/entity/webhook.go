@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+// Webhook posts the events of the subscribed types to a URL. ThingID, Namespace
+// and Feature optionally narrow down the events delivered.
+type Webhook struct {
+	ID      string `gorm:"column:id;type:uuid;primaryKey"`
+	Name    string `gorm:"column:name;type:varchar(255)"`
+	URL     string `gorm:"column:url;type:text"`
+	Secret  string `gorm:"column:secret;type:varchar(255)"`
+	Enabled bool   `gorm:"column:enabled;default:true"`
+	// EventTypes is the JSON array of subscribed event types
+	EventTypes JSON   `gorm:"column:event_types;type:jsonb"`
+	ThingID    string `gorm:"column:thing_id;type:varchar(255)"`
+	Namespace  string `gorm:"column:namespace;type:varchar(255)"`
+	Feature    string `gorm:"column:feature;type:varchar(255)"`
+	BaseEntity
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// WebhookDelivery is an event queued for a webhook and the outcome of sending it.
+// Deliveries that exhausted their attempts stay DEAD until retried by hand.
+type WebhookDelivery struct {
+	ID             string     `gorm:"column:id;type:uuid;primaryKey"`
+	WebhookID      string     `gorm:"column:webhook_id;type:uuid;index"`
+	EventID        string     `gorm:"column:event_id;type:uuid"`
+	EventType      string     `gorm:"column:event_type;type:varchar(50)"`
+	ThingID        string     `gorm:"column:thing_id;type:varchar(255)"`
+	Payload        JSON       `gorm:"column:payload;type:jsonb"`
+	Status         string     `gorm:"column:status;type:varchar(20);index"`
+	Attempts       int        `gorm:"column:attempts;default:0"`
+	NextAttemptAt  *time.Time `gorm:"column:next_attempt_at;index"`
+	LastAttemptAt  *time.Time `gorm:"column:last_attempt_at"`
+	ResponseStatus int        `gorm:"column:response_status"`
+	Error          string     `gorm:"column:error;type:text"`
+	DeliveredAt    *time.Time `gorm:"column:delivered_at"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime;index"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification event types
+const (
+	EventAlertFired        = "alert.fired"
+	EventAlertResolved     = "alert.resolved"
+	EventAlertAcknowledged = "alert.acknowledged"
+	EventDeviceOnline      = "device.online"
+	EventDeviceOffline     = "device.offline"
+	EventTwinCreated       = "twin.created"
+	EventTwinModified      = "twin.modified"
+	EventTwinMerged        = "twin.merged"
+	EventTwinDeleted       = "twin.deleted"
+	// EventWebhookTest is sent on demand to check a webhook, it needs no subscription
+	EventWebhookTest = "webhook.test"
+)
+
+// EventTypes lists every event type notifications may subscribe to
+var EventTypes = []string{
+	EventAlertFired, EventAlertResolved, EventAlertAcknowledged,
+	EventDeviceOnline, EventDeviceOffline,
+	EventTwinCreated, EventTwinModified, EventTwinMerged, EventTwinDeleted,
+}
+
+// NotificationEvent is an event sent to notification channels
+type NotificationEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	ThingID   string          `json:"thingId"`
+	Feature   string          `json:"feature,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookSpec describes where a webhook posts and which events it receives
+type WebhookSpec struct {
+	Name string
+	URL  string
+	// Secret signs the deliveries, one is generated when empty
+	Secret     string
+	Enabled    bool
+	EventTypes []string
+	ThingID    string
+	Namespace  string
+	Feature    string
+}
@@ -0,0 +1,33 @@
+package model
+
+const (
+	RowValid   = "VALID"
+	RowInvalid = "INVALID"
+	RowPending = "PENDING"
+	RowCreated = "CREATED"
+	RowFailed  = "FAILED"
+)
+
+// ProvisioningRow is a single device of a bulk provisioning request
+type ProvisioningRow struct {
+	ThingID    string                 `json:"thingId"`
+	PolicyID   string                 `json:"policyId,omitempty"`
+	Definition string                 `json:"definition,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Features   map[string]Feature     `json:"features,omitempty"`
+}
+
+// ProvisioningRowResult reports the outcome of a single row
+type ProvisioningRowResult struct {
+	Row     int    `json:"row"`
+	ThingID string `json:"thingId"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ProvisioningReport is the result of a provisioning job
+type ProvisioningReport struct {
+	Succeeded int                     `json:"succeeded"`
+	Failed    int                     `json:"failed"`
+	Results   []ProvisioningRowResult `json:"results"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// RuleSpec describes the condition of an alert rule and the things it applies to
+type RuleSpec struct {
+	Name        string
+	Description string
+	Enabled     bool
+	// ThingID and Namespace optionally restrict the rule, neither applies it to every thing
+	ThingID   string
+	Namespace string
+	Feature   string
+	Kind      string
+	Operator  string
+	Threshold float64
+	Min       *float64
+	Max       *float64
+	// RatePerMinute is the largest change of the value per minute
+	RatePerMinute float64
+	Absence       time.Duration
+	Hysteresis    float64
+	Debounce      time.Duration
+	Severity      string
+	// Recipients are emailed when an alert fires or resolves, using the optional templates
+	Recipients   []string
+	EmailSubject string
+	EmailBody    string
+}
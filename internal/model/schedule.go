@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ScheduleSpec describes when a command runs and which things it targets
+type ScheduleSpec struct {
+	Name string
+	Kind string
+	// RunAt is the time of a one-shot schedule, or the first run of an interval schedule
+	RunAt    *time.Time
+	Cron     string
+	Interval time.Duration
+	// Exactly one of ThingID and Filter selects the targets
+	ThingID     string
+	Filter      *SearchFilter
+	Feature     string
+	Subject     string
+	Params      json.RawMessage
+	Timeout     time.Duration
+	MaxAttempts int
+	Enabled     bool
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"ditto/internal/ditto/rql"
+)
+
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// searchablePaths are the top level thing properties predicates may reference
+var searchablePaths = []string{"thingId", "policyId", "definition", "_created", "_modified", "_revision"}
+
+// searchablePrefixes are the nested thing properties predicates may reference
+var searchablePrefixes = []string{"attributes/", "features/"}
+
+// SearchPredicate is a single condition on a thing property
+type SearchPredicate struct {
+	Path   string        `json:"path" binding:"required"`
+	Op     string        `json:"op" binding:"required,oneof=eq ne gt ge lt le in like ilike exists"`
+	Value  interface{}   `json:"value"`
+	Values []interface{} `json:"values"`
+}
+
+// SearchFilter selects things by namespace and predicates
+type SearchFilter struct {
+	Namespaces []string          `json:"namespaces"`
+	Filters    []SearchPredicate `json:"filters" binding:"dive"`
+	// Match combines the filters with "all" (default) or "any"
+	Match string `json:"match" binding:"omitempty,oneof=all any"`
+}
+
+// Expr converts the predicate to an RQL expression
+func (p SearchPredicate) Expr() (rql.Expr, error) {
+	if err := validateSearchPath(p.Path); err != nil {
+		return nil, err
+	}
+
+	var expr rql.Expr
+	switch p.Op {
+	case "eq":
+		expr = rql.Eq(p.Path, p.Value)
+	case "ne":
+		expr = rql.Ne(p.Path, p.Value)
+	case "gt":
+		expr = rql.Gt(p.Path, p.Value)
+	case "ge":
+		expr = rql.Ge(p.Path, p.Value)
+	case "lt":
+		expr = rql.Lt(p.Path, p.Value)
+	case "le":
+		expr = rql.Le(p.Path, p.Value)
+	case "in":
+		expr = rql.In(p.Path, p.Values...)
+	case "like", "ilike":
+		pattern, ok := p.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s on %s requires a string value", p.Op, p.Path)
+		}
+		if p.Op == "like" {
+			expr = rql.Like(p.Path, pattern)
+		} else {
+			expr = rql.ILike(p.Path, pattern)
+		}
+	case "exists":
+		expr = rql.Exists(p.Path)
+	default:
+		return nil, fmt.Errorf("unsupported operator: %s", p.Op)
+	}
+
+	if err := expr.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filter on %s: %v", p.Path, err)
+	}
+	return expr, nil
+}
+
+// Expr converts the filter to an RQL expression
+func (f SearchFilter) Expr() (rql.Expr, error) {
+	filters := make([]rql.Expr, 0, len(f.Filters))
+	for _, p := range f.Filters {
+		expr, err := p.Expr()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, expr)
+	}
+	if f.Match == MatchAny {
+		return rql.Or(filters...), nil
+	}
+	return rql.And(filters...), nil
+}
+
+// validateSearchPath restricts predicates to thing attributes, features and metadata
+func validateSearchPath(path string) error {
+	for _, p := range searchablePaths {
+		if path == p {
+			return nil
+		}
+	}
+	for _, prefix := range searchablePrefixes {
+		if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+			return rql.ValidatePath(path)
+		}
+	}
+	return fmt.Errorf("unsupported filter path: %s", path)
+}
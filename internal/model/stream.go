@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceEvent is a decoded twin event streamed to API clients
+type DeviceEvent struct {
+	ThingID   string          `json:"thingId"`
+	Action    string          `json:"action"`
+	Topic     string          `json:"topic"`
+	Path      string          `json:"path"`
+	Feature   string          `json:"feature,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	Revision  int64           `json:"revision,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// StreamCursor is the position of an event in the stream, a thing and its Ditto revision
+type StreamCursor struct {
+	ThingID  string
+	Revision int64
+}
+
+// String formats the cursor as <thingId>:<revision>
+func (c StreamCursor) String() string {
+	return c.ThingID + ":" + strconv.FormatInt(c.Revision, 10)
+}
+
+// ParseStreamCursor parses a cursor formatted as <thingId>:<revision>
+func ParseStreamCursor(value string) (StreamCursor, error) {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return StreamCursor{}, fmt.Errorf("invalid event id: %s", value)
+	}
+	revision, err := strconv.ParseInt(value[i+1:], 10, 64)
+	if err != nil {
+		return StreamCursor{}, fmt.Errorf("invalid event id: %s", value)
+	}
+	return StreamCursor{ThingID: value[:i], Revision: revision}, nil
+}
+
+// Cursor returns the stream position of the event
+func (e DeviceEvent) Cursor() StreamCursor {
+	return StreamCursor{ThingID: e.ThingID, Revision: e.Revision}
+}
+
+// StreamFilter selects the events a client receives, empty lists match everything
+type StreamFilter struct {
+	ThingIDs   []string `json:"things,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Features   []string `json:"features,omitempty"`
+}
+
+// Matches tells whether an event passes the filter. Events changing a whole thing
+// or all its features pass a feature filter since they may include the feature.
+func (f StreamFilter) Matches(event DeviceEvent) bool {
+	if len(f.ThingIDs) > 0 && !containsString(f.ThingIDs, event.ThingID) {
+		return false
+	}
+	if len(f.Namespaces) > 0 {
+		namespace := event.ThingID
+		if i := strings.Index(namespace, ":"); i >= 0 {
+			namespace = namespace[:i]
+		}
+		if !containsString(f.Namespaces, namespace) {
+			return false
+		}
+	}
+	if len(f.Features) > 0 && event.Feature != "" && !containsString(f.Features, event.Feature) {
+		return false
+	}
+	return true
+}
+
+// containsString tells whether a list holds a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
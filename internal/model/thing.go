@@ -20,6 +20,12 @@ type Feature struct {
 	DesiredProperties map[string]interface{} `json:"desiredProperties,omitempty"`
 }
 
+// ThingList represents a page of things returned by a search
+type ThingList struct {
+	Items  []*Thing `json:"items"`
+	Cursor string   `json:"cursor,omitempty"`
+}
+
 // ThingCreate represents the data needed to create a thing
 type ThingCreate struct {
 	ID         string                 `json:"thingId" validate:"required"`
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when no key of the JWKS matches the kid of a token
+var ErrUnknownKey = errors.New("no matching key in the JWKS")
+
+// discoveryDocument is the part of the OpenID provider metadata the verifier needs
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jwk is a JSON Web Key, only RSA and EC signing keys are used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the signing keys of the provider. Keys are fetched again once
+// refreshInterval has passed, or when a token names an unknown kid, which is how
+// a key rotation shows, at most once per minRefresh so that forged kids cannot
+// make the verifier hammer the provider.
+type keySet struct {
+	client          *http.Client
+	issuer          string
+	jwksURL         string
+	refreshInterval time.Duration
+	minRefresh      time.Duration
+
+	// fetch serializes the requests to the provider
+	fetch     sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// key returns the key of a kid, an empty kid matches the only key of a single key set
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	fresh := time.Since(s.fetchedAt) < s.refreshInterval
+	s.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+
+	if err := s.refresh(ctx, ok); err != nil {
+		if ok {
+			// Keep verifying with the cached key while the provider is unreachable
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// lookup finds a cached key, the read lock must be held
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the keys again. A stale but known key is refreshed on the
+// refresh interval alone, an unknown key only when minRefresh has passed.
+func (s *keySet) refresh(ctx context.Context, known bool) error {
+	s.fetch.Lock()
+	defer s.fetch.Unlock()
+
+	s.mu.RLock()
+	since := time.Since(s.fetchedAt)
+	s.mu.RUnlock()
+	if since < s.minRefresh || (known && since < s.refreshInterval) {
+		// Fetched meanwhile by another request, or too recently to try again
+		return nil
+	}
+
+	keys, err := s.load(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A failed fetch also counts, so an unreachable provider is not retried on every request
+	s.fetchedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+// load fetches the JWKS, resolving its URL from the discovery document first if needed
+func (s *keySet) load(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	if s.jwksURL == "" {
+		var doc discoveryDocument
+		discoveryURL := strings.TrimRight(s.issuer, "/") + "/.well-known/openid-configuration"
+		if err := s.get(ctx, discoveryURL, &doc); err != nil {
+			return nil, fmt.Errorf("failed to load OIDC discovery document: %w", err)
+		}
+		if doc.Issuer != s.issuer {
+			return nil, fmt.Errorf("discovery document is for issuer %q, expected %q", doc.Issuer, s.issuer)
+		}
+		if doc.JWKSURI == "" {
+			return nil, fmt.Errorf("discovery document has no jwks_uri")
+		}
+		s.jwksURL = doc.JWKSURI
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.get(ctx, s.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// One unsupported key does not invalidate the others
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signing key")
+	}
+	return keys, nil
+}
+
+// get decodes the JSON document at a URL
+func (s *keySet) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// publicKey decodes an RSA or EC public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url encoded unsigned big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
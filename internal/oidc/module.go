@@ -0,0 +1,13 @@
+package oidc
+
+import (
+	"ditto/config"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(func(cfg *config.Config) (*Verifier, error) {
+		return NewVerifier(cfg.OIDC, nil)
+	}),
+)
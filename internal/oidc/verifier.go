@@ -0,0 +1,191 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ditto/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrDisabled is returned by the verifier when OIDC_ISSUER is not set
+var ErrDisabled = errors.New("OIDC is not configured")
+
+// Principal is the user a verified token was issued to
+type Principal struct {
+	UserID   string
+	Username string
+	Email    string
+	Roles    []string
+}
+
+// Verifier validates the access tokens of an OpenID provider: the signature
+// against the provider's JWKS, the algorithm, issuer, audience and lifetime.
+// The claims configured for the user id, username, email and roles are mapped
+// to a Principal.
+type Verifier struct {
+	config     config.OIDCConfig
+	algorithms []string
+	audiences  []string
+	keys       *keySet
+}
+
+// NewVerifier creates a verifier for the issuer of cfg, fetching its keys with client.
+// Keys are loaded on first use, so the provider may be down when the service starts.
+func NewVerifier(cfg config.OIDCConfig, client *http.Client) (*Verifier, error) {
+	if cfg.Issuer == "" {
+		return &Verifier{config: cfg}, nil
+	}
+	if client == nil {
+		client = &http.Client{Timeout: cfg.HTTPTimeout}
+	}
+	if cfg.JWKSRefresh <= 0 {
+		cfg.JWKSRefresh = time.Hour
+	}
+	if cfg.JWKSMinRefresh <= 0 {
+		cfg.JWKSMinRefresh = time.Minute
+	}
+	if cfg.UserIDClaim == "" {
+		cfg.UserIDClaim = "sub"
+	}
+
+	v := &Verifier{
+		config:     cfg,
+		algorithms: splitList(cfg.Algorithms),
+		audiences:  splitList(cfg.Audiences),
+		keys: &keySet{
+			client:          client,
+			issuer:          cfg.Issuer,
+			jwksURL:         cfg.JWKSURL,
+			refreshInterval: cfg.JWKSRefresh,
+			minRefresh:      cfg.JWKSMinRefresh,
+		},
+	}
+	// Any client of the provider could otherwise present its tokens to the API
+	if len(v.audiences) == 0 {
+		return nil, fmt.Errorf("OIDC_AUDIENCE is required with OIDC_ISSUER")
+	}
+	if len(v.algorithms) == 0 {
+		v.algorithms = []string{"RS256", "ES256"}
+	}
+	for _, alg := range v.algorithms {
+		if !strings.HasPrefix(alg, "RS") && !strings.HasPrefix(alg, "PS") && !strings.HasPrefix(alg, "ES") {
+			return nil, fmt.Errorf("OIDC algorithm %s is not an RSA or ECDSA algorithm", alg)
+		}
+	}
+	return v, nil
+}
+
+// Enabled tells whether OIDC tokens are accepted, a nil verifier accepts none
+func (v *Verifier) Enabled() bool {
+	return v != nil && v.config.Issuer != ""
+}
+
+// Issues tells whether a token was issued by the provider, judging by its unverified iss claim
+func (v *Verifier) Issues(token string) bool {
+	if !v.Enabled() {
+		return false
+	}
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+	return claims.Issuer == v.config.Issuer
+}
+
+// Verify validates a token and returns the user it was issued to
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	if !v.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods(v.algorithms),
+		jwt.WithIssuer(v.config.Issuer),
+		jwt.WithLeeway(v.config.Leeway),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC token: %w", err)
+	}
+
+	audiences, err := claims.GetAudience()
+	if err != nil || !slices.ContainsFunc(audiences, func(aud string) bool { return slices.Contains(v.audiences, aud) }) {
+		return nil, fmt.Errorf("invalid OIDC token: audience is not accepted")
+	}
+
+	principal := &Principal{
+		UserID:   claimString(claims, v.config.UserIDClaim),
+		Username: claimString(claims, v.config.UsernameClaim),
+		Email:    claimString(claims, v.config.EmailClaim),
+		Roles:    claimStrings(claims, v.config.RolesClaim),
+	}
+	if principal.UserID == "" {
+		return nil, fmt.Errorf("invalid OIDC token: no %s claim", v.config.UserIDClaim)
+	}
+	if principal.Username == "" {
+		principal.Username = principal.UserID
+	}
+	return principal, nil
+}
+
+// claim returns the value at a dotted path of nested claims
+func claim(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// claimString returns a string claim, other types are ignored
+func claimString(claims map[string]interface{}, path string) string {
+	value, _ := claim(claims, path).(string)
+	return value
+}
+
+// claimStrings returns a list claim, given either as an array or as a space or comma separated string
+func claimStrings(claims map[string]interface{}, path string) []string {
+	switch value := claim(claims, path).(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	case string:
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	default:
+		return nil
+	}
+}
+
+// splitList splits a comma separated setting
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,399 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ditto/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testProvider is an OpenID provider serving a discovery document and a JWKS
+// that can be rotated, counting the requests it gets
+type testProvider struct {
+	server *httptest.Server
+
+	mu              sync.Mutex
+	keys            []jwk
+	discoveryHits   int
+	jwksHits        int
+	discoveryIssuer string
+}
+
+func newTestProvider(t *testing.T, keys ...jwk) *testProvider {
+	t.Helper()
+	p := &testProvider{keys: keys}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			p.discoveryHits++
+			issuer := p.server.URL
+			if p.discoveryIssuer != "" {
+				issuer = p.discoveryIssuer
+			}
+			_ = json.NewEncoder(w).Encode(discoveryDocument{Issuer: issuer, JWKSURI: p.server.URL + "/keys"})
+		case "/keys":
+			p.jwksHits++
+			_ = json.NewEncoder(w).Encode(map[string][]jwk{"keys": p.keys})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) issuer() string {
+	return p.server.URL
+}
+
+func (p *testProvider) rotate(keys ...jwk) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+}
+
+func (p *testProvider) hits() (discovery, jwks int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.discoveryHits, p.jwksHits
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encodeBigInt(key.N), E: encodeBigInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256", X: encodeBigInt(key.X), Y: encodeBigInt(key.Y)}
+}
+
+// testAudience is the audience of the test verifiers, which tokens name by default
+const testAudience = "ditto-api"
+
+// sign issues a token, claims default to a valid token of the provider
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, issuer string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.MapClaims{
+		"iss": issuer,
+		"aud": testAudience,
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(token, name)
+			continue
+		}
+		token[name] = value
+	}
+
+	jwtToken := jwt.NewWithClaims(method, token)
+	if kid != "" {
+		jwtToken.Header["kid"] = kid
+	}
+	signed, err := jwtToken.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// newTestVerifier creates a verifier accepting testAudience unless cfg names the audiences
+func newTestVerifier(t *testing.T, cfg config.OIDCConfig) *Verifier {
+	t.Helper()
+	if cfg.Audiences == "" {
+		cfg.Audiences = testAudience
+	}
+	v, err := NewVerifier(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifyMapsClaims(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t), newECKey(t)
+	provider := newTestProvider(t, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+	v := newTestVerifier(t, config.OIDCConfig{
+		Issuer:        provider.issuer(),
+		Leeway:        30 * time.Second,
+		UsernameClaim: "preferred_username",
+		EmailClaim:    "email",
+		RolesClaim:    "realm_access.roles",
+	})
+
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, provider.issuer(), jwt.MapClaims{
+		"preferred_username": "jane",
+		"email":              "jane@example.com",
+		"realm_access":       map[string]interface{}{"roles": []string{"operator", "", "viewer"}},
+	})
+	principal, err := v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.UserID != "user-1" || principal.Username != "jane" || principal.Email != "jane@example.com" {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+	if !slices.Equal(principal.Roles, []string{"operator", "viewer"}) {
+		t.Errorf("expected the nested roles, got %v", principal.Roles)
+	}
+
+	// ES256 keys of the same set verify as well, and the username falls back to the user id
+	token = sign(t, jwt.SigningMethodES256, "ec-1", ecKey, provider.issuer(), jwt.MapClaims{
+		"realm_access": map[string]interface{}{"roles": "admin, viewer"},
+	})
+	principal, err = v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Username != "user-1" || !slices.Equal(principal.Roles, []string{"admin", "viewer"}) {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+
+	// Keys are discovered once and cached
+	if discovery, jwks := provider.hits(); discovery != 1 || jwks != 1 {
+		t.Errorf("expected one discovery and one JWKS request, got %d and %d", discovery, jwks)
+	}
+
+	// A token without the user id claim has no principal
+	token = sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, provider.issuer(), jwt.MapClaims{"sub": nil})
+	if _, err := v.Verify(context.Background(), token); err == nil {
+		t.Error("expected a token without sub to be rejected")
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t), newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", oldKey))
+	v := newTestVerifier(t, config.OIDCConfig{
+		Issuer:         provider.issuer(),
+		JWKSMinRefresh: 10 * time.Millisecond,
+	})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", oldKey, provider.issuer(), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.rotate(rsaJWK("k2", newKey))
+	time.Sleep(20 * time.Millisecond)
+
+	// The unknown kid of the new key makes the verifier fetch the keys again
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k2", newKey, provider.issuer(), nil)); err != nil {
+		t.Fatalf("expected the rotated key to be picked up, got %v", err)
+	}
+	if discovery, jwks := provider.hits(); discovery != 1 || jwks != 2 {
+		t.Errorf("expected the JWKS to be fetched again without discovery, got %d and %d", discovery, jwks)
+	}
+
+	// The retired key is gone with the new key set
+	_, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", oldKey, provider.issuer(), nil))
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected the retired key to be unknown, got %v", err)
+	}
+}
+
+func TestVerifyThrottlesRefetch(t *testing.T) {
+	key := newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", key))
+	v := newTestVerifier(t, config.OIDCConfig{
+		Issuer:         provider.issuer(),
+		JWKSURL:        provider.issuer() + "/keys",
+		JWKSMinRefresh: time.Hour,
+	})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Forged kids do not reach the provider again within JWKSMinRefresh
+	for _, kid := range []string{"forged-1", "forged-2", "forged-3"} {
+		_, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, kid, key, provider.issuer(), nil))
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("kid %s: expected ErrUnknownKey, got %v", kid, err)
+		}
+	}
+	// A configured JWKS URL skips discovery
+	if discovery, jwks := provider.hits(); discovery != 0 || jwks != 1 {
+		t.Errorf("expected a single JWKS request and no discovery, got %d and %d", discovery, jwks)
+	}
+}
+
+func TestVerifyRejectsAlgorithms(t *testing.T) {
+	key := newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", key))
+	v := newTestVerifier(t, config.OIDCConfig{Issuer: provider.issuer(), Algorithms: "RS256"})
+
+	publicKey, _ := json.Marshal(rsaJWK("k1", key))
+	tests := map[string]string{
+		// A HMAC over the public key must not pass for a signature of the key
+		"HS256":         sign(t, jwt.SigningMethodHS256, "k1", publicKey, provider.issuer(), nil),
+		"none":          sign(t, jwt.SigningMethodNone, "k1", jwt.UnsafeAllowNoneSignatureType, provider.issuer(), nil),
+		"not allowed":   sign(t, jwt.SigningMethodRS384, "k1", key, provider.issuer(), nil),
+		"other key":     sign(t, jwt.SigningMethodRS256, "k1", newRSAKey(t), provider.issuer(), nil),
+		"not a JWT":     "not.a.token",
+		"empty":         "",
+		"unsigned body": sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), nil) + "x",
+	}
+	for name, token := range tests {
+		if _, err := v.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	for _, algorithms := range []string{"HS256", "RS256,none"} {
+		if _, err := NewVerifier(config.OIDCConfig{Issuer: provider.issuer(), Audiences: testAudience, Algorithms: algorithms}, nil); err == nil {
+			t.Errorf("expected algorithms %s to be refused", algorithms)
+		}
+	}
+}
+
+func TestVerifyIssuerAndAudience(t *testing.T) {
+	key := newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", key))
+	v := newTestVerifier(t, config.OIDCConfig{Issuer: provider.issuer(), Audiences: "ditto-api, ditto-ui"})
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{name: "accepted audience", claims: jwt.MapClaims{"aud": "ditto-ui"}, valid: true},
+		{name: "one of several audiences", claims: jwt.MapClaims{"aud": []string{"account", "ditto-api"}}, valid: true},
+		{name: "other audience", claims: jwt.MapClaims{"aud": "account"}},
+		{name: "no audience", claims: jwt.MapClaims{"aud": nil}},
+		{name: "other issuer", claims: jwt.MapClaims{"aud": "ditto-api", "iss": "https://evil.example.com"}},
+		{name: "no issuer", claims: jwt.MapClaims{"aud": "ditto-api", "iss": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), tt.claims))
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+
+	// An audience is required, any client of the provider could present its tokens otherwise
+	for _, audiences := range []string{"", " , "} {
+		if _, err := NewVerifier(config.OIDCConfig{Issuer: provider.issuer(), Audiences: audiences}, nil); err == nil {
+			t.Errorf("expected audiences %q to be refused", audiences)
+		}
+	}
+
+	other := sign(t, jwt.SigningMethodRS256, "k1", key, "https://evil.example.com", nil)
+	if !v.Issues(sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), nil)) || v.Issues(other) {
+		t.Error("expected Issues to tell tokens of the provider from others")
+	}
+}
+
+func TestVerifyDiscoveryIssuerMismatch(t *testing.T) {
+	key := newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", key))
+	provider.discoveryIssuer = "https://evil.example.com"
+	v := newTestVerifier(t, config.OIDCConfig{Issuer: provider.issuer()})
+
+	if _, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), nil)); err == nil {
+		t.Fatal("expected the keys of a discovery document for another issuer to be refused")
+	}
+	if _, jwks := provider.hits(); jwks != 0 {
+		t.Errorf("expected no JWKS request, got %d", jwks)
+	}
+}
+
+func TestVerifyExpiryLeeway(t *testing.T) {
+	key := newRSAKey(t)
+	provider := newTestProvider(t, rsaJWK("k1", key))
+	v := newTestVerifier(t, config.OIDCConfig{Issuer: provider.issuer(), Leeway: 30 * time.Second})
+
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{name: "expired within leeway", claims: jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}, valid: true},
+		{name: "expired beyond leeway", claims: jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}},
+		{name: "no expiry", claims: jwt.MapClaims{"exp": nil}},
+		{name: "not yet valid within leeway", claims: jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()}, valid: true},
+		{name: "not yet valid beyond leeway", claims: jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, "k1", key, provider.issuer(), tt.claims))
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":          "user-1",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin", 1, "viewer"}},
+		"resource":     map[string]interface{}{"ditto": map[string]interface{}{"roles": "operator viewer"}},
+		"scope":        "a,b c",
+	}
+
+	if got := claimString(claims, "sub"); got != "user-1" {
+		t.Errorf("expected user-1, got %q", got)
+	}
+	if got := claimString(claims, "realm_access"); got != "" {
+		t.Errorf("expected an object to be no string, got %q", got)
+	}
+	if got := claimStrings(claims, "realm_access.roles"); !slices.Equal(got, []string{"admin", "viewer"}) {
+		t.Errorf("unexpected roles %v", got)
+	}
+	if got := claimStrings(claims, "resource.ditto.roles"); !slices.Equal(got, []string{"operator", "viewer"}) {
+		t.Errorf("unexpected roles %v", got)
+	}
+	if got := claimStrings(claims, "scope"); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected scope %v", got)
+	}
+	for _, path := range []string{"", "missing", "sub.roles", "realm_access.roles.admin"} {
+		if got := claim(claims, path); got != nil {
+			t.Errorf("claim(%q) = %v, want nil", path, got)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package rbac
+
+import (
+	"ditto/config"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(func(cfg *config.Config) (*Policy, error) {
+		return ParsePolicy(cfg.RBAC.Roles)
+	}),
+)
@@ -0,0 +1,135 @@
+package rbac
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Permissions checked by the API routes
+const (
+	DevicesRead   = "devices:read"
+	DevicesWrite  = "devices:write"
+	CommandsSend  = "commands:send"
+	PoliciesAdmin = "policies:admin"
+	RulesWrite    = "rules:write"
+	WebhooksAdmin = "webhooks:admin"
+	UsersAdmin    = "users:admin"
+	// All grants every permission
+	All = "*"
+)
+
+// Permissions lists every permission
+var Permissions = []string{DevicesRead, DevicesWrite, CommandsSend, PoliciesAdmin, RulesWrite, WebhooksAdmin, UsersAdmin}
+
+// Policy maps roles to the permissions they grant. Users get roles, assigned in
+// the user store or taken from the roles claim of their OIDC token. A role named
+// after a permission grants that permission, so SSO groups may name permissions directly.
+type Policy struct {
+	roles map[string][]string
+}
+
+// ParsePolicy parses comma separated role definitions of the form
+// role=permission permission, for example operator=devices:read commands:send
+func ParsePolicy(value string) (*Policy, error) {
+	p := &Policy{roles: make(map[string][]string)}
+	for _, text := range strings.Split(value, ",") {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		role, permissions, ok := strings.Cut(text, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("invalid role %q: expected role=permission permission", text)
+		}
+		for _, permission := range strings.Fields(permissions) {
+			if permission != All && !slices.Contains(Permissions, permission) {
+				return nil, fmt.Errorf("invalid role %q: unknown permission %s", role, permission)
+			}
+			p.roles[role] = append(p.roles[role], permission)
+		}
+	}
+	return p, nil
+}
+
+// Valid tells whether a role is defined or names a permission
+func (p *Policy) Valid(role string) bool {
+	_, ok := p.roles[role]
+	return ok || slices.Contains(Permissions, role)
+}
+
+// Resolve returns the sorted permissions granted by a set of roles, unknown roles grant nothing
+func (p *Policy) Resolve(roles []string) []string {
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		if permissions, ok := p.roles[role]; ok {
+			for _, permission := range permissions {
+				granted[permission] = true
+			}
+		} else if slices.Contains(Permissions, role) {
+			granted[role] = true
+		}
+	}
+
+	permissions := make([]string, 0, len(granted))
+	for permission := range granted {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// Allows tells whether granted permissions include a permission
+func Allows(granted []string, permission string) bool {
+	return slices.Contains(granted, All) || slices.Contains(granted, permission)
+}
+
+// ProxyPermission returns the permission a proxied request needs, judged by the
+// escaped Ditto API path it is forwarded to. Policies need policies:admin, thing
+// commands and messages commands:send, reads devices:read and all other requests
+// devices:write. Segments are compared decoded, as Ditto resolves them.
+func ProxyPermission(method, path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	isMessage := func(segment string) bool {
+		return segment == "commands" || segment == "inbox" || segment == "outbox"
+	}
+	isThing := len(segments) >= 2 && segments[0] == "things"
+	switch {
+	case len(segments) >= 1 && segments[0] == "policies":
+		return PoliciesAdmin
+	case isThing && len(segments) >= 3 && isMessage(segments[2]),
+		isThing && len(segments) >= 5 && segments[2] == "features" && isMessage(segments[4]):
+		return CommandsSend
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return DevicesRead
+	default:
+		return DevicesWrite
+	}
+}
+
+// StreamPermission returns the permission a Ditto WebSocket subscription needs,
+// live commands and messages need commands:send and events devices:read
+func StreamPermission(stream string) string {
+	switch stream {
+	case "MESSAGES", "LIVE-COMMANDS":
+		return CommandsSend
+	default:
+		return DevicesRead
+	}
+}
+
+// SplitRoles splits a comma or space separated list of roles
+func SplitRoles(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,110 @@
+package rbac
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("viewer=devices:read, operator=devices:read devices:write commands:send, admin=*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		roles []string
+		want  []string
+	}{
+		{roles: nil, want: []string{}},
+		{roles: []string{"viewer"}, want: []string{DevicesRead}},
+		{roles: []string{"viewer", "operator"}, want: []string{CommandsSend, DevicesRead, DevicesWrite}},
+		{roles: []string{"admin"}, want: []string{All}},
+		{roles: []string{"viewer", "rules:write"}, want: []string{DevicesRead, RulesWrite}},
+		{roles: []string{"unknown"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := policy.Resolve(tt.roles); !slices.Equal(got, tt.want) {
+			t.Errorf("Resolve(%v) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+
+	if !policy.Valid("operator") || !policy.Valid(UsersAdmin) || policy.Valid("unknown") {
+		t.Error("expected defined roles and permissions to be valid and others not")
+	}
+
+	for _, value := range []string{"viewer", "=devices:read", "viewer=devices:delete"} {
+		if _, err := ParsePolicy(value); err == nil {
+			t.Errorf("ParsePolicy(%q): expected an error", value)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	if !Allows([]string{DevicesRead}, DevicesRead) {
+		t.Error("expected a granted permission to be allowed")
+	}
+	if Allows([]string{DevicesRead}, DevicesWrite) {
+		t.Error("expected a missing permission to be refused")
+	}
+	if !Allows([]string{All}, PoliciesAdmin) {
+		t.Error("expected * to allow every permission")
+	}
+	if Allows(nil, DevicesRead) {
+		t.Error("expected no permissions to allow nothing")
+	}
+}
+
+func TestProxyPermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodGet, path: "/things", want: DevicesRead},
+		{method: http.MethodGet, path: "/things/org:dev", want: DevicesRead},
+		{method: http.MethodHead, path: "/things/org:dev/attributes", want: DevicesRead},
+		{method: http.MethodOptions, path: "/things/org:dev", want: DevicesRead},
+		{method: http.MethodPut, path: "/things/org:dev", want: DevicesWrite},
+		{method: http.MethodPatch, path: "/things/org:dev/features/temp/properties", want: DevicesWrite},
+		{method: http.MethodDelete, path: "/things/org:dev", want: DevicesWrite},
+		{method: http.MethodPost, path: "/things/org:dev/inbox/messages/reboot", want: CommandsSend},
+		{method: http.MethodGet, path: "/things/org:dev/outbox/messages/status", want: CommandsSend},
+		{method: http.MethodPost, path: "/things/org:dev/features/lamp/inbox/messages/on", want: CommandsSend},
+		{method: http.MethodPost, path: "/things/org:dev/commands/reboot", want: CommandsSend},
+		{method: http.MethodGet, path: "/things/org:dev/features/inbox", want: DevicesRead},
+		{method: http.MethodGet, path: "/policies/org:p", want: PoliciesAdmin},
+		{method: http.MethodPut, path: "/policies/org:p/entries/owner", want: PoliciesAdmin},
+		// Segments are compared the way Ditto decodes them
+		{method: http.MethodPost, path: "/things/org:dev/%69nbox/messages/reboot", want: CommandsSend},
+		{method: http.MethodPost, path: "/things/org:dev/features/lamp/%6Futbox/messages/on", want: CommandsSend},
+		{method: http.MethodGet, path: "/%70olicies/org:p", want: PoliciesAdmin},
+		{method: http.MethodGet, path: "//things//org:dev//inbox/messages/x", want: CommandsSend},
+	}
+
+	for _, tt := range tests {
+		if got := ProxyPermission(tt.method, tt.path); got != tt.want {
+			t.Errorf("ProxyPermission(%s, %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStreamPermission(t *testing.T) {
+	tests := map[string]string{
+		"EVENTS":        DevicesRead,
+		"LIVE-EVENTS":   DevicesRead,
+		"MESSAGES":      CommandsSend,
+		"LIVE-COMMANDS": CommandsSend,
+	}
+	for stream, want := range tests {
+		if got := StreamPermission(stream); got != want {
+			t.Errorf("StreamPermission(%s) = %s, want %s", stream, got, want)
+		}
+	}
+}
+
+func TestSplitRoles(t *testing.T) {
+	if got := SplitRoles("admin, viewer operator,,"); !slices.Equal(got, []string{"admin", "viewer", "operator"}) {
+		t.Errorf("unexpected roles %v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// AlertFilter narrows down an alert listing
+type AlertFilter struct {
+	RuleID  string
+	ThingID string
+	Status  string
+	Limit   int
+	Offset  int
+}
+
+// AlertRepository defines the interface for alert persistence
+type AlertRepository interface {
+	Create(ctx context.Context, alert *entity.Alert) error
+	GetByID(ctx context.Context, id string) (*entity.Alert, error)
+	List(ctx context.Context, filter AlertFilter) ([]*entity.Alert, int64, error)
+	// ListActive returns the firing and acknowledged alerts, used to restore the rules engine
+	ListActive(ctx context.Context) ([]*entity.Alert, error)
+	// Resolve resolves an active alert
+	Resolve(ctx context.Context, id string, value *float64, at time.Time) error
+	// ResolveByRule resolves every active alert of a rule
+	ResolveByRule(ctx context.Context, ruleID string, at time.Time) error
+	// Acknowledge acknowledges a firing alert, ErrNotFound is returned when there is none
+	Acknowledge(ctx context.Context, id, user string, at time.Time) (*entity.Alert, error)
+}
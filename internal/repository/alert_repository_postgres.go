@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// activeAlertStatuses are the statuses of alerts whose condition still holds
+var activeAlertStatuses = []string{enum.AlertFiring, enum.AlertAcknowledged}
+
+// AlertRepositoryPostgres implements AlertRepository on top of Postgres
+type AlertRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewAlertRepository creates a new Postgres backed alert repository
+func NewAlertRepository(db database.Database) AlertRepository {
+	return &AlertRepositoryPostgres{db: db}
+}
+
+// Create implements AlertRepository
+func (r *AlertRepositoryPostgres) Create(ctx context.Context, alert *entity.Alert) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(alert).Error; err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+	return nil
+}
+
+// GetByID implements AlertRepository
+func (r *AlertRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Alert, error) {
+	var alert entity.Alert
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&alert).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+	return &alert, nil
+}
+
+// List implements AlertRepository
+func (r *AlertRepositoryPostgres) List(ctx context.Context, filter AlertFilter) ([]*entity.Alert, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Alert{})
+	if filter.RuleID != "" {
+		query = query.Where("rule_id = ?", filter.RuleID)
+	}
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count alerts: %w", err)
+	}
+
+	var alerts []*entity.Alert
+	err := query.Order("fired_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&alerts).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	return alerts, total, nil
+}
+
+// ListActive implements AlertRepository
+func (r *AlertRepositoryPostgres) ListActive(ctx context.Context) ([]*entity.Alert, error) {
+	var alerts []*entity.Alert
+	if err := r.db.GetDB().WithContext(ctx).Where("status IN ?", activeAlertStatuses).Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list active alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// Resolve implements AlertRepository
+func (r *AlertRepositoryPostgres) Resolve(ctx context.Context, id string, value *float64, at time.Time) error {
+	updates := map[string]interface{}{
+		"status":      enum.AlertResolved,
+		"resolved_at": at,
+	}
+	if value != nil {
+		updates["value"] = *value
+	}
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Alert{}).
+		Where("id = ? AND status IN ?", id, activeAlertStatuses).
+		Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("failed to resolve alert: %w", err)
+	}
+	return nil
+}
+
+// ResolveByRule implements AlertRepository
+func (r *AlertRepositoryPostgres) ResolveByRule(ctx context.Context, ruleID string, at time.Time) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Alert{}).
+		Where("rule_id = ? AND status IN ?", ruleID, activeAlertStatuses).
+		Updates(map[string]interface{}{
+			"status":      enum.AlertResolved,
+			"resolved_at": at,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to resolve rule alerts: %w", err)
+	}
+	return nil
+}
+
+// Acknowledge implements AlertRepository
+func (r *AlertRepositoryPostgres) Acknowledge(ctx context.Context, id, user string, at time.Time) (*entity.Alert, error) {
+	var alerts []*entity.Alert
+	result := r.db.GetDB().WithContext(ctx).Model(&alerts).Clauses(clause.Returning{}).
+		Where("id = ? AND status = ?", id, enum.AlertFiring).
+		Updates(map[string]interface{}{
+			"status":          enum.AlertAcknowledged,
+			"acknowledged_at": at,
+			"acknowledged_by": user,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to acknowledge alert: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || len(alerts) == 0 {
+		return nil, ErrNotFound
+	}
+	return alerts[0], nil
+}
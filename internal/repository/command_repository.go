@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// CommandFilter narrows down a command history listing
+type CommandFilter struct {
+	ThingID string
+	Feature string
+	User    string
+	Status  string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+// CommandRepository defines the interface for command history persistence
+type CommandRepository interface {
+	Create(ctx context.Context, command *entity.Command) error
+	// Complete stores the outcome of a delivered command
+	Complete(ctx context.Context, command *entity.Command) error
+	GetByID(ctx context.Context, id string) (*entity.Command, error)
+	List(ctx context.Context, filter CommandFilter) ([]*entity.Command, int64, error)
+	// ExpirePending fails commands still pending since before the given time, they
+	// were lost when the process handling them stopped
+	ExpirePending(ctx context.Context, before time.Time) (int64, error)
+}
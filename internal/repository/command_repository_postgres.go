@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// CommandRepositoryPostgres implements CommandRepository on top of Postgres
+type CommandRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewCommandRepository creates a new Postgres backed command repository
+func NewCommandRepository(db database.Database) CommandRepository {
+	return &CommandRepositoryPostgres{db: db}
+}
+
+// Create implements CommandRepository
+func (r *CommandRepositoryPostgres) Create(ctx context.Context, command *entity.Command) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(command).Error; err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+	return nil
+}
+
+// Complete implements CommandRepository
+func (r *CommandRepositoryPostgres) Complete(ctx context.Context, command *entity.Command) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Command{}).Where("id = ?", command.ID).Updates(map[string]interface{}{
+		"status":          command.Status,
+		"response_status": command.ResponseStatus,
+		"response":        command.Response,
+		"error":           command.Error,
+		"completed_at":    command.CompletedAt,
+		"latency_ms":      command.LatencyMs,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to complete command: %w", err)
+	}
+	return nil
+}
+
+// GetByID implements CommandRepository
+func (r *CommandRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Command, error) {
+	var command entity.Command
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&command).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command: %w", err)
+	}
+	return &command, nil
+}
+
+// List implements CommandRepository
+func (r *CommandRepositoryPostgres) List(ctx context.Context, filter CommandFilter) ([]*entity.Command, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Command{})
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Feature != "" {
+		query = query.Where("feature = ?", filter.Feature)
+	}
+	if filter.User != "" {
+		query = query.Where("created_by = ?", filter.User)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("sent_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("sent_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count commands: %w", err)
+	}
+
+	var commands []*entity.Command
+	err := query.Order("sent_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&commands).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list commands: %w", err)
+	}
+	return commands, total, nil
+}
+
+// ExpirePending implements CommandRepository
+func (r *CommandRepositoryPostgres) ExpirePending(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Command{}).
+		Where("status = ? AND sent_at < ?", enum.CommandPending, before).
+		Updates(map[string]interface{}{
+			"status":       enum.CommandFailed,
+			"error":        "delivery interrupted",
+			"completed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to expire pending commands: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+)
+
+// ConnectivityFilter narrows down a connectivity listing
+type ConnectivityFilter struct {
+	Online     *bool
+	Definition string
+	Limit      int
+	Offset     int
+}
+
+// ConnectivityRepository defines the interface for connectivity persistence
+type ConnectivityRepository interface {
+	GetByID(ctx context.Context, thingID string) (*entity.Connectivity, error)
+	GetMany(ctx context.Context, thingIDs []string) (map[string]*entity.Connectivity, error)
+	Save(ctx context.Context, connectivity *entity.Connectivity) error
+	Delete(ctx context.Context, thingID string) error
+	List(ctx context.Context, filter ConnectivityFilter) ([]*entity.Connectivity, int64, error)
+	// Counts returns the number of online and offline things per definition
+	Counts(ctx context.Context) ([]model.ConnectivityCount, error)
+	// MarkOffline switches online things not seen since lastSeenBefore and not reported
+	// ready by their ConnectionStatus to offline and returns them. Things are selected
+	// by definition, or when definition is empty by not having one of the excluded definitions.
+	MarkOffline(ctx context.Context, definition string, excluded []string, lastSeenBefore, now time.Time) ([]*entity.Connectivity, error)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConnectivityRepositoryPostgres implements ConnectivityRepository on top of Postgres
+type ConnectivityRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewConnectivityRepository creates a new Postgres backed connectivity repository
+func NewConnectivityRepository(db database.Database) ConnectivityRepository {
+	return &ConnectivityRepositoryPostgres{db: db}
+}
+
+// GetByID implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) GetByID(ctx context.Context, thingID string) (*entity.Connectivity, error) {
+	var connectivity entity.Connectivity
+	err := r.db.GetDB().WithContext(ctx).Where("thing_id = ?", thingID).First(&connectivity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connectivity: %w", err)
+	}
+	return &connectivity, nil
+}
+
+// GetMany implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) GetMany(ctx context.Context, thingIDs []string) (map[string]*entity.Connectivity, error) {
+	var rows []*entity.Connectivity
+	if err := r.db.GetDB().WithContext(ctx).Where("thing_id IN ?", thingIDs).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get connectivity: %w", err)
+	}
+
+	result := make(map[string]*entity.Connectivity, len(rows))
+	for _, row := range rows {
+		result[row.ThingID] = row
+	}
+	return result, nil
+}
+
+// Save implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) Save(ctx context.Context, connectivity *entity.Connectivity) error {
+	if err := r.db.GetDB().WithContext(ctx).Save(connectivity).Error; err != nil {
+		return fmt.Errorf("failed to save connectivity: %w", err)
+	}
+	return nil
+}
+
+// Delete implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) Delete(ctx context.Context, thingID string) error {
+	// Connectivity is derived from events, so it is removed for good with the thing
+	err := r.db.GetDB().WithContext(ctx).Unscoped().Where("thing_id = ?", thingID).Delete(&entity.Connectivity{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete connectivity: %w", err)
+	}
+	return nil
+}
+
+// List implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) List(ctx context.Context, filter ConnectivityFilter) ([]*entity.Connectivity, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Connectivity{})
+	if filter.Online != nil {
+		query = query.Where("online = ?", *filter.Online)
+	}
+	if filter.Definition != "" {
+		query = query.Where("definition = ?", filter.Definition)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count connectivity: %w", err)
+	}
+
+	var rows []*entity.Connectivity
+	err := query.Order("last_seen_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list connectivity: %w", err)
+	}
+	return rows, total, nil
+}
+
+// Counts implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) Counts(ctx context.Context) ([]model.ConnectivityCount, error) {
+	var counts []model.ConnectivityCount
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Connectivity{}).
+		Select("definition, COUNT(*) FILTER (WHERE online) AS online, COUNT(*) FILTER (WHERE NOT online) AS offline").
+		Group("definition").
+		Order("definition").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count connectivity: %w", err)
+	}
+	return counts, nil
+}
+
+// MarkOffline implements ConnectivityRepository
+func (r *ConnectivityRepositoryPostgres) MarkOffline(ctx context.Context, definition string, excluded []string, lastSeenBefore, now time.Time) ([]*entity.Connectivity, error) {
+	var rows []*entity.Connectivity
+	query := r.db.GetDB().WithContext(ctx).Model(&rows).
+		Where("online = ? AND last_seen_at < ?", true, lastSeenBefore).
+		Where("ready_until IS NULL OR ready_until < ?", now)
+	if definition != "" {
+		query = query.Where("definition = ?", definition)
+	} else if len(excluded) > 0 {
+		query = query.Where("definition NOT IN ?", excluded)
+	}
+
+	// RETURNING fills rows with the things that were switched
+	err := query.Clauses(clause.Returning{}).Updates(map[string]interface{}{
+		"online":     false,
+		"changed_at": now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark things offline: %w", err)
+	}
+	return rows, nil
+}
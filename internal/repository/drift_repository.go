@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// DriftFilter narrows down a drift listing
+type DriftFilter struct {
+	ThingID string
+	Status  string
+	Limit   int
+	Offset  int
+}
+
+// DriftRepository defines the interface for drift state persistence
+type DriftRepository interface {
+	// Save creates or updates the drift of a thing feature
+	Save(ctx context.Context, drift *entity.Drift) error
+	ListByThing(ctx context.Context, thingID string) ([]*entity.Drift, error)
+	List(ctx context.Context, filter DriftFilter) ([]*entity.Drift, int64, error)
+	// DeleteByThing removes the drift state of a deleted thing
+	DeleteByThing(ctx context.Context, thingID string) error
+	// ClaimResends locks drifting features last sent before the given time and still
+	// below maxResends, records a new resend on them and returns them
+	ClaimResends(ctx context.Context, before time.Time, maxResends, limit int) ([]*entity.Drift, error)
+	// SetCommand stores the command sent to converge a drift
+	SetCommand(ctx context.Context, id, commandID string) error
+	// MarkStuck gives up on drifts that exhausted their resends before the given time
+	MarkStuck(ctx context.Context, before time.Time, maxResends int) (int64, error)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DriftRepositoryPostgres implements DriftRepository on top of Postgres
+type DriftRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewDriftRepository creates a new Postgres backed drift repository
+func NewDriftRepository(db database.Database) DriftRepository {
+	return &DriftRepositoryPostgres{db: db}
+}
+
+// Save implements DriftRepository
+func (r *DriftRepositoryPostgres) Save(ctx context.Context, drift *entity.Drift) error {
+	if err := r.db.GetDB().WithContext(ctx).Save(drift).Error; err != nil {
+		return fmt.Errorf("failed to save drift: %w", err)
+	}
+	return nil
+}
+
+// ListByThing implements DriftRepository
+func (r *DriftRepositoryPostgres) ListByThing(ctx context.Context, thingID string) ([]*entity.Drift, error) {
+	var drifts []*entity.Drift
+	err := r.db.GetDB().WithContext(ctx).Where("thing_id = ?", thingID).Order("feature").Find(&drifts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drifts: %w", err)
+	}
+	return drifts, nil
+}
+
+// List implements DriftRepository
+func (r *DriftRepositoryPostgres) List(ctx context.Context, filter DriftFilter) ([]*entity.Drift, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Drift{})
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count drifts: %w", err)
+	}
+
+	var drifts []*entity.Drift
+	err := query.Order("detected_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&drifts).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list drifts: %w", err)
+	}
+	return drifts, total, nil
+}
+
+// DeleteByThing implements DriftRepository
+func (r *DriftRepositoryPostgres) DeleteByThing(ctx context.Context, thingID string) error {
+	// Drift state is derived from the twin, so it is removed for good with the thing
+	err := r.db.GetDB().WithContext(ctx).Unscoped().Where("thing_id = ?", thingID).Delete(&entity.Drift{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete drifts: %w", err)
+	}
+	return nil
+}
+
+// ClaimResends implements DriftRepository
+func (r *DriftRepositoryPostgres) ClaimResends(ctx context.Context, before time.Time, maxResends, limit int) ([]*entity.Drift, error) {
+	var due []*entity.Drift
+	err := r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND resends < ?", enum.DriftDrifting, maxResends).
+			Where("COALESCE(last_resent_at, detected_at) <= ?", before).
+			Order("detected_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, drift := range due {
+			drift.Resends++
+			drift.LastResentAt = &now
+			err := tx.Model(&entity.Drift{}).Where("id = ?", drift.ID).Updates(map[string]interface{}{
+				"resends":        drift.Resends,
+				"last_resent_at": drift.LastResentAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim drift resends: %w", err)
+	}
+	return due, nil
+}
+
+// SetCommand implements DriftRepository
+func (r *DriftRepositoryPostgres) SetCommand(ctx context.Context, id, commandID string) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Drift{}).Where("id = ?", id).
+		Update("last_command_id", commandID).Error
+	if err != nil {
+		return fmt.Errorf("failed to store drift command: %w", err)
+	}
+	return nil
+}
+
+// MarkStuck implements DriftRepository
+func (r *DriftRepositoryPostgres) MarkStuck(ctx context.Context, before time.Time, maxResends int) (int64, error) {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Drift{}).
+		Where("status = ? AND resends >= ? AND last_resent_at <= ?", enum.DriftDrifting, maxResends, before).
+		Update("status", enum.DriftStuck)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark stuck drifts: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
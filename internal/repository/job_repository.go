@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// JobFilter narrows down a job listing
+type JobFilter struct {
+	Type   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// JobRepository defines the interface for job persistence
+type JobRepository interface {
+	Create(ctx context.Context, job *entity.Job) error
+	GetByID(ctx context.Context, id string) (*entity.Job, error)
+	List(ctx context.Context, filter JobFilter) ([]*entity.Job, int64, error)
+	// Claim atomically moves the oldest runnable job of the given types to RUNNING,
+	// leased to owner
+	Claim(ctx context.Context, types []string, owner string, now time.Time) (*entity.Job, error)
+	// Heartbeat renews the lease of a running job. It returns ErrNotFound when the
+	// job is no longer running under that owner, as its lease expired.
+	Heartbeat(ctx context.Context, id, owner string, now time.Time) error
+	UpdateProgress(ctx context.Context, id string, progress, total int) error
+	// Finish stores the outcome of an attempt, status is one of the job states. It
+	// returns ErrNotFound when the job owner lost its lease in the meantime.
+	Finish(ctx context.Context, job *entity.Job) error
+	RequestCancel(ctx context.Context, id string) error
+	IsCancelRequested(ctx context.Context, id string) (bool, error)
+	// Retry makes a failed or cancelled job runnable again, on behalf of subject
+	Retry(ctx context.Context, id, subject string) error
+	// Requeue makes the running jobs whose lease was last renewed before expiredBefore
+	// runnable again, their owner is presumed dead
+	Requeue(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobRepositoryPostgres implements JobRepository on top of Postgres
+type JobRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewJobRepository creates a new Postgres backed job repository
+func NewJobRepository(db database.Database) JobRepository {
+	return &JobRepositoryPostgres{db: db}
+}
+
+// Create implements JobRepository
+func (r *JobRepositoryPostgres) Create(ctx context.Context, job *entity.Job) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(job).Error; err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// GetByID implements JobRepository
+func (r *JobRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Job, error) {
+	var job entity.Job
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return &job, nil
+}
+
+// List implements JobRepository
+func (r *JobRepositoryPostgres) List(ctx context.Context, filter JobFilter) ([]*entity.Job, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Job{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	var jobs []*entity.Job
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&jobs).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// Claim implements JobRepository
+func (r *JobRepositoryPostgres) Claim(ctx context.Context, types []string, owner string, now time.Time) (*entity.Job, error) {
+	var job entity.Job
+	err := r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND type IN ?", enum.JobPending, types).
+			Where("run_after IS NULL OR run_after <= ?", now).
+			Order("created_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = enum.JobRunning
+		job.Attempts++
+		job.StartedAt = &now
+		job.Owner = owner
+		job.HeartbeatAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"started_at":   job.StartedAt,
+			"owner":        job.Owner,
+			"heartbeat_at": job.HeartbeatAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return &job, nil
+}
+
+// Heartbeat implements JobRepository
+func (r *JobRepositoryPostgres) Heartbeat(ctx context.Context, id, owner string, now time.Time) error {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Job{}).
+		Where("id = ? AND owner = ? AND status = ?", id, owner, enum.JobRunning).
+		Update("heartbeat_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to renew job lease: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateProgress implements JobRepository
+func (r *JobRepositoryPostgres) UpdateProgress(ctx context.Context, id string, progress, total int) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"progress": progress,
+		"total":    total,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %w", err)
+	}
+	return nil
+}
+
+// Finish implements JobRepository
+func (r *JobRepositoryPostgres) Finish(ctx context.Context, job *entity.Job) error {
+	// Only the owner of the lease may store an outcome, a requeued job belongs to its next owner
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Job{}).
+		Where("id = ? AND owner = ? AND status = ?", job.ID, job.Owner, enum.JobRunning).
+		Updates(map[string]interface{}{
+			"status":       job.Status,
+			"result":       job.Result,
+			"error":        job.Error,
+			"progress":     job.Progress,
+			"total":        job.Total,
+			"run_after":    job.RunAfter,
+			"finished_at":  job.FinishedAt,
+			"owner":        "",
+			"heartbeat_at": nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to finish job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RequestCancel implements JobRepository
+func (r *JobRepositoryPostgres) RequestCancel(ctx context.Context, id string) error {
+	now := time.Now()
+	db := r.db.GetDB().WithContext(ctx)
+
+	// Pending jobs are cancelled right away, running jobs are flagged for their worker
+	err := db.Model(&entity.Job{}).Where("id = ? AND status = ?", id, enum.JobPending).Updates(map[string]interface{}{
+		"status":           enum.JobCancelled,
+		"cancel_requested": true,
+		"finished_at":      now,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	err = db.Model(&entity.Job{}).Where("id = ? AND status = ?", id, enum.JobRunning).
+		Update("cancel_requested", true).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel job: %w", err)
+	}
+	return nil
+}
+
+// Retry implements JobRepository
+func (r *JobRepositoryPostgres) Retry(ctx context.Context, id, subject string) error {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Job{}).
+		Where("id = ? AND status IN ?", id, []string{enum.JobFailed, enum.JobCancelled}).
+		Updates(map[string]interface{}{
+			"status":           enum.JobPending,
+			"subject":          subject,
+			"attempts":         0,
+			"error":            "",
+			"cancel_requested": false,
+			"run_after":        nil,
+			"finished_at":      nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to retry job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Requeue implements JobRepository
+func (r *JobRepositoryPostgres) Requeue(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	db := r.db.GetDB().WithContext(ctx)
+	expired := func() *gorm.DB {
+		return db.Model(&entity.Job{}).
+			Where("status = ?", enum.JobRunning).
+			Where("heartbeat_at IS NULL OR heartbeat_at < ?", expiredBefore)
+	}
+
+	// Jobs whose cancellation was requested are not resumed
+	err := expired().Where("cancel_requested = ?", true).
+		Updates(map[string]interface{}{
+			"status":       enum.JobCancelled,
+			"finished_at":  time.Now(),
+			"owner":        "",
+			"heartbeat_at": nil,
+		}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel interrupted jobs: %w", err)
+	}
+
+	result := expired().Updates(map[string]interface{}{
+		"status":       enum.JobPending,
+		"owner":        "",
+		"heartbeat_at": nil,
+	})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to requeue jobs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// IsCancelRequested implements JobRepository
+func (r *JobRepositoryPostgres) IsCancelRequested(ctx context.Context, id string) (bool, error) {
+	var job entity.Job
+	err := r.db.GetDB().WithContext(ctx).Select("cancel_requested").Where("id = ?", id).First(&job).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check job cancellation: %w", err)
+	}
+	return job.CancelRequested, nil
+}
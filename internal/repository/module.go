@@ -1,5 +1,41 @@
 package repository
 
-import "go.uber.org/fx"
+import (
+	"ditto/internal/model/entity"
+	"ditto/pkg/database"
 
-var Module = fx.Options()
+	"go.uber.org/fx"
+)
+
+// migrate creates or updates the tables of all persisted entities
+func migrate(db database.Database) error {
+	return db.GetDB().AutoMigrate(
+		&entity.Job{},
+		&entity.Command{},
+		&entity.Schedule{},
+		&entity.ScheduleExecution{},
+		&entity.Drift{},
+		&entity.Connectivity{},
+		&entity.Rule{},
+		&entity.Alert{},
+		&entity.Webhook{},
+		&entity.WebhookDelivery{},
+		&entity.User{},
+		&entity.RefreshToken{},
+	)
+}
+
+var Module = fx.Options(
+	fx.Provide(
+		NewJobRepository,
+		NewCommandRepository,
+		NewScheduleRepository,
+		NewDriftRepository,
+		NewConnectivityRepository,
+		NewRuleRepository,
+		NewAlertRepository,
+		NewWebhookRepository,
+		NewUserRepository,
+	),
+	fx.Invoke(migrate),
+)
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"ditto/internal/model/entity"
+)
+
+// RuleFilter narrows down a rule listing
+type RuleFilter struct {
+	ThingID string
+	Feature string
+	Enabled *bool
+	Limit   int
+	Offset  int
+}
+
+// RuleRepository defines the interface for alert rule persistence
+type RuleRepository interface {
+	Create(ctx context.Context, rule *entity.Rule) error
+	Update(ctx context.Context, rule *entity.Rule) error
+	Delete(ctx context.Context, id, user string) error
+	GetByID(ctx context.Context, id string) (*entity.Rule, error)
+	List(ctx context.Context, filter RuleFilter) ([]*entity.Rule, int64, error)
+	// ListEnabled returns every enabled rule, used to load the rules engine
+	ListEnabled(ctx context.Context) ([]*entity.Rule, error)
+}
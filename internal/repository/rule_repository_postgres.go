@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ditto/internal/model/entity"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// RuleRepositoryPostgres implements RuleRepository on top of Postgres
+type RuleRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewRuleRepository creates a new Postgres backed rule repository
+func NewRuleRepository(db database.Database) RuleRepository {
+	return &RuleRepositoryPostgres{db: db}
+}
+
+// Create implements RuleRepository
+func (r *RuleRepositoryPostgres) Create(ctx context.Context, rule *entity.Rule) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+	return nil
+}
+
+// Update implements RuleRepository
+func (r *RuleRepositoryPostgres) Update(ctx context.Context, rule *entity.Rule) error {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Rule{}).Where("id = ?", rule.ID).
+		Select("*").Omit("id", "created_at", "created_by", "deleted_at", "deleted_by").
+		Updates(rule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete implements RuleRepository
+func (r *RuleRepositoryPostgres) Delete(ctx context.Context, id, user string) error {
+	return r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Rule{}).Where("id = ?", id).Update("deleted_by", user)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete rule: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("id = ?", id).Delete(&entity.Rule{}).Error; err != nil {
+			return fmt.Errorf("failed to delete rule: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByID implements RuleRepository
+func (r *RuleRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Rule, error) {
+	var rule entity.Rule
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// List implements RuleRepository
+func (r *RuleRepositoryPostgres) List(ctx context.Context, filter RuleFilter) ([]*entity.Rule, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Rule{})
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Feature != "" {
+		query = query.Where("feature = ?", filter.Feature)
+	}
+	if filter.Enabled != nil {
+		query = query.Where("enabled = ?", *filter.Enabled)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count rules: %w", err)
+	}
+
+	var rules []*entity.Rule
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&rules).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list rules: %w", err)
+	}
+	return rules, total, nil
+}
+
+// ListEnabled implements RuleRepository
+func (r *RuleRepositoryPostgres) ListEnabled(ctx context.Context) ([]*entity.Rule, error) {
+	var rules []*entity.Rule
+	if err := r.db.GetDB().WithContext(ctx).Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list enabled rules: %w", err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// ScheduleFilter narrows down a schedule listing
+type ScheduleFilter struct {
+	ThingID string
+	Enabled *bool
+	Limit   int
+	Offset  int
+}
+
+// NextRunFunc computes the run following the given time, nil ends the schedule
+type NextRunFunc func(schedule *entity.Schedule, after time.Time) *time.Time
+
+// ScheduleRepository defines the interface for schedule persistence
+type ScheduleRepository interface {
+	Create(ctx context.Context, schedule *entity.Schedule) error
+	Update(ctx context.Context, schedule *entity.Schedule) error
+	Delete(ctx context.Context, id, user string) error
+	GetByID(ctx context.Context, id string) (*entity.Schedule, error)
+	List(ctx context.Context, filter ScheduleFilter) ([]*entity.Schedule, int64, error)
+	// ClaimDue locks enabled schedules due at now, advances them with next and returns
+	// them as they were before advancing. Schedules without a next run are disabled.
+	ClaimDue(ctx context.Context, now time.Time, limit int, next NextRunFunc) ([]*entity.Schedule, error)
+
+	CreateExecution(ctx context.Context, execution *entity.ScheduleExecution) error
+	FinishExecution(ctx context.Context, execution *entity.ScheduleExecution) error
+	ListExecutions(ctx context.Context, scheduleID string, limit, offset int) ([]*entity.ScheduleExecution, int64, error)
+}
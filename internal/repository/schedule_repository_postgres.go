@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScheduleRepositoryPostgres implements ScheduleRepository on top of Postgres
+type ScheduleRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewScheduleRepository creates a new Postgres backed schedule repository
+func NewScheduleRepository(db database.Database) ScheduleRepository {
+	return &ScheduleRepositoryPostgres{db: db}
+}
+
+// Create implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) Create(ctx context.Context, schedule *entity.Schedule) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(schedule).Error; err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+// Update implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) Update(ctx context.Context, schedule *entity.Schedule) error {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Schedule{}).Where("id = ?", schedule.ID).
+		Select("*").Omit("id", "created_at", "created_by", "deleted_at", "deleted_by", "last_run_at").
+		Updates(schedule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update schedule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) Delete(ctx context.Context, id, user string) error {
+	return r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Schedule{}).Where("id = ?", id).Update("deleted_by", user)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete schedule: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("id = ?", id).Delete(&entity.Schedule{}).Error; err != nil {
+			return fmt.Errorf("failed to delete schedule: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByID implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Schedule, error) {
+	var schedule entity.Schedule
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return &schedule, nil
+}
+
+// List implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) List(ctx context.Context, filter ScheduleFilter) ([]*entity.Schedule, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Schedule{})
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Enabled != nil {
+		query = query.Where("enabled = ?", *filter.Enabled)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count schedules: %w", err)
+	}
+
+	var schedules []*entity.Schedule
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&schedules).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return schedules, total, nil
+}
+
+// ClaimDue implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) ClaimDue(ctx context.Context, now time.Time, limit int, next NextRunFunc) ([]*entity.Schedule, error) {
+	var due []*entity.Schedule
+	err := r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled = ? AND next_run_at <= ?", true, now).
+			Order("next_run_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		for _, schedule := range due {
+			nextRunAt := next(schedule, now)
+			err := tx.Model(&entity.Schedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+				"next_run_at": nextRunAt,
+				"last_run_at": now,
+				"enabled":     nextRunAt != nil,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due schedules: %w", err)
+	}
+	return due, nil
+}
+
+// CreateExecution implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) CreateExecution(ctx context.Context, execution *entity.ScheduleExecution) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(execution).Error; err != nil {
+		return fmt.Errorf("failed to create schedule execution: %w", err)
+	}
+	return nil
+}
+
+// FinishExecution implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) FinishExecution(ctx context.Context, execution *entity.ScheduleExecution) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.ScheduleExecution{}).Where("id = ?", execution.ID).Updates(map[string]interface{}{
+		"job_id":      execution.JobID,
+		"status":      execution.Status,
+		"matched":     execution.Matched,
+		"succeeded":   execution.Succeeded,
+		"failed":      execution.Failed,
+		"results":     execution.Results,
+		"error":       execution.Error,
+		"finished_at": execution.FinishedAt,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to finish schedule execution: %w", err)
+	}
+	return nil
+}
+
+// ListExecutions implements ScheduleRepository
+func (r *ScheduleRepositoryPostgres) ListExecutions(ctx context.Context, scheduleID string, limit, offset int) ([]*entity.ScheduleExecution, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.ScheduleExecution{}).Where("schedule_id = ?", scheduleID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count schedule executions: %w", err)
+	}
+
+	var executions []*entity.ScheduleExecution
+	err := query.Order("started_at DESC").Limit(limit).Offset(offset).Find(&executions).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list schedule executions: %w", err)
+	}
+	return executions, total, nil
+}
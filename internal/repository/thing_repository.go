@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 
+	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
 	"ditto/internal/model"
 )
 
@@ -12,5 +14,6 @@ type ThingRepository interface {
 	GetByID(ctx context.Context, id string) (*model.Thing, error)
 	Update(ctx context.Context, id string, thing *model.ThingUpdate) error
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context, offset, limit int) ([]*model.Thing, error)
+	List(ctx context.Context, query ditto.SearchQuery) (*model.ThingList, error)
+	Count(ctx context.Context, filter rql.Expr, namespaces []string) (int, error)
 }
@@ -4,10 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"strings"
 
 	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
 	"ditto/internal/model"
 )
 
@@ -52,7 +51,7 @@ func (r *ThingRepositoryDitto) Create(ctx context.Context, thing *model.Thing) e
 	}
 
 	// Create thing in Ditto
-	err = r.dittoService.CreateThing(thing.ID, thingJSON)
+	err = r.dittoService.CreateThing(ctx, thing.ID, thingJSON)
 	if err != nil {
 		return fmt.Errorf("failed to create thing in Ditto: %w", err)
 	}
@@ -63,7 +62,7 @@ func (r *ThingRepositoryDitto) Create(ctx context.Context, thing *model.Thing) e
 // GetByID implements ThingRepository
 func (r *ThingRepositoryDitto) GetByID(ctx context.Context, id string) (*model.Thing, error) {
 	// Get thing from Ditto
-	dittoThingJSON, err := r.dittoService.GetThing(id)
+	dittoThingJSON, err := r.dittoService.GetThing(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get thing from Ditto: %w", err)
 	}
@@ -144,7 +143,7 @@ func (r *ThingRepositoryDitto) Update(ctx context.Context, id string, thing *mod
 	}
 
 	// Update thing in Ditto
-	err = r.dittoService.UpdateThing(id, updateJSON)
+	err = r.dittoService.UpdateThing(ctx, id, updateJSON)
 	if err != nil {
 		return fmt.Errorf("failed to update thing in Ditto: %w", err)
 	}
@@ -155,7 +154,7 @@ func (r *ThingRepositoryDitto) Update(ctx context.Context, id string, thing *mod
 // Delete implements ThingRepository
 func (r *ThingRepositoryDitto) Delete(ctx context.Context, id string) error {
 	// Delete thing from Ditto
-	err := r.dittoService.DeleteThing(id)
+	err := r.dittoService.DeleteThing(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete thing from Ditto: %w", err)
 	}
@@ -164,98 +163,35 @@ func (r *ThingRepositoryDitto) Delete(ctx context.Context, id string) error {
 }
 
 // List implements ThingRepository
-func (r *ThingRepositoryDitto) List(ctx context.Context, offset, limit int) ([]*model.Thing, error) {
-	// Create search query
-	query := map[string]interface{}{
-		"filter": "exists(thingId)",
-		"options": map[string]interface{}{
-			"size":   limit,
-			"offset": offset,
-		},
-	}
-
-	// Marshal query to JSON
-	queryJSON, err := json.Marshal(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal search query: %w", err)
-	}
-
-	// Create HTTP request
-	url := "http://localhost:8080/api/2/search/things"
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(queryJSON)))
+func (r *ThingRepositoryDitto) List(ctx context.Context, query ditto.SearchQuery) (*model.ThingList, error) {
+	// Search things in Ditto
+	result, err := r.client.Search(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Add headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Basic ZGl0dG86ZGl0dG8=") // base64 encoded "ditto:ditto"
-
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search request failed with status: %d", resp.StatusCode)
-	}
-
-	// Parse response
-	var result struct {
-		Items []map[string]interface{} `json:"items"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to search things in Ditto: %w", err)
 	}
 
 	// Convert to model.Thing slice
 	things := make([]*model.Thing, 0, len(result.Items))
 	for _, item := range result.Items {
-		thing := &model.Thing{
-			ID: item["thingId"].(string),
+		var thing model.Thing
+		if err := json.Unmarshal(item, &thing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal thing: %w", err)
 		}
+		things = append(things, &thing)
+	}
 
-		if policyID, ok := item["policyId"].(string); ok {
-			thing.PolicyID = policyID
-		}
-		if definition, ok := item["definition"].(string); ok {
-			thing.Definition = definition
-		}
-		if attrs, ok := item["attributes"].(map[string]interface{}); ok {
-			thing.Attributes = attrs
-		}
-		if features, ok := item["features"].(map[string]interface{}); ok {
-			thing.Features = make(map[string]model.Feature)
-			for name, feature := range features {
-				if featureMap, ok := feature.(map[string]interface{}); ok {
-					f := model.Feature{}
-
-					if def, ok := featureMap["definition"].([]interface{}); ok {
-						f.Definition = make([]string, len(def))
-						for i, d := range def {
-							f.Definition[i] = d.(string)
-						}
-					}
-
-					if props, ok := featureMap["properties"].(map[string]interface{}); ok {
-						f.Properties = props
-					}
-
-					if desiredProps, ok := featureMap["desiredProperties"].(map[string]interface{}); ok {
-						f.DesiredProperties = desiredProps
-					}
-
-					thing.Features[name] = f
-				}
-			}
-		}
+	return &model.ThingList{
+		Items:  things,
+		Cursor: result.Cursor,
+	}, nil
+}
 
-		things = append(things, thing)
+// Count implements ThingRepository
+func (r *ThingRepositoryDitto) Count(ctx context.Context, filter rql.Expr, namespaces []string) (int, error) {
+	count, err := r.client.Count(ctx, filter, namespaces)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count things in Ditto: %w", err)
 	}
 
-	return things, nil
+	return count, nil
 }
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// UserRepository defines the interface for user and refresh token persistence
+type UserRepository interface {
+	Create(ctx context.Context, user *entity.User) error
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	GetByUsername(ctx context.Context, username string) (*entity.User, error)
+	List(ctx context.Context, limit, offset int) ([]*entity.User, int64, error)
+	UpdateRoles(ctx context.Context, id string, roles entity.JSON, user string) error
+	UpdateLastLogin(ctx context.Context, id string, at time.Time) error
+
+	CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error
+	GetRefreshToken(ctx context.Context, id string) (*entity.RefreshToken, error)
+	// RotateRefreshToken revokes a token in favour of next. It returns ErrNotFound
+	// when the token is already revoked, so a token is only ever rotated once.
+	RotateRefreshToken(ctx context.Context, id string, next *entity.RefreshToken, now time.Time) error
+	// RevokeRefreshFamily revokes every token issued from the same login
+	RevokeRefreshFamily(ctx context.Context, familyID string, now time.Time) error
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// UserRepositoryPostgres implements UserRepository on top of Postgres
+type UserRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewUserRepository creates a new Postgres backed user repository
+func NewUserRepository(db database.Database) UserRepository {
+	return &UserRepositoryPostgres{db: db}
+}
+
+// Create implements UserRepository
+func (r *UserRepositoryPostgres) Create(ctx context.Context, user *entity.User) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetByID implements UserRepository
+func (r *UserRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	var user entity.User
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetByUsername implements UserRepository
+func (r *UserRepositoryPostgres) GetByUsername(ctx context.Context, username string) (*entity.User, error) {
+	var user entity.User
+	err := r.db.GetDB().WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// List implements UserRepository
+func (r *UserRepositoryPostgres) List(ctx context.Context, limit, offset int) ([]*entity.User, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.User{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []*entity.User
+	err := query.Order("username").Limit(limit).Offset(offset).Find(&users).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
+// UpdateRoles implements UserRepository
+func (r *UserRepositoryPostgres) UpdateRoles(ctx context.Context, id string, roles entity.JSON, user string) error {
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"roles":      roles,
+			"updated_by": user,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user roles: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateLastLogin implements UserRepository
+func (r *UserRepositoryPostgres) UpdateLastLogin(ctx context.Context, id string, at time.Time) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).
+		UpdateColumn("last_login_at", at).Error
+	if err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+	return nil
+}
+
+// CreateRefreshToken implements UserRepository
+func (r *UserRepositoryPostgres) CreateRefreshToken(ctx context.Context, token *entity.RefreshToken) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshToken implements UserRepository
+func (r *UserRepositoryPostgres) GetRefreshToken(ctx context.Context, id string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken implements UserRepository
+func (r *UserRepositoryPostgres) RotateRefreshToken(ctx context.Context, id string, next *entity.RefreshToken, now time.Time) error {
+	return r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Only the request revoking the token may issue its successor
+		result := tx.Model(&entity.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", id).
+			Updates(map[string]interface{}{
+				"revoked_at":  now,
+				"replaced_by": next.ID,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke refresh token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+		return nil
+	})
+}
+
+// RevokeRefreshFamily implements UserRepository
+func (r *UserRepositoryPostgres) RevokeRefreshFamily(ctx context.Context, familyID string, now time.Time) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
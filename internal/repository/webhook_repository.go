@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ditto/internal/model/entity"
+)
+
+// DeliveryFilter narrows down a webhook delivery listing
+type DeliveryFilter struct {
+	WebhookID string
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+// WebhookRepository defines the interface for webhook and delivery persistence
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *entity.Webhook) error
+	Update(ctx context.Context, webhook *entity.Webhook) error
+	Delete(ctx context.Context, id, user string) error
+	GetByID(ctx context.Context, id string) (*entity.Webhook, error)
+	List(ctx context.Context, limit, offset int) ([]*entity.Webhook, int64, error)
+	// ListEnabled returns every enabled webhook, used to route events
+	ListEnabled(ctx context.Context) ([]*entity.Webhook, error)
+
+	CreateDeliveries(ctx context.Context, deliveries []*entity.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id string) (*entity.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*entity.WebhookDelivery, int64, error)
+	// ClaimDeliveries locks deliveries due at now, pushes their next attempt back by
+	// lease so no other poller picks them up while they are sent, and returns them
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*entity.WebhookDelivery, error)
+	// SaveAttempt stores the outcome of a delivery attempt
+	SaveAttempt(ctx context.Context, delivery *entity.WebhookDelivery) error
+	// Redeliver queues a delivery again with a fresh set of attempts
+	Redeliver(ctx context.Context, id string, now time.Time) (*entity.WebhookDelivery, error)
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepositoryPostgres implements WebhookRepository on top of Postgres
+type WebhookRepositoryPostgres struct {
+	db database.Database
+}
+
+// NewWebhookRepository creates a new Postgres backed webhook repository
+func NewWebhookRepository(db database.Database) WebhookRepository {
+	return &WebhookRepositoryPostgres{db: db}
+}
+
+// Create implements WebhookRepository
+func (r *WebhookRepositoryPostgres) Create(ctx context.Context, webhook *entity.Webhook) error {
+	if err := r.db.GetDB().WithContext(ctx).Create(webhook).Error; err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// Update implements WebhookRepository
+func (r *WebhookRepositoryPostgres) Update(ctx context.Context, webhook *entity.Webhook) error {
+	omit := []string{"id", "created_at", "created_by", "deleted_at", "deleted_by"}
+	if webhook.Secret == "" {
+		// An update without a secret keeps the current one
+		omit = append(omit, "secret")
+	}
+	result := r.db.GetDB().WithContext(ctx).Model(&entity.Webhook{}).Where("id = ?", webhook.ID).
+		Select("*").Omit(omit...).
+		Updates(webhook)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete implements WebhookRepository
+func (r *WebhookRepositoryPostgres) Delete(ctx context.Context, id, user string) error {
+	return r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Webhook{}).Where("id = ?", id).Update("deleted_by", user)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete webhook: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("id = ?", id).Delete(&entity.Webhook{}).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		// Pending deliveries of a deleted webhook are dropped, the log is kept
+		err := tx.Model(&entity.WebhookDelivery{}).
+			Where("webhook_id = ? AND status IN ?", id, []string{enum.DeliveryPending, enum.DeliveryRetrying}).
+			Updates(map[string]interface{}{
+				"status":          enum.DeliveryDead,
+				"next_attempt_at": nil,
+				"error":           "webhook deleted",
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel webhook deliveries: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByID implements WebhookRepository
+func (r *WebhookRepositoryPostgres) GetByID(ctx context.Context, id string) (*entity.Webhook, error) {
+	var webhook entity.Webhook
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&webhook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// List implements WebhookRepository
+func (r *WebhookRepositoryPostgres) List(ctx context.Context, limit, offset int) ([]*entity.Webhook, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.Webhook{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhooks: %w", err)
+	}
+
+	var webhooks []*entity.Webhook
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&webhooks).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return webhooks, total, nil
+}
+
+// ListEnabled implements WebhookRepository
+func (r *WebhookRepositoryPostgres) ListEnabled(ctx context.Context) ([]*entity.Webhook, error) {
+	var webhooks []*entity.Webhook
+	if err := r.db.GetDB().WithContext(ctx).Where("enabled = ?", true).Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list enabled webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// CreateDeliveries implements WebhookRepository
+func (r *WebhookRepositoryPostgres) CreateDeliveries(ctx context.Context, deliveries []*entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := r.db.GetDB().WithContext(ctx).Create(deliveries).Error; err != nil {
+		return fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// GetDelivery implements WebhookRepository
+func (r *WebhookRepositoryPostgres) GetDelivery(ctx context.Context, id string) (*entity.WebhookDelivery, error) {
+	var delivery entity.WebhookDelivery
+	err := r.db.GetDB().WithContext(ctx).Where("id = ?", id).First(&delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries implements WebhookRepository
+func (r *WebhookRepositoryPostgres) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*entity.WebhookDelivery, int64, error) {
+	query := r.db.GetDB().WithContext(ctx).Model(&entity.WebhookDelivery{})
+	if filter.WebhookID != "" {
+		query = query.Where("webhook_id = ?", filter.WebhookID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	var deliveries []*entity.WebhookDelivery
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// ClaimDeliveries implements WebhookRepository
+func (r *WebhookRepositoryPostgres) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*entity.WebhookDelivery, error) {
+	var due []*entity.WebhookDelivery
+	err := r.db.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []string{enum.DeliveryPending, enum.DeliveryRetrying}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(due))
+		for i, delivery := range due {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&entity.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return due, nil
+}
+
+// SaveAttempt implements WebhookRepository
+func (r *WebhookRepositoryPostgres) SaveAttempt(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	err := r.db.GetDB().WithContext(ctx).Model(&entity.WebhookDelivery{}).Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"last_attempt_at": delivery.LastAttemptAt,
+			"response_status": delivery.ResponseStatus,
+			"error":           delivery.Error,
+			"delivered_at":    delivery.DeliveredAt,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Redeliver implements WebhookRepository
+func (r *WebhookRepositoryPostgres) Redeliver(ctx context.Context, id string, now time.Time) (*entity.WebhookDelivery, error) {
+	var deliveries []*entity.WebhookDelivery
+	result := r.db.GetDB().WithContext(ctx).Model(&deliveries).Clauses(clause.Returning{}).
+		Where("id = ? AND status = ?", id, enum.DeliveryDead).
+		Updates(map[string]interface{}{
+			"status":          enum.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"error":           "",
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+	return deliveries[0], nil
+}
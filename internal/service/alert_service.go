@@ -0,0 +1,509 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// alertKey identifies the evaluation state of a rule for a thing
+type alertKey struct {
+	ruleID  string
+	thingID string
+}
+
+// alertState is what the engine remembers of a rule for a thing between measurements
+type alertState struct {
+	// pendingSince is when the condition started to hold, zero while it does not
+	pendingSince time.Time
+	value        float64
+	// prevValue and prevAt are the last measurement, used for the rate of change
+	prevValue float64
+	prevAt    time.Time
+	hasPrev   bool
+	// lastSeen is when the feature last reported a value, used for absence
+	lastSeen time.Time
+	alert    *entity.Alert
+}
+
+// AlertService is the rules engine. It evaluates the measurements decoded from
+// twin events against the enabled rules and keeps the resulting alerts.
+type AlertService struct {
+	ruleRepo  repository.RuleRepository
+	alertRepo repository.AlertRepository
+	bus       *eventbus.Bus
+	logger    logger.Logger
+	config    config.RulesConfig
+
+	measurements chan ditto.Measurement
+	deleted      chan string
+	reload       chan struct{}
+
+	// rules and states are only used by the engine goroutine
+	rules  map[string]*entity.Rule
+	states map[alertKey]*alertState
+	// now is the clock of the engine, time.Now unless replaced by tests
+	now func() time.Time
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewAlertService creates the rules engine fed by the measurements of the Ditto service
+func NewAlertService(
+	lc fx.Lifecycle,
+	ruleRepo repository.RuleRepository,
+	alertRepo repository.AlertRepository,
+	bus *eventbus.Bus,
+	logger logger.Logger,
+	cfg *config.Config,
+) *AlertService {
+	s := &AlertService{
+		ruleRepo:  ruleRepo,
+		alertRepo: alertRepo,
+		bus:       bus,
+		logger:    logger,
+		config:    cfg.Rules,
+		deleted:   make(chan string, 100),
+		reload:    make(chan struct{}, 1),
+		rules:     make(map[string]*entity.Rule),
+		states:    make(map[alertKey]*alertState),
+		now:       time.Now,
+	}
+	if s.config.QueueSize <= 0 {
+		s.config.QueueSize = 10000
+	}
+	if s.config.ReloadInterval <= 0 {
+		s.config.ReloadInterval = 30 * time.Second
+	}
+	if s.config.CheckInterval <= 0 {
+		s.config.CheckInterval = 10 * time.Second
+	}
+	s.measurements = make(chan ditto.Measurement, s.config.QueueSize)
+	if !s.config.Enabled {
+		return s
+	}
+
+	eventbus.Subscribe(bus, ditto.MeasurementTopic, "rules", s.handleMeasurement)
+	eventbus.Subscribe(bus, ditto.EventTopic, "rules", s.handleEvent)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(1)
+			go s.run(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s
+}
+
+// Reload asks the engine to reload the rules after they changed
+func (s *AlertService) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns an alert
+func (s *AlertService) Get(ctx context.Context, id string) (*entity.Alert, error) {
+	return s.alertRepo.GetByID(ctx, id)
+}
+
+// List returns a page of alerts and the total number of matching alerts
+func (s *AlertService) List(ctx context.Context, filter repository.AlertFilter) ([]*entity.Alert, int64, error) {
+	return s.alertRepo.List(ctx, filter)
+}
+
+// Acknowledge marks a firing alert as acknowledged. It keeps being tracked until its condition clears.
+func (s *AlertService) Acknowledge(ctx context.Context, id, user string) (*entity.Alert, error) {
+	alert, err := s.alertRepo.Acknowledge(ctx, id, user, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.ruleRepo.GetByID(ctx, alert.RuleID)
+	if err == nil {
+		s.notify(alert, rule)
+	}
+	return alert, nil
+}
+
+// handleMeasurement queues a measurement without blocking the listener
+func (s *AlertService) handleMeasurement(m ditto.Measurement) {
+	select {
+	case s.measurements <- m:
+	default:
+		s.logger.Warn("Rules engine queue is full, dropping measurement",
+			zap.String("thingId", m.ThingID), zap.String("feature", m.Feature))
+	}
+}
+
+// handleEvent forgets the state of deleted things
+func (s *AlertService) handleEvent(event ditto.Event) {
+	if event.IsTwinEvent() && event.Action() == ditto.ActionDeleted && (event.Path == "" || event.Path == "/") {
+		select {
+		case s.deleted <- event.ThingID():
+		default:
+		}
+	}
+}
+
+// run evaluates measurements, checks absences and reloads rules until ctx is cancelled
+func (s *AlertService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	if err := s.restore(ctx); err != nil && ctx.Err() == nil {
+		s.logger.Error("Failed to load alert rules", zap.Error(err))
+	}
+
+	check := time.NewTicker(s.config.CheckInterval)
+	defer check.Stop()
+	reload := time.NewTicker(s.config.ReloadInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-s.measurements:
+			s.evaluate(ctx, m)
+		case thingID := <-s.deleted:
+			s.forget(ctx, thingID)
+		case <-check.C:
+			s.check(ctx, s.now())
+		case <-s.reload:
+			s.reloadRules(ctx)
+		case <-reload.C:
+			s.reloadRules(ctx)
+		}
+	}
+}
+
+// restore loads the rules and picks up the alerts still active from a previous run
+func (s *AlertService) restore(ctx context.Context) error {
+	if err := s.loadRules(ctx); err != nil {
+		return err
+	}
+
+	alerts, err := s.alertRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, alert := range alerts {
+		if _, ok := s.rules[alert.RuleID]; !ok {
+			// The rule was deleted or disabled while the engine was down
+			if err := s.alertRepo.Resolve(ctx, alert.ID, nil, now); err != nil {
+				return err
+			}
+			continue
+		}
+		state := s.state(alert.RuleID, alert.ThingID, now)
+		state.alert = alert
+	}
+	return nil
+}
+
+// reloadRules refreshes the rules, resolving the alerts of rules that changed or went away
+func (s *AlertService) reloadRules(ctx context.Context) {
+	previous := s.rules
+	if err := s.loadRules(ctx); err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to reload alert rules", zap.Error(err))
+		}
+		return
+	}
+
+	now := s.now()
+	for key, state := range s.states {
+		rule, ok := s.rules[key.ruleID]
+		if ok && !ruleChanged(previous[key.ruleID], rule) {
+			continue
+		}
+		if state.alert != nil {
+			s.resolve(ctx, previous[key.ruleID], state, nil, now)
+		}
+		delete(s.states, key)
+	}
+	s.trackAbsence(now)
+}
+
+// loadRules replaces the enabled rules
+func (s *AlertService) loadRules(ctx context.Context) error {
+	rules, err := s.ruleRepo.ListEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	s.rules = make(map[string]*entity.Rule, len(rules))
+	for _, rule := range rules {
+		s.rules[rule.ID] = rule
+	}
+	s.trackAbsence(s.now())
+	return nil
+}
+
+// trackAbsence starts the absence clock of rules bound to a single thing, so
+// a thing that never reports after startup is detected too
+func (s *AlertService) trackAbsence(now time.Time) {
+	for _, rule := range s.rules {
+		if rule.Kind == enum.RuleAbsence && rule.ThingID != "" {
+			s.state(rule.ID, rule.ThingID, now)
+		}
+	}
+}
+
+// state returns the state of a rule for a thing, creating it if needed
+func (s *AlertService) state(ruleID, thingID string, now time.Time) *alertState {
+	key := alertKey{ruleID: ruleID, thingID: thingID}
+	state, ok := s.states[key]
+	if !ok {
+		state = &alertState{lastSeen: now}
+		s.states[key] = state
+	}
+	return state
+}
+
+// evaluate applies a measurement to every rule on its feature
+func (s *AlertService) evaluate(ctx context.Context, m ditto.Measurement) {
+	now := s.now()
+	for _, rule := range s.rules {
+		if !ruleApplies(rule, m.ThingID, m.Feature) {
+			continue
+		}
+		state := s.state(rule.ID, m.ThingID, now)
+		state.lastSeen = now
+
+		if rule.Kind == enum.RuleAbsence {
+			state.pendingSince = time.Time{}
+			if state.alert != nil {
+				s.resolve(ctx, rule, state, &m.Value, now)
+			}
+			continue
+		}
+
+		breached, cleared, known := condition(rule, state, m)
+		state.value = m.Value
+		if !known {
+			continue
+		}
+
+		if state.alert != nil {
+			if cleared {
+				s.resolve(ctx, rule, state, &m.Value, now)
+			}
+			continue
+		}
+		if !breached {
+			state.pendingSince = time.Time{}
+			continue
+		}
+		if state.pendingSince.IsZero() {
+			state.pendingSince = now
+		}
+		if now.Sub(state.pendingSince) >= time.Duration(rule.DebounceSeconds)*time.Second {
+			s.fire(ctx, rule, m.ThingID, state, &m.Value, describeBreach(rule, m.Value), now)
+		}
+	}
+}
+
+// check fires debounced conditions that kept holding and detects absent features
+func (s *AlertService) check(ctx context.Context, now time.Time) {
+	for key, state := range s.states {
+		rule, ok := s.rules[key.ruleID]
+		if !ok || state.alert != nil {
+			continue
+		}
+		debounce := time.Duration(rule.DebounceSeconds) * time.Second
+
+		if rule.Kind == enum.RuleAbsence {
+			absence := time.Duration(rule.AbsenceSeconds) * time.Second
+			if now.Sub(state.lastSeen) >= absence+debounce {
+				message := fmt.Sprintf("%s reported no value for %s", rule.Feature, now.Sub(state.lastSeen).Round(time.Second))
+				s.fire(ctx, rule, key.thingID, state, nil, message, now)
+			}
+			continue
+		}
+
+		if !state.pendingSince.IsZero() && now.Sub(state.pendingSince) >= debounce {
+			value := state.value
+			s.fire(ctx, rule, key.thingID, state, &value, describeBreach(rule, value), now)
+		}
+	}
+}
+
+// forget drops the state of a deleted thing and resolves its alerts
+func (s *AlertService) forget(ctx context.Context, thingID string) {
+	now := s.now()
+	for key, state := range s.states {
+		if key.thingID != thingID {
+			continue
+		}
+		if state.alert != nil {
+			s.resolve(ctx, s.rules[key.ruleID], state, nil, now)
+		}
+		delete(s.states, key)
+	}
+}
+
+// fire stores a new alert for a thing
+func (s *AlertService) fire(ctx context.Context, rule *entity.Rule, thingID string, state *alertState, value *float64, message string, now time.Time) {
+	alert := &entity.Alert{
+		ID:       uuid.NewString(),
+		RuleID:   rule.ID,
+		ThingID:  thingID,
+		Feature:  rule.Feature,
+		Severity: rule.Severity,
+		Status:   enum.AlertFiring,
+		Value:    value,
+		Message:  message,
+		FiredAt:  now,
+	}
+	if err := s.alertRepo.Create(ctx, alert); err != nil {
+		s.logger.Error("Failed to store alert", zap.String("rule", rule.ID), zap.String("thingId", thingID), zap.Error(err))
+		return
+	}
+
+	state.alert = alert
+	state.pendingSince = time.Time{}
+	s.logger.Info(fmt.Sprintf("Alert %s fired for %s: %s", rule.Name, thingID, message))
+	s.notify(alert, rule)
+}
+
+// resolve closes the active alert of a state
+func (s *AlertService) resolve(ctx context.Context, rule *entity.Rule, state *alertState, value *float64, now time.Time) {
+	alert := state.alert
+	if err := s.alertRepo.Resolve(ctx, alert.ID, value, now); err != nil {
+		s.logger.Error("Failed to resolve alert", zap.String("alert", alert.ID), zap.Error(err))
+		return
+	}
+
+	alert.Status = enum.AlertResolved
+	alert.ResolvedAt = &now
+	if value != nil {
+		alert.Value = value
+	}
+	state.alert = nil
+	state.pendingSince = time.Time{}
+	if rule != nil {
+		s.logger.Info(fmt.Sprintf("Alert %s resolved for %s", rule.Name, alert.ThingID))
+		s.notify(alert, rule)
+	}
+}
+
+// notify publishes a copy of an alert and its rule
+func (s *AlertService) notify(alert *entity.Alert, rule *entity.Rule) {
+	eventbus.Publish(s.bus, AlertTopic, AlertEvent{Alert: *alert, Rule: *rule})
+}
+
+// ruleApplies tells whether a rule covers a feature of a thing
+func ruleApplies(rule *entity.Rule, thingID, feature string) bool {
+	if rule.Feature != feature {
+		return false
+	}
+	if rule.ThingID != "" {
+		return rule.ThingID == thingID
+	}
+	if rule.Namespace != "" {
+		return ditto.Namespace(thingID) == rule.Namespace
+	}
+	return true
+}
+
+// ruleChanged tells whether a rule was updated since it was loaded
+func ruleChanged(previous, current *entity.Rule) bool {
+	if previous == nil || previous.UpdatedAt == nil || current.UpdatedAt == nil {
+		return previous == nil
+	}
+	return !previous.UpdatedAt.Equal(*current.UpdatedAt)
+}
+
+// condition evaluates a measurement against a rule. breached tells whether the
+// condition holds, cleared whether the value recovered past the hysteresis
+// margin, and known is false when the rule cannot decide yet.
+func condition(rule *entity.Rule, state *alertState, m ditto.Measurement) (breached, cleared, known bool) {
+	h := rule.Hysteresis
+	v := m.Value
+
+	switch rule.Kind {
+	case enum.RuleThreshold:
+		breached = compare(v, rule.Operator, rule.Threshold)
+		switch rule.Operator {
+		case "gt", "ge":
+			cleared = !compare(v, rule.Operator, rule.Threshold-h)
+		default:
+			cleared = !compare(v, rule.Operator, rule.Threshold+h)
+		}
+		return breached, cleared, true
+
+	case enum.RuleRange:
+		below := rule.Min != nil && v < *rule.Min
+		above := rule.Max != nil && v > *rule.Max
+		cleared = (rule.Min == nil || v >= *rule.Min+h) && (rule.Max == nil || v <= *rule.Max-h)
+		return below || above, cleared, true
+
+	case enum.RuleRateOfChange:
+		prevValue, prevAt, hasPrev := state.prevValue, state.prevAt, state.hasPrev
+		state.prevValue, state.prevAt, state.hasPrev = v, m.Timestamp, true
+
+		minutes := m.Timestamp.Sub(prevAt).Minutes()
+		if !hasPrev || minutes <= 0 {
+			return false, false, false
+		}
+		rate := math.Abs(v-prevValue) / minutes
+		return rate > rule.RatePerMinute, rate <= rule.RatePerMinute-h, true
+	}
+	return false, false, false
+}
+
+// compare applies a threshold operator
+func compare(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case "gt":
+		return value > threshold
+	case "ge":
+		return value >= threshold
+	case "lt":
+		return value < threshold
+	case "le":
+		return value <= threshold
+	}
+	return false
+}
+
+// describeBreach renders the alert message of a breached condition
+func describeBreach(rule *entity.Rule, value float64) string {
+	switch rule.Kind {
+	case enum.RuleThreshold:
+		return fmt.Sprintf("%s value %g is %s %g", rule.Feature, value, rule.Operator, rule.Threshold)
+	case enum.RuleRange:
+		return fmt.Sprintf("%s value %g is out of range", rule.Feature, value)
+	case enum.RuleRateOfChange:
+		return fmt.Sprintf("%s value %g changes faster than %g per minute", rule.Feature, value, rule.RatePerMinute)
+	}
+	return fmt.Sprintf("%s value %g", rule.Feature, value)
+}
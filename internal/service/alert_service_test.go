@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// fakeRuleRepository serves the enabled rules of the engine.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeRuleRepository struct {
+	repository.RuleRepository
+
+	rules []*entity.Rule
+}
+
+func (r *fakeRuleRepository) ListEnabled(context.Context) ([]*entity.Rule, error) {
+	rules := make([]*entity.Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	return rules, nil
+}
+
+// fakeAlertRepository keeps the alerts stored by the engine.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeAlertRepository struct {
+	repository.AlertRepository
+
+	alerts map[string]*entity.Alert
+}
+
+func (r *fakeAlertRepository) Create(_ context.Context, alert *entity.Alert) error {
+	stored := *alert
+	r.alerts[alert.ID] = &stored
+	return nil
+}
+
+func (r *fakeAlertRepository) Resolve(_ context.Context, id string, value *float64, at time.Time) error {
+	alert, ok := r.alerts[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	alert.Status = enum.AlertResolved
+	alert.ResolvedAt = &at
+	if value != nil {
+		alert.Value = value
+	}
+	return nil
+}
+
+// active returns the firing alert of a rule, nil when there is none
+func (r *fakeAlertRepository) active(ruleID string) *entity.Alert {
+	for _, alert := range r.alerts {
+		if alert.RuleID == ruleID && alert.Status == enum.AlertFiring {
+			return alert
+		}
+	}
+	return nil
+}
+
+// testClock is the fake clock of the engine
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) advance(d time.Duration) time.Time {
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+func newTestAlertService(t *testing.T, rules ...*entity.Rule) (*AlertService, *fakeRuleRepository, *fakeAlertRepository, *testClock) {
+	t.Helper()
+	// Disabled, so no engine goroutine runs: the tests drive evaluate and check themselves
+	cfg := &config.Config{}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	ruleRepo := &fakeRuleRepository{rules: rules}
+	alertRepo := &fakeAlertRepository{alerts: make(map[string]*entity.Alert)}
+	s := NewAlertService(lc, ruleRepo, alertRepo, eventbus.NewBus(lc, log, cfg), log, cfg)
+
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s.now = func() time.Time { return clock.now }
+	s.reloadRules(context.Background())
+	return s, ruleRepo, alertRepo, clock
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestAlertCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     entity.Rule
+		value    float64
+		breached bool
+		cleared  bool
+	}{
+		{name: "above threshold", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "gt", Threshold: 90, Hysteresis: 5}, value: 95, breached: true},
+		{name: "below threshold within hysteresis", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "gt", Threshold: 90, Hysteresis: 5}, value: 88},
+		{name: "below threshold past hysteresis", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "gt", Threshold: 90, Hysteresis: 5}, value: 85, cleared: true},
+		{name: "at ge threshold", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "ge", Threshold: 90}, value: 90, breached: true},
+		{name: "below ge threshold without hysteresis", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "ge", Threshold: 90}, value: 89.9, cleared: true},
+		{name: "below lt threshold", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "lt", Threshold: 10, Hysteresis: 2}, value: 5, breached: true},
+		{name: "above lt threshold within hysteresis", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "lt", Threshold: 10, Hysteresis: 2}, value: 11},
+		{name: "above lt threshold past hysteresis", rule: entity.Rule{Kind: enum.RuleThreshold, Operator: "lt", Threshold: 10, Hysteresis: 2}, value: 12, cleared: true},
+		{name: "below range", rule: entity.Rule{Kind: enum.RuleRange, Min: float(10), Max: float(20), Hysteresis: 1}, value: 9, breached: true},
+		{name: "above range", rule: entity.Rule{Kind: enum.RuleRange, Min: float(10), Max: float(20), Hysteresis: 1}, value: 25, breached: true},
+		{name: "in range near min", rule: entity.Rule{Kind: enum.RuleRange, Min: float(10), Max: float(20), Hysteresis: 1}, value: 10.5},
+		{name: "in range near max", rule: entity.Rule{Kind: enum.RuleRange, Min: float(10), Max: float(20), Hysteresis: 1}, value: 19.5},
+		{name: "in range past hysteresis", rule: entity.Rule{Kind: enum.RuleRange, Min: float(10), Max: float(20), Hysteresis: 1}, value: 19, cleared: true},
+		{name: "open range", rule: entity.Rule{Kind: enum.RuleRange, Max: float(20), Hysteresis: 1}, value: -100, cleared: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breached, cleared, known := condition(&tt.rule, &alertState{}, ditto.Measurement{Value: tt.value})
+			if breached != tt.breached || cleared != tt.cleared || !known {
+				t.Errorf("expected breached %v and cleared %v, got %v and %v (known %v)", tt.breached, tt.cleared, breached, cleared, known)
+			}
+		})
+	}
+}
+
+func TestAlertConditionRateOfChange(t *testing.T) {
+	rule := &entity.Rule{Kind: enum.RuleRateOfChange, RatePerMinute: 10, Hysteresis: 2}
+	state := &alertState{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		at       time.Duration
+		value    float64
+		breached bool
+		cleared  bool
+		known    bool
+	}{
+		{name: "first sample", at: 0, value: 0},
+		{name: "slow change", at: time.Minute, value: 5, cleared: true, known: true},
+		{name: "fast change", at: 2 * time.Minute, value: 20, breached: true, known: true},
+		{name: "same timestamp", at: 2 * time.Minute, value: 30},
+		{name: "change within hysteresis", at: 3 * time.Minute, value: 39, known: true},
+	}
+	// The samples depend on each other, they run in order on the same state
+	for _, tt := range tests {
+		breached, cleared, known := condition(rule, state, ditto.Measurement{Value: tt.value, Timestamp: start.Add(tt.at)})
+		if breached != tt.breached || cleared != tt.cleared || known != tt.known {
+			t.Errorf("%s: expected breached %v, cleared %v and known %v, got %v, %v and %v",
+				tt.name, tt.breached, tt.cleared, tt.known, breached, cleared, known)
+		}
+	}
+}
+
+func TestAlertThresholdFiresAndResolves(t *testing.T) {
+	rule := &entity.Rule{ID: "r1", Name: "Overheat", Kind: enum.RuleThreshold, Feature: "temp", Operator: "gt", Threshold: 90, Hysteresis: 5, DebounceSeconds: 60}
+	s, _, alerts, clock := newTestAlertService(t, rule)
+	ctx := context.Background()
+	measure := func(value float64) {
+		s.evaluate(ctx, ditto.Measurement{ThingID: "org:dev", Feature: "temp", Value: value, Timestamp: clock.now})
+	}
+
+	// A breach recovering within the debounce fires nothing
+	measure(95)
+	clock.advance(30 * time.Second)
+	measure(80)
+	s.check(ctx, clock.advance(time.Minute))
+	if alert := alerts.active("r1"); alert != nil {
+		t.Fatalf("expected no alert for a breach shorter than the debounce, got %+v", alert)
+	}
+
+	// A breach holding for the debounce fires from check, without another measurement
+	measure(95)
+	s.check(ctx, clock.advance(59*time.Second))
+	if alerts.active("r1") != nil {
+		t.Fatal("expected no alert before the debounce elapsed")
+	}
+	s.check(ctx, clock.advance(time.Second))
+	alert := alerts.active("r1")
+	if alert == nil || alert.ThingID != "org:dev" || alert.Value == nil || *alert.Value != 95 {
+		t.Fatalf("expected an alert with the breaching value, got %+v", alert)
+	}
+
+	// The alert only resolves past the hysteresis margin
+	measure(88)
+	if alerts.active("r1") == nil {
+		t.Fatal("expected the alert to keep firing within the hysteresis margin")
+	}
+	clock.advance(time.Minute)
+	measure(84)
+	if alerts.active("r1") != nil || alert.Status != enum.AlertResolved || *alert.Value != 84 || !alert.ResolvedAt.Equal(clock.now) {
+		t.Fatalf("expected the alert to resolve with the recovered value, got %+v", alert)
+	}
+
+	// Other things and features are left alone
+	s.evaluate(ctx, ditto.Measurement{ThingID: "org:dev", Feature: "humidity", Value: 99})
+	if len(alerts.alerts) != 1 {
+		t.Errorf("expected a single alert, got %d", len(alerts.alerts))
+	}
+}
+
+func TestAlertWithoutDebounceFiresRightAway(t *testing.T) {
+	rule := &entity.Rule{ID: "r1", Kind: enum.RuleRange, Namespace: "org", Feature: "temp", Min: float(10), Max: float(20)}
+	s, _, alerts, _ := newTestAlertService(t, rule)
+
+	s.evaluate(context.Background(), ditto.Measurement{ThingID: "other:dev", Feature: "temp", Value: 30})
+	if alerts.active("r1") != nil {
+		t.Fatal("expected a thing outside the namespace of the rule to be ignored")
+	}
+	s.evaluate(context.Background(), ditto.Measurement{ThingID: "org:dev", Feature: "temp", Value: 30})
+	if alerts.active("r1") == nil {
+		t.Fatal("expected the alert to fire on the breaching measurement")
+	}
+}
+
+func TestAlertAbsenceFiresAndResolves(t *testing.T) {
+	rule := &entity.Rule{ID: "r1", Name: "Silent", Kind: enum.RuleAbsence, ThingID: "org:dev", Feature: "temp", AbsenceSeconds: 300, DebounceSeconds: 60}
+	s, _, alerts, clock := newTestAlertService(t, rule)
+	ctx := context.Background()
+
+	// The absence clock starts with the rule, a thing that never reports is detected
+	s.check(ctx, clock.advance(359*time.Second))
+	if alerts.active("r1") != nil {
+		t.Fatal("expected no alert before the absence and debounce elapsed")
+	}
+	s.check(ctx, clock.advance(time.Second))
+	alert := alerts.active("r1")
+	if alert == nil || alert.Value != nil || alert.Message != "temp reported no value for 6m0s" {
+		t.Fatalf("expected an absence alert, got %+v", alert)
+	}
+
+	// A value resolves it and restarts the clock
+	s.evaluate(ctx, ditto.Measurement{ThingID: "org:dev", Feature: "temp", Value: 21})
+	if alerts.active("r1") != nil || alert.Status != enum.AlertResolved {
+		t.Fatalf("expected the absence alert to resolve, got %+v", alert)
+	}
+	s.check(ctx, clock.advance(5*time.Minute))
+	if alerts.active("r1") != nil {
+		t.Fatal("expected the clock to restart with the value")
+	}
+}
+
+func TestAlertReloadResolvesChangedRules(t *testing.T) {
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newRule := func(id string) *entity.Rule {
+		at := updated
+		return &entity.Rule{ID: id, Kind: enum.RuleThreshold, Feature: "temp", Operator: "gt", Threshold: 90, BaseEntity: entity.BaseEntity{UpdatedAt: &at}}
+	}
+	changed, deleted, kept := newRule("changed"), newRule("deleted"), newRule("kept")
+	s, rules, alerts, clock := newTestAlertService(t, changed, deleted, kept)
+	ctx := context.Background()
+
+	s.evaluate(ctx, ditto.Measurement{ThingID: "org:dev", Feature: "temp", Value: 95})
+	for _, id := range []string{"changed", "deleted", "kept"} {
+		if alerts.active(id) == nil {
+			t.Fatalf("expected rule %s to fire", id)
+		}
+	}
+
+	// One rule is updated, another deleted or disabled
+	later := updated.Add(time.Hour)
+	changed.UpdatedAt = &later
+	rules.rules = []*entity.Rule{changed, kept}
+	clock.advance(time.Minute)
+	s.reloadRules(ctx)
+
+	for _, id := range []string{"changed", "deleted"} {
+		if alerts.active(id) != nil {
+			t.Errorf("expected the alert of rule %s to resolve", id)
+		}
+	}
+	if alerts.active("kept") == nil {
+		t.Error("expected the alert of the unchanged rule to keep firing")
+	}
+
+	// The changed rule starts over and fires again while the value still breaches it
+	s.evaluate(ctx, ditto.Measurement{ThingID: "org:dev", Feature: "temp", Value: 95})
+	if alerts.active("changed") == nil || alerts.active("deleted") != nil {
+		t.Error("expected only the changed rule to fire again")
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/rbac"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+	"ditto/pkg/util"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown user, a disabled user or a wrong password alike
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrInvalidToken is returned for refresh tokens that are malformed, expired or revoked
+	ErrInvalidToken = errors.New("invalid or expired refresh token")
+	// ErrAuthNotConfigured is returned when JWT_SECRET or JWT_REFRESH_SECRET is not set
+	ErrAuthNotConfigured = errors.New("token authentication is not configured")
+	// ErrUserExists is returned when creating a user whose username is taken
+	ErrUserExists = errors.New("username is already taken")
+	// ErrUnknownRole is returned when assigning a role RBAC_ROLES does not define
+	ErrUnknownRole = errors.New("unknown role")
+)
+
+// AuthService logs users of the Postgres user store in. A login returns a short
+// lived access token and a refresh token. Every refresh rotates the refresh token,
+// and using a rotated token again revokes all the tokens of that login, since the
+// token must have leaked.
+type AuthService struct {
+	repo   repository.UserRepository
+	policy *rbac.Policy
+	logger logger.Logger
+	jwt    config.JWTConfig
+	config config.AuthConfig
+
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	// dummyHash is compared against for unknown users, so they take as long as wrong passwords
+	dummyHash []byte
+}
+
+// NewAuthService creates the auth service, creating the configured admin user at startup
+func NewAuthService(lc fx.Lifecycle, repo repository.UserRepository, policy *rbac.Policy, logger logger.Logger, cfg *config.Config) *AuthService {
+	s := &AuthService{
+		repo:       repo,
+		policy:     policy,
+		logger:     logger,
+		jwt:        cfg.JWT,
+		config:     cfg.Auth,
+		accessTTL:  parseTTL(logger, "JWT_EXPIRATION_TIME", cfg.JWT.ExpirationTime, 15*time.Minute),
+		refreshTTL: parseTTL(logger, "JWT_REFRESH_EXPIRATION_TIME", cfg.JWT.RefreshExpirationTime, 7*24*time.Hour),
+	}
+	if s.config.BcryptCost < bcrypt.MinCost || s.config.BcryptCost > bcrypt.MaxCost {
+		s.config.BcryptCost = bcrypt.DefaultCost
+	}
+	s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), s.config.BcryptCost)
+
+	if s.config.AdminUsername != "" {
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return s.ensureAdmin(ctx)
+			},
+		})
+	}
+
+	return s
+}
+
+// parseTTL parses a token lifetime setting, falling back to def when unset or invalid
+func parseTTL(logger logger.Logger, name, value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		logger.Warn("Invalid token lifetime, using the default",
+			zap.String("setting", name), zap.String("value", value), zap.Duration("default", def))
+		return def
+	}
+	return ttl
+}
+
+// ensureAdmin creates the AUTH_ADMIN_USERNAME user unless it already exists. An
+// existing admin without roles, created before roles existed, gets AUTH_ADMIN_ROLES.
+func (s *AuthService) ensureAdmin(ctx context.Context) error {
+	roles := rbac.SplitRoles(s.config.AdminRoles)
+	user, err := s.repo.GetByUsername(ctx, s.config.AdminUsername)
+	if err == nil {
+		if len(user.Roles) == 0 {
+			_, err = s.SetRoles(ctx, user.ID, roles, "system")
+		}
+		return err
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if s.config.AdminPassword == "" {
+		return fmt.Errorf("AUTH_ADMIN_PASSWORD is required to create the admin user")
+	}
+
+	if _, err := s.CreateUser(ctx, s.config.AdminUsername, s.config.AdminEmail, s.config.AdminPassword, roles, "system"); err != nil {
+		return err
+	}
+	s.logger.Info("Created admin user", zap.String("username", s.config.AdminUsername))
+	return nil
+}
+
+// CreateUser stores a new enabled user with the bcrypt hash of its password
+func (s *AuthService) CreateUser(ctx context.Context, username, email, password string, roles []string, by string) (*entity.User, error) {
+	rolesJSON, err := s.rolesJSON(roles)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetByUsername(ctx, username); err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.BcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &entity.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: string(hash),
+		Enabled:      true,
+		Roles:        rolesJSON,
+		BaseEntity: entity.BaseEntity{
+			CreatedBy: by,
+			UpdatedBy: by,
+		},
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers returns a page of users
+func (s *AuthService) ListUsers(ctx context.Context, limit, offset int) ([]*entity.User, int64, error) {
+	return s.repo.List(ctx, limit, offset)
+}
+
+// SetRoles replaces the roles of a user. Access tokens already issued keep their
+// roles until they expire, the next refresh picks up the new ones.
+func (s *AuthService) SetRoles(ctx context.Context, id string, roles []string, by string) (*entity.User, error) {
+	rolesJSON, err := s.rolesJSON(roles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRoles(ctx, id, rolesJSON, by); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// rolesJSON checks that roles are defined and encodes them for the roles column
+func (s *AuthService) rolesJSON(roles []string) (entity.JSON, error) {
+	for _, role := range roles {
+		if !s.policy.Valid(role) {
+			return nil, fmt.Errorf("%w %s", ErrUnknownRole, role)
+		}
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	return entity.NewJSON(roles)
+}
+
+// Login checks the password of a user and issues the first token pair of a new login
+func (s *AuthService) Login(ctx context.Context, username, password string) (*model.TokenPair, error) {
+	if !s.configured() {
+		return nil, ErrAuthNotConfigured
+	}
+
+	user, err := s.repo.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil || !user.Enabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	pair, token, err := s.issue(user, uuid.New().String(), now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRefreshToken(ctx, token); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateLastLogin(ctx, user.ID, now); err != nil {
+		s.logger.Warn("Failed to record login", zap.String("user", user.Username), zap.Error(err))
+	}
+	return pair, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, revoking the presented token
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	if !s.configured() {
+		return nil, ErrAuthNotConfigured
+	}
+
+	claims, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	current, err := s.repo.GetRefreshToken(ctx, claims.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if current.RevokedAt != nil {
+		return nil, s.revokeReused(ctx, current, now)
+	}
+	if !now.Before(current.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.repo.GetByID(ctx, current.UserID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !user.Enabled) {
+		// Disabled and deleted users lose their logins
+		if err := s.repo.RevokeRefreshFamily(ctx, current.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pair, next, err := s.issue(user, current.FamilyID, now)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.RotateRefreshToken(ctx, current.ID, next, now)
+	if errors.Is(err, repository.ErrNotFound) {
+		// A concurrent refresh rotated the token first
+		return nil, s.revokeReused(ctx, current, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Logout revokes the refresh tokens of the login a refresh token belongs to.
+// Logging out with an expired or already revoked token succeeds.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	if !s.configured() {
+		return ErrAuthNotConfigured
+	}
+
+	claims, err := s.parseRefreshToken(refreshToken, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return ErrInvalidToken
+	}
+	current, err := s.repo.GetRefreshToken(ctx, claims.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	return s.repo.RevokeRefreshFamily(ctx, current.FamilyID, time.Now())
+}
+
+// revokeReused revokes the login of a refresh token presented after it was rotated
+func (s *AuthService) revokeReused(ctx context.Context, token *entity.RefreshToken, now time.Time) error {
+	s.logger.Warn("Revoked refresh token reused, revoking its login",
+		zap.String("user_id", token.UserID), zap.String("family_id", token.FamilyID))
+	if err := s.repo.RevokeRefreshFamily(ctx, token.FamilyID, now); err != nil {
+		return err
+	}
+	return ErrInvalidToken
+}
+
+// issue signs a token pair for a user, the refresh token belongs to the given family
+func (s *AuthService) issue(user *entity.User, familyID string, now time.Time) (*model.TokenPair, *entity.RefreshToken, error) {
+	var roles []string
+	if err := user.Roles.Decode(&roles); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode roles of user %s: %w", user.Username, err)
+	}
+
+	access := util.AccessClaims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Username: user.Username,
+		Roles:    roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, access).SignedString([]byte(s.jwt.Secret))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	token := &entity.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
+	refresh := jwt.RegisteredClaims{
+		ID:        token.ID,
+		Subject:   user.ID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(token.ExpiresAt),
+	}
+	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refresh).SignedString([]byte(s.jwt.RefreshSecret))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign refresh token: %w", err)
+	}
+
+	return &model.TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        s.accessTTL,
+		RefreshExpiresIn: s.refreshTTL,
+	}, token, nil
+}
+
+// parseRefreshToken verifies the signature of a refresh token and returns its claims
+func (s *AuthService) parseRefreshToken(refreshToken string, opts ...jwt.ParserOption) (*jwt.RegisteredClaims, error) {
+	var claims jwt.RegisteredClaims
+	opts = append(opts, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	_, err := jwt.ParseWithClaims(refreshToken, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.jwt.RefreshSecret), nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("refresh token has no jti")
+	}
+	return &claims, nil
+}
+
+// configured tells whether the token secrets are set
+func (s *AuthService) configured() bool {
+	return s.jwt.Secret != "" && s.jwt.RefreshSecret != ""
+}
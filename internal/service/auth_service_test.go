@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/model/entity"
+	"ditto/internal/rbac"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+	"ditto/pkg/util"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/fx/fxtest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeUserRepository keeps users and refresh tokens in memory with the
+// semantics of the Postgres repository. Tokens are returned as copies, like rows.
+type fakeUserRepository struct {
+	mu     sync.Mutex
+	users  map[string]*entity.User
+	tokens map[string]*entity.RefreshToken
+	// beforeRotate runs inside RotateRefreshToken before the token is checked
+	beforeRotate func(id string)
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{
+		users:  make(map[string]*entity.User),
+		tokens: make(map[string]*entity.RefreshToken),
+	}
+}
+
+func (r *fakeUserRepository) Create(_ context.Context, user *entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeUserRepository) GetByID(_ context.Context, id string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) GetByUsername(_ context.Context, username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *fakeUserRepository) List(_ context.Context, limit, offset int) ([]*entity.User, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*entity.User
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	return users, int64(len(users)), nil
+}
+
+func (r *fakeUserRepository) UpdateRoles(_ context.Context, id string, roles entity.JSON, by string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	user.Roles = roles
+	user.UpdatedBy = by
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateLastLogin(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		user.LastLoginAt = &at
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) CreateRefreshToken(_ context.Context, token *entity.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *token
+	r.tokens[token.ID] = &copied
+	return nil
+}
+
+func (r *fakeUserRepository) GetRefreshToken(_ context.Context, id string) (*entity.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) RotateRefreshToken(_ context.Context, id string, next *entity.RefreshToken, now time.Time) error {
+	if r.beforeRotate != nil {
+		r.beforeRotate(id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.RevokedAt != nil {
+		return repository.ErrNotFound
+	}
+	token.RevokedAt = &now
+	token.ReplacedBy = next.ID
+	copied := *next
+	r.tokens[next.ID] = &copied
+	return nil
+}
+
+func (r *fakeUserRepository) RevokeRefreshFamily(_ context.Context, familyID string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// token returns the stored refresh token of a signed refresh token
+func (r *fakeUserRepository) token(t *testing.T, s *AuthService, refreshToken string) entity.RefreshToken {
+	t.Helper()
+	claims, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := r.GetRefreshToken(context.Background(), claims.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *stored
+}
+
+var testJWTConfig = config.JWTConfig{
+	Secret:        "access-secret",
+	RefreshSecret: "refresh-secret",
+}
+
+func newTestAuthService(t *testing.T, repo repository.UserRepository) *AuthService {
+	t.Helper()
+	policy, err := rbac.ParsePolicy("viewer=devices:read,admin=*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		JWT:  testJWTConfig,
+		Auth: config.AuthConfig{BcryptCost: bcrypt.MinCost},
+	}
+	return NewAuthService(fxtest.NewLifecycle(t), repo, policy, logger.NewLogger(cfg), cfg)
+}
+
+func TestAuthLogin(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, "jane", "jane@example.com", "correct horse", []string{"viewer"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateUser(ctx, "jane", "", "other", nil, "test"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("expected ErrUserExists, got %v", err)
+	}
+	if _, err := s.CreateUser(ctx, "joe", "", "secret", []string{"root"}, "test"); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("expected ErrUnknownRole, got %v", err)
+	}
+
+	pair, err := s.Login(ctx, "jane", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := util.ParseAccessToken(pair.AccessToken, testJWTConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != user.ID || claims.Username != "jane" || !slices.Equal(claims.Roles, []string{"viewer"}) {
+		t.Errorf("unexpected access token claims %+v", claims)
+	}
+	stored := repo.token(t, s, pair.RefreshToken)
+	if stored.UserID != user.ID || stored.FamilyID == "" || stored.RevokedAt != nil {
+		t.Errorf("unexpected refresh token %+v", stored)
+	}
+	if logged, _ := repo.GetByID(ctx, user.ID); logged.LastLoginAt == nil {
+		t.Error("expected the login to be recorded")
+	}
+
+	// The refresh token is no access token and the other way round
+	if _, err := util.ParseAccessToken(pair.RefreshToken, testJWTConfig); err == nil {
+		t.Error("expected the refresh token to be refused as access token")
+	}
+	if _, err := s.Refresh(ctx, pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the access token to be refused as refresh token, got %v", err)
+	}
+
+	repo.users[user.ID].Enabled = false
+	for _, login := range [][2]string{{"jane", "wrong"}, {"nobody", "correct horse"}, {"jane", "correct horse"}} {
+		if _, err := s.Login(ctx, login[0], login[1]); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Login(%s, %s): expected ErrInvalidCredentials, got %v", login[0], login[1], err)
+		}
+	}
+}
+
+func TestAuthRefreshRotation(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	if _, err := s.CreateUser(ctx, "jane", "", "secret", []string{"viewer"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("expected a new refresh token")
+	}
+
+	// The presented token is revoked in favour of its successor of the same login
+	rotated, next := repo.token(t, s, first.RefreshToken), repo.token(t, s, second.RefreshToken)
+	if rotated.RevokedAt == nil || rotated.ReplacedBy != next.ID {
+		t.Errorf("expected the first token to be replaced by %s, got %+v", next.ID, rotated)
+	}
+	if next.FamilyID != rotated.FamilyID || next.RevokedAt != nil {
+		t.Errorf("expected the successor to continue the login, got %+v", next)
+	}
+
+	// The successor rotates in turn, and roles changed meanwhile are picked up
+	user, _ := repo.GetByUsername(ctx, "jane")
+	if _, err := s.SetRoles(ctx, user.ID, []string{"admin"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	third, err := s.Refresh(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := util.ParseAccessToken(third.AccessToken, testJWTConfig)
+	if err != nil || !slices.Equal(claims.Roles, []string{"admin"}) {
+		t.Errorf("expected the new roles in the access token, got %v, %v", claims, err)
+	}
+}
+
+func TestAuthRefreshReuseRevokesFamily(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	if _, err := s.CreateUser(ctx, "jane", "", "secret", nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	stolen, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner refreshes, then the attacker replays the rotated token
+	current, err := s.Refresh(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the reused token to be refused, got %v", err)
+	}
+
+	// Every token of that login is revoked, the current one included
+	if token := repo.token(t, s, current.RefreshToken); token.RevokedAt == nil {
+		t.Error("expected the current token of the login to be revoked")
+	}
+	if _, err := s.Refresh(ctx, current.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the current token to be refused, got %v", err)
+	}
+
+	// Other logins of the user are left alone
+	if _, err := s.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("expected another login to keep working, got %v", err)
+	}
+}
+
+func TestAuthConcurrentRefreshRevokesFamily(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	if _, err := s.CreateUser(ctx, "jane", "", "secret", nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another refresh of the same token wins the rotation between the read and the rotate
+	var winner string
+	repo.beforeRotate = func(string) {
+		repo.beforeRotate = nil
+		next, err := s.Refresh(ctx, pair.RefreshToken)
+		if err != nil {
+			t.Errorf("expected the first refresh to succeed, got %v", err)
+			return
+		}
+		winner = next.RefreshToken
+	}
+
+	if _, err := s.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the losing refresh to be refused, got %v", err)
+	}
+	if token := repo.token(t, s, winner); token.RevokedAt == nil {
+		t.Error("expected the login to be revoked when a token is rotated twice")
+	}
+}
+
+func TestAuthRefreshRefused(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	user, err := s.CreateUser(ctx, "jane", "", "secret", nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := repo.token(t, s, pair.RefreshToken)
+
+	forge := func(secret string, claims jwt.RegisteredClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	tests := map[string]string{
+		"malformed":    "not-a-token",
+		"wrong secret": forge("other-secret", jwt.RegisteredClaims{ID: stored.ID, ExpiresAt: exp}),
+		"unknown jti":  forge(testJWTConfig.RefreshSecret, jwt.RegisteredClaims{ID: "unknown", ExpiresAt: exp}),
+		"no jti":       forge(testJWTConfig.RefreshSecret, jwt.RegisteredClaims{ExpiresAt: exp}),
+		"expired":      forge(testJWTConfig.RefreshSecret, jwt.RegisteredClaims{ID: stored.ID, ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}),
+	}
+	for name, token := range tests {
+		if _, err := s.Refresh(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+	if token := repo.token(t, s, pair.RefreshToken); token.RevokedAt != nil {
+		t.Fatal("expected refused tokens not to revoke the login")
+	}
+
+	// A disabled user loses the login
+	repo.users[user.ID].Enabled = false
+	if _, err := s.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected a disabled user to be refused, got %v", err)
+	}
+	if token := repo.token(t, s, pair.RefreshToken); token.RevokedAt == nil {
+		t.Error("expected the login of a disabled user to be revoked")
+	}
+
+	unconfigured := newTestAuthService(t, repo)
+	unconfigured.jwt.RefreshSecret = ""
+	if _, err := unconfigured.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrAuthNotConfigured) {
+		t.Errorf("expected ErrAuthNotConfigured, got %v", err)
+	}
+}
+
+func TestAuthLogout(t *testing.T) {
+	repo := newFakeUserRepository()
+	s := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	if _, err := s.CreateUser(ctx, "jane", "", "secret", nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.Login(ctx, "jane", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging out with a rotated token of the login still ends the whole login
+	if err := s.Logout(ctx, first.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the login to be revoked, got %v", err)
+	}
+	if err := s.Logout(ctx, second.RefreshToken); err != nil {
+		t.Errorf("expected logging out again to succeed, got %v", err)
+	}
+	if err := s.Logout(ctx, "not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
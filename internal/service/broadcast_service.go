@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"ditto/internal/ditto"
+	"ditto/internal/job"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// BroadcastJobType identifies command broadcast jobs
+	BroadcastJobType = "command.broadcast"
+
+	// DefaultBroadcastConcurrency is the number of commands in flight at once
+	DefaultBroadcastConcurrency = 8
+	// MaxBroadcastConcurrency caps the concurrency a request may ask for
+	MaxBroadcastConcurrency = 32
+	// MaxBroadcastRate caps the number of commands per second a request may ask for
+	MaxBroadcastRate = 100
+)
+
+// BroadcastService sends a command to every thing of a group
+type BroadcastService struct {
+	commandService *CommandService
+	dittoClient    *ditto.Client
+	jobs           *job.Manager
+	logger         logger.Logger
+}
+
+// NewBroadcastService creates a new broadcast service and registers its job handler
+func NewBroadcastService(commandService *CommandService, dittoClient *ditto.Client, jobs *job.Manager, logger logger.Logger) *BroadcastService {
+	s := &BroadcastService{
+		commandService: commandService,
+		dittoClient:    dittoClient,
+		jobs:           jobs,
+		logger:         logger,
+	}
+	jobs.Register(BroadcastJobType, s.run)
+	return s
+}
+
+// Validate checks the targets, command, rate and canary of a broadcast
+func (s *BroadcastService) Validate(broadcast model.Broadcast) error {
+	if _, err := broadcast.Filter.Expr(); err != nil {
+		return err
+	}
+	// An empty filter would target the whole fleet
+	if len(broadcast.Filter.Filters) == 0 && len(broadcast.Filter.Namespaces) == 0 {
+		return fmt.Errorf("filter must restrict the targeted things")
+	}
+	if broadcast.Feature == "" || broadcast.Subject == "" {
+		return fmt.Errorf("feature and command are required")
+	}
+	if len(broadcast.Params) > 0 && !json.Valid(broadcast.Params) {
+		return fmt.Errorf("params must be valid JSON")
+	}
+	if broadcast.Rate < 0 || broadcast.Rate > MaxBroadcastRate {
+		return fmt.Errorf("rate must be between 0 and %d commands per second", MaxBroadcastRate)
+	}
+	if canary := broadcast.Canary; canary != nil {
+		if canary.Size < 1 {
+			return fmt.Errorf("canary size must be at least 1")
+		}
+		if canary.MinSuccessRatio <= 0 || canary.MinSuccessRatio > 1 {
+			return fmt.Errorf("canary min success ratio must be greater than 0 and at most 1")
+		}
+	}
+	return nil
+}
+
+// Start submits a broadcast job
+func (s *BroadcastService) Start(ctx context.Context, broadcast model.Broadcast, user string) (*entity.Job, error) {
+	if err := s.Validate(broadcast); err != nil {
+		return nil, err
+	}
+	if broadcast.Concurrency <= 0 {
+		broadcast.Concurrency = DefaultBroadcastConcurrency
+	}
+	if broadcast.Concurrency > MaxBroadcastConcurrency {
+		broadcast.Concurrency = MaxBroadcastConcurrency
+	}
+	broadcast.Timeout = s.commandService.Timeout(broadcast.Timeout)
+
+	payload := broadcastPayload{
+		Broadcast: broadcast,
+		User:      user,
+	}
+	// Rerunning a partially sent broadcast would resend to every thing, the report tells what failed
+	return s.jobs.Submit(ctx, BroadcastJobType, payload, job.WithCreatedBy(user), job.WithMaxAttempts(1))
+}
+
+// Get returns a broadcast job
+func (s *BroadcastService) Get(ctx context.Context, jobID string) (*entity.Job, error) {
+	j, err := s.jobs.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if j.Type != BroadcastJobType {
+		return nil, repository.ErrNotFound
+	}
+	return j, nil
+}
+
+// run resolves the targets, sends to the canary batch first and to the rest if it succeeded
+func (s *BroadcastService) run(ctx context.Context, task *job.Task) (interface{}, error) {
+	var payload broadcastPayload
+	if err := task.Decode(&payload); err != nil {
+		return nil, job.Permanent(fmt.Errorf("invalid broadcast payload: %w", err))
+	}
+	broadcast := payload.Broadcast
+
+	targets, err := searchThingIDs(ctx, s.dittoClient, broadcast.Filter)
+	if err != nil {
+		return nil, err
+	}
+	task.SetTotal(len(targets))
+
+	report := &model.BroadcastReport{
+		Matched:  len(targets),
+		ByStatus: make(map[string]int),
+		Results:  make([]model.CommandTargetResult, len(targets)),
+	}
+	for i, thingID := range targets {
+		report.Results[i] = model.CommandTargetResult{ThingID: thingID, Status: enum.CommandPending}
+	}
+
+	var limiter *rate.Limiter
+	if broadcast.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(broadcast.Rate), 1)
+	}
+
+	var runErr error
+	remaining := 0
+	if canary := broadcast.Canary; canary != nil && canary.Size < len(targets) {
+		s.dispatch(ctx, task, broadcast, payload.User, limiter, report.Results[:canary.Size])
+
+		succeeded := 0
+		for _, result := range report.Results[:canary.Size] {
+			if commandSucceeded(result.Status) {
+				succeeded++
+			}
+		}
+		ratio := float64(succeeded) / float64(canary.Size)
+		report.CanaryRatio = &ratio
+
+		if ratio < canary.MinSuccessRatio {
+			report.Aborted = true
+			for i := canary.Size; i < len(report.Results); i++ {
+				report.Results[i].Status = model.CommandSkipped
+			}
+			runErr = fmt.Errorf("canary success ratio %.2f is below %.2f, broadcast aborted", ratio, canary.MinSuccessRatio)
+		} else {
+			remaining = canary.Size
+		}
+	}
+	if !report.Aborted && ctx.Err() == nil {
+		s.dispatch(ctx, task, broadcast, payload.User, limiter, report.Results[remaining:])
+	}
+
+	for _, result := range report.Results {
+		report.ByStatus[result.Status]++
+		switch {
+		case commandSucceeded(result.Status):
+			report.Succeeded++
+		case result.Status == model.CommandSkipped:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+
+	s.logger.Info(fmt.Sprintf("Broadcast job %s finished: %d succeeded, %d failed, %d skipped", task.ID, report.Succeeded, report.Failed, report.Skipped))
+
+	if runErr != nil {
+		return report, job.Permanent(runErr)
+	}
+	return report, ctx.Err()
+}
+
+// dispatch sends the command to a batch of targets, updating their results in place
+func (s *BroadcastService) dispatch(ctx context.Context, task *job.Task, broadcast model.Broadcast, user string, limiter *rate.Limiter, batch []model.CommandTargetResult) {
+	sem := make(chan struct{}, broadcast.Concurrency)
+	var wg sync.WaitGroup
+	for i := range batch {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *model.CommandTargetResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			command, err := s.commandService.Send(ctx, model.Command{
+				ThingID:          result.ThingID,
+				Feature:          broadcast.Feature,
+				Subject:          broadcast.Subject,
+				Params:           broadcast.Params,
+				Timeout:          broadcast.Timeout,
+				ResponseRequired: broadcast.ResponseRequired,
+			}, user)
+
+			result.Attempts = 1
+			if err != nil {
+				result.Status = enum.CommandFailed
+				result.Error = err.Error()
+			} else {
+				result.CommandID = command.ID
+				result.Status = command.Status
+				result.Error = command.Error
+			}
+			task.Add(1)
+		}(&batch[i])
+	}
+	wg.Wait()
+}
+
+// commandSucceeded reports whether a command status counts as delivered
+func commandSucceeded(status string) bool {
+	return status == enum.CommandSucceeded || status == enum.CommandSent
+}
+
+// broadcastPayload is the stored input of a broadcast job
+type broadcastPayload struct {
+	Broadcast model.Broadcast `json:"broadcast"`
+	User      string          `json:"user"`
+}
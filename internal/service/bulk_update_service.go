@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
+	"ditto/internal/job"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/pkg/logger"
+)
+
+const (
+	// BulkUpdateJobType identifies bulk update jobs
+	BulkUpdateJobType = "device.bulk_update"
+
+	// DefaultBulkUpdateConcurrency is the number of things patched in parallel
+	DefaultBulkUpdateConcurrency = 8
+	// MaxBulkUpdateConcurrency caps the concurrency a request may ask for
+	MaxBulkUpdateConcurrency = 32
+
+	bulkUpdatePreviewSample = 10
+)
+
+// BulkUpdateService applies merge patches to all things matching a filter
+type BulkUpdateService struct {
+	dittoClient *ditto.Client
+	jobs        *job.Manager
+	logger      logger.Logger
+}
+
+// NewBulkUpdateService creates a new bulk update service and registers its job handler
+func NewBulkUpdateService(dittoClient *ditto.Client, jobs *job.Manager, logger logger.Logger) *BulkUpdateService {
+	s := &BulkUpdateService{
+		dittoClient: dittoClient,
+		jobs:        jobs,
+		logger:      logger,
+	}
+	jobs.Register(BulkUpdateJobType, s.run)
+	return s
+}
+
+// Validate checks the filter and that the patch is a JSON object. A filter
+// without predicates matches every thing, which must be confirmed with All.
+func (s *BulkUpdateService) Validate(update model.BulkUpdate) error {
+	if len(update.Filter.Filters) == 0 && !update.All {
+		return fmt.Errorf("filter has no predicates, set all to update every thing")
+	}
+	if _, err := update.Filter.Expr(); err != nil {
+		return err
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(update.Patch, &patch); err != nil || len(patch) == 0 {
+		return fmt.Errorf("patch must be a non-empty JSON object")
+	}
+	if _, ok := patch["thingId"]; ok {
+		return fmt.Errorf("patch must not change thingId")
+	}
+	return nil
+}
+
+// Preview counts the matching things and returns a sample of their IDs
+func (s *BulkUpdateService) Preview(ctx context.Context, filter model.SearchFilter) (*model.BulkUpdatePreview, error) {
+	expr, err := filter.Expr()
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.dittoClient.Count(ctx, expr, filter.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.dittoClient.Search(ctx, ditto.SearchQuery{
+		Filter:     expr,
+		Namespaces: filter.Namespaces,
+		Fields:     []string{"thingId"},
+		Size:       bulkUpdatePreviewSample,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.BulkUpdatePreview{
+		Count:  count,
+		Sample: make([]string, 0, len(result.Items)),
+	}
+	for _, item := range result.Items {
+		var thing struct {
+			ThingID string `json:"thingId"`
+		}
+		if err := json.Unmarshal(item, &thing); err == nil {
+			preview.Sample = append(preview.Sample, thing.ThingID)
+		}
+	}
+	return preview, nil
+}
+
+// Start submits a bulk update job
+func (s *BulkUpdateService) Start(ctx context.Context, update model.BulkUpdate, user string) (*entity.Job, error) {
+	if err := s.Validate(update); err != nil {
+		return nil, err
+	}
+	if update.Concurrency <= 0 {
+		update.Concurrency = DefaultBulkUpdateConcurrency
+	}
+	if update.Concurrency > MaxBulkUpdateConcurrency {
+		update.Concurrency = MaxBulkUpdateConcurrency
+	}
+
+	// A partially applied patch is not retried automatically, the report tells what failed
+	return s.jobs.Submit(ctx, BulkUpdateJobType, update, job.WithCreatedBy(user), job.WithMaxAttempts(1))
+}
+
+// run patches every matching thing, using its revision as ETag so concurrent changes are detected
+func (s *BulkUpdateService) run(ctx context.Context, task *job.Task) (interface{}, error) {
+	var update model.BulkUpdate
+	if err := task.Decode(&update); err != nil {
+		return nil, job.Permanent(fmt.Errorf("invalid bulk update payload: %w", err))
+	}
+
+	expr, err := update.Filter.Expr()
+	if err != nil {
+		return nil, job.Permanent(err)
+	}
+
+	count, err := s.dittoClient.Count(ctx, expr, update.Filter.Namespaces)
+	if err != nil {
+		return nil, err
+	}
+	if update.ExpectedCount != nil && *update.ExpectedCount != count {
+		return nil, job.Permanent(fmt.Errorf("expected %d matching things but found %d", *update.ExpectedCount, count))
+	}
+	task.SetTotal(count)
+
+	report := &model.BulkUpdateReport{Failures: []model.BulkUpdateFailure{}}
+	var reportMu sync.Mutex
+
+	type target struct {
+		ThingID  string `json:"thingId"`
+		Revision int64  `json:"_revision"`
+	}
+	targets := make(chan target)
+
+	var wg sync.WaitGroup
+	for i := 0; i < update.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				err := s.dittoClient.MergeThing(ctx, t.ThingID, update.Patch, ditto.RevisionETag(t.Revision))
+
+				reportMu.Lock()
+				switch {
+				case err == nil:
+					report.Updated++
+				case errors.Is(err, ditto.ErrPreconditionFailed):
+					report.Conflicts++
+					report.Failures = append(report.Failures, model.BulkUpdateFailure{ThingID: t.ThingID, Status: model.UpdateConflict, Error: err.Error()})
+				default:
+					report.Failed++
+					report.Failures = append(report.Failures, model.BulkUpdateFailure{ThingID: t.ThingID, Status: model.UpdateFailed, Error: err.Error()})
+				}
+				reportMu.Unlock()
+				task.Add(1)
+			}
+		}()
+	}
+
+	// Stream targets from the search so memory stays bounded for large fleets
+	iterErr := s.dittoClient.ForEach(ctx, ditto.SearchQuery{
+		Filter:     expr,
+		Namespaces: update.Filter.Namespaces,
+		Fields:     []string{"thingId", "_revision"},
+		Sort:       []rql.SortKey{rql.Asc("thingId")},
+	}, func(item json.RawMessage) error {
+		var t target
+		if err := json.Unmarshal(item, &t); err != nil {
+			return fmt.Errorf("failed to decode search result: %w", err)
+		}
+		reportMu.Lock()
+		report.Matched++
+		reportMu.Unlock()
+
+		select {
+		case targets <- t:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(targets)
+	wg.Wait()
+
+	s.logger.Info(fmt.Sprintf("Bulk update job %s finished: %d matched, %d updated, %d conflicts, %d failed",
+		task.ID, report.Matched, report.Updated, report.Conflicts, report.Failed))
+
+	return report, iterErr
+}
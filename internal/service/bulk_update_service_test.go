@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ditto/internal/model"
+)
+
+func TestBulkUpdateValidate(t *testing.T) {
+	company := model.SearchFilter{Filters: []model.SearchPredicate{{Path: "attributes/company", Op: "eq", Value: "acme"}}}
+	patch := json.RawMessage(`{"attributes":{"location":"hq"}}`)
+
+	tests := []struct {
+		name    string
+		update  model.BulkUpdate
+		wantErr bool
+	}{
+		{name: "filtered", update: model.BulkUpdate{Filter: company, Patch: patch}},
+		{name: "empty filter", update: model.BulkUpdate{Patch: patch}, wantErr: true},
+		{name: "namespaces only", update: model.BulkUpdate{Filter: model.SearchFilter{Namespaces: []string{"org"}}, Patch: patch}, wantErr: true},
+		{name: "every thing", update: model.BulkUpdate{Patch: patch, All: true}},
+		{name: "every thing of a namespace", update: model.BulkUpdate{Filter: model.SearchFilter{Namespaces: []string{"org"}}, Patch: patch, All: true}},
+		{name: "invalid filter", update: model.BulkUpdate{Filter: model.SearchFilter{Filters: []model.SearchPredicate{{Path: "_policy", Op: "eq"}}}, Patch: patch}, wantErr: true},
+		{name: "empty patch", update: model.BulkUpdate{Filter: company, Patch: json.RawMessage(`{}`)}, wantErr: true},
+		{name: "thingId patch", update: model.BulkUpdate{Filter: company, Patch: json.RawMessage(`{"thingId":"org:x"}`)}, wantErr: true},
+	}
+
+	s := &BulkUpdateService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.update)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected the update to be refused")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
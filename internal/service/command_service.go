@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// commandExpiryGrace is added to the maximum timeout before a pending command is
+// considered lost
+const commandExpiryGrace = time.Minute
+
+// CommandService delivers commands to devices and records them in the command history
+type CommandService struct {
+	dittoClient *ditto.Client
+	repo        repository.CommandRepository
+	bus         *eventbus.Bus
+	logger      logger.Logger
+	config      config.CommandConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewCommandService creates a new command service
+func NewCommandService(lc fx.Lifecycle, dittoClient *ditto.Client, repo repository.CommandRepository, bus *eventbus.Bus, logger logger.Logger, cfg *config.Config) *CommandService {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &CommandService{
+		dittoClient: dittoClient,
+		repo:        repo,
+		bus:         bus,
+		logger:      logger,
+		config:      cfg.Command,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			s.wg.Add(1)
+			go s.expire()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			s.cancel()
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s
+}
+
+// Timeout clamps a requested timeout to the configured bounds, zero selects the default
+func (s *CommandService) Timeout(requested time.Duration) time.Duration {
+	if requested <= 0 {
+		return s.config.DefaultTimeout
+	}
+	if requested > s.config.MaxTimeout {
+		return s.config.MaxTimeout
+	}
+	return requested
+}
+
+// Send delivers a command and waits for the device reply
+func (s *CommandService) Send(ctx context.Context, cmd model.Command, user string) (*entity.Command, error) {
+	record, err := s.record(ctx, cmd, false, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deliver(ctx, cmd, record)
+	return record, nil
+}
+
+// SendAsync records a command and delivers it in the background, the returned command
+// is pending and its outcome can be fetched with Get once the device replied
+func (s *CommandService) SendAsync(ctx context.Context, cmd model.Command, user string) (*entity.Command, error) {
+	record, err := s.record(ctx, cmd, true, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// The delivery outlives the request but is still made on behalf of its user
+	deliverCtx := s.ctx
+	if identity, ok := ditto.IdentityFromContext(ctx); ok {
+		deliverCtx = ditto.WithIdentity(deliverCtx, identity)
+	}
+
+	pending := *record
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.deliver(deliverCtx, cmd, record)
+	}()
+
+	return &pending, nil
+}
+
+// Get returns a command of the history
+func (s *CommandService) Get(ctx context.Context, id string) (*entity.Command, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns a page of the command history and the total number of matching commands
+func (s *CommandService) List(ctx context.Context, filter repository.CommandFilter) ([]*entity.Command, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// record stores a pending command under a new ID, which is also its correlation ID
+func (s *CommandService) record(ctx context.Context, cmd model.Command, async bool, user string) (*entity.Command, error) {
+	timeout := s.Timeout(cmd.Timeout)
+	if !cmd.ResponseRequired {
+		timeout = 0
+	}
+
+	id := uuid.NewString()
+	record := &entity.Command{
+		ID:               id,
+		ThingID:          cmd.ThingID,
+		Feature:          cmd.Feature,
+		Subject:          cmd.Subject,
+		CorrelationID:    id,
+		Async:            async,
+		ResponseRequired: cmd.ResponseRequired,
+		TimeoutMs:        timeout.Milliseconds(),
+		Status:           enum.CommandPending,
+		SentAt:           time.Now(),
+		BaseEntity:       entity.BaseEntity{CreatedBy: user},
+	}
+	if len(cmd.Params) > 0 {
+		if !json.Valid(cmd.Params) {
+			return nil, fmt.Errorf("command params are not valid JSON")
+		}
+		record.Params = entity.JSON(cmd.Params)
+	}
+
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	eventbus.Publish(s.bus, CommandTopic, *record)
+	return record, nil
+}
+
+// deliver sends the command to Ditto and stores the outcome in the history
+func (s *CommandService) deliver(ctx context.Context, cmd model.Command, record *entity.Command) {
+	timeout := time.Duration(record.TimeoutMs) * time.Millisecond
+
+	resp, err := s.dittoClient.SendFeatureMessage(ctx, ditto.MessageRequest{
+		ThingID:          cmd.ThingID,
+		Feature:          cmd.Feature,
+		Subject:          cmd.Subject,
+		Payload:          cmd.Params,
+		Timeout:          timeout,
+		ResponseRequired: cmd.ResponseRequired,
+		CorrelationID:    record.CorrelationID,
+	})
+
+	now := time.Now()
+	record.CompletedAt = &now
+	record.LatencyMs = now.Sub(record.SentAt).Milliseconds()
+
+	switch {
+	case err != nil:
+		record.Status = enum.CommandFailed
+		record.Error = err.Error()
+	case resp.Timeout():
+		record.Status = enum.CommandTimeout
+		record.ResponseStatus = resp.Status
+		record.Error = fmt.Sprintf("device did not reply within %s", timeout)
+	case !cmd.ResponseRequired && resp.Status == http.StatusAccepted:
+		record.Status = enum.CommandSent
+		record.ResponseStatus = resp.Status
+	case resp.Status >= 200 && resp.Status < 300:
+		record.Status = enum.CommandSucceeded
+		record.ResponseStatus = resp.Status
+		record.Response = entity.JSON(resp.Payload)
+	default:
+		record.Status = enum.CommandFailed
+		record.ResponseStatus = resp.Status
+		record.Response = entity.JSON(resp.Payload)
+		record.Error = fmt.Sprintf("command failed with status %d", resp.Status)
+	}
+
+	// The outcome is stored even if the request that sent the command was cancelled
+	if err := s.repo.Complete(context.Background(), record); err != nil {
+		s.logger.Error("Failed to store command outcome", zap.String("command_id", record.ID), zap.Error(err))
+	}
+	eventbus.Publish(s.bus, CommandTopic, *record)
+
+	s.logger.Info("Command delivered",
+		zap.String("command_id", record.ID),
+		zap.String("thing_id", record.ThingID),
+		zap.String("feature", record.Feature),
+		zap.String("subject", record.Subject),
+		zap.String("user", record.CreatedBy),
+		zap.String("status", record.Status),
+		zap.Int64("latency_ms", record.LatencyMs),
+	)
+}
+
+// expire periodically fails commands left pending by a stopped process
+func (s *CommandService) expire() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(commandExpiryGrace)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-(s.config.MaxTimeout + commandExpiryGrace))
+		expired, err := s.repo.ExpirePending(s.ctx, cutoff)
+		if err != nil && s.ctx.Err() == nil {
+			s.logger.Warn("Failed to expire pending commands", zap.Error(err))
+		}
+		if expired > 0 {
+			s.logger.Info(fmt.Sprintf("Expired %d pending commands", expired))
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
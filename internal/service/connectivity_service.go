@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ConnectionStatusFeature is the Ditto convention feature reporting device connectivity
+const ConnectionStatusFeature = "ConnectionStatus"
+
+// ConnectivityService tracks when things were last seen and detects offline things
+type ConnectivityService struct {
+	repo        repository.ConnectivityRepository
+	dittoClient *ditto.Client
+	bus         *eventbus.Bus
+	logger      logger.Logger
+	config      config.ConnectivityConfig
+	timeouts    map[string]time.Duration
+
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	status  map[string]struct{}
+	deleted map[string]struct{}
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewConnectivityService creates a connectivity tracker fed by the events of the Ditto service
+func NewConnectivityService(
+	lc fx.Lifecycle,
+	repo repository.ConnectivityRepository,
+	dittoClient *ditto.Client,
+	bus *eventbus.Bus,
+	logger logger.Logger,
+	cfg *config.Config,
+) (*ConnectivityService, error) {
+	timeouts, err := parseDefinitionTimeouts(cfg.Connectivity.DefinitionTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ConnectivityService{
+		repo:        repo,
+		dittoClient: dittoClient,
+		bus:         bus,
+		logger:      logger,
+		config:      cfg.Connectivity,
+		timeouts:    timeouts,
+		seen:        make(map[string]time.Time),
+		status:      make(map[string]struct{}),
+		deleted:     make(map[string]struct{}),
+	}
+	if s.config.FlushInterval <= 0 {
+		s.config.FlushInterval = 5 * time.Second
+	}
+	if s.config.SweepInterval <= 0 {
+		s.config.SweepInterval = 30 * time.Second
+	}
+	if !s.config.Enabled {
+		return s, nil
+	}
+
+	eventbus.Subscribe(bus, ditto.EventTopic, "connectivity", s.handleEvent)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(1)
+			go s.run(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s, nil
+}
+
+// Timeout returns the offline timeout of a thing definition
+func (s *ConnectivityService) Timeout(definition string) time.Duration {
+	if timeout, ok := s.timeouts[definition]; ok {
+		return timeout
+	}
+	return s.config.OfflineTimeout
+}
+
+// Get returns the connectivity of a thing
+func (s *ConnectivityService) Get(ctx context.Context, thingID string) (*entity.Connectivity, error) {
+	return s.repo.GetByID(ctx, thingID)
+}
+
+// List returns a page of thing connectivity and the total number of matching things
+func (s *ConnectivityService) List(ctx context.Context, filter repository.ConnectivityFilter) ([]*entity.Connectivity, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// Counts returns the number of online and offline things per definition
+func (s *ConnectivityService) Counts(ctx context.Context) ([]model.ConnectivityCount, error) {
+	return s.repo.Counts(ctx)
+}
+
+// handleEvent records that a thing was seen without blocking the listener
+func (s *ConnectivityService) handleEvent(event ditto.Event) {
+	thingID := event.ThingID()
+	if thingID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event.IsTwinEvent() && event.Action() == ditto.ActionDeleted && (event.Path == "" || event.Path == "/") {
+		s.deleted[thingID] = struct{}{}
+		delete(s.seen, thingID)
+		delete(s.status, thingID)
+		return
+	}
+
+	s.seen[thingID] = time.Now()
+	if s.config.UseConnectionStatus && touchesConnectionStatus(event) {
+		s.status[thingID] = struct{}{}
+	}
+}
+
+// run flushes last-seen times and sweeps for offline things until ctx is cancelled
+func (s *ConnectivityService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	flush := time.NewTicker(s.config.FlushInterval)
+	defer flush.Stop()
+	sweep := time.NewTicker(s.config.SweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			if err := s.flush(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to store connectivity", zap.Error(err))
+			}
+		case <-sweep.C:
+			if err := s.sweep(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to detect offline things", zap.Error(err))
+			}
+		}
+	}
+}
+
+// flush stores the things seen since the last flush and reports those coming online
+func (s *ConnectivityService) flush(ctx context.Context) error {
+	s.mu.Lock()
+	seen, status, deleted := s.seen, s.status, s.deleted
+	s.seen = make(map[string]time.Time)
+	s.status = make(map[string]struct{})
+	s.deleted = make(map[string]struct{})
+	s.mu.Unlock()
+
+	for thingID := range deleted {
+		if err := s.repo.Delete(ctx, thingID); err != nil {
+			return err
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(seen))
+	for thingID := range seen {
+		ids = append(ids, thingID)
+	}
+	existing, err := s.repo.GetMany(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for thingID, seenAt := range seen {
+		row := existing[thingID]
+		_, statusChanged := status[thingID]
+
+		if row == nil || statusChanged {
+			fetched, err := s.fetch(ctx, thingID, row == nil, statusChanged)
+			if errors.Is(err, ditto.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				s.logger.Warn("Failed to fetch thing connectivity", zap.String("thing_id", thingID), zap.Error(err))
+				if row == nil {
+					continue
+				}
+			} else if row == nil {
+				row = fetched
+			} else {
+				row.ReadySince, row.ReadyUntil = fetched.ReadySince, fetched.ReadyUntil
+			}
+		}
+
+		row.LastSeenAt = seenAt
+		online := true
+		reason := model.ConnectivityReasonEvent
+		if s.config.UseConnectionStatus && row.ReadyUntil != nil {
+			online = row.ReadyUntil.After(now)
+			reason = model.ConnectivityReasonConnectionStatus
+		}
+
+		changed := row.Online != online || row.ChangedAt.IsZero()
+		if changed {
+			row.Online = online
+			row.ChangedAt = now
+		}
+		if err := s.repo.Save(ctx, row); err != nil {
+			return err
+		}
+		if changed {
+			s.emit(row, reason)
+		}
+	}
+	return nil
+}
+
+// sweep marks things offline that were not seen within the timeout of their definition
+func (s *ConnectivityService) sweep(ctx context.Context) error {
+	now := time.Now()
+
+	definitions := make([]string, 0, len(s.timeouts))
+	for definition, timeout := range s.timeouts {
+		definitions = append(definitions, definition)
+		rows, err := s.repo.MarkOffline(ctx, definition, nil, now.Add(-timeout), now)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			s.emit(row, model.ConnectivityReasonTimeout)
+		}
+	}
+
+	rows, err := s.repo.MarkOffline(ctx, "", definitions, now.Add(-s.config.OfflineTimeout), now)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		s.emit(row, model.ConnectivityReasonTimeout)
+	}
+	return nil
+}
+
+// fetch reads the definition of a new thing and its ConnectionStatus feature
+func (s *ConnectivityService) fetch(ctx context.Context, thingID string, definition, status bool) (*entity.Connectivity, error) {
+	fields := []string{"thingId"}
+	if definition {
+		fields = append(fields, "definition")
+	}
+	if status {
+		fields = append(fields, "features/"+ConnectionStatusFeature+"/properties/status")
+	}
+
+	data, err := s.dittoClient.FetchThing(ctx, thingID, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var thing struct {
+		Definition string `json:"definition"`
+		Features   map[string]struct {
+			Properties struct {
+				Status struct {
+					ReadySince *time.Time `json:"readySince"`
+					ReadyUntil *time.Time `json:"readyUntil"`
+				} `json:"status"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &thing); err != nil {
+		return nil, fmt.Errorf("failed to decode thing: %w", err)
+	}
+
+	row := &entity.Connectivity{
+		ThingID:    thingID,
+		Definition: thing.Definition,
+	}
+	if feature, ok := thing.Features[ConnectionStatusFeature]; ok {
+		row.ReadySince = feature.Properties.Status.ReadySince
+		row.ReadyUntil = feature.Properties.Status.ReadyUntil
+	}
+	return row, nil
+}
+
+func (s *ConnectivityService) emit(row *entity.Connectivity, reason string) {
+	change := model.ConnectivityChange{
+		ThingID:    row.ThingID,
+		Online:     row.Online,
+		LastSeenAt: row.LastSeenAt,
+		ChangedAt:  row.ChangedAt,
+		Reason:     reason,
+	}
+
+	s.logger.Info("Connectivity changed",
+		zap.String("thing_id", change.ThingID),
+		zap.Bool("online", change.Online),
+		zap.String("reason", reason),
+	)
+
+	eventbus.Publish(s.bus, ConnectivityTopic, change)
+}
+
+// touchesConnectionStatus reports whether an event may change the ConnectionStatus feature
+func touchesConnectionStatus(event ditto.Event) bool {
+	if !event.IsTwinEvent() || !event.TouchesFeatures() {
+		return false
+	}
+	feature := event.Feature()
+	return feature == "" || feature == ConnectionStatusFeature
+}
+
+// parseDefinitionTimeouts reads comma separated definition=duration pairs
+func parseDefinitionTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid connectivity timeout %q, expected definition=duration", pair)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(pair[i+1:]))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid connectivity timeout %q, expected definition=duration", pair)
+		}
+		timeouts[strings.TrimSpace(pair[:i])] = timeout
+	}
+	return timeouts, nil
+}
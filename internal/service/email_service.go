@@ -0,0 +1,454 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "tls"
+	SMTPTLSNone     = "none"
+
+	defaultAlertSubject = `[{{.Rule.Severity}}] {{.Rule.Name}} {{if eq .Event "alert.resolved"}}resolved{{else}}firing{{end}} on {{.ThingID}}`
+	defaultAlertBody    = `{{.Alert.Message}}
+
+Thing:    {{.ThingID}}
+Feature:  {{.Alert.Feature}}
+{{- with .Alert.Value}}
+Value:    {{.}}{{end}}
+Status:   {{.Alert.Status}}
+Fired at: {{.Alert.FiredAt.Format "2006-01-02 15:04:05 MST"}}
+{{- with .Alert.ResolvedAt}}
+Resolved: {{.Format "2006-01-02 15:04:05 MST"}}{{end}}
+{{- if .Attributes}}
+
+Attributes:
+{{- range $name, $value := .Attributes}}
+  {{$name}}: {{$value}}{{end}}{{end}}
+`
+	defaultOfflineSubject = `{{.ThingID}} is offline`
+	defaultOfflineBody    = `{{.ThingID}} has not been seen since {{.Connectivity.LastSeenAt.Format "2006-01-02 15:04:05 MST"}}.
+{{- if .Attributes}}
+
+Attributes:
+{{- range $name, $value := .Attributes}}
+  {{$name}}: {{$value}}{{end}}{{end}}
+`
+
+	// emailFetchTimeout bounds the lookup of the thing rendered in an email
+	emailFetchTimeout = 5 * time.Second
+	// emailSendTimeout bounds a whole SMTP conversation
+	emailSendTimeout = 30 * time.Second
+)
+
+// EmailData is what email templates are rendered with
+type EmailData struct {
+	Event     string
+	ThingID   string
+	Timestamp time.Time
+	// Alert and Rule are set for alert emails, Connectivity for offline emails
+	Alert        *entity.Alert
+	Rule         *entity.Rule
+	Connectivity *model.ConnectivityChange
+	// Attributes and Features are the current state of the thing, empty when it could not be read
+	Attributes map[string]interface{}
+	Features   map[string]interface{}
+}
+
+// emailTemplates are the subject and body templates of an email kind
+type emailTemplates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// emailJob is a notification waiting to be rendered and sent
+type emailJob struct {
+	key        string
+	recipients []string
+	templates  emailTemplates
+	data       EmailData
+}
+
+// EmailService emails the recipients of a rule when its alerts fire or resolve,
+// and the configured operators when a device goes offline
+type EmailService struct {
+	dittoClient *ditto.Client
+	logger      logger.Logger
+	config      config.EmailConfig
+
+	alertTemplates    emailTemplates
+	offlineTemplates  emailTemplates
+	offlineRecipients []string
+	limiter           *rate.Limiter
+
+	jobs chan emailJob
+
+	throttleMu sync.Mutex
+	lastSent   map[string]time.Time
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewEmailService creates an SMTP channel fed by alerts and connectivity changes
+func NewEmailService(
+	lc fx.Lifecycle,
+	dittoClient *ditto.Client,
+	bus *eventbus.Bus,
+	logger logger.Logger,
+	cfg *config.Config,
+) (*EmailService, error) {
+	s := &EmailService{
+		dittoClient: dittoClient,
+		logger:      logger,
+		config:      cfg.Email,
+		lastSent:    make(map[string]time.Time),
+	}
+	if !s.config.Enabled {
+		return s, nil
+	}
+
+	if s.config.Host == "" || s.config.From == "" {
+		return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required when email is enabled")
+	}
+	if _, err := mail.ParseAddress(s.config.From); err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+	switch s.config.TLSMode {
+	case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		return nil, fmt.Errorf("SMTP_TLS_MODE must be one of %s, %s, %s", SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone)
+	}
+	for _, recipient := range strings.Split(s.config.OfflineRecipients, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return nil, fmt.Errorf("invalid EMAIL_OFFLINE_RECIPIENTS entry %q: %w", recipient, err)
+		}
+		s.offlineRecipients = append(s.offlineRecipients, recipient)
+	}
+
+	var err error
+	if s.alertTemplates, err = newEmailTemplates(s.config.AlertSubject, s.config.AlertBody, defaultAlertSubject, defaultAlertBody); err != nil {
+		return nil, err
+	}
+	if s.offlineTemplates, err = newEmailTemplates(s.config.OfflineSubject, s.config.OfflineBody, defaultOfflineSubject, defaultOfflineBody); err != nil {
+		return nil, err
+	}
+
+	if s.config.MaxPerMinute <= 0 {
+		s.config.MaxPerMinute = 60
+	}
+	if s.config.QueueSize <= 0 {
+		s.config.QueueSize = 1000
+	}
+	s.limiter = rate.NewLimiter(rate.Limit(float64(s.config.MaxPerMinute)/60), 1)
+	s.jobs = make(chan emailJob, s.config.QueueSize)
+
+	eventbus.Subscribe(bus, AlertTopic, "email", func(e AlertEvent) {
+		s.handleAlert(&e.Alert, &e.Rule)
+	})
+	eventbus.Subscribe(bus, ConnectivityTopic, "email", s.handleConnectivity)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(1)
+			go s.run(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s, nil
+}
+
+// handleAlert queues an email to the recipients of the rule when an alert fires or resolves
+func (s *EmailService) handleAlert(alert *entity.Alert, rule *entity.Rule) {
+	if alert.Status == enum.AlertAcknowledged {
+		return
+	}
+	var recipients []string
+	if err := rule.Recipients.Decode(&recipients); err != nil || len(recipients) == 0 {
+		return
+	}
+
+	templates := s.alertTemplates
+	if rule.EmailSubject != "" || rule.EmailBody != "" {
+		var err error
+		templates, err = newEmailTemplates(rule.EmailSubject, rule.EmailBody, defaultAlertSubject, defaultAlertBody)
+		if err != nil {
+			s.logger.Error("Invalid email template of rule", zap.String("rule", rule.ID), zap.Error(err))
+			return
+		}
+	}
+
+	event := newAlertEvent(alert, rule)
+	s.enqueue(emailJob{
+		key:        event.Type + "/" + rule.ID + "/" + alert.ThingID,
+		recipients: recipients,
+		templates:  templates,
+		data: EmailData{
+			Event:     event.Type,
+			ThingID:   alert.ThingID,
+			Timestamp: event.Timestamp,
+			Alert:     alert,
+			Rule:      rule,
+		},
+	})
+}
+
+// handleConnectivity queues an email to the operators when a device goes offline
+func (s *EmailService) handleConnectivity(change model.ConnectivityChange) {
+	if change.Online || len(s.offlineRecipients) == 0 {
+		return
+	}
+
+	s.enqueue(emailJob{
+		key:        model.EventDeviceOffline + "/" + change.ThingID,
+		recipients: s.offlineRecipients,
+		templates:  s.offlineTemplates,
+		data: EmailData{
+			Event:        model.EventDeviceOffline,
+			ThingID:      change.ThingID,
+			Timestamp:    change.ChangedAt,
+			Connectivity: &change,
+		},
+	})
+}
+
+// enqueue queues an email without blocking the caller
+func (s *EmailService) enqueue(job emailJob) {
+	select {
+	case s.jobs <- job:
+	default:
+		s.logger.Warn("Email queue is full, dropping notification",
+			zap.String("event", job.data.Event), zap.String("thingId", job.data.ThingID))
+	}
+}
+
+// run renders and sends queued emails until ctx is cancelled
+func (s *EmailService) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.jobs:
+			if !s.allow(job.key, time.Now()) {
+				s.logger.Debug("Email throttled", zap.String("key", job.key))
+				continue
+			}
+			if err := s.limiter.Wait(ctx); err != nil {
+				return
+			}
+			if err := s.deliver(ctx, job); err != nil && ctx.Err() == nil {
+				s.logger.Error("Failed to send email",
+					zap.String("event", job.data.Event), zap.String("thingId", job.data.ThingID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// allow tells whether an email with the given key may be sent, and records it if so
+func (s *EmailService) allow(key string, now time.Time) bool {
+	s.throttleMu.Lock()
+	defer s.throttleMu.Unlock()
+
+	if last, ok := s.lastSent[key]; ok && now.Sub(last) < s.config.Throttle {
+		return false
+	}
+	s.lastSent[key] = now
+
+	// Forget keys that can no longer throttle anything
+	if len(s.lastSent) > 10000 {
+		for k, last := range s.lastSent {
+			if now.Sub(last) >= s.config.Throttle {
+				delete(s.lastSent, k)
+			}
+		}
+	}
+	return true
+}
+
+// deliver renders an email with the current state of its thing and sends it
+func (s *EmailService) deliver(ctx context.Context, job emailJob) error {
+	fetchCtx, cancel := context.WithTimeout(ctx, emailFetchTimeout)
+	thing, err := s.dittoClient.FetchThing(fetchCtx, job.data.ThingID, []string{"attributes", "features"})
+	cancel()
+	if err == nil {
+		var state struct {
+			Attributes map[string]interface{} `json:"attributes"`
+			Features   map[string]interface{} `json:"features"`
+		}
+		if json.Unmarshal(thing, &state) == nil {
+			job.data.Attributes = state.Attributes
+			job.data.Features = state.Features
+		}
+	}
+
+	var subject, body bytes.Buffer
+	if err := job.templates.subject.Execute(&subject, job.data); err != nil {
+		return fmt.Errorf("failed to render email subject: %w", err)
+	}
+	if err := job.templates.body.Execute(&body, job.data); err != nil {
+		return fmt.Errorf("failed to render email body: %w", err)
+	}
+
+	message := s.compose(job.recipients, strings.TrimSpace(subject.String()), body.String())
+	return s.send(ctx, job.recipients, message)
+}
+
+// compose builds a plain text MIME message
+func (s *EmailService) compose(recipients []string, subject, body string) []byte {
+	from, _ := mail.ParseAddress(s.config.From)
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domain)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+// send runs an SMTP conversation with the configured server
+func (s *EmailService) send(ctx context.Context, recipients []string, message []byte) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	tlsConfig := &tls.Config{
+		ServerName:         s.config.Host,
+		InsecureSkipVerify: s.config.InsecureSkipVerify,
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if s.config.TLSMode == SMTPTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(emailSendTimeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if s.config.TLSMode == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(s.config.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	for _, recipient := range recipients {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s failed: %w", address.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// newEmailTemplates parses subject and body templates, falling back to the defaults when empty
+func newEmailTemplates(subject, body, defaultSubject, defaultBody string) (emailTemplates, error) {
+	if subject == "" {
+		subject = defaultSubject
+	}
+	if body == "" {
+		body = defaultBody
+	}
+
+	var templates emailTemplates
+	var err error
+	if templates.subject, err = parseEmailTemplate("subject", subject); err != nil {
+		return templates, err
+	}
+	if templates.body, err = parseEmailTemplate("body", body); err != nil {
+		return templates, err
+	}
+	return templates, nil
+}
+
+// parseEmailTemplate parses an email Go template, missing keys render as empty
+func parseEmailTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
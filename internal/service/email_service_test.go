@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// smtpMessage is an email received by the test SMTP server
+type smtpMessage struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// smtpServer is a minimal in-process SMTP server without extensions. Recipients
+// listed in reject are refused with a 550.
+type smtpServer struct {
+	listener net.Listener
+	messages chan smtpMessage
+	reject   map[string]bool
+	wg       sync.WaitGroup
+}
+
+func newSMTPServer(t *testing.T, reject ...string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{
+		listener: listener,
+		messages: make(chan smtpMessage, 10),
+		reject:   make(map[string]bool),
+	}
+	for _, address := range reject {
+		s.reject[address] = true
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// serve speaks just enough SMTP for net/smtp to send a message
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	text := textproto.NewConn(conn)
+
+	var msg smtpMessage
+	_ = text.PrintfLine("220 localhost test SMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			msg = smtpMessage{from: smtpAddress(line)}
+			_ = text.PrintfLine("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			address := smtpAddress(line)
+			if s.reject[address] {
+				_ = text.PrintfLine("550 No such user")
+				continue
+			}
+			msg.recipients = append(msg.recipients, address)
+			_ = text.PrintfLine("250 OK")
+		case verb == "DATA":
+			_ = text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.messages <- msg
+			_ = text.PrintfLine("250 OK")
+		case verb == "RSET":
+			msg = smtpMessage{}
+			_ = text.PrintfLine("250 OK")
+		case verb == "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			return
+		default:
+			_ = text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// smtpAddress returns the address of a MAIL FROM or RCPT TO command
+func smtpAddress(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func (s *smtpServer) receive(t *testing.T) smtpMessage {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an email to be sent")
+		return smtpMessage{}
+	}
+}
+
+func newTestEmailConfig(smtp *smtpServer) *config.Config {
+	return &config.Config{Email: config.EmailConfig{
+		Enabled:           true,
+		Host:              "127.0.0.1",
+		Port:              smtp.port(),
+		From:              "Ditto Alerts <alerts@example.com>",
+		TLSMode:           SMTPTLSNone,
+		Throttle:          time.Minute,
+		MaxPerMinute:      6000,
+		OfflineRecipients: "ops@example.com",
+	}}
+}
+
+// newTestDittoClient serves a thing with a location attribute to the emails rendered
+func newTestDittoClient(t *testing.T) *ditto.Client {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/2/things/org:dev" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"attributes":{"location":"hall 3"},"features":{}}`))
+	}))
+	t.Cleanup(upstream.Close)
+	return ditto.NewClient(upstream.URL, "ditto", "ditto")
+}
+
+func TestEmailServiceSendsNotifications(t *testing.T) {
+	smtp := newSMTPServer(t)
+	cfg := newTestEmailConfig(smtp)
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	bus := eventbus.NewBus(lc, log, cfg)
+
+	if _, err := NewEmailService(lc, newTestDittoClient(t), bus, log, cfg); err != nil {
+		t.Fatal(err)
+	}
+	lc.RequireStart()
+	defer lc.RequireStop()
+
+	recipients, _ := entity.NewJSON([]string{"Jane Doe <jane@example.com>", "oncall@example.com"})
+	value := 92.5
+	eventbus.Publish(bus, AlertTopic, AlertEvent{
+		Alert: entity.Alert{
+			ID:      "a1",
+			ThingID: "org:dev",
+			Feature: "temperature",
+			Status:  enum.AlertFiring,
+			Value:   &value,
+			Message: "temperature above 90",
+			FiredAt: time.Now(),
+		},
+		Rule: entity.Rule{ID: "r1", Name: "Overheat", Severity: "critical", Recipients: recipients},
+	})
+
+	msg := smtp.receive(t)
+	if msg.from != "alerts@example.com" {
+		t.Errorf("expected the envelope sender alerts@example.com, got %s", msg.from)
+	}
+	if strings.Join(msg.recipients, ",") != "jane@example.com,oncall@example.com" {
+		t.Errorf("unexpected envelope recipients %v", msg.recipients)
+	}
+	for _, want := range []string{
+		`From: "Ditto Alerts" <alerts@example.com>`,
+		"To: Jane Doe <jane@example.com>, oncall@example.com",
+		"Subject: [critical] Overheat firing on org:dev",
+		"Content-Type: text/plain; charset=utf-8",
+		"temperature above 90",
+		"Value:    92.5",
+		// The current state of the thing is fetched from Ditto
+		"location: hall 3",
+	} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("expected the email to contain %q, got:\n%s", want, msg.data)
+		}
+	}
+
+	// Offline devices are reported to the operators
+	eventbus.Publish(bus, ConnectivityTopic, model.ConnectivityChange{
+		ThingID:    "org:dev",
+		Online:     false,
+		LastSeenAt: time.Now().Add(-time.Hour),
+		ChangedAt:  time.Now(),
+	})
+	msg = smtp.receive(t)
+	if strings.Join(msg.recipients, ",") != "ops@example.com" || !strings.Contains(msg.data, "Subject: org:dev is offline") {
+		t.Errorf("unexpected offline email to %v:\n%s", msg.recipients, msg.data)
+	}
+}
+
+func TestEmailServiceSendFailure(t *testing.T) {
+	smtp := newSMTPServer(t, "nobody@example.com")
+	cfg := newTestEmailConfig(smtp)
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	s, err := NewEmailService(lc, nil, eventbus.NewBus(lc, log, cfg), log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := s.compose([]string{"nobody@example.com"}, "Test", "body")
+	err = s.send(context.Background(), []string{"nobody@example.com"}, message)
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO nobody@example.com") {
+		t.Fatalf("expected the refused recipient to fail the email, got %v", err)
+	}
+
+	// STARTTLS is required unless turned off
+	s.config.TLSMode = SMTPTLSStartTLS
+	err = s.send(context.Background(), []string{"ops@example.com"}, message)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected a server without STARTTLS to be refused, got %v", err)
+	}
+
+	s.config.Port = 1
+	s.config.TLSMode = SMTPTLSNone
+	if err := s.send(context.Background(), []string{"ops@example.com"}, message); err == nil {
+		t.Fatal("expected an unreachable server to fail the email")
+	}
+}
+
+func TestEmailServiceThrottle(t *testing.T) {
+	s := &EmailService{config: config.EmailConfig{Throttle: time.Minute}, lastSent: make(map[string]time.Time)}
+	now := time.Now()
+
+	if !s.allow("alert.fired/r1/org:dev", now) {
+		t.Fatal("expected the first email to be allowed")
+	}
+	if s.allow("alert.fired/r1/org:dev", now.Add(30*time.Second)) {
+		t.Error("expected a second email within the throttle to be refused")
+	}
+	if !s.allow("alert.fired/r1/org:other", now.Add(30*time.Second)) {
+		t.Error("expected another thing not to be throttled")
+	}
+	if !s.allow("alert.fired/r1/org:dev", now.Add(time.Minute)) {
+		t.Error("expected an email after the throttle to be allowed")
+	}
+}
+
+func TestNewEmailServiceValidatesConfig(t *testing.T) {
+	tests := map[string]func(*config.EmailConfig){
+		"missing host":      func(c *config.EmailConfig) { c.Host = "" },
+		"invalid from":      func(c *config.EmailConfig) { c.From = "not an address" },
+		"invalid TLS mode":  func(c *config.EmailConfig) { c.TLSMode = "ssl" },
+		"invalid recipient": func(c *config.EmailConfig) { c.OfflineRecipients = "ops@example.com, nope" },
+		"invalid subject":   func(c *config.EmailConfig) { c.AlertSubject = "{{.Rule" },
+		"invalid body":      func(c *config.EmailConfig) { c.OfflineBody = "{{end}}" },
+		"valid":             nil,
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{Email: config.EmailConfig{
+				Enabled: true,
+				Host:    "smtp.example.com",
+				Port:    587,
+				From:    "alerts@example.com",
+				TLSMode: SMTPTLSStartTLS,
+			}}
+			if mutate != nil {
+				mutate(&cfg.Email)
+			}
+			lc := fxtest.NewLifecycle(t)
+			log := logger.NewLogger(cfg)
+			_, err := NewEmailService(lc, nil, eventbus.NewBus(lc, log, cfg), log, cfg)
+			if (err != nil) != (mutate != nil) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
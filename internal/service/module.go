@@ -2,4 +2,22 @@ package service
 
 import "go.uber.org/fx"
 
-var Module = fx.Options()
+var Module = fx.Options(
+	fx.Provide(
+		NewProvisioningService,
+		NewBulkUpdateService,
+		NewCommandService,
+		NewScheduleService,
+		NewBroadcastService,
+		NewReconcileService,
+		NewConnectivityService,
+		NewAlertService,
+		NewRuleService,
+		NewWebhookService,
+		NewEmailService,
+		NewStreamService,
+		NewAuthService,
+	),
+	// Notification channels have no API, they only listen to events
+	fx.Invoke(func(*EmailService) {}),
+)
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"ditto/internal/ditto"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+
+	"github.com/google/uuid"
+)
+
+// alertEventData is the data of an alert notification
+type alertEventData struct {
+	AlertID        string     `json:"alertId"`
+	RuleID         string     `json:"ruleId"`
+	RuleName       string     `json:"ruleName"`
+	Kind           string     `json:"kind"`
+	Severity       string     `json:"severity"`
+	Status         string     `json:"status"`
+	Value          *float64   `json:"value,omitempty"`
+	Message        string     `json:"message"`
+	FiredAt        time.Time  `json:"firedAt"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+}
+
+// twinEventData is the data of a twin notification, the Ditto event without its headers
+type twinEventData struct {
+	Topic    string          `json:"topic"`
+	Path     string          `json:"path"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Revision int64           `json:"revision,omitempty"`
+}
+
+// newAlertEvent converts an alert change to a notification event
+func newAlertEvent(alert *entity.Alert, rule *entity.Rule) model.NotificationEvent {
+	eventType := model.EventAlertFired
+	timestamp := alert.FiredAt
+	switch alert.Status {
+	case enum.AlertResolved:
+		eventType = model.EventAlertResolved
+		if alert.ResolvedAt != nil {
+			timestamp = *alert.ResolvedAt
+		}
+	case enum.AlertAcknowledged:
+		eventType = model.EventAlertAcknowledged
+		if alert.AcknowledgedAt != nil {
+			timestamp = *alert.AcknowledgedAt
+		}
+	}
+
+	data, _ := json.Marshal(alertEventData{
+		AlertID:        alert.ID,
+		RuleID:         rule.ID,
+		RuleName:       rule.Name,
+		Kind:           rule.Kind,
+		Severity:       alert.Severity,
+		Status:         alert.Status,
+		Value:          alert.Value,
+		Message:        alert.Message,
+		FiredAt:        alert.FiredAt,
+		ResolvedAt:     alert.ResolvedAt,
+		AcknowledgedBy: alert.AcknowledgedBy,
+	})
+	return model.NotificationEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		ThingID:   alert.ThingID,
+		Feature:   alert.Feature,
+		Timestamp: timestamp,
+		Data:      data,
+	}
+}
+
+// newConnectivityEvent converts a connectivity change to a notification event
+func newConnectivityEvent(change model.ConnectivityChange) model.NotificationEvent {
+	eventType := model.EventDeviceOffline
+	if change.Online {
+		eventType = model.EventDeviceOnline
+	}
+
+	data, _ := json.Marshal(change)
+	return model.NotificationEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		ThingID:   change.ThingID,
+		Timestamp: change.ChangedAt,
+		Data:      data,
+	}
+}
+
+// newTwinEvent converts a Ditto twin event to a notification event, ok is false
+// for events that are not twin events
+func newTwinEvent(event ditto.Event) (model.NotificationEvent, bool) {
+	if !event.IsTwinEvent() {
+		return model.NotificationEvent{}, false
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	data, _ := json.Marshal(twinEventData{
+		Topic:    event.Topic,
+		Path:     event.Path,
+		Value:    event.Value,
+		Revision: event.Revision,
+	})
+	return model.NotificationEvent{
+		ID:        uuid.NewString(),
+		Type:      "twin." + event.Action(),
+		ThingID:   event.ThingID(),
+		Feature:   event.Feature(),
+		Timestamp: timestamp,
+		Data:      data,
+	}, true
+}
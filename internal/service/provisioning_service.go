@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"ditto/internal/ditto"
+	"ditto/internal/job"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+)
+
+const (
+	// ProvisioningJobType identifies provisioning jobs
+	ProvisioningJobType = "device.provisioning"
+
+	// DefaultProvisioningConcurrency is the number of devices created in parallel
+	DefaultProvisioningConcurrency = 8
+	// MaxProvisioningConcurrency caps the concurrency a request may ask for
+	MaxProvisioningConcurrency = 32
+	// MaxProvisioningRows caps the number of devices of a single request
+	MaxProvisioningRows = 5000
+
+	csvAttributePrefix = "attributes/"
+)
+
+// ProvisioningService creates devices in bulk
+type ProvisioningService struct {
+	dittoClient *ditto.Client
+	jobs        *job.Manager
+	logger      logger.Logger
+}
+
+// NewProvisioningService creates a new provisioning service and registers its job handler
+func NewProvisioningService(dittoClient *ditto.Client, jobs *job.Manager, logger logger.Logger) *ProvisioningService {
+	s := &ProvisioningService{
+		dittoClient: dittoClient,
+		jobs:        jobs,
+		logger:      logger,
+	}
+	jobs.Register(ProvisioningJobType, s.run)
+	return s
+}
+
+// ParseProvisioningJSON reads rows from a JSON array
+func ParseProvisioningJSON(r io.Reader) ([]model.ProvisioningRow, error) {
+	var rows []model.ProvisioningRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %w", err)
+	}
+	return rows, nil
+}
+
+// ParseProvisioningCSV reads rows from CSV with a header line. Supported columns are
+// thingId, policyId, definition, attributes and features (the latter two as JSON objects)
+// and attributes/<name> columns which set a single string attribute.
+func ParseProvisioningCSV(r io.Reader) ([]model.ProvisioningRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	var rows []model.ProvisioningRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+
+		var row model.ProvisioningRow
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				continue
+			}
+
+			switch column = strings.TrimSpace(column); {
+			case column == "thingId":
+				row.ThingID = value
+			case column == "policyId":
+				row.PolicyID = value
+			case column == "definition":
+				row.Definition = value
+			case column == "attributes":
+				if err := json.Unmarshal([]byte(value), &row.Attributes); err != nil {
+					return nil, fmt.Errorf("invalid attributes JSON on CSV line %d: %w", line, err)
+				}
+			case column == "features":
+				if err := json.Unmarshal([]byte(value), &row.Features); err != nil {
+					return nil, fmt.Errorf("invalid features JSON on CSV line %d: %w", line, err)
+				}
+			case strings.HasPrefix(column, csvAttributePrefix):
+				if row.Attributes == nil {
+					row.Attributes = make(map[string]interface{})
+				}
+				row.Attributes[strings.TrimPrefix(column, csvAttributePrefix)] = value
+			default:
+				return nil, fmt.Errorf("unknown CSV column: %s", column)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Validate checks all rows before anything is created. It returns one result per
+// row and whether all rows are valid. Rows without a policyId use the thingId.
+func (s *ProvisioningService) Validate(rows []model.ProvisioningRow) ([]model.ProvisioningRowResult, bool) {
+	results := make([]model.ProvisioningRowResult, len(rows))
+	seen := make(map[string]int, len(rows))
+	valid := true
+
+	for i := range rows {
+		row := &rows[i]
+		if row.PolicyID == "" {
+			row.PolicyID = row.ThingID
+		}
+
+		result := model.ProvisioningRowResult{Row: i + 1, ThingID: row.ThingID, Status: model.RowValid}
+		switch {
+		case row.ThingID == "":
+			result.Error = "thingId is required"
+		case ditto.ValidateEntityID(row.ThingID) != nil:
+			result.Error = ditto.ValidateEntityID(row.ThingID).Error()
+		case ditto.ValidateEntityID(row.PolicyID) != nil:
+			result.Error = ditto.ValidateEntityID(row.PolicyID).Error()
+		case seen[row.ThingID] > 0:
+			result.Error = fmt.Sprintf("duplicate thingId, first seen on row %d", seen[row.ThingID])
+		}
+
+		if result.Error != "" {
+			result.Status = model.RowInvalid
+			valid = false
+		} else {
+			seen[row.ThingID] = i + 1
+		}
+		results[i] = result
+	}
+
+	return results, valid
+}
+
+// Start submits a provisioning job for validated rows
+func (s *ProvisioningService) Start(ctx context.Context, rows []model.ProvisioningRow, concurrency int, user string) (*entity.Job, error) {
+	if concurrency <= 0 {
+		concurrency = DefaultProvisioningConcurrency
+	}
+	if concurrency > MaxProvisioningConcurrency {
+		concurrency = MaxProvisioningConcurrency
+	}
+
+	payload := provisioningPayload{
+		Rows:        rows,
+		Concurrency: concurrency,
+	}
+	return s.jobs.Submit(ctx, ProvisioningJobType, payload, job.WithCreatedBy(user))
+}
+
+// Get returns a provisioning job
+func (s *ProvisioningService) Get(ctx context.Context, jobID string) (*entity.Job, error) {
+	j, err := s.jobs.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if j.Type != ProvisioningJobType {
+		return nil, repository.ErrNotFound
+	}
+	return j, nil
+}
+
+// run creates the policies and things of a job with bounded concurrency
+func (s *ProvisioningService) run(ctx context.Context, task *job.Task) (interface{}, error) {
+	var payload provisioningPayload
+	if err := task.Decode(&payload); err != nil {
+		return nil, job.Permanent(fmt.Errorf("invalid provisioning payload: %w", err))
+	}
+	rows := payload.Rows
+	task.SetTotal(len(rows))
+
+	report := &model.ProvisioningReport{
+		Results: make([]model.ProvisioningRowResult, len(rows)),
+	}
+	for i, row := range rows {
+		report.Results[i] = model.ProvisioningRowResult{Row: i + 1, ThingID: row.ThingID, Status: model.RowPending}
+	}
+
+	// Rows sharing a policy must wait for it, so each policy is created once
+	var policyMu sync.Mutex
+	policies := make(map[string]*policyResult)
+	ensurePolicy := func(ctx context.Context, policyID string) error {
+		policyMu.Lock()
+		result, ok := policies[policyID]
+		if !ok {
+			result = &policyResult{}
+			policies[policyID] = result
+		}
+		policyMu.Unlock()
+
+		result.once.Do(func() {
+			result.err = s.dittoClient.CreatePolicy(ctx, policyID)
+		})
+		return result.err
+	}
+
+	var reportMu sync.Mutex
+	sem := make(chan struct{}, payload.Concurrency)
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, row model.ProvisioningRow) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := s.provision(ctx, row, ensurePolicy)
+
+			reportMu.Lock()
+			if err != nil {
+				report.Results[i].Status = model.RowFailed
+				report.Results[i].Error = err.Error()
+				report.Failed++
+			} else {
+				report.Results[i].Status = model.RowCreated
+				report.Succeeded++
+			}
+			reportMu.Unlock()
+			task.Add(1)
+		}(i, row)
+	}
+	wg.Wait()
+
+	s.logger.Info(fmt.Sprintf("Provisioning job %s finished: %d created, %d failed", task.ID, report.Succeeded, report.Failed))
+
+	return report, ctx.Err()
+}
+
+// provision creates the policy and thing of a single row
+func (s *ProvisioningService) provision(ctx context.Context, row model.ProvisioningRow, ensurePolicy func(context.Context, string) error) error {
+	if err := ensurePolicy(ctx, row.PolicyID); err != nil {
+		return fmt.Errorf("failed to create policy %s: %w", row.PolicyID, err)
+	}
+
+	thing := map[string]interface{}{
+		"thingId":  row.ThingID,
+		"policyId": row.PolicyID,
+	}
+	if row.Definition != "" {
+		thing["definition"] = row.Definition
+	}
+	if row.Attributes != nil {
+		thing["attributes"] = row.Attributes
+	}
+	if row.Features != nil {
+		thing["features"] = row.Features
+	}
+
+	payload, err := json.Marshal(thing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal thing: %w", err)
+	}
+
+	if err := s.dittoClient.CreateThing(ctx, row.ThingID, payload); err != nil {
+		return fmt.Errorf("failed to create thing: %w", err)
+	}
+	return nil
+}
+
+// provisioningPayload is the stored input of a provisioning job
+type provisioningPayload struct {
+	Rows        []model.ProvisioningRow `json:"rows"`
+	Concurrency int                     `json:"concurrency"`
+}
+
+type policyResult struct {
+	once sync.Once
+	err  error
+}
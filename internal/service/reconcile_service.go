@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReconcilerUser is recorded as the sender of commands resent to converge devices
+	ReconcilerUser = "reconciler"
+
+	// reconcileResendBatch is the number of drifts resent per tick
+	reconcileResendBatch = 50
+	// reconcileDebounce batches bursts of events for the same things
+	reconcileDebounce = 500 * time.Millisecond
+)
+
+// ReconcileService compares the desired and reported properties of features on every
+// twin change and resends the desired values to devices that did not converge in time
+type ReconcileService struct {
+	repo           repository.DriftRepository
+	dittoClient    *ditto.Client
+	commandService *CommandService
+	logger         logger.Logger
+	config         config.ReconcileConfig
+
+	mu    sync.Mutex
+	dirty map[string]struct{}
+	wake  chan struct{}
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewReconcileService creates a reconciler watching the twin events of the Ditto service
+func NewReconcileService(
+	lc fx.Lifecycle,
+	repo repository.DriftRepository,
+	dittoClient *ditto.Client,
+	bus *eventbus.Bus,
+	commandService *CommandService,
+	logger logger.Logger,
+	cfg *config.Config,
+) *ReconcileService {
+	s := &ReconcileService{
+		repo:           repo,
+		dittoClient:    dittoClient,
+		commandService: commandService,
+		logger:         logger,
+		config:         cfg.Reconcile,
+		dirty:          make(map[string]struct{}),
+		wake:           make(chan struct{}, 1),
+	}
+	if s.config.Interval <= 0 {
+		s.config.Interval = 30 * time.Second
+	}
+	if !s.config.Enabled {
+		return s
+	}
+
+	eventbus.Subscribe(bus, ditto.EventTopic, "reconcile", s.handleEvent)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(2)
+			go s.watch(ctx)
+			go s.resend(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s
+}
+
+// DeviceDrift returns the drift state of every feature of a thing, re-evaluated first if refresh is set.
+// The user must be able to read the thing to refresh it, the twin is then evaluated as the service
+// does on events: what the user cannot see must not converge, nor a thing hidden from them be dropped.
+func (s *ReconcileService) DeviceDrift(ctx context.Context, thingID string, refresh bool) ([]*entity.Drift, error) {
+	if refresh {
+		if _, err := s.dittoClient.FetchThing(ctx, thingID, []string{"thingId"}); err != nil {
+			return nil, err
+		}
+		if err := s.evaluate(ditto.WithoutIdentity(ctx), thingID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListByThing(ctx, thingID)
+}
+
+// List returns a page of drifts across the fleet and the total number of matching drifts
+func (s *ReconcileService) List(ctx context.Context, filter repository.DriftFilter) ([]*entity.Drift, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// handleEvent marks the thing of a feature change for evaluation without blocking the listener
+func (s *ReconcileService) handleEvent(event ditto.Event) {
+	if !event.IsTwinEvent() || !event.TouchesFeatures() {
+		return
+	}
+	thingID := event.ThingID()
+	if thingID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	s.dirty[thingID] = struct{}{}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// watch evaluates the things changed by twin events
+func (s *ReconcileService) watch(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+
+		// Let a burst of events settle before fetching the things
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconcileDebounce):
+		}
+
+		s.mu.Lock()
+		things := s.dirty
+		s.dirty = make(map[string]struct{})
+		s.mu.Unlock()
+
+		for thingID := range things {
+			if err := s.evaluate(ctx, thingID); err != nil && ctx.Err() == nil {
+				s.logger.Warn("Failed to evaluate drift", zap.String("thing_id", thingID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// evaluate compares the desired and reported properties of a thing and stores the drift per feature
+func (s *ReconcileService) evaluate(ctx context.Context, thingID string) error {
+	data, err := s.dittoClient.FetchThing(ctx, thingID, []string{"thingId", "features"})
+	if errors.Is(err, ditto.ErrNotFound) {
+		return s.repo.DeleteByThing(ctx, thingID)
+	}
+	if err != nil {
+		return err
+	}
+
+	var thing model.Thing
+	if err := json.Unmarshal(data, &thing); err != nil {
+		return fmt.Errorf("failed to decode thing: %w", err)
+	}
+
+	drifts, err := s.repo.ListByThing(ctx, thingID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*entity.Drift, len(drifts))
+	for _, drift := range drifts {
+		existing[drift.Feature] = drift
+	}
+
+	now := time.Now()
+	for name, feature := range thing.Features {
+		drift := existing[name]
+		delete(existing, name)
+
+		desired, reported := diffDesired(feature)
+		if len(desired) == 0 {
+			if drift != nil && drift.Status != enum.DriftConverged {
+				if err := s.converge(ctx, drift, now); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		desiredJSON, err := entity.NewJSON(desired)
+		if err != nil {
+			return err
+		}
+		reportedJSON, err := entity.NewJSON(reported)
+		if err != nil {
+			return err
+		}
+
+		detected := true
+		switch {
+		case drift == nil:
+			drift = &entity.Drift{ID: uuid.NewString(), ThingID: thingID, Feature: name}
+		case drift.Status == enum.DriftConverged:
+		case drift.Status == enum.DriftStuck && !jsonEqual(drift.Desired, desiredJSON):
+			// A new desired state gets a fresh set of resends
+		default:
+			detected = false
+		}
+		if detected {
+			resetDrift(drift, now)
+			s.logger.Info("Drift detected", zap.String("thing_id", thingID), zap.String("feature", name))
+		}
+
+		drift.Desired = desiredJSON
+		drift.Reported = reportedJSON
+		drift.CheckedAt = now
+		if err := s.repo.Save(ctx, drift); err != nil {
+			return err
+		}
+	}
+
+	// Features that were removed no longer drift
+	for _, drift := range existing {
+		if drift.Status != enum.DriftConverged {
+			if err := s.converge(ctx, drift, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ReconcileService) converge(ctx context.Context, drift *entity.Drift, now time.Time) error {
+	drift.Status = enum.DriftConverged
+	drift.ConvergedAt = &now
+	drift.CheckedAt = now
+	drift.Desired = nil
+	drift.Reported = nil
+	s.logger.Info("Drift converged", zap.String("thing_id", drift.ThingID), zap.String("feature", drift.Feature))
+	return s.repo.Save(ctx, drift)
+}
+
+// resend periodically resends the desired values of features drifting longer than the grace period
+func (s *ReconcileService) resend(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := time.Now().Add(-s.config.GracePeriod)
+		stuck, err := s.repo.MarkStuck(ctx, before, s.config.MaxResends)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to mark stuck drifts", zap.Error(err))
+		}
+		if stuck > 0 {
+			s.logger.Warn(fmt.Sprintf("%d features did not converge after %d resends", stuck, s.config.MaxResends))
+		}
+
+		due, err := s.repo.ClaimResends(ctx, before, s.config.MaxResends, reconcileResendBatch)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to claim drift resends", zap.Error(err))
+			continue
+		}
+		for _, drift := range due {
+			s.resendDrift(ctx, drift)
+		}
+	}
+}
+
+// resendDrift checks the drift is still current and sends the desired values to the device
+func (s *ReconcileService) resendDrift(ctx context.Context, drift *entity.Drift) {
+	// Events may have been missed, so the twin is checked before bothering the device
+	if err := s.evaluate(ctx, drift.ThingID); err != nil {
+		s.logger.Warn("Failed to evaluate drift", zap.String("thing_id", drift.ThingID), zap.Error(err))
+		return
+	}
+	drifts, err := s.repo.ListByThing(ctx, drift.ThingID)
+	if err != nil {
+		s.logger.Warn("Failed to load drift", zap.String("thing_id", drift.ThingID), zap.Error(err))
+		return
+	}
+
+	for _, current := range drifts {
+		if current.ID != drift.ID || current.Status != enum.DriftDrifting {
+			continue
+		}
+
+		command, err := s.commandService.SendAsync(ctx, model.Command{
+			ThingID:          current.ThingID,
+			Feature:          current.Feature,
+			Subject:          s.config.Subject,
+			Params:           json.RawMessage(current.Desired),
+			ResponseRequired: true,
+		}, ReconcilerUser)
+		if err != nil {
+			s.logger.Error("Failed to resend desired properties", zap.String("thing_id", current.ThingID), zap.Error(err))
+			return
+		}
+		if err := s.repo.SetCommand(ctx, current.ID, command.ID); err != nil {
+			s.logger.Warn("Failed to store drift command", zap.String("drift_id", current.ID), zap.Error(err))
+		}
+
+		s.logger.Info("Resent desired properties",
+			zap.String("thing_id", current.ThingID),
+			zap.String("feature", current.Feature),
+			zap.Int("resends", current.Resends),
+			zap.String("command_id", command.ID),
+		)
+	}
+}
+
+// diffDesired returns the desired properties whose reported value differs, with the reported values
+func diffDesired(feature model.Feature) (desired, reported map[string]interface{}) {
+	desired = make(map[string]interface{})
+	reported = make(map[string]interface{})
+	for key, want := range feature.DesiredProperties {
+		got, ok := feature.Properties[key]
+		if ok && reflect.DeepEqual(want, got) {
+			continue
+		}
+		desired[key] = want
+		reported[key] = got
+	}
+	return desired, reported
+}
+
+// resetDrift starts tracking a new drift
+func resetDrift(drift *entity.Drift, now time.Time) {
+	drift.Status = enum.DriftDrifting
+	drift.DetectedAt = now
+	drift.ConvergedAt = nil
+	drift.Resends = 0
+	drift.LastResentAt = nil
+	drift.LastCommandID = ""
+}
+
+// jsonEqual compares two JSON documents by value
+func jsonEqual(a, b entity.JSON) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
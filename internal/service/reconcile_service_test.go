@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// fakeDriftRepository keeps drifts in memory and records the things whose drifts were deleted.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeDriftRepository struct {
+	repository.DriftRepository
+
+	mu      sync.Mutex
+	drifts  map[string]*entity.Drift
+	deleted []string
+}
+
+func (r *fakeDriftRepository) Save(_ context.Context, drift *entity.Drift) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *drift
+	r.drifts[drift.ThingID+"/"+drift.Feature] = &stored
+	return nil
+}
+
+func (r *fakeDriftRepository) ListByThing(_ context.Context, thingID string) ([]*entity.Drift, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var drifts []*entity.Drift
+	for _, drift := range r.drifts {
+		if drift.ThingID == thingID {
+			copied := *drift
+			drifts = append(drifts, &copied)
+		}
+	}
+	return drifts, nil
+}
+
+func (r *fakeDriftRepository) DeleteByThing(_ context.Context, thingID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, thingID)
+	return nil
+}
+
+func TestDeviceDriftRefreshEvaluatesAsTheService(t *testing.T) {
+	// The user may read org:dev but not its features, nor org:hidden at all
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service := strings.HasPrefix(r.Header.Get("Authorization"), "Basic ")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/2/things/org:dev" && service:
+			_, _ = w.Write([]byte(`{"thingId":"org:dev","features":{"fan":{"properties":{"speed":1},"desiredProperties":{"speed":3}}}}`))
+		case r.URL.Path == "/api/2/things/org:dev":
+			_, _ = w.Write([]byte(`{"thingId":"org:dev"}`))
+		case r.URL.Path == "/api/2/things/org:hidden" && service:
+			_, _ = w.Write([]byte(`{"thingId":"org:hidden","features":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	client := ditto.NewClient(upstream.URL, "ditto", "ditto")
+	if err := client.ForwardIdentity(ditto.IdentityJWT, ""); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	lc := fxtest.NewLifecycle(t)
+	log := logger.NewLogger(cfg)
+	repo := &fakeDriftRepository{drifts: map[string]*entity.Drift{
+		"org:hidden/fan": {ID: "d1", ThingID: "org:hidden", Feature: "fan", Status: enum.DriftDrifting},
+	}}
+	s := NewReconcileService(lc, repo, client, eventbus.NewBus(lc, log, cfg), nil, log, cfg)
+	user := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "oidc:alice", Token: "alice-token"})
+
+	drifts, err := s.DeviceDrift(user, "org:dev", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].Feature != "fan" || drifts[0].Status != enum.DriftDrifting {
+		t.Fatalf("expected the drift of the features hidden from the user to be found, got %+v", drifts)
+	}
+
+	// A thing the user cannot read is reported missing, its drift state is kept
+	if _, err := s.DeviceDrift(user, "org:hidden", true); !errors.Is(err, ditto.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if len(repo.deleted) != 0 {
+		t.Errorf("expected no drift to be deleted, got %v", repo.deleted)
+	}
+	if drift := repo.drifts["org:hidden/fan"]; drift.Status != enum.DriftDrifting {
+		t.Errorf("expected the drift of org:hidden to be untouched, got %s", drift.Status)
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"ditto/internal/ditto"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// RuleService stores alert rules and keeps the rules engine in sync with them
+type RuleService struct {
+	repo         repository.RuleRepository
+	alertService *AlertService
+}
+
+// NewRuleService creates a new rule service
+func NewRuleService(repo repository.RuleRepository, alertService *AlertService) *RuleService {
+	return &RuleService{
+		repo:         repo,
+		alertService: alertService,
+	}
+}
+
+// Validate checks the scope and the condition of a rule
+func (s *RuleService) Validate(spec model.RuleSpec) error {
+	if strings.TrimSpace(spec.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if spec.Feature == "" {
+		return fmt.Errorf("feature is required")
+	}
+
+	switch {
+	case spec.ThingID != "" && spec.Namespace != "":
+		return fmt.Errorf("thing_id and namespace are mutually exclusive")
+	case spec.ThingID != "":
+		if err := ditto.ValidateEntityID(spec.ThingID); err != nil {
+			return err
+		}
+	}
+
+	switch spec.Kind {
+	case enum.RuleThreshold:
+		switch spec.Operator {
+		case "gt", "ge", "lt", "le":
+		default:
+			return fmt.Errorf("operator must be one of gt, ge, lt, le")
+		}
+	case enum.RuleRange:
+		if spec.Min == nil && spec.Max == nil {
+			return fmt.Errorf("min or max is required for %s rules", enum.RuleRange)
+		}
+		if spec.Min != nil && spec.Max != nil && *spec.Min > *spec.Max {
+			return fmt.Errorf("min must not be greater than max")
+		}
+	case enum.RuleRateOfChange:
+		if spec.RatePerMinute <= 0 {
+			return fmt.Errorf("rate_per_minute must be positive for %s rules", enum.RuleRateOfChange)
+		}
+	case enum.RuleAbsence:
+		if spec.Absence <= 0 {
+			return fmt.Errorf("absence must be positive for %s rules", enum.RuleAbsence)
+		}
+	default:
+		return fmt.Errorf("kind must be one of %s, %s, %s, %s",
+			enum.RuleThreshold, enum.RuleRange, enum.RuleRateOfChange, enum.RuleAbsence)
+	}
+
+	if spec.Hysteresis < 0 {
+		return fmt.Errorf("hysteresis must not be negative")
+	}
+	if spec.Debounce < 0 {
+		return fmt.Errorf("debounce must not be negative")
+	}
+	switch spec.Severity {
+	case "", enum.SeverityInfo, enum.SeverityWarning, enum.SeverityCritical:
+	default:
+		return fmt.Errorf("severity must be one of %s, %s, %s", enum.SeverityInfo, enum.SeverityWarning, enum.SeverityCritical)
+	}
+
+	for _, recipient := range spec.Recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %q: %v", recipient, err)
+		}
+	}
+	if _, err := parseEmailTemplate("email_subject", spec.EmailSubject); err != nil {
+		return err
+	}
+	if _, err := parseEmailTemplate("email_body", spec.EmailBody); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Create stores a new rule
+func (s *RuleService) Create(ctx context.Context, spec model.RuleSpec, user string) (*entity.Rule, error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	rule, err := buildRule(spec)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = uuid.NewString()
+	rule.CreatedBy = user
+	rule.UpdatedBy = user
+
+	if err := s.repo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	s.alertService.Reload()
+	return rule, nil
+}
+
+// Update replaces a rule. Its active alerts are resolved since the condition changed.
+func (s *RuleService) Update(ctx context.Context, id string, spec model.RuleSpec, user string) (*entity.Rule, error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	rule, err := buildRule(spec)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = id
+	rule.UpdatedBy = user
+
+	if err := s.repo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	s.alertService.Reload()
+	return s.repo.GetByID(ctx, id)
+}
+
+// Delete removes a rule and resolves its active alerts, the alert history is kept
+func (s *RuleService) Delete(ctx context.Context, id, user string) error {
+	if err := s.repo.Delete(ctx, id, user); err != nil {
+		return err
+	}
+	s.alertService.Reload()
+	return nil
+}
+
+// Get returns a rule
+func (s *RuleService) Get(ctx context.Context, id string) (*entity.Rule, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns a page of rules and the total number of matching rules
+func (s *RuleService) List(ctx context.Context, filter repository.RuleFilter) ([]*entity.Rule, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// buildRule converts a rule spec to an entity
+func buildRule(spec model.RuleSpec) (*entity.Rule, error) {
+	severity := spec.Severity
+	if severity == "" {
+		severity = enum.SeverityWarning
+	}
+	var recipients entity.JSON
+	if len(spec.Recipients) > 0 {
+		var err error
+		if recipients, err = entity.NewJSON(spec.Recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	return &entity.Rule{
+		Name:            spec.Name,
+		Description:     spec.Description,
+		Enabled:         spec.Enabled,
+		ThingID:         spec.ThingID,
+		Namespace:       spec.Namespace,
+		Feature:         spec.Feature,
+		Kind:            spec.Kind,
+		Operator:        spec.Operator,
+		Threshold:       spec.Threshold,
+		Min:             spec.Min,
+		Max:             spec.Max,
+		RatePerMinute:   spec.RatePerMinute,
+		AbsenceSeconds:  int64(spec.Absence / time.Second),
+		Hysteresis:      spec.Hysteresis,
+		DebounceSeconds: int64(spec.Debounce / time.Second),
+		Severity:        severity,
+		Recipients:      recipients,
+		EmailSubject:    spec.EmailSubject,
+		EmailBody:       spec.EmailBody,
+	}, nil
+}
@@ -0,0 +1,516 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/job"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// ScheduleJobType identifies jobs executing a schedule
+	ScheduleJobType = "schedule.execution"
+
+	// MinScheduleInterval is the shortest interval of a recurring schedule
+	MinScheduleInterval = time.Minute
+	// DefaultScheduleAttempts is the number of attempts per thing when none is given
+	DefaultScheduleAttempts = 3
+	// MaxScheduleAttempts caps the attempts per thing a schedule may ask for
+	MaxScheduleAttempts = 10
+
+	// scheduleClaimBatch is the number of due schedules started per poll
+	scheduleClaimBatch = 50
+)
+
+// ScheduleService stores schedules and runs the due ones through the command service
+type ScheduleService struct {
+	repo           repository.ScheduleRepository
+	commandService *CommandService
+	dittoClient    *ditto.Client
+	jobs           *job.Manager
+	logger         logger.Logger
+	config         config.SchedulerConfig
+	// identityMode is the DITTO_IDENTITY_MODE the runs are made on behalf of their user with
+	identityMode string
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewScheduleService creates a new schedule service whose poller follows the application lifecycle
+func NewScheduleService(
+	lc fx.Lifecycle,
+	repo repository.ScheduleRepository,
+	commandService *CommandService,
+	dittoClient *ditto.Client,
+	jobs *job.Manager,
+	logger logger.Logger,
+	cfg *config.Config,
+) *ScheduleService {
+	s := &ScheduleService{
+		repo:           repo,
+		commandService: commandService,
+		dittoClient:    dittoClient,
+		jobs:           jobs,
+		logger:         logger,
+		config:         cfg.Scheduler,
+		identityMode:   cfg.Ditto.IdentityMode,
+	}
+	if s.config.PollInterval <= 0 {
+		s.config.PollInterval = 15 * time.Second
+	}
+	if s.config.Concurrency <= 0 {
+		s.config.Concurrency = 1
+	}
+	jobs.Register(ScheduleJobType, s.run)
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(1)
+			go s.poll(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s
+}
+
+// Validate checks the timing, targets and command of a schedule
+func (s *ScheduleService) Validate(spec model.ScheduleSpec) error {
+	if strings.TrimSpace(spec.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch spec.Kind {
+	case enum.ScheduleOnce:
+		if spec.RunAt == nil {
+			return fmt.Errorf("run_at is required for %s schedules", enum.ScheduleOnce)
+		}
+	case enum.ScheduleCron:
+		if _, err := cron.ParseStandard(spec.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+	case enum.ScheduleInterval:
+		if spec.Interval < MinScheduleInterval {
+			return fmt.Errorf("interval must be at least %s", MinScheduleInterval)
+		}
+	default:
+		return fmt.Errorf("kind must be one of %s, %s, %s", enum.ScheduleOnce, enum.ScheduleCron, enum.ScheduleInterval)
+	}
+
+	switch {
+	case spec.ThingID != "" && spec.Filter != nil:
+		return fmt.Errorf("thing_id and filter are mutually exclusive")
+	case spec.ThingID != "":
+		if err := ditto.ValidateEntityID(spec.ThingID); err != nil {
+			return err
+		}
+	case spec.Filter != nil:
+		if _, err := spec.Filter.Expr(); err != nil {
+			return err
+		}
+		// An empty filter would target the whole fleet
+		if len(spec.Filter.Filters) == 0 && len(spec.Filter.Namespaces) == 0 {
+			return fmt.Errorf("filter must restrict the targeted things")
+		}
+	default:
+		return fmt.Errorf("either thing_id or filter is required")
+	}
+
+	if spec.Feature == "" || spec.Subject == "" {
+		return fmt.Errorf("feature and command are required")
+	}
+	if len(spec.Params) > 0 && !json.Valid(spec.Params) {
+		return fmt.Errorf("params must be valid JSON")
+	}
+	if spec.MaxAttempts < 0 || spec.MaxAttempts > MaxScheduleAttempts {
+		return fmt.Errorf("max_attempts must be between 1 and %d", MaxScheduleAttempts)
+	}
+	// No bearer token is kept to forward at planned runs, only triggering a schedule has one
+	if spec.Enabled && s.identityMode == ditto.IdentityJWT {
+		return fmt.Errorf("schedules cannot be enabled with the %s identity mode, they can only be triggered", ditto.IdentityJWT)
+	}
+	return nil
+}
+
+// Create stores a new schedule and computes its first run. The schedule runs on
+// behalf of the Ditto identity of ctx, if any.
+func (s *ScheduleService) Create(ctx context.Context, spec model.ScheduleSpec, user string) (*entity.Schedule, error) {
+	schedule, err := s.build(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = uuid.NewString()
+	schedule.CreatedBy = user
+	schedule.UpdatedBy = user
+
+	if err := s.repo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Update replaces a schedule and recomputes its next run, from then on it runs on
+// behalf of the Ditto identity of ctx
+func (s *ScheduleService) Update(ctx context.Context, id string, spec model.ScheduleSpec, user string) (*entity.Schedule, error) {
+	schedule, err := s.build(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = id
+	schedule.UpdatedBy = user
+
+	if err := s.repo.Update(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// Delete removes a schedule, its execution history is kept
+func (s *ScheduleService) Delete(ctx context.Context, id, user string) error {
+	return s.repo.Delete(ctx, id, user)
+}
+
+// Get returns a schedule
+func (s *ScheduleService) Get(ctx context.Context, id string) (*entity.Schedule, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns a page of schedules and the total number of matching schedules
+func (s *ScheduleService) List(ctx context.Context, filter repository.ScheduleFilter) ([]*entity.Schedule, int64, error) {
+	return s.repo.List(ctx, filter)
+}
+
+// Executions returns a page of the execution history of a schedule
+func (s *ScheduleService) Executions(ctx context.Context, id string, limit, offset int) ([]*entity.ScheduleExecution, int64, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListExecutions(ctx, id, limit, offset)
+}
+
+// Trigger runs a schedule right away, independently of its timing
+func (s *ScheduleService) Trigger(ctx context.Context, id, user string) (*entity.Job, error) {
+	schedule, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.submit(ctx, schedule, time.Now(), user)
+}
+
+// build validates a spec and converts it to a schedule with its first run, run on behalf of the identity of ctx
+func (s *ScheduleService) build(ctx context.Context, spec model.ScheduleSpec) (*entity.Schedule, error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	if spec.MaxAttempts == 0 {
+		spec.MaxAttempts = DefaultScheduleAttempts
+	}
+
+	schedule := &entity.Schedule{
+		Name:            strings.TrimSpace(spec.Name),
+		Kind:            spec.Kind,
+		RunAt:           spec.RunAt,
+		Cron:            spec.Cron,
+		IntervalSeconds: int64(spec.Interval / time.Second),
+		ThingID:         spec.ThingID,
+		Feature:         spec.Feature,
+		Subject:         spec.Subject,
+		TimeoutMs:       s.commandService.Timeout(spec.Timeout).Milliseconds(),
+		MaxAttempts:     spec.MaxAttempts,
+		Enabled:         spec.Enabled,
+	}
+	if identity, ok := ditto.IdentityFromContext(ctx); ok {
+		schedule.RunAs = identity.Subject
+	}
+	if len(spec.Params) > 0 {
+		schedule.Params = entity.JSON(spec.Params)
+	}
+	if spec.Filter != nil {
+		filter, err := entity.NewJSON(spec.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal filter: %w", err)
+		}
+		schedule.Filter = filter
+	}
+
+	schedule.NextRunAt = firstRun(schedule, time.Now())
+	if schedule.NextRunAt == nil {
+		schedule.Enabled = false
+	}
+	return schedule, nil
+}
+
+// firstRun returns the first run of a new or updated schedule
+func firstRun(schedule *entity.Schedule, now time.Time) *time.Time {
+	switch schedule.Kind {
+	case enum.ScheduleOnce:
+		return schedule.RunAt
+	case enum.ScheduleInterval:
+		if schedule.RunAt != nil && schedule.RunAt.After(now) {
+			return schedule.RunAt
+		}
+		next := now.Add(time.Duration(schedule.IntervalSeconds) * time.Second)
+		return &next
+	default:
+		return nextRun(schedule, now)
+	}
+}
+
+// nextRun returns the run following a run at now, nil when the schedule is over
+func nextRun(schedule *entity.Schedule, now time.Time) *time.Time {
+	switch schedule.Kind {
+	case enum.ScheduleCron:
+		spec, err := cron.ParseStandard(schedule.Cron)
+		if err != nil {
+			return nil
+		}
+		next := spec.Next(now)
+		return &next
+	case enum.ScheduleInterval:
+		// Step from the planned run so the schedule does not drift with poll delays
+		interval := time.Duration(schedule.IntervalSeconds) * time.Second
+		next := now.Add(interval)
+		if schedule.NextRunAt != nil {
+			next = *schedule.NextRunAt
+			for !next.After(now) {
+				next = next.Add(interval)
+			}
+		}
+		return &next
+	default:
+		return nil
+	}
+}
+
+// poll starts the due schedules until ctx is cancelled
+func (s *ScheduleService) poll(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		due, err := s.repo.ClaimDue(ctx, time.Now(), scheduleClaimBatch, nextRun)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to claim due schedules", zap.Error(err))
+		}
+		for _, schedule := range due {
+			runCtx := ctx
+			if schedule.RunAs != "" {
+				runCtx = ditto.WithIdentity(ctx, ditto.Identity{Subject: schedule.RunAs})
+			}
+			if _, err := s.submit(runCtx, schedule, *schedule.NextRunAt, schedule.UpdatedBy); err != nil {
+				s.logger.Error("Failed to start schedule", zap.String("schedule_id", schedule.ID), zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// submit starts a job executing the schedule once on behalf of user, and of the Ditto identity of ctx
+func (s *ScheduleService) submit(ctx context.Context, schedule *entity.Schedule, plannedAt time.Time, user string) (*entity.Job, error) {
+	payload := schedulePayload{
+		ScheduleID: schedule.ID,
+		PlannedAt:  plannedAt,
+		User:       user,
+	}
+	// Retries happen per thing inside the job, rerunning the whole job would resend to all things
+	return s.jobs.Submit(ctx, ScheduleJobType, payload, job.WithCreatedBy(user), job.WithMaxAttempts(1))
+}
+
+// run executes a schedule against its targets and records the execution
+func (s *ScheduleService) run(ctx context.Context, task *job.Task) (interface{}, error) {
+	var payload schedulePayload
+	if err := task.Decode(&payload); err != nil {
+		return nil, job.Permanent(fmt.Errorf("invalid schedule payload: %w", err))
+	}
+
+	schedule, err := s.repo.GetByID(ctx, payload.ScheduleID)
+	if err != nil {
+		return nil, job.Permanent(fmt.Errorf("failed to load schedule %s: %w", payload.ScheduleID, err))
+	}
+
+	execution := &entity.ScheduleExecution{
+		ID:         uuid.NewString(),
+		ScheduleID: schedule.ID,
+		JobID:      task.ID,
+		Status:     enum.ExecutionRunning,
+		StartedAt:  time.Now(),
+		BaseEntity: entity.BaseEntity{CreatedBy: payload.user(schedule)},
+	}
+	if err := s.repo.CreateExecution(ctx, execution); err != nil {
+		return nil, err
+	}
+
+	results, runErr := s.execute(ctx, task, schedule, payload.user(schedule))
+
+	now := time.Now()
+	execution.FinishedAt = &now
+	execution.Matched = len(results)
+	for _, result := range results {
+		if commandSucceeded(result.Status) {
+			execution.Succeeded++
+		} else {
+			execution.Failed++
+		}
+	}
+	switch {
+	case runErr != nil:
+		execution.Status = enum.ExecutionFailed
+		execution.Error = runErr.Error()
+	case execution.Failed == 0:
+		execution.Status = enum.ExecutionSucceeded
+	case execution.Succeeded == 0:
+		execution.Status = enum.ExecutionFailed
+	default:
+		execution.Status = enum.ExecutionPartial
+	}
+	if data, err := entity.NewJSON(results); err == nil {
+		execution.Results = data
+	}
+
+	if err := s.repo.FinishExecution(context.Background(), execution); err != nil {
+		s.logger.Error("Failed to store schedule execution", zap.String("execution_id", execution.ID), zap.Error(err))
+	}
+
+	s.logger.Info(fmt.Sprintf("Schedule %s executed: %d succeeded, %d failed", schedule.ID, execution.Succeeded, execution.Failed))
+
+	if runErr != nil {
+		return execution, job.Permanent(runErr)
+	}
+	return execution, nil
+}
+
+// execute sends the command to every target with bounded concurrency, recorded as sent by user
+func (s *ScheduleService) execute(ctx context.Context, task *job.Task, schedule *entity.Schedule, user string) ([]model.CommandTargetResult, error) {
+	targets, err := s.targets(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	task.SetTotal(len(targets))
+
+	results := make([]model.CommandTargetResult, len(targets))
+	sem := make(chan struct{}, s.config.Concurrency)
+	var wg sync.WaitGroup
+	for i, thingID := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, thingID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = s.send(ctx, schedule, thingID, user)
+			task.Add(1)
+		}(i, thingID)
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// targets resolves the things a schedule applies to
+func (s *ScheduleService) targets(ctx context.Context, schedule *entity.Schedule) ([]string, error) {
+	if schedule.ThingID != "" {
+		return []string{schedule.ThingID}, nil
+	}
+
+	var filter model.SearchFilter
+	if err := schedule.Filter.Decode(&filter); err != nil {
+		return nil, fmt.Errorf("invalid schedule filter: %w", err)
+	}
+	return searchThingIDs(ctx, s.dittoClient, filter)
+}
+
+// send delivers the command to one thing on behalf of user, retrying failures with a doubling backoff
+func (s *ScheduleService) send(ctx context.Context, schedule *entity.Schedule, thingID, user string) model.CommandTargetResult {
+	cmd := model.Command{
+		ThingID:          thingID,
+		Feature:          schedule.Feature,
+		Subject:          schedule.Subject,
+		Params:           json.RawMessage(schedule.Params),
+		Timeout:          time.Duration(schedule.TimeoutMs) * time.Millisecond,
+		ResponseRequired: true,
+	}
+
+	result := model.CommandTargetResult{ThingID: thingID}
+	backoff := s.config.RetryBackoff
+	for attempt := 1; attempt <= schedule.MaxAttempts; attempt++ {
+		result.Attempts = attempt
+
+		command, err := s.commandService.Send(ctx, cmd, user)
+		if err != nil {
+			result.Status = enum.CommandFailed
+			result.Error = err.Error()
+		} else {
+			result.CommandID = command.ID
+			result.Status = command.Status
+			result.Error = command.Error
+			if command.Status == enum.CommandSucceeded {
+				return result
+			}
+		}
+
+		if attempt == schedule.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return result
+}
+
+// schedulePayload is the stored input of a schedule execution job
+type schedulePayload struct {
+	ScheduleID string    `json:"scheduleId"`
+	PlannedAt  time.Time `json:"plannedAt"`
+	// User triggered the run, or last changed the schedule for planned runs
+	User string `json:"user,omitempty"`
+}
+
+// user returns the user the commands of the run are recorded for, jobs
+// queued before the user was stored fall back to the last editor
+func (p schedulePayload) user(schedule *entity.Schedule) string {
+	if p.User != "" {
+		return p.User
+	}
+	return schedule.UpdatedBy
+}
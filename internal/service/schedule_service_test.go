@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/job"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// fakeScheduleRepository keeps schedules in memory, ClaimDue returns every enabled schedule.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeScheduleRepository struct {
+	repository.ScheduleRepository
+
+	mu        sync.Mutex
+	schedules map[string]*entity.Schedule
+	// claimed is called after ClaimDue returned the due schedules
+	claimed func()
+}
+
+func (r *fakeScheduleRepository) Create(_ context.Context, schedule *entity.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *schedule
+	r.schedules[schedule.ID] = &stored
+	return nil
+}
+
+func (r *fakeScheduleRepository) Update(_ context.Context, schedule *entity.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.schedules[schedule.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	updated := *schedule
+	updated.CreatedBy = stored.CreatedBy
+	r.schedules[schedule.ID] = &updated
+	return nil
+}
+
+func (r *fakeScheduleRepository) GetByID(_ context.Context, id string) (*entity.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedule, ok := r.schedules[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *schedule
+	return &copied, nil
+}
+
+func (r *fakeScheduleRepository) ClaimDue(context.Context, time.Time, int, repository.NextRunFunc) ([]*entity.Schedule, error) {
+	r.mu.Lock()
+	var due []*entity.Schedule
+	for _, schedule := range r.schedules {
+		if schedule.Enabled {
+			copied := *schedule
+			due = append(due, &copied)
+		}
+	}
+	r.mu.Unlock()
+	if r.claimed != nil {
+		r.claimed()
+	}
+	return due, nil
+}
+
+// fakeSubmittedJobs records the jobs submitted to a manager that is never started
+type fakeSubmittedJobs struct {
+	repository.JobRepository
+
+	mu   sync.Mutex
+	jobs []entity.Job
+}
+
+func (r *fakeSubmittedJobs) Create(_ context.Context, job *entity.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, *job)
+	return nil
+}
+
+func newTestScheduleService(t *testing.T, identityMode string) (*ScheduleService, *fakeScheduleRepository, *fakeSubmittedJobs) {
+	t.Helper()
+	cfg := &config.Config{
+		Ditto:   config.DittoConfig{IdentityMode: identityMode},
+		Command: config.CommandConfig{DefaultTimeout: 10 * time.Second, MaxTimeout: time.Minute},
+	}
+	log := logger.NewLogger(cfg)
+	repo := &fakeScheduleRepository{schedules: make(map[string]*entity.Schedule)}
+	submitted := &fakeSubmittedJobs{}
+	jobs := job.NewManager(fxtest.NewLifecycle(t), submitted, log, cfg)
+	commands := &CommandService{config: cfg.Command}
+	return NewScheduleService(fxtest.NewLifecycle(t), repo, commands, nil, jobs, log, cfg), repo, submitted
+}
+
+func newTestScheduleSpec() model.ScheduleSpec {
+	return model.ScheduleSpec{
+		Name:     "Nightly reboot",
+		Kind:     enum.ScheduleInterval,
+		Interval: time.Hour,
+		ThingID:  "org:dev",
+		Feature:  "system",
+		Subject:  "reboot",
+		Enabled:  true,
+	}
+}
+
+func TestScheduledRunsAreMadeOnBehalfOfTheLastEditor(t *testing.T) {
+	s, repo, submitted := newTestScheduleService(t, ditto.IdentityPreAuthenticated)
+
+	alice := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "alice"})
+	schedule, err := s.Create(alice, newTestScheduleSpec(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.RunAs != "alice" {
+		t.Fatalf("expected the schedule to run as its creator, got %q", schedule.RunAs)
+	}
+
+	bob := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "bob"})
+	schedule, err = s.Update(bob, schedule.ID, newTestScheduleSpec(), "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.RunAs != "bob" || schedule.CreatedBy != "alice" || schedule.UpdatedBy != "bob" {
+		t.Fatalf("expected the schedule to run as its last editor, got %q created by %q and updated by %q", schedule.RunAs, schedule.CreatedBy, schedule.UpdatedBy)
+	}
+
+	// A single poll submits the due schedule
+	ctx, cancel := context.WithCancel(context.Background())
+	repo.claimed = cancel
+	s.wg.Add(1)
+	s.poll(ctx)
+
+	if len(submitted.jobs) != 1 {
+		t.Fatalf("expected one planned run, got %d", len(submitted.jobs))
+	}
+	if run := submitted.jobs[0]; run.Subject != "bob" || run.CreatedBy != "bob" {
+		t.Errorf("expected the run to be made on behalf of bob, got subject %q created by %q", run.Subject, run.CreatedBy)
+	}
+}
+
+func TestSchedulesCannotBeEnabledWithForwardedTokens(t *testing.T) {
+	s, _, _ := newTestScheduleService(t, ditto.IdentityJWT)
+	user := ditto.WithIdentity(context.Background(), ditto.Identity{Subject: "alice", Token: "token"})
+
+	if _, err := s.Create(user, newTestScheduleSpec(), "alice"); err == nil {
+		t.Fatal("expected an enabled schedule to be refused in jwt mode")
+	}
+
+	// A disabled schedule may still be triggered, with the token of the request
+	spec := newTestScheduleSpec()
+	spec.Enabled = false
+	if _, err := s.Create(user, spec, "alice"); err != nil {
+		t.Fatal(err)
+	}
+}
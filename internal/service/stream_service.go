@@ -0,0 +1,286 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrTooManyClients is returned when the stream has no room for another client
+var ErrTooManyClients = errors.New("too many stream clients")
+
+// ErrStreamUnavailable is returned when identities are forwarded to Ditto. The events
+// are received with the service account, Ditto policies cannot be applied per client.
+var ErrStreamUnavailable = errors.New("event streams are unavailable when identities are forwarded to Ditto")
+
+// StreamSubscription is a client of the event stream. Events stops being fed and
+// Done is closed when the client falls behind by more than its buffer.
+type StreamSubscription struct {
+	ID     string
+	Events <-chan model.DeviceEvent
+	Done   <-chan struct{}
+
+	events chan model.DeviceEvent
+	done   chan struct{}
+	user   string
+	// namespaces are those the client may receive events of, all when empty
+	namespaces []string
+
+	mu     sync.RWMutex
+	filter model.StreamFilter
+	closed bool
+}
+
+// Filter returns the current filter of the subscription
+func (s *StreamSubscription) Filter() model.StreamFilter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter
+}
+
+// SetFilter replaces the filter of the subscription
+func (s *StreamSubscription) SetFilter(filter model.StreamFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filter = filter
+}
+
+// matches tells whether the client receives an event, its filter can only narrow
+// down the namespaces it may receive events of
+func (s *StreamSubscription) matches(event model.DeviceEvent) bool {
+	if !s.Filter().Matches(event) {
+		return false
+	}
+	return model.StreamFilter{Namespaces: s.namespaces}.Matches(event)
+}
+
+// StreamService fans the twin events of the event bus out to the WebSocket and
+// SSE clients, and keeps the most recent ones to resume SSE streams. The events are
+// those the service account receives from Ditto, clients get them without Ditto
+// policies applied, limited to the namespaces of PROXY_WS_NAMESPACES.
+type StreamService struct {
+	logger logger.Logger
+	config config.StreamConfig
+	// namespaces limit the events of every client, all when empty
+	namespaces []string
+	// unavailable is set when identities are forwarded, Ditto decides what a user may see then
+	unavailable bool
+
+	mu      sync.RWMutex
+	clients map[string]*StreamSubscription
+	// replay is a ring of the most recent events, next is the slot of the next one
+	replay []model.DeviceEvent
+	next   int
+}
+
+// NewStreamService creates an event stream subscribed to the Ditto events of the bus
+func NewStreamService(bus *eventbus.Bus, logger logger.Logger, cfg *config.Config) *StreamService {
+	s := &StreamService{
+		logger:      logger,
+		config:      cfg.Stream,
+		unavailable: cfg.Ditto.IdentityMode != "" && cfg.Ditto.IdentityMode != ditto.IdentityService,
+		clients:     make(map[string]*StreamSubscription),
+	}
+	for _, namespace := range strings.Split(cfg.Proxy.WSNamespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			s.namespaces = append(s.namespaces, namespace)
+		}
+	}
+	if s.config.ClientBuffer <= 0 {
+		s.config.ClientBuffer = 256
+	}
+	if s.config.WriteTimeout <= 0 {
+		s.config.WriteTimeout = 10 * time.Second
+	}
+	if s.config.PingInterval <= 0 {
+		s.config.PingInterval = 30 * time.Second
+	}
+	if s.config.ReplaySize < 0 {
+		s.config.ReplaySize = 0
+	}
+	s.replay = make([]model.DeviceEvent, 0, s.config.ReplaySize)
+
+	eventbus.Subscribe(bus, ditto.EventTopic, "stream", s.handleEvent)
+	return s
+}
+
+// Config returns the stream settings, used by the transports to pace their clients
+func (s *StreamService) Config() config.StreamConfig {
+	return s.config
+}
+
+// Subscribe registers a client receiving the events that match the filter
+func (s *StreamService) Subscribe(filter model.StreamFilter, user string) (*StreamSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribe(filter, user)
+}
+
+// SubscribeFrom registers a client like Subscribe and also returns the buffered events
+// following the cursor, so a reconnecting client resumes without gaps or duplicates.
+// When the cursor is no longer buffered only a stream of a single thing is replayed,
+// from the revisions newer than the cursor.
+func (s *StreamService) SubscribeFrom(filter model.StreamFilter, user string, cursor *model.StreamCursor) (*StreamSubscription, []model.DeviceEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.subscribe(filter, user)
+	if err != nil || cursor == nil {
+		return sub, nil, err
+	}
+
+	recent := s.recent()
+	start := -1
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].Cursor() == *cursor {
+			start = i + 1
+			break
+		}
+	}
+	singleThing := len(filter.ThingIDs) == 1 && filter.ThingIDs[0] == cursor.ThingID
+	if start < 0 && !singleThing {
+		return sub, nil, nil
+	}
+
+	var replay []model.DeviceEvent
+	for _, event := range recent[max(start, 0):] {
+		if start < 0 && event.Revision <= cursor.Revision {
+			continue
+		}
+		if sub.matches(event) {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, nil
+}
+
+// subscribe registers a client, s.mu must be held
+func (s *StreamService) subscribe(filter model.StreamFilter, user string) (*StreamSubscription, error) {
+	if s.unavailable {
+		return nil, ErrStreamUnavailable
+	}
+	if s.config.MaxClients > 0 && len(s.clients) >= s.config.MaxClients {
+		return nil, ErrTooManyClients
+	}
+
+	events := make(chan model.DeviceEvent, s.config.ClientBuffer)
+	done := make(chan struct{})
+	sub := &StreamSubscription{
+		ID:         uuid.NewString(),
+		Events:     events,
+		Done:       done,
+		events:     events,
+		done:       done,
+		user:       user,
+		namespaces: s.namespaces,
+		filter:     filter,
+	}
+	s.clients[sub.ID] = sub
+	return sub, nil
+}
+
+// Unsubscribe removes a client
+func (s *StreamService) Unsubscribe(sub *StreamSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(sub)
+}
+
+// Clients returns the number of connected clients
+func (s *StreamService) Clients() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients)
+}
+
+// handleEvent records a twin event delivered by the bus and passes it to every matching client
+func (s *StreamService) handleEvent(e ditto.Event) {
+	event, ok := newDeviceEvent(e)
+	if !ok {
+		return
+	}
+
+	// The write lock keeps the replay buffer and the fan-out in step with SubscribeFrom
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(event)
+
+	for _, sub := range s.clients {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			s.logger.Warn("Disconnecting slow stream client", zap.String("client", sub.ID), zap.String("user", sub.user))
+			s.remove(sub)
+		}
+	}
+}
+
+// record appends an event to the replay buffer, s.mu must be held
+func (s *StreamService) record(event model.DeviceEvent) {
+	if s.config.ReplaySize == 0 {
+		return
+	}
+	if len(s.replay) < s.config.ReplaySize {
+		s.replay = append(s.replay, event)
+		return
+	}
+	s.replay[s.next] = event
+	s.next = (s.next + 1) % s.config.ReplaySize
+}
+
+// recent returns the buffered events from oldest to newest, s.mu must be held
+func (s *StreamService) recent() []model.DeviceEvent {
+	if len(s.replay) < s.config.ReplaySize {
+		return s.replay
+	}
+	recent := make([]model.DeviceEvent, 0, len(s.replay))
+	recent = append(recent, s.replay[s.next:]...)
+	return append(recent, s.replay[:s.next]...)
+}
+
+// remove drops a client and signals it, s.mu must be held
+func (s *StreamService) remove(sub *StreamSubscription) {
+	delete(s.clients, sub.ID)
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.done)
+	}
+}
+
+// newDeviceEvent converts a Ditto twin event to a stream event, ok is false for other events
+func newDeviceEvent(e ditto.Event) (model.DeviceEvent, bool) {
+	if !e.IsTwinEvent() {
+		return model.DeviceEvent{}, false
+	}
+
+	timestamp := e.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return model.DeviceEvent{
+		ThingID:   e.ThingID(),
+		Action:    e.Action(),
+		Topic:     e.Topic,
+		Path:      e.Path,
+		Feature:   e.Feature(),
+		Value:     e.Value,
+		Revision:  e.Revision,
+		Timestamp: timestamp,
+	}, true
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ditto/internal/ditto"
+	"ditto/internal/model"
+)
+
+// searchThingIDs returns the IDs of all things matching a filter, sorted by thingId
+func searchThingIDs(ctx context.Context, dittoClient *ditto.Client, filter model.SearchFilter) ([]string, error) {
+	expr, err := filter.Expr()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	err = dittoClient.ForEach(ctx, ditto.SearchQuery{
+		Filter:     expr,
+		Namespaces: filter.Namespaces,
+		Fields:     []string{"thingId"},
+	}, func(item json.RawMessage) error {
+		var thing struct {
+			ThingID string `json:"thingId"`
+		}
+		if err := json.Unmarshal(item, &thing); err != nil {
+			return err
+		}
+		ids = append(ids, thing.ThingID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search targets: %w", err)
+	}
+	return ids, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"ditto/internal/ditto"
+	"ditto/internal/ditto/rql"
 	"ditto/internal/model"
 	"ditto/internal/repository"
 )
@@ -27,7 +28,7 @@ func NewThingService(repo repository.ThingRepository, dittoClient *ditto.Client)
 // Create creates a new thing
 func (s *ThingService) Create(ctx context.Context, input *model.ThingCreate) (*model.Thing, error) {
 	// Create policy if it doesn't exist
-	if err := s.dittoClient.CreatePolicy(input.PolicyID); err != nil {
+	if err := s.dittoClient.CreatePolicy(ctx, input.PolicyID); err != nil {
 		return nil, fmt.Errorf("failed to create policy: %w", err)
 	}
 
@@ -87,7 +88,12 @@ func (s *ThingService) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
 
-// List retrieves a list of things with pagination
-func (s *ThingService) List(ctx context.Context, offset, limit int) ([]*model.Thing, error) {
-	return s.repo.List(ctx, offset, limit)
+// List retrieves a page of things matching the search query
+func (s *ThingService) List(ctx context.Context, query ditto.SearchQuery) (*model.ThingList, error) {
+	return s.repo.List(ctx, query)
+}
+
+// Count returns the number of things matching the filter
+func (s *ThingService) Count(ctx context.Context, filter rql.Expr, namespaces []string) (int, error) {
+	return s.repo.Count(ctx, filter, namespaces)
 }
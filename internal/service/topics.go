@@ -0,0 +1,22 @@
+package service
+
+import (
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+)
+
+// AlertEvent is an alert with the rule that raised it
+type AlertEvent struct {
+	Alert entity.Alert
+	Rule  entity.Rule
+}
+
+var (
+	// ConnectivityTopic carries the things going online or offline
+	ConnectivityTopic = eventbus.NewTopic[model.ConnectivityChange]("connectivity")
+	// AlertTopic carries alerts when they fire, resolve or are acknowledged
+	AlertTopic = eventbus.NewTopic[AlertEvent]("alerts")
+	// CommandTopic carries commands when they are sent and when their outcome is known
+	CommandTopic = eventbus.NewTopic[entity.Command]("commands")
+)
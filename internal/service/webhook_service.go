@@ -0,0 +1,583 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/ditto"
+	"ditto/internal/eventbus"
+	"ditto/internal/model"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// Webhook request headers
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// WebhookSignatureHeader carries sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// webhookClaimBatch is the number of due deliveries sent per poll
+	webhookClaimBatch = 100
+	// webhookReloadInterval is how often the enabled webhooks are reloaded
+	webhookReloadInterval = 30 * time.Second
+)
+
+// WebhookService posts notification events to the subscribed webhooks. Events
+// are queued as deliveries in Postgres and sent with retries, so they survive
+// restarts and receiver outages.
+type WebhookService struct {
+	repo   repository.WebhookRepository
+	logger logger.Logger
+	config config.WebhookConfig
+	client *http.Client
+
+	events chan model.NotificationEvent
+	reload chan struct{}
+	wake   chan struct{}
+
+	// webhooks are only used by the routing goroutine
+	webhooks []*entity.Webhook
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewWebhookService creates a webhook channel fed by alerts, connectivity changes and twin events
+func NewWebhookService(
+	lc fx.Lifecycle,
+	repo repository.WebhookRepository,
+	bus *eventbus.Bus,
+	logger logger.Logger,
+	cfg *config.Config,
+) *WebhookService {
+	s := &WebhookService{
+		repo:   repo,
+		logger: logger,
+		config: cfg.Webhook,
+		reload: make(chan struct{}, 1),
+		wake:   make(chan struct{}, 1),
+	}
+	if s.config.Timeout <= 0 {
+		s.config.Timeout = 10 * time.Second
+	}
+	if s.config.MaxAttempts <= 0 {
+		s.config.MaxAttempts = 1
+	}
+	if s.config.RetryBackoff <= 0 {
+		s.config.RetryBackoff = 10 * time.Second
+	}
+	if s.config.MaxBackoff < s.config.RetryBackoff {
+		s.config.MaxBackoff = s.config.RetryBackoff
+	}
+	if s.config.PollInterval <= 0 {
+		s.config.PollInterval = 2 * time.Second
+	}
+	if s.config.Concurrency <= 0 {
+		s.config.Concurrency = 1
+	}
+	if s.config.QueueSize <= 0 {
+		s.config.QueueSize = 1000
+	}
+	s.events = make(chan model.NotificationEvent, s.config.QueueSize)
+	s.client = &http.Client{Timeout: s.config.Timeout}
+	if !s.config.Enabled {
+		return s
+	}
+
+	eventbus.Subscribe(bus, AlertTopic, "webhook", func(e AlertEvent) {
+		s.publish(newAlertEvent(&e.Alert, &e.Rule))
+	})
+	eventbus.Subscribe(bus, ConnectivityTopic, "webhook", func(change model.ConnectivityChange) {
+		s.publish(newConnectivityEvent(change))
+	})
+	eventbus.Subscribe(bus, ditto.EventTopic, "webhook", func(event ditto.Event) {
+		if e, ok := newTwinEvent(event); ok {
+			s.publish(e)
+		}
+	})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stop = cancel
+			s.wg.Add(2)
+			go s.route(ctx)
+			go s.deliver(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			if s.stop != nil {
+				s.stop()
+			}
+			s.wg.Wait()
+			return nil
+		},
+	})
+
+	return s
+}
+
+// Validate checks the URL and the subscribed event types of a webhook
+func (s *WebhookService) Validate(spec model.WebhookSpec) error {
+	if strings.TrimSpace(spec.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if len(spec.EventTypes) == 0 {
+		return fmt.Errorf("at least one event type is required")
+	}
+	for _, eventType := range spec.EventTypes {
+		if !isEventType(eventType) {
+			return fmt.Errorf("unsupported event type: %s", eventType)
+		}
+	}
+	if spec.ThingID != "" && spec.Namespace != "" {
+		return fmt.Errorf("thing_id and namespace are mutually exclusive")
+	}
+	if spec.ThingID != "" {
+		if err := ditto.ValidateEntityID(spec.ThingID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create stores a new webhook, generating its signing secret if none is given
+func (s *WebhookService) Create(ctx context.Context, spec model.WebhookSpec, user string) (*entity.Webhook, error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	if spec.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		spec.Secret = secret
+	}
+
+	webhook, err := buildWebhook(spec)
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = uuid.NewString()
+	webhook.CreatedBy = user
+	webhook.UpdatedBy = user
+
+	if err := s.repo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.Reload()
+	return webhook, nil
+}
+
+// Update replaces a webhook, keeping its secret unless a new one is given
+func (s *WebhookService) Update(ctx context.Context, id string, spec model.WebhookSpec, user string) (*entity.Webhook, error) {
+	if err := s.Validate(spec); err != nil {
+		return nil, err
+	}
+	webhook, err := buildWebhook(spec)
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = id
+	webhook.UpdatedBy = user
+
+	if err := s.repo.Update(ctx, webhook); err != nil {
+		return nil, err
+	}
+	s.Reload()
+	return s.repo.GetByID(ctx, id)
+}
+
+// Delete removes a webhook and drops its pending deliveries
+func (s *WebhookService) Delete(ctx context.Context, id, user string) error {
+	if err := s.repo.Delete(ctx, id, user); err != nil {
+		return err
+	}
+	s.Reload()
+	return nil
+}
+
+// Get returns a webhook
+func (s *WebhookService) Get(ctx context.Context, id string) (*entity.Webhook, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns a page of webhooks and the total number of webhooks
+func (s *WebhookService) List(ctx context.Context, limit, offset int) ([]*entity.Webhook, int64, error) {
+	return s.repo.List(ctx, limit, offset)
+}
+
+// Deliveries returns a page of the delivery log
+func (s *WebhookService) Deliveries(ctx context.Context, filter repository.DeliveryFilter) ([]*entity.WebhookDelivery, int64, error) {
+	return s.repo.ListDeliveries(ctx, filter)
+}
+
+// GetDelivery returns a delivery
+func (s *WebhookService) GetDelivery(ctx context.Context, id string) (*entity.WebhookDelivery, error) {
+	return s.repo.GetDelivery(ctx, id)
+}
+
+// Redeliver queues a dead delivery again
+func (s *WebhookService) Redeliver(ctx context.Context, id string) (*entity.WebhookDelivery, error) {
+	delivery, err := s.repo.Redeliver(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.poke()
+	return delivery, nil
+}
+
+// Test queues a webhook.test event for a webhook, whatever its subscriptions
+func (s *WebhookService) Test(ctx context.Context, id string) (*entity.WebhookDelivery, error) {
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(map[string]string{"webhookId": webhook.ID, "name": webhook.Name})
+	delivery, err := newDelivery(webhook, model.NotificationEvent{
+		ID:        uuid.NewString(),
+		Type:      model.EventWebhookTest,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateDeliveries(ctx, []*entity.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	s.poke()
+	return delivery, nil
+}
+
+// Reload asks the router to reload the webhooks after they changed
+func (s *WebhookService) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// publish queues an event for routing without blocking the caller
+func (s *WebhookService) publish(event model.NotificationEvent) {
+	select {
+	case s.events <- event:
+	default:
+		s.logger.Warn("Webhook queue is full, dropping event",
+			zap.String("type", event.Type), zap.String("thingId", event.ThingID))
+	}
+}
+
+// poke wakes the delivery loop up
+func (s *WebhookService) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// route turns queued events into deliveries of the matching webhooks until ctx is cancelled
+func (s *WebhookService) route(ctx context.Context) {
+	defer s.wg.Done()
+
+	s.loadWebhooks(ctx)
+	reload := time.NewTicker(webhookReloadInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reload:
+			s.loadWebhooks(ctx)
+		case <-reload.C:
+			s.loadWebhooks(ctx)
+		case event := <-s.events:
+			var deliveries []*entity.WebhookDelivery
+			for _, webhook := range s.webhooks {
+				if !webhookMatches(webhook, event) {
+					continue
+				}
+				delivery, err := newDelivery(webhook, event)
+				if err != nil {
+					s.logger.Error("Failed to encode webhook event", zap.String("type", event.Type), zap.Error(err))
+					break
+				}
+				deliveries = append(deliveries, delivery)
+			}
+			if len(deliveries) == 0 {
+				continue
+			}
+			if err := s.repo.CreateDeliveries(ctx, deliveries); err != nil {
+				if ctx.Err() == nil {
+					s.logger.Error("Failed to queue webhook deliveries", zap.String("type", event.Type), zap.Error(err))
+				}
+				continue
+			}
+			s.poke()
+		}
+	}
+}
+
+// loadWebhooks replaces the enabled webhooks
+func (s *WebhookService) loadWebhooks(ctx context.Context) {
+	webhooks, err := s.repo.ListEnabled(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("Failed to load webhooks", zap.Error(err))
+		}
+		return
+	}
+	s.webhooks = webhooks
+}
+
+// deliver sends due deliveries until ctx is cancelled
+func (s *WebhookService) deliver(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		// A delivery is not picked up again while it is being sent
+		lease := 2*s.config.Timeout + s.config.PollInterval
+		deliveries, err := s.repo.ClaimDeliveries(ctx, time.Now(), lease, webhookClaimBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("Failed to claim webhook deliveries", zap.Error(err))
+			}
+			continue
+		}
+
+		sem := make(chan struct{}, s.config.Concurrency)
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(delivery *entity.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.attempt(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) == webhookClaimBatch {
+			s.poke()
+		}
+	}
+}
+
+// attempt sends a delivery once and schedules the retry or dead-letters it on failure
+func (s *WebhookService) attempt(ctx context.Context, delivery *entity.WebhookDelivery) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+
+	status, retryable, err := s.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// Shutting down, the lease runs out and the delivery is picked up after the restart
+		return
+	}
+	delivery.ResponseStatus = status
+
+	switch {
+	case err == nil:
+		delivery.Status = enum.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.Error = ""
+	case retryable && delivery.Attempts < s.config.MaxAttempts:
+		next := now.Add(s.backoff(delivery.Attempts))
+		delivery.Status = enum.DeliveryRetrying
+		delivery.NextAttemptAt = &next
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = enum.DeliveryDead
+		delivery.Error = err.Error()
+		s.logger.Warn("Webhook delivery moved to dead letters",
+			zap.String("delivery", delivery.ID), zap.String("webhook", delivery.WebhookID), zap.Error(err))
+	}
+
+	if err := s.repo.SaveAttempt(ctx, delivery); err != nil && ctx.Err() == nil {
+		s.logger.Error("Failed to store webhook delivery attempt", zap.String("delivery", delivery.ID), zap.Error(err))
+	}
+}
+
+// send posts a delivery to its webhook. retryable tells whether a failure may succeed later.
+func (s *WebhookService) send(ctx context.Context, delivery *entity.WebhookDelivery) (status int, retryable bool, err error) {
+	webhook, err := s.repo.GetByID(ctx, delivery.WebhookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, false, fmt.Errorf("webhook deleted")
+	}
+	if err != nil {
+		return 0, true, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ditto-service-webhook")
+	req.Header.Set(WebhookIDHeader, delivery.ID)
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	err = fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	// Other client errors mean the receiver rejects the request as it is
+	retryable = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retryable, err
+}
+
+// backoff returns the delay before the next attempt, doubling with every attempt
+func (s *WebhookService) backoff(attempt int) time.Duration {
+	delay := s.config.RetryBackoff
+	for i := 1; i < attempt && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	return delay
+}
+
+// SignWebhook returns the signature header value of a webhook payload. Receivers
+// recompute it over the timestamp header and the raw body to authenticate a delivery.
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookMatches tells whether a webhook subscribes to an event
+func webhookMatches(webhook *entity.Webhook, event model.NotificationEvent) bool {
+	var eventTypes []string
+	if err := webhook.EventTypes.Decode(&eventTypes); err != nil {
+		return false
+	}
+	subscribed := false
+	for _, eventType := range eventTypes {
+		if eventType == event.Type {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return false
+	}
+
+	if webhook.ThingID != "" && webhook.ThingID != event.ThingID {
+		return false
+	}
+	if webhook.Namespace != "" && webhook.Namespace != ditto.Namespace(event.ThingID) {
+		return false
+	}
+	return webhook.Feature == "" || webhook.Feature == event.Feature
+}
+
+// newDelivery queues an event for a webhook
+func newDelivery(webhook *entity.Webhook, event model.NotificationEvent) (*entity.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &entity.WebhookDelivery{
+		ID:            uuid.NewString(),
+		WebhookID:     webhook.ID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		ThingID:       event.ThingID,
+		Payload:       entity.JSON(payload),
+		Status:        enum.DeliveryPending,
+		NextAttemptAt: &now,
+	}, nil
+}
+
+// buildWebhook converts a webhook spec to an entity
+func buildWebhook(spec model.WebhookSpec) (*entity.Webhook, error) {
+	eventTypes, err := entity.NewJSON(spec.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.Webhook{
+		Name:       spec.Name,
+		URL:        spec.URL,
+		Secret:     spec.Secret,
+		Enabled:    spec.Enabled,
+		EventTypes: eventTypes,
+		ThingID:    spec.ThingID,
+		Namespace:  spec.Namespace,
+		Feature:    spec.Feature,
+	}, nil
+}
+
+// newWebhookSecret generates a random signing secret
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// isEventType tells whether notifications may subscribe to an event type
+func isEventType(eventType string) bool {
+	for _, t := range model.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ditto/config"
+	"ditto/internal/model/entity"
+	"ditto/internal/model/entity/enum"
+	"ditto/internal/repository"
+	"ditto/pkg/logger"
+
+	"go.uber.org/fx/fxtest"
+)
+
+// fakeWebhookRepository keeps webhooks in memory and records the stored attempts.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeWebhookRepository struct {
+	repository.WebhookRepository
+
+	mu          sync.Mutex
+	webhooks    map[string]*entity.Webhook
+	attempts    []entity.WebhookDelivery
+	redelivered []string
+}
+
+func (r *fakeWebhookRepository) GetByID(_ context.Context, id string) (*entity.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (r *fakeWebhookRepository) SaveAttempt(_ context.Context, delivery *entity.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *delivery)
+	return nil
+}
+
+func (r *fakeWebhookRepository) Redeliver(_ context.Context, id string, now time.Time) (*entity.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == "missing" {
+		return nil, repository.ErrNotFound
+	}
+	r.redelivered = append(r.redelivered, id)
+	return &entity.WebhookDelivery{ID: id, Status: enum.DeliveryPending, NextAttemptAt: &now}, nil
+}
+
+func (r *fakeWebhookRepository) lastAttempt(t *testing.T) entity.WebhookDelivery {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.attempts) == 0 {
+		t.Fatal("expected an attempt to be stored")
+	}
+	return r.attempts[len(r.attempts)-1]
+}
+
+func newTestWebhookService(t *testing.T, repo repository.WebhookRepository, webhookCfg config.WebhookConfig) *WebhookService {
+	t.Helper()
+	cfg := &config.Config{Webhook: webhookCfg}
+	// Disabled, so no event is routed and no delivery loop runs: the tests drive attempts themselves
+	cfg.Webhook.Enabled = false
+	return NewWebhookService(fxtest.NewLifecycle(t), repo, nil, logger.NewLogger(cfg), cfg)
+}
+
+func newTestDelivery(webhookID string) *entity.WebhookDelivery {
+	return &entity.WebhookDelivery{
+		ID:        "d1",
+		WebhookID: webhookID,
+		EventType: "alert.raised",
+		Payload:   entity.JSON(`{"type":"alert.raised","thingId":"org:dev"}`),
+		Status:    enum.DeliveryPending,
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	payload := []byte(`{"a":1}`)
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	got := SignWebhook("secret", "1700000000", payload)
+	if got != want {
+		t.Fatalf("expected signature %s, got %s", want, got)
+	}
+	if got == SignWebhook("other", "1700000000", payload) {
+		t.Error("expected the signature to depend on the secret")
+	}
+	if got == SignWebhook("secret", "1700000001", payload) {
+		t.Error("expected the signature to depend on the timestamp")
+	}
+	if got == SignWebhook("secret", "1700000000", []byte(`{"a":2}`)) {
+		t.Error("expected the signature to depend on the payload")
+	}
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepository{webhooks: map[string]*entity.Webhook{
+		"w1": {ID: "w1", URL: receiver.URL, Secret: "s3cret"},
+	}}
+	s := newTestWebhookService(t, repo, config.WebhookConfig{MaxAttempts: 3})
+
+	delivery := newTestDelivery("w1")
+	s.attempt(context.Background(), delivery)
+
+	req := <-requests
+	if string(req.body) != string(delivery.Payload) {
+		t.Errorf("expected the payload as body, got %s", req.body)
+	}
+	if req.header.Get(WebhookIDHeader) != "d1" || req.header.Get(WebhookEventHeader) != "alert.raised" {
+		t.Errorf("unexpected delivery headers %v", req.header)
+	}
+	timestamp := req.header.Get(WebhookTimestampHeader)
+	if sent, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Fatalf("unexpected timestamp %q", timestamp)
+	}
+	// The receiver verifies the signature over the timestamp and the raw body
+	if got, want := req.header.Get(WebhookSignatureHeader), SignWebhook("s3cret", timestamp, req.body); got != want {
+		t.Errorf("expected signature %s, got %s", want, got)
+	}
+
+	stored := repo.lastAttempt(t)
+	if stored.Status != enum.DeliveryDelivered || stored.Attempts != 1 || stored.ResponseStatus != http.StatusNoContent {
+		t.Errorf("expected a delivered first attempt, got %s after %d attempts with %d", stored.Status, stored.Attempts, stored.ResponseStatus)
+	}
+	if stored.DeliveredAt == nil || stored.NextAttemptAt != nil || stored.Error != "" {
+		t.Errorf("unexpected delivered state %+v", stored)
+	}
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer receiver.Close()
+
+	repo := &fakeWebhookRepository{webhooks: map[string]*entity.Webhook{
+		"w1": {ID: "w1", URL: receiver.URL, Secret: "s"},
+	}}
+	s := newTestWebhookService(t, repo, config.WebhookConfig{
+		MaxAttempts:  3,
+		RetryBackoff: time.Minute,
+		MaxBackoff:   time.Hour,
+	})
+
+	delivery := newTestDelivery("w1")
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		s.attempt(context.Background(), delivery)
+
+		stored := repo.lastAttempt(t)
+		if stored.Status != enum.DeliveryRetrying || stored.Attempts != attempt+1 {
+			t.Fatalf("attempt %d: expected RETRYING, got %s after %d attempts", attempt+1, stored.Status, stored.Attempts)
+		}
+		if stored.ResponseStatus != http.StatusInternalServerError || stored.Error == "" {
+			t.Errorf("attempt %d: expected the failure to be recorded, got %d %q", attempt+1, stored.ResponseStatus, stored.Error)
+		}
+		if stored.NextAttemptAt == nil || stored.NextAttemptAt.Before(before.Add(backoff)) || stored.NextAttemptAt.After(time.Now().Add(backoff)) {
+			t.Errorf("attempt %d: expected the next attempt in %s, got %v", attempt+1, backoff, stored.NextAttemptAt)
+		}
+	}
+
+	// The last attempt dead-letters the delivery
+	s.attempt(context.Background(), delivery)
+	stored := repo.lastAttempt(t)
+	if stored.Status != enum.DeliveryDead || stored.Attempts != 3 || stored.NextAttemptAt != nil {
+		t.Errorf("expected DEAD after exhausting the attempts, got %s after %d attempts", stored.Status, stored.Attempts)
+	}
+
+	// Timeouts and rate limiting are retried as well
+	for _, code := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests} {
+		status.Store(int32(code))
+		s.attempt(context.Background(), newTestDelivery("w1"))
+		if stored := repo.lastAttempt(t); stored.Status != enum.DeliveryRetrying {
+			t.Errorf("status %d: expected RETRYING, got %s", code, stored.Status)
+		}
+	}
+}
+
+func TestWebhookDeliveryDeadLetters(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown event", http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	repo := &fakeWebhookRepository{webhooks: map[string]*entity.Webhook{
+		"rejecting":   {ID: "rejecting", URL: receiver.URL},
+		"unreachable": {ID: "unreachable", URL: unreachable.URL},
+	}}
+	s := newTestWebhookService(t, repo, config.WebhookConfig{MaxAttempts: 5})
+
+	tests := []struct {
+		name      string
+		webhookID string
+		status    string
+		response  int
+	}{
+		{name: "client error", webhookID: "rejecting", status: enum.DeliveryDead, response: http.StatusBadRequest},
+		{name: "deleted webhook", webhookID: "deleted", status: enum.DeliveryDead},
+		{name: "network error", webhookID: "unreachable", status: enum.DeliveryRetrying},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.attempt(context.Background(), newTestDelivery(tt.webhookID))
+
+			stored := repo.lastAttempt(t)
+			if stored.Status != tt.status || stored.Attempts != 1 {
+				t.Errorf("expected %s after the first attempt, got %s after %d", tt.status, stored.Status, stored.Attempts)
+			}
+			if stored.ResponseStatus != tt.response || stored.Error == "" {
+				t.Errorf("expected response %d and an error, got %d %q", tt.response, stored.ResponseStatus, stored.Error)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	s := newTestWebhookService(t, &fakeWebhookRepository{}, config.WebhookConfig{
+		RetryBackoff: 10 * time.Second,
+		MaxBackoff:   time.Minute,
+	})
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, delay := range want {
+		if got := s.backoff(i + 1); got != delay {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, delay)
+		}
+	}
+	if got := s.backoff(1000); got != time.Minute {
+		t.Errorf("expected the backoff to stay capped, got %s", got)
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	repo := &fakeWebhookRepository{}
+	s := newTestWebhookService(t, repo, config.WebhookConfig{})
+
+	delivery, err := s.Redeliver(context.Background(), "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != enum.DeliveryPending || len(repo.redelivered) != 1 || repo.redelivered[0] != "d1" {
+		t.Errorf("expected d1 to be queued again, got %s and %v", delivery.Status, repo.redelivered)
+	}
+	select {
+	case <-s.wake:
+	default:
+		t.Error("expected the delivery loop to be woken up")
+	}
+
+	if _, err := s.Redeliver(context.Background(), "missing"); err != repository.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	select {
+	case <-s.wake:
+		t.Error("expected no wake up when nothing was queued")
+	default:
+	}
+}
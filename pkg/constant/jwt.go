@@ -6,5 +6,9 @@ const (
 	Firstname  = "firstname"
 	Lastname   = "lastname"
 	Email      = "email"
+	Roles      = "roles"
 	ExpireDate = "exp"
 )
+
+// Permissions holds the permissions of the authenticated user in the gin context
+const Permissions = "permissions"
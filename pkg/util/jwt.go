@@ -68,6 +68,33 @@ func TokenValid(c *gin.Context, config config.JWTConfig) error {
 	return nil
 }
 
+// AccessClaims are the claims of the access tokens issued by /api/auth/login
+type AccessClaims struct {
+	UserID   string   `json:"user_id"`
+	Email    string   `json:"email,omitempty"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ParseAccessToken validates an access token signed with the JWT secret and returns its claims
+func ParseAccessToken(tokenString string, config config.JWTConfig) (*AccessClaims, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is not set")
+	}
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token: %w", err)
+	}
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("invalid access token: no %s claim", constant.UserID)
+	}
+	return &claims, nil
+}
+
 // ExtractUserIDFromContext lấy userID từ context
 func ExtractUserIDFromContext(c *gin.Context) int {
 	val := c.GetString(constant.UserID)